@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ABCase es una entrada del experimento (una línea del archivo JSONL)
+type ABCase struct {
+	Name  string `json:"name"`
+	Input string `json:"input"`
+	Task  string `json:"task"`
+}
+
+// ABRun es el resultado de una variante sobre una entrada
+type ABRun struct {
+	Variant   string           `json:"variant"`
+	Case      string           `json:"case"`
+	Output    string           `json:"output,omitempty"`
+	Scores    []CriterionScore `json:"scores,omitempty"`
+	Score     float64          `json:"score"`
+	LatencyMs int64            `json:"latency_ms"`
+	Usage     Usage            `json:"usage"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// ABSummary agrega las métricas de una variante
+type ABSummary struct {
+	Variant          string  `json:"variant"`
+	MeanScore        float64 `json:"mean_score"`
+	Wins             int     `json:"wins"`
+	MeanLatencyMs    int64   `json:"mean_latency_ms"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Errors           int     `json:"errors"`
+}
+
+// defaultABRubric se usa cuando no se indica --rubric
+var defaultABRubric = Rubric{
+	Scale: 10,
+	Criteria: []Criterion{
+		{Name: "calidad", Description: "La respuesta resuelve la tarea de forma correcta, completa y clara", Weight: 1},
+	},
+}
+
+func loadABCases(path string) ([]ABCase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer las entradas: %v", err)
+	}
+	defer f.Close()
+
+	var cases []ABCase
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var c ABCase
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("%s:%d: JSON inválido: %v", path, line, err)
+		}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("caso-%d", len(cases)+1)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("%s no contiene entradas", path)
+	}
+	return cases, nil
+}
+
+// runABVariant ejecuta una variante sobre una entrada y la puntúa
+func runABVariant(name string, t *PromptTemplate, c ABCase, rubric Rubric, fs *flag.FlagSet) ABRun {
+	run := ABRun{Variant: name, Case: c.Name}
+
+	requestBody := newRequestBody(buildMessages(t.apply(""), c.Input, ""))
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if t.Model != "" {
+		requestBody.Model = t.Model
+	}
+	if t.Temperature != nil && !explicit["t"] && !explicit["temperature"] {
+		requestBody.Temperature = *t.Temperature
+	}
+	if t.MaxTokens > 0 && !explicit["m"] && !explicit["maxtokens"] {
+		requestBody.MaxTokens = t.MaxTokens
+	}
+	requestBody.Stop = t.Stop
+
+	start := time.Now()
+	output, response, err := complete(requestBody)
+	run.LatencyMs = time.Since(start).Milliseconds()
+	run.Usage = response.Usage
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	run.Output = output
+
+	// Todas las variantes se juzgan contra la misma tarea
+	if c.Task != "" {
+		rubric.Task = c.Task
+	} else if rubric.Task == "" {
+		rubric.Task = "Responder adecuadamente a esta entrada:\n" + c.Input
+	}
+	run.Scores, run.Score, err = rubric.judge(output)
+	if err != nil {
+		run.Error = "evaluador: " + err.Error()
+	}
+	return run
+}
+
+// summarizeAB calcula las métricas por variante; gana la variante con mayor
+// puntuación en cada entrada (los empates no cuentan)
+func summarizeAB(variants []string, cases []ABCase, runs [][]ABRun) []ABSummary {
+	summaries := make([]ABSummary, len(variants))
+	for v, name := range variants {
+		s := &summaries[v]
+		s.Variant = name
+		scored := 0
+		var latency int64
+		for _, run := range runs[v] {
+			latency += run.LatencyMs
+			s.PromptTokens += run.Usage.PromptTokens
+			s.CompletionTokens += run.Usage.CompletionTokens
+			if run.Error != "" {
+				s.Errors++
+				continue
+			}
+			s.MeanScore += run.Score
+			scored++
+		}
+		if scored > 0 {
+			s.MeanScore /= float64(scored)
+		}
+		s.MeanLatencyMs = latency / int64(len(cases))
+	}
+
+	for c := range cases {
+		best, bestScore, tie := -1, 0.0, false
+		for v := range variants {
+			run := runs[v][c]
+			if run.Error != "" {
+				continue
+			}
+			switch {
+			case best < 0 || run.Score > bestScore:
+				best, bestScore, tie = v, run.Score, false
+			case run.Score == bestScore:
+				tie = true
+			}
+		}
+		if best >= 0 && !tie {
+			summaries[best].Wins++
+		}
+	}
+	return summaries
+}
+
+func runAB(args []string) {
+	fs := flag.NewFlagSet("ab", flag.ExitOnError)
+	variantsFlag := fs.String("variants", "", "Plantillas a comparar, separadas por comas")
+	inputsFile := fs.String("inputs", "", "Archivo JSONL con las entradas")
+	rubricFile := fs.String("rubric", "", "Rúbrica YAML para el evaluador")
+	concurrency := fs.Int("c", 4, "Llamadas en paralelo")
+	fs.IntVar(concurrency, "concurrency", 4, "Llamadas en paralelo")
+	asJSON := fs.Bool("json", false, "Emitir resultados y resumen como JSON")
+	addModelFlags(fs, 0.0)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s ab --variants <a.tmpl,b.tmpl> --inputs <casos.jsonl> [opciones]
+
+Ejecuta cada variante de prompt sobre todas las entradas, puntúa las
+respuestas con el modelo como evaluador y muestra una tabla comparativa
+(puntuación media, victorias, latencia y tokens).
+
+Las variantes son plantillas de prompt (archivo o nombre de la biblioteca);
+su cabecera YAML fija modelo, temperatura, etc. de cada variante.
+
+Formato de las entradas (una por línea):
+  {"name": "login", "input": "<código o texto>", "task": "opcional"}
+
+Opciones:
+  --variants <lista>      Plantillas a comparar, separadas por comas
+  --inputs <archivo>      Entradas en JSONL
+  --rubric <archivo>      Rúbrica YAML (default: un criterio de calidad
+                          general de 0 a 10)
+  -c, --concurrency <n>   Llamadas en paralelo (default: 4)
+  --json                  Emitir resultados y resumen como JSON
+  -t, --temperature       Temperatura por defecto (default: 0.0)
+  -v, --verbose           Mostrar logs detallados
+`, os.Args[0])
+	}
+	fs.Parse(args)
+
+	var variants []string
+	for _, v := range strings.Split(*variantsFlag, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			variants = append(variants, v)
+		}
+	}
+	if len(variants) < 2 || *inputsFile == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+
+	templates := make([]*PromptTemplate, len(variants))
+	for i, name := range variants {
+		t, err := loadPromptTemplate(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		templates[i] = t
+	}
+	cases, err := loadABCases(*inputsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	rubric := defaultABRubric
+	if *rubricFile != "" {
+		r, err := loadRubric(*rubricFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		rubric = *r
+	}
+
+	setupSubcommand()
+
+	runs := make([][]ABRun, len(variants))
+	sem := make(chan struct{}, *concurrency)
+	var wg sync.WaitGroup
+	for v := range variants {
+		runs[v] = make([]ABRun, len(cases))
+		for c := range cases {
+			wg.Add(1)
+			go func(v, c int) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				logger.Printf("Ejecutando %s sobre %s...\n", variants[v], cases[c].Name)
+				runs[v][c] = runABVariant(variants[v], templates[v], cases[c], rubric, fs)
+			}(v, c)
+		}
+	}
+	wg.Wait()
+
+	summaries := summarizeAB(variants, cases, runs)
+
+	if *asJSON {
+		var all []ABRun
+		for _, r := range runs {
+			all = append(all, r...)
+		}
+		data, _ := json.MarshalIndent(struct {
+			Summary []ABSummary `json:"summary"`
+			Runs    []ABRun     `json:"runs"`
+		}{summaries, all}, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VARIANTE\tPUNTUACIÓN\tVICTORIAS\tLATENCIA MEDIA\tTOKENS ENTRADA\tTOKENS SALIDA\tERRORES")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s\t%.2f/%d\t%d/%d\t%s\t%d\t%d\t%d\n",
+			s.Variant, s.MeanScore, rubric.Scale, s.Wins, len(cases),
+			(time.Duration(s.MeanLatencyMs) * time.Millisecond).String(),
+			s.PromptTokens, s.CompletionTokens, s.Errors)
+	}
+	w.Flush()
+
+	failed := false
+	for _, r := range runs {
+		for _, run := range r {
+			if run.Error != "" {
+				fmt.Fprintf(os.Stderr, "Error en %s / %s: %s\n", run.Variant, run.Case, run.Error)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
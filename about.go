@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Archivos que se analizan con --about si no se indican con -f
+var aboutExtensions = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".mjs": true, ".cjs": true, ".ts": true, ".tsx": true,
+}
+
+// Límites del recorrido del directorio actual
+const (
+	maxAboutFiles    = 2000
+	maxAboutFileSize = 1 << 20
+	// Las funciones que llaman al símbolo y superan maxCallerLines se
+	// reducen a las líneas que lo rodean
+	maxCallerLines = 60
+	callerContext  = 4
+)
+
+var identRe = regexp.MustCompile(`[A-Za-z_$][\w$]*`)
+
+// aboutUnit es una definición de un archivo con los identificadores que usa
+type aboutUnit struct {
+	codeUnit
+	File   string
+	idents map[string]bool
+}
+
+func (u *aboutUnit) names() []string {
+	var names []string
+	for _, n := range strings.Split(u.Name, ",") {
+		if n == "" {
+			continue
+		}
+		names = append(names, n)
+		// Los métodos de Go (Tipo.método) también se buscan por el método
+		if i := strings.LastIndex(n, "."); i >= 0 {
+			names = append(names, n[i+1:])
+		}
+	}
+	return names
+}
+
+func (u *aboutUnit) defines(symbol string) bool {
+	for _, n := range u.names() {
+		if n == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// aboutFiles devuelve los archivos de código del directorio actual, sin
+// dependencias, artefactos ni directorios ocultos
+func aboutFiles() ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != "." && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" ||
+				name == "dist" || name == "build" || name == "__pycache__") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if aboutExtensions[strings.ToLower(filepath.Ext(path))] {
+			if info, err := d.Info(); err == nil && info.Size() <= maxAboutFileSize {
+				files = append(files, path)
+			}
+		}
+		if len(files) >= maxAboutFiles {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return files, err
+}
+
+// aboutContext construye un contexto mínimo sobre los símbolos: su
+// definición, las funciones y tipos que usan y las que los llaman. Con files
+// vacío se analizan los archivos del directorio actual.
+func aboutContext(symbols, files []string) (string, error) {
+	if len(files) == 0 {
+		var err error
+		if files, err = aboutFiles(); err != nil {
+			return "", err
+		}
+		if len(files) == 0 {
+			return "", fmt.Errorf("--about: no hay archivos de Go, Python o JavaScript en el directorio actual")
+		}
+	}
+
+	var units []*aboutUnit
+	for _, file := range files {
+		data, err := readContextFile(file)
+		if err != nil {
+			return "", fmt.Errorf("no se pudo leer %s: %v", file, err)
+		}
+		for _, u := range codeUnits(file, string(data)) {
+			idents := map[string]bool{}
+			for _, id := range identRe.FindAllString(u.Text, -1) {
+				idents[id] = true
+			}
+			units = append(units, &aboutUnit{codeUnit: u, File: file, idents: idents})
+		}
+	}
+	logger.Printf("--about: %d definiciones en %d archivos\n", len(units), len(files))
+
+	// Qué definiciones se incluyen y por qué
+	roles := map[*aboutUnit]string{}
+	for _, symbol := range symbols {
+		var targets []*aboutUnit
+		for _, u := range units {
+			if u.defines(symbol) {
+				targets = append(targets, u)
+				roles[u] = "definición de " + symbol
+			}
+		}
+		if len(targets) == 0 {
+			return "", fmt.Errorf("--about: no se encontró la definición de %q", symbol)
+		}
+
+		for _, u := range units {
+			if roles[u] != "" {
+				continue
+			}
+			for _, t := range targets {
+				switch {
+				case u.idents[symbol]:
+					roles[u] = "usa " + symbol
+				case usesAny(t, u.names()):
+					roles[u] = "usada por " + symbol
+				}
+			}
+		}
+	}
+
+	var selected []*aboutUnit
+	for _, u := range units {
+		if roles[u] != "" {
+			selected = append(selected, u)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].File < selected[j].File
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Código relacionado con %s (definiciones, funciones y tipos que usan y código que los llama):\n", strings.Join(symbols, ", "))
+	for _, u := range selected {
+		text := strings.TrimRight(u.Text, "\n")
+		end := u.Line + strings.Count(text, "\n")
+		if strings.HasPrefix(roles[u], "usa ") {
+			text = callerExcerpt(u, symbols)
+		}
+		fmt.Fprintf(&sb, "\n%s:%d-%d (%s):\n```\n%s\n```\n", u.File, u.Line, end, roles[u], text)
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// usesAny indica si la definición menciona alguno de los nombres
+func usesAny(u *aboutUnit, names []string) bool {
+	for _, n := range names {
+		if u.idents[n] && !u.defines(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// callerExcerpt devuelve la función que llama a los símbolos completa o, si
+// es larga, solo las líneas alrededor de cada mención
+func callerExcerpt(u *aboutUnit, symbols []string) string {
+	lines := strings.Split(strings.TrimRight(u.Text, "\n"), "\n")
+	if len(lines) <= maxCallerLines {
+		return strings.Join(lines, "\n")
+	}
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		for _, id := range identRe.FindAllString(line, -1) {
+			if !slices.Contains(symbols, id) {
+				continue
+			}
+			for j := max(0, i-callerContext); j <= min(len(lines)-1, i+callerContext); j++ {
+				keep[j] = true
+			}
+			break
+		}
+	}
+	// La cabecera de la función siempre se conserva
+	keep[firstCodeLineIndex(lines)] = true
+
+	var out []string
+	for i, line := range lines {
+		switch {
+		case keep[i]:
+			out = append(out, line)
+		case i == 0 || keep[i-1]:
+			out = append(out, fmt.Sprintf("\t... (línea %d)", u.Line+i))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func firstCodeLineIndex(lines []string) int {
+	first := firstCodeLine(strings.Join(lines, "\n"))
+	for i, line := range lines {
+		if strings.TrimSpace(line) == first {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Expansiones encadenadas como máximo (un alias puede usar otro)
+const maxAliasDepth = 10
+
+// El subcomando se registra aquí porque runAlias consulta la tabla de
+// subcomandos y declararlo en ella crearía un ciclo de inicialización
+func init() {
+	subcommands["alias"] = runAlias
+}
+
+// aliasesFile guarda los alias creados con "alias add"; los de la sección
+// aliases de config.yaml se comparten con el resto del equipo
+func aliasesFile() string {
+	return filepath.Join(configDir(), "aliases.yaml")
+}
+
+func loadAliasFile() (map[string]string, error) {
+	aliases := map[string]string{}
+	data, err := os.ReadFile(aliasesFile())
+	if os.IsNotExist(err) {
+		return aliases, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("%s inválido: %v", aliasesFile(), err)
+	}
+	return aliases, nil
+}
+
+func saveAliasFile(aliases map[string]string) error {
+	if err := os.MkdirAll(configDir(), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(aliases)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(aliasesFile(), data, 0644)
+}
+
+// loadAliases combina los alias de config.yaml con los propios, que tienen
+// preferencia
+func loadAliases() (map[string]string, error) {
+	aliases := map[string]string{}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	for name, expansion := range cfg.Aliases {
+		aliases[name] = expansion
+	}
+	own, err := loadAliasFile()
+	if err != nil {
+		return nil, err
+	}
+	for name, expansion := range own {
+		aliases[name] = expansion
+	}
+	return aliases, nil
+}
+
+// splitArgs divide la expansión de un alias en argumentos como lo haría el
+// shell: separa por espacios y respeta las comillas simples y dobles
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("comillas sin cerrar en %q", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// expandAliases sustituye el primer argumento por su alias antes de
+// interpretar los flags; los subcomandos no se pueden redefinir
+func expandAliases(args []string) ([]string, error) {
+	if len(args) < 2 || strings.HasPrefix(args[1], "-") || subcommands[args[1]] != nil {
+		return args, nil
+	}
+	aliases, err := loadAliases()
+	if err != nil {
+		return nil, err
+	}
+	for depth := 0; ; depth++ {
+		expansion, ok := aliases[args[1]]
+		if !ok || subcommands[args[1]] != nil {
+			return args, nil
+		}
+		if depth == maxAliasDepth {
+			return nil, fmt.Errorf("el alias %q se expande a sí mismo", args[1])
+		}
+		words, err := splitArgs(expansion)
+		if err != nil {
+			return nil, fmt.Errorf("alias %s: %v", args[1], err)
+		}
+		logger.Printf("Alias %s: %s\n", args[1], expansion)
+		args = append(append([]string{args[0]}, words...), args[2:]...)
+		if len(args) < 2 {
+			return args, nil
+		}
+	}
+}
+
+func runAlias(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s alias <acción>
+
+Acciones:
+  add <nombre> '<argumentos>'   Crear o reemplazar un alias
+  list                          Listar los alias
+  rm <nombre>                   Eliminar un alias
+
+Un alias sustituye al primer argumento antes de interpretar los flags:
+  %s alias add explain '-p explain-code -t 0.2'
+  %s explain -f x.go            (equivale a: %s -p explain-code -t 0.2 -f x.go)
+
+Los alias se guardan en %s. Los de la sección aliases de
+config.yaml se comparten igual; si un nombre está en ambos, gana el propio.
+Un alias puede empezar por un subcomando, pero no redefinirlo.
+`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], aliasesFile())
+	}
+	fs := flag.NewFlagSet("alias", flag.ExitOnError)
+	fs.Usage = usage
+	fs.Parse(args)
+	args = fs.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			usage()
+			os.Exit(1)
+		}
+		name, expansion := args[1], strings.Join(args[2:], " ")
+		if strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t") {
+			fmt.Fprintf(os.Stderr, "Error: nombre de alias no válido: %q\n", name)
+			os.Exit(1)
+		}
+		if subcommands[name] != nil {
+			fmt.Fprintf(os.Stderr, "Error: %q es un subcomando y no se puede redefinir\n", name)
+			os.Exit(1)
+		}
+		if _, err := splitArgs(expansion); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		aliases, err := loadAliasFile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		aliases[name] = expansion
+		if err := saveAliasFile(aliases); err != nil {
+			fmt.Fprintf(os.Stderr, "Error al guardar %s: %v\n", aliasesFile(), err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s = %s\n", name, expansion)
+
+	case "list":
+		aliases, err := loadAliases()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		var names []string
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s = %s\n", name, aliases[name])
+		}
+
+	case "rm":
+		if len(args) != 2 {
+			usage()
+			os.Exit(1)
+		}
+		aliases, err := loadAliasFile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if _, ok := aliases[args[1]]; !ok {
+			fmt.Fprintf(os.Stderr, "Error: no existe el alias %q en %s\n", args[1], aliasesFile())
+			os.Exit(1)
+		}
+		delete(aliases, args[1])
+		if err := saveAliasFile(aliases); err != nil {
+			fmt.Fprintf(os.Stderr, "Error al guardar %s: %v\n", aliasesFile(), err)
+			os.Exit(1)
+		}
+
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
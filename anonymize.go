@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// anonymizeMode activa --anonymize; anonymizeTerms son los textos de
+	// --anonymize-term, que se sustituyen siempre (nombres de clientes, etc.)
+	anonymizeMode  bool
+	anonymizeTerms stringList
+
+	// anon sustituye los datos sensibles en las solicitudes y los restaura en
+	// las respuestas; nil si --anonymize no está activo
+	anon *anonymizer
+)
+
+var (
+	anonEmailRe = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`)
+	anonIPv4Re  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	anonIPv6Re  = regexp.MustCompile(`(?i)\b[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}\b`)
+
+	// Solo se consideran hosts los nombres con un dominio de primer nivel
+	// conocido, para no confundirlos con archivos (main.go) o código (fmt.Println)
+	anonHostRe = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+(?:com|net|org|io|dev|app|co|es|mx|ar|cl|pe|uk|de|fr|it|pt|br|eu|us|gov|edu|mil|int|info|biz|cloud|local|internal|corp|lan|intra|localdomain)\b`)
+
+	// Nombres: un nombre de pila común seguido de uno o dos apellidos, o el
+	// valor de un campo como nombre: o author=
+	anonNameRe      = regexp.MustCompile(`\b(?:` + strings.Join(commonGivenNames, "|") + `)(?:\s+[A-ZÁÉÍÓÚÑ][a-záéíóúñü]+){1,2}`)
+	anonNameFieldRe = regexp.MustCompile(`\b(?i:nombre|name|full_?name|author|autor|contacto|contact|cliente|customer)\s*[:=]\s*["']?([A-ZÁÉÍÓÚÑ][\p{L}'-]+(?:\s+[A-ZÁÉÍÓÚÑ][\p{L}'-]+){0,3})`)
+
+	anonPlaceholderRe = regexp.MustCompile(`\b(PERSONA|EMAIL|IP|HOST|DATO)_(\d+)\b`)
+)
+
+var commonGivenNames = []string{
+	"Alejandro", "Alberto", "Alicia", "Ana", "Andrés", "Antonio", "Beatriz", "Carlos", "Carmen", "Clara",
+	"Cristina", "Daniel", "David", "Diego", "Elena", "Eva", "Fernando", "Francisco", "Gabriel", "Guillermo",
+	"Isabel", "Javier", "Jesús", "Jorge", "José", "Juan", "Laura", "Lucía", "Luis", "Manuel", "Marta",
+	"María", "Mario", "Miguel", "Natalia", "Pablo", "Patricia", "Pedro", "Raquel", "Ricardo", "Roberto",
+	"Rosa", "Sara", "Sergio", "Sofía", "Teresa", "Alice", "Andrew", "Anna", "Brian", "Charles", "Chris",
+	"Emily", "Emma", "George", "James", "Jennifer", "John", "Jessica", "Kevin", "Linda", "Mark",
+	"Mary", "Matthew", "Michael", "Paul", "Peter", "Robert", "Sarah", "Susan", "Thomas", "William",
+}
+
+// anonymizer asigna a cada dato sensible un marcador estable (EMAIL_1,
+// IP_2...) para toda la ejecución y guarda la correspondencia inversa
+type anonymizer struct {
+	mu       sync.Mutex
+	byValue  map[string]string
+	byMarker map[string]string
+	counts   map[string]int
+	terms    []string
+}
+
+func newAnonymizer(terms []string) *anonymizer {
+	// Los términos más largos primero, para que no los corte uno más corto
+	terms = append([]string(nil), terms...)
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	return &anonymizer{
+		byValue:  map[string]string{},
+		byMarker: map[string]string{},
+		counts:   map[string]int{},
+		terms:    terms,
+	}
+}
+
+func (a *anonymizer) marker(kind, value string) string {
+	key := kind + "\x00" + strings.ToLower(value)
+	if m, ok := a.byValue[key]; ok {
+		return m
+	}
+	a.counts[kind]++
+	m := kind + "_" + strconv.Itoa(a.counts[kind])
+	a.byValue[key] = m
+	a.byMarker[m] = value
+	return m
+}
+
+// Anonymize sustituye los datos sensibles del texto por sus marcadores
+func (a *anonymizer) Anonymize(text string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, t := range a.terms {
+		if t != "" {
+			text = strings.ReplaceAll(text, t, a.marker("DATO", t))
+		}
+	}
+	text = anonEmailRe.ReplaceAllStringFunc(text, func(s string) string { return a.marker("EMAIL", s) })
+	replaceIP := func(s string) string {
+		if net.ParseIP(s) == nil {
+			return s
+		}
+		return a.marker("IP", s)
+	}
+	text = anonIPv4Re.ReplaceAllStringFunc(text, replaceIP)
+	text = anonIPv6Re.ReplaceAllStringFunc(text, replaceIP)
+	text = anonHostRe.ReplaceAllStringFunc(text, func(s string) string { return a.marker("HOST", s) })
+	text = anonNameFieldRe.ReplaceAllStringFunc(text, func(s string) string {
+		name := anonNameFieldRe.FindStringSubmatch(s)[1]
+		return strings.TrimSuffix(s, name) + a.marker("PERSONA", name)
+	})
+	return anonNameRe.ReplaceAllStringFunc(text, func(s string) string { return a.marker("PERSONA", s) })
+}
+
+// Restore devuelve los datos originales en lugar de los marcadores
+func (a *anonymizer) Restore(text string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return anonPlaceholderRe.ReplaceAllStringFunc(text, func(m string) string {
+		if v, ok := a.byMarker[m]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// Summary resume cuántos datos de cada tipo se han sustituido
+func (a *anonymizer) Summary() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var parts []string
+	for _, kind := range []string{"PERSONA", "EMAIL", "IP", "HOST", "DATO"} {
+		if n := a.counts[kind]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", strings.ToLower(kind), n))
+		}
+	}
+	if len(parts) == 0 {
+		return "ninguno"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// anonymizeMessages devuelve una copia de los mensajes con los datos
+// sensibles sustituidos
+func anonymizeMessages(messages []Message) []Message {
+	out := make([]Message, len(messages))
+	for i, m := range messages {
+		m.Content = anon.Anonymize(m.Content)
+		out[i] = m
+	}
+	return out
+}
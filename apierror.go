@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reintentos automáticos cuando la API está saturada (503)
+const maxOverloadRetries = 3
+
+// APIError es una respuesta de error de la API con un mensaje que indica
+// cómo resolverlo
+type APIError struct {
+	Status    int
+	Message   string
+	RequestID string
+	Hint      string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("la API respondió con código %d", e.Status)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Hint != "" {
+		msg += "\n  " + e.Hint
+	}
+	if e.RequestID != "" {
+		msg += "\n  ID de la solicitud: " + e.RequestID
+	}
+	return msg
+}
+
+// requestIDHeaders son las cabeceras en las que los proveedores devuelven el
+// identificador de la solicitud
+var requestIDHeaders = []string{"X-Request-Id", "Request-Id", "X-Ds-Trace-Id", "Apim-Request-Id"}
+
+// newAPIError interpreta una respuesta de error y añade una sugerencia para
+// los códigos más habituales
+func newAPIError(status int, header http.Header, body []byte, requestBody RequestBody) *APIError {
+	e := &APIError{Status: status}
+	var response ResponseBody
+	if json.Unmarshal(body, &response) == nil {
+		e.Message = response.Error.Message
+	}
+	for _, h := range requestIDHeaders {
+		if v := header.Get(h); v != "" {
+			e.RequestID = v
+			break
+		}
+	}
+
+	switch {
+	case status == http.StatusUnauthorized:
+		e.Hint = "La API key no es válida o fue revocada; comprueba " + apiKeyEnvName() + " (o su archivo .env o key_command en config.yaml)."
+	case status == http.StatusPaymentRequired:
+		e.Hint = "Saldo insuficiente; recárgalo en https://platform.deepseek.com/top_up."
+		if provider == nil || activeProvider == "deepseek" {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			if balance, err := fetchBalance(ctx); err == nil {
+				for _, info := range balance.BalanceInfos {
+					e.Hint += fmt.Sprintf(" Saldo actual: %s %s.", info.TotalBalance, info.Currency)
+				}
+			}
+		}
+	case status == http.StatusBadRequest && isContextLengthError(e.Message):
+		tokens := 0
+		for _, m := range requestBody.Messages {
+			tokens += estimateTokens(m.Content)
+		}
+		e.Hint = fmt.Sprintf("La solicitud no cabe en la ventana de contexto: ~%d tokens de entrada + %d de respuesta (-m) frente a %d de %s. "+
+			"Reduce la entrada con --context-budget o --priority, o divídela en partes.",
+			tokens, requestBody.MaxTokens, contextWindow(requestBody.Model), requestBody.Model)
+	case status == http.StatusServiceUnavailable:
+		e.Hint = fmt.Sprintf("La API está saturada y siguió fallando tras %d reintentos; prueba de nuevo en unos minutos.", maxOverloadRetries)
+	}
+	return e
+}
+
+func isContextLengthError(message string) bool {
+	message = strings.ToLower(message)
+	for _, s := range []string{"context length", "maximum context", "context window", "too many tokens", "too long"} {
+		if strings.Contains(message, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyEnvName devuelve la variable de entorno de la API key del proveedor
+func apiKeyEnvName() string {
+	if provider != nil && provider.KeyEnv != "" {
+		return provider.KeyEnv
+	}
+	return "DEEPSEEK_API_KEY"
+}
+
+// doWithRetry envía la solicitud que crea newReq y la repite con espera
+// exponencial (o la de Retry-After) mientras la API responda 503
+func doWithRetry(newReq func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		// La solicitud queda en la auditoría antes de enviarse, y cada
+		// intento con su resultado
+		req = auditRequest(req, attempt+1)
+		resp, err := apiClient.Do(traceRequest(req))
+		if err != nil {
+			auditFailure(req, 0, err)
+			return nil, fmt.Errorf("no se pudo realizar la solicitud HTTP: %v", err)
+		}
+		if resp.StatusCode != http.StatusServiceUnavailable || attempt >= maxOverloadRetries {
+			return resp, nil
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		auditResponse(resp, body, false)
+
+		wait := time.Duration(1<<attempt) * time.Second
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 && secs <= 60 {
+			wait = time.Duration(secs) * time.Second
+		}
+		statusf("La API está saturada (503); reintentando en %s (%d/%d)...\n", wait, attempt+1, maxOverloadRetries)
+		time.Sleep(wait)
+	}
+}
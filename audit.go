@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Tiempo máximo de espera por el bloqueo del registro de auditoría, y edad a
+// partir de la cual un bloqueo se considera abandonado
+const (
+	auditLockWait  = 10 * time.Second
+	auditLockStale = time.Minute
+)
+
+// auditEntry es una línea del registro de auditoría. Hash es el SHA-256 de
+// la línea serializada con Hash vacío, y Prev el Hash de la línea anterior,
+// de modo que modificar, borrar o reordenar una entrada rompe la cadena.
+//
+// Cada envío a la API genera una entrada "request" en el momento de
+// enviarse y otra "response" o "error" con su resultado, que apunta a la
+// primera con Request; una solicitud sin resultado se envió pero no llegó a
+// completarse (el proceso se interrumpió).
+type auditEntry struct {
+	Seq      int       `json:"seq"`
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	Endpoint string    `json:"endpoint"`
+	Model    string    `json:"model"`
+
+	// Último mensaje del usuario (o prompt de FIM) tal como se envió, y huella
+	// del resto de los mensajes (system, historial, prefijo)
+	Prompt        string `json:"prompt"`
+	ContextSHA256 string `json:"context_sha256"`
+
+	RequestSHA256  string `json:"request_sha256"`
+	RequestBytes   int    `json:"request_bytes"`
+	Status         int    `json:"status"`
+	Stream         bool   `json:"stream,omitempty"`
+	ResponseSHA256 string `json:"response_sha256"`
+	ResponseBytes  int    `json:"response_bytes"`
+
+	// Tipo de entrada (request, response o error), intento dentro de los
+	// reintentos, entrada de la solicitud a la que responde y error de
+	// transporte o de lectura
+	Event   string `json:"event,omitempty"`
+	Attempt int    `json:"attempt,omitempty"`
+	Request int    `json:"request,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	Prev string `json:"prev"`
+	Hash string `json:"hash"`
+}
+
+// auditRef enlaza una solicitud en curso con su entrada del registro; viaja
+// en el contexto de la solicitud hasta su respuesta
+type auditRef struct {
+	seq      int
+	endpoint string
+	model    string
+	sha      string
+	stream   bool
+}
+
+type auditRefKey struct{}
+
+var auditMu sync.Mutex
+
+// auditFile devuelve la ruta del registro de auditoría (audit_log en
+// config.yaml o DEEPCLI_AUDIT_LOG); "" si no está activo
+func auditFile() string {
+	if p := os.Getenv("DEEPCLI_AUDIT_LOG"); p != "" {
+		return expandHome(p)
+	}
+	if cfg, err := loadConfig(); err == nil && cfg.AuditLog != "" {
+		return expandHome(cfg.AuditLog)
+	}
+	return ""
+}
+
+// hashAuditEntry calcula el hash encadenado de una entrada
+func hashAuditEntry(e auditEntry) string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	return sha256Hex(data)
+}
+
+// auditRequest registra una solicitud justo antes de enviarla, con su
+// cuerpo exacto (con --anonymize, ya anonimizado), y devuelve la solicitud
+// con la referencia a su entrada para registrar después el resultado. Un
+// fallo al registrar se avisa pero no impide el envío.
+func auditRequest(req *http.Request, attempt int) *http.Request {
+	file := auditFile()
+	if file == "" || req.GetBody == nil {
+		return req
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		statusf("Advertencia: no se pudo registrar la solicitud en la auditoría: %v\n", err)
+		return req
+	}
+	request, _ := io.ReadAll(rc)
+	rc.Close()
+
+	var sent struct {
+		Model    string    `json:"model"`
+		Prompt   string    `json:"prompt"`
+		Stream   bool      `json:"stream"`
+		Messages []Message `json:"messages"`
+	}
+	json.Unmarshal(request, &sent)
+	prompt, rest := sent.Prompt, sent.Messages
+	for i := len(rest) - 1; i >= 0; i-- {
+		if rest[i].Role == "user" {
+			prompt = rest[i].Content
+			rest = append(append([]Message{}, rest[:i]...), rest[i+1:]...)
+			break
+		}
+	}
+	contextJSON, _ := json.Marshal(rest)
+
+	ref := &auditRef{
+		endpoint: redactURL(req.URL.String()),
+		model:    sent.Model,
+		sha:      sha256Hex(request),
+		stream:   sent.Stream,
+	}
+	seq, err := appendAuditEntry(file, auditEntry{
+		Time:          time.Now().UTC(),
+		User:          currentUser(),
+		Endpoint:      ref.endpoint,
+		Model:         ref.model,
+		Prompt:        prompt,
+		ContextSHA256: sha256Hex(contextJSON),
+		RequestSHA256: ref.sha,
+		RequestBytes:  len(request),
+		Stream:        ref.stream,
+		Event:         "request",
+		Attempt:       attempt,
+	})
+	if err != nil {
+		statusf("Advertencia: no se pudo escribir el registro de auditoría %s: %v\n", file, err)
+		return req
+	}
+	ref.seq = seq
+	return req.WithContext(context.WithValue(req.Context(), auditRefKey{}, ref))
+}
+
+// auditResponse registra la respuesta a una solicitud registrada con
+// auditRequest. Un fallo al registrar se avisa pero no interrumpe la
+// respuesta, que ya se ha recibido.
+func auditResponse(resp *http.Response, response []byte, stream bool) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	ref, _ := resp.Request.Context().Value(auditRefKey{}).(*auditRef)
+	if ref == nil {
+		return
+	}
+	writeAuditResult(ref, auditEntry{
+		Status:         resp.StatusCode,
+		Stream:         stream,
+		ResponseSHA256: sha256Hex(response),
+		ResponseBytes:  len(response),
+		Event:          "response",
+	})
+}
+
+// auditFailure registra que una solicitud registrada con auditRequest no
+// obtuvo respuesta (error de red, tiempo agotado, cancelación) o que no se
+// pudo leer entera
+func auditFailure(req *http.Request, status int, err error) {
+	if req == nil || err == nil {
+		return
+	}
+	ref, _ := req.Context().Value(auditRefKey{}).(*auditRef)
+	if ref == nil {
+		return
+	}
+	writeAuditResult(ref, auditEntry{
+		Status: status,
+		Stream: ref.stream,
+		Event:  "error",
+		Error:  err.Error(),
+	})
+}
+
+func writeAuditResult(ref *auditRef, entry auditEntry) {
+	file := auditFile()
+	if file == "" {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	entry.User = currentUser()
+	entry.Endpoint = ref.endpoint
+	entry.Model = ref.model
+	entry.RequestSHA256 = ref.sha
+	entry.Request = ref.seq
+	if _, err := appendAuditEntry(file, entry); err != nil {
+		statusf("Advertencia: no se pudo escribir el registro de auditoría %s: %v\n", file, err)
+	}
+}
+
+// appendAuditEntry añade la entrada al final del registro, encadenada con la
+// última, y devuelve su número. Un archivo de bloqueo evita que dos procesos
+// lean la misma última entrada y bifurquen la cadena.
+func appendAuditEntry(file string, entry auditEntry) (int, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return 0, err
+	}
+	unlock, err := lockAuditFile(file)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	last, err := lastLine(f)
+	if err != nil {
+		return 0, err
+	}
+	if len(last) > 0 {
+		var prev auditEntry
+		if err := json.Unmarshal(last, &prev); err != nil {
+			return 0, fmt.Errorf("la última entrada no es válida; comprueba el registro con \"deepcli audit-log verify\"")
+		}
+		entry.Seq, entry.Prev = prev.Seq+1, prev.Hash
+	} else {
+		entry.Seq = 1
+	}
+	entry.Hash = hashAuditEntry(entry)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return 0, err
+	}
+	return entry.Seq, f.Sync()
+}
+
+// lockAuditFile crea el archivo de bloqueo del registro, esperando si otro
+// proceso lo tiene; los bloqueos abandonados (un proceso que murió) se
+// eliminan pasado auditLockStale
+func lockAuditFile(file string) (func(), error) {
+	lock := file + ".lock"
+	deadline := time.Now().Add(auditLockWait)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > auditLockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("el registro está bloqueado por otro proceso (%s)", lock)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// lastLine devuelve la última línea no vacía del archivo, leyendo desde el
+// final en bloques para no cargar el registro entero
+func lastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	const block = 64 * 1024
+	var tail []byte
+	for end := info.Size(); end > 0; {
+		start := max(end-block, 0)
+		buf := make([]byte, end-start)
+		if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+			return nil, err
+		}
+		tail = append(buf, tail...)
+		trimmed := bytes.TrimRight(tail, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+		if start == 0 {
+			return trimmed, nil
+		}
+		end = start
+	}
+	return nil, nil
+}
+
+// verifyAuditLog recorre el registro comprobando el hash de cada entrada y
+// su enlace con la anterior. Devuelve el número de entradas válidas, el hash
+// de la última y, si la cadena está rota, la línea y el motivo.
+func verifyAuditLog(r io.Reader) (n int, last string, line int, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	var prev auditEntry
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return n, prev.Hash, line, fmt.Errorf("la entrada no es JSON válido: %v", err)
+		}
+		if want := hashAuditEntry(e); e.Hash != want {
+			return n, prev.Hash, line, fmt.Errorf("el hash no coincide con el contenido (entrada %d modificada)", e.Seq)
+		}
+		if e.Seq != prev.Seq+1 {
+			return n, prev.Hash, line, fmt.Errorf("se esperaba la entrada %d y aparece la %d (entradas borradas o reordenadas)", prev.Seq+1, e.Seq)
+		}
+		if e.Prev != prev.Hash {
+			return n, prev.Hash, line, fmt.Errorf("la entrada %d no enlaza con la anterior", e.Seq)
+		}
+		prev = e
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, prev.Hash, line, err
+	}
+	return n, prev.Hash, 0, nil
+}
+
+func runAuditLog(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s audit-log <acción> [archivo]
+
+Registro de auditoría de las solicitudes a la API. Se activa con audit_log:
+<ruta> en config.yaml (o DEEPCLI_AUDIT_LOG) y guarda, por cada solicitud,
+el usuario, el modelo, el prompt enviado, la huella SHA-256 del resto del
+contexto y de la solicitud completa en el momento de enviarla, y en otra
+entrada enlazada la huella de la respuesta o el error (red, tiempo agotado,
+cancelación). Cada reintento se registra aparte. Cada entrada incluye
+el hash de la anterior, así que cualquier modificación, borrado o
+reordenación se detecta al verificar. Para detectar además que se han
+eliminado las últimas entradas, conserva en otro sistema el último hash
+que muestra verify.
+
+Acciones:
+  verify [archivo]    Comprobar la integridad de la cadena (default: el
+                      registro configurado)
+  path                Mostrar la ruta del registro configurado
+`, os.Args[0])
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "verify":
+		if len(args) > 2 {
+			usage()
+			os.Exit(1)
+		}
+		file := auditFile()
+		if len(args) == 2 {
+			file = args[1]
+		}
+		if file == "" {
+			fmt.Fprintf(os.Stderr, "Error: no hay registro de auditoría configurado (audit_log en config.yaml o DEEPCLI_AUDIT_LOG)\n")
+			os.Exit(1)
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		n, last, line, err := verifyAuditLog(f)
+		if err != nil {
+			if line > 0 {
+				fmt.Fprintf(os.Stderr, "Error: %s:%d: %v\n", file, line, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			fmt.Fprintf(os.Stderr, "%d entradas válidas antes del fallo\n", n)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %d entradas, cadena íntegra\n", file, n)
+		if n > 0 {
+			// Guardar este hash fuera del equipo permite detectar también
+			// que se han eliminado las últimas entradas
+			fmt.Printf("Último hash: %s\n", last)
+		}
+	case "path":
+		if file := auditFile(); file != "" {
+			fmt.Println(file)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: no hay registro de auditoría configurado\n")
+			os.Exit(1)
+		}
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuthConfig es la autenticación de un proveedor en config.yaml:
+// bearer (default), header (cabecera propia), sigv4 (firma de AWS) o none
+type AuthConfig struct {
+	Type    string `yaml:"type"`
+	Header  string `yaml:"header"`
+	Prefix  string `yaml:"prefix"`
+	Region  string `yaml:"region"`
+	Service string `yaml:"service"`
+}
+
+// applyAuthConfig traslada la sección auth de config.yaml al proveedor
+func applyAuthConfig(name string, preset *ProviderPreset, auth *AuthConfig) error {
+	switch strings.ToLower(auth.Type) {
+	case "", "bearer":
+		preset.AuthType = ""
+		preset.AuthHeader = "Authorization"
+		preset.AuthPrefix = "Bearer "
+	case "header":
+		if auth.Header == "" {
+			return fmt.Errorf("el proveedor %s usa auth header pero no indica auth.header", name)
+		}
+		preset.AuthType = ""
+		preset.AuthHeader = auth.Header
+		preset.AuthPrefix = auth.Prefix
+	case "sigv4":
+		if auth.Region == "" || auth.Service == "" {
+			return fmt.Errorf("el proveedor %s usa sigv4 pero no indica auth.region y auth.service", name)
+		}
+		preset.AuthType = "sigv4"
+		preset.Region = auth.Region
+		preset.Service = auth.Service
+	case "none":
+		preset.AuthType = "none"
+	default:
+		return fmt.Errorf("el proveedor %s usa un tipo de autenticación desconocido %q (bearer, header, sigv4 o none)", name, auth.Type)
+	}
+	return nil
+}
+
+// authorizeRequest añade la autenticación del proveedor activo a la
+// solicitud, y después las cabeceras adicionales; body es el cuerpo ya
+// serializado (necesario para SigV4). Con SigV4 las cabeceras adicionales
+// se añaden antes de firmar, para que la firma las incluya.
+func authorizeRequest(req *http.Request, body []byte) error {
+	if provider != nil && provider.AuthType == "sigv4" {
+		if err := checkSignedHeaders(); err != nil {
+			return err
+		}
+		applyCustomHeaders(req)
+		return signSigV4(req, body, provider.Region, provider.Service, time.Now())
+	}
+	defer applyCustomHeaders(req)
+	if provider == nil {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		return nil
+	}
+	switch provider.AuthType {
+	case "none":
+	default:
+		if apiKey != "" {
+			req.Header.Set(provider.AuthHeader, provider.AuthPrefix+apiKey)
+		}
+	}
+	return nil
+}
+
+// awsCredentials lee las credenciales estándar de AWS del entorno
+func awsCredentials() (accessKey, secretKey, sessionToken string, err error) {
+	accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return "", "", "", fmt.Errorf("la firma SigV4 requiere AWS_ACCESS_KEY_ID y AWS_SECRET_ACCESS_KEY")
+	}
+	return accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"), nil
+}
+
+// signSigV4 firma la solicitud con AWS Signature Version 4
+func signSigV4(req *http.Request, body []byte, region, service string, now time.Time) error {
+	accessKey, secretKey, sessionToken, err := awsCredentials()
+	if err != nil {
+		return err
+	}
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	host := req.URL.Host
+	if req.Host != "" {
+		host = req.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.Join(values, ",")
+		}
+	}
+
+	signedHeaders, signature := sigV4Signature(req.Method, req.URL.Path, req.URL.Query(), headers, payloadHash, region, service, secretKey, amzDate)
+	scope := strings.Join([]string{amzDate[:8], region, service, "aws4_request"}, "/")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+// sigV4Signature calcula la firma a partir de la solicitud canónica
+func sigV4Signature(method, path string, query map[string][]string, headers map[string]string, payloadHash, region, service, secretKey, amzDate string) (string, string) {
+	// Ruta canónica: cada segmento se codifica dos veces salvo en S3
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		seg = awsURIEncode(seg)
+		if service != "s3" {
+			seg = awsURIEncode(seg)
+		}
+		segments[i] = seg
+	}
+	canonicalURI := strings.Join(segments, "/")
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	var params []string
+	for key, values := range query {
+		for _, v := range values {
+			params = append(params, awsURIEncode(key)+"="+awsURIEncode(v))
+		}
+	}
+	sort.Strings(params)
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.Join(strings.Fields(headers[name]), " ") + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI,
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := amzDate[:8]
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// awsURIEncode codifica todo salvo los caracteres no reservados (RFC 3986)
+func awsURIEncode(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	subcommands["auth"] = runAuth
+}
+
+// AuthReport es el resultado de "deepcli auth test"
+type AuthReport struct {
+	Provider     string            `json:"provider"`
+	BaseURL      string            `json:"base_url"`
+	KeySource    string            `json:"key_source"`
+	Key          string            `json:"key,omitempty"`
+	Valid        bool              `json:"valid"`
+	Status       int               `json:"status,omitempty"`
+	Problem      string            `json:"problem,omitempty"`
+	Models       int               `json:"models,omitempty"`
+	Completion   string            `json:"completion,omitempty"`
+	Organization string            `json:"organization,omitempty"`
+	Tier         string            `json:"tier,omitempty"`
+	RateLimits   map[string]string `json:"rate_limits,omitempty"`
+	Balance      []BalanceInfo     `json:"balance,omitempty"`
+}
+
+// maskKey muestra solo el principio y el final de la API key
+func maskKey(key string) string {
+	if len(key) <= 10 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:3] + "…" + key[len(key)-4:]
+}
+
+// keySource indica de dónde sale la API key, en el mismo orden en que la
+// busca resolveSecret
+func keySource() string {
+	if provider != nil {
+		switch provider.AuthType {
+		case "none":
+			return "sin autenticación"
+		case "sigv4":
+			return "firma SigV4 (AWS_ACCESS_KEY_ID)"
+		}
+	}
+	env := apiKeyEnvName()
+	switch {
+	case os.Getenv(env) != "":
+		return env + " (entorno o .env)"
+	case os.Getenv(env+"_FILE") != "":
+		return env + "_FILE (" + os.Getenv(env+"_FILE") + ")"
+	case apiKey != "":
+		return "key_command"
+	}
+	return "ninguna"
+}
+
+// inspectAuthHeaders toma de las cabeceras de la respuesta la organización,
+// el nivel de la cuenta y los límites de uso, si el proveedor los envía
+func inspectAuthHeaders(report *AuthReport, header http.Header) {
+	for name, values := range header {
+		lower := strings.ToLower(name)
+		value := strings.Join(values, ", ")
+		switch {
+		case strings.Contains(lower, "organization"):
+			report.Organization = value
+		case strings.Contains(lower, "tier"):
+			report.Tier = value
+		case strings.HasPrefix(lower, "x-ratelimit-") || strings.HasPrefix(lower, "ratelimit-") || lower == "retry-after":
+			if report.RateLimits == nil {
+				report.RateLimits = map[string]string{}
+			}
+			report.RateLimits[lower] = value
+		}
+	}
+}
+
+// authProblem explica un código de error al comprobar la clave
+func authProblem(status int, header http.Header, body []byte) string {
+	e := newAPIError(status, header, body, RequestBody{})
+	switch status {
+	case http.StatusUnauthorized:
+		msg := "la API rechazó la clave (401"
+		if e.Message != "" {
+			msg += ": " + e.Message
+		}
+		return msg + "). " + e.Hint
+	case http.StatusForbidden:
+		return "la API key es válida pero no tiene permiso para este recurso o región (403)"
+	case http.StatusPaymentRequired:
+		return "la API key es válida pero la cuenta no tiene saldo (402)"
+	case http.StatusNotFound:
+		return "la URL base no es correcta: " + redactURL(apiBaseURL) + " respondió 404"
+	}
+	return e.Error()
+}
+
+// testAuth comprueba la clave con GET /models, que no consume tokens, y
+// opcionalmente con una solicitud de un solo token, que además devuelve las
+// cabeceras de límites de uso
+func testAuth(withCompletion bool) AuthReport {
+	report := AuthReport{
+		Provider:  activeProvider,
+		BaseURL:   redactURL(apiBaseURL),
+		KeySource: keySource(),
+	}
+	if report.Provider == "" {
+		report.Provider = "deepseek"
+	}
+	if apiKey != "" {
+		report.Key = maskKey(apiKey)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", modelsEndpoint(), nil)
+	if err == nil {
+		err = authorizeRequest(req, nil)
+	}
+	if err != nil {
+		report.Problem = err.Error()
+		return report
+	}
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		report.Problem = fmt.Sprintf("no se pudo conectar con %s: %v", redactURL(apiBaseURL), err)
+		return report
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	report.Status = resp.StatusCode
+	inspectAuthHeaders(&report, resp.Header)
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		report.Valid = true
+		var list struct {
+			Data []ModelInfo `json:"data"`
+		}
+		if json.Unmarshal(body, &list) == nil {
+			report.Models = len(list.Data)
+		}
+	case resp.StatusCode == http.StatusNotFound && withCompletion:
+		// Algunos gateways no implementan /models; la solicitud de
+		// prueba decide
+	default:
+		report.Problem = authProblem(resp.StatusCode, resp.Header, body)
+		return report
+	}
+
+	if withCompletion {
+		requestBody := newRequestBody([]Message{{Role: "user", Content: "ping"}})
+		requestBody.MaxTokens = 1
+		requestBody.Stop = nil
+		req, err := newHTTPRequest(chatEndpoint(false), requestBody)
+		if err != nil {
+			report.Valid = false
+			report.Problem = err.Error()
+			return report
+		}
+		start := time.Now()
+		req = auditRequest(req.WithContext(ctx), 1)
+		resp, err := apiClient.Do(req)
+		if err != nil {
+			auditFailure(req, 0, err)
+			report.Valid = false
+			report.Problem = fmt.Sprintf("no se pudo completar la solicitud de prueba: %v", err)
+			return report
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		auditResponse(resp, body, false)
+		report.Status = resp.StatusCode
+		inspectAuthHeaders(&report, resp.Header)
+		if resp.StatusCode != http.StatusOK {
+			report.Valid = false
+			report.Problem = authProblem(resp.StatusCode, resp.Header, body)
+			return report
+		}
+		report.Valid = true
+		report.Completion = fmt.Sprintf("correcta con %s (%d ms)", providerModel(model), time.Since(start).Milliseconds())
+	}
+
+	if provider == nil || activeProvider == "deepseek" {
+		if balance, err := fetchBalance(ctx); err == nil {
+			report.Balance = balance.BalanceInfos
+		} else {
+			logger.Printf("No se pudo consultar el saldo: %v\n", err)
+		}
+	}
+	return report
+}
+
+func runAuth(args []string) {
+	fs := flag.NewFlagSet("auth", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "Mostrar el resultado en JSON")
+	noCompletion := fs.Bool("no-completion", false, "Comprobar solo la clave, sin la solicitud de un token")
+	fs.StringVar(&providerName, "provider", "", "Proveedor a comprobar")
+	fs.StringVar(&model, "model", defaultModel, "Modelo de la solicitud de prueba")
+	addModelFlags(fs, defaultTemperature)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s auth test [opciones]
+
+Comprueba la configuración de la API en un solo paso: de dónde sale la API
+key, si el proveedor la acepta (GET /models, sin coste), si puede generar
+con el modelo (una solicitud de un token), y la organización, el nivel de
+la cuenta, los límites de uso y el saldo cuando el proveedor los informa.
+Si algo falla explica la causa (clave revocada, URL base incorrecta, sin
+saldo...) y termina con código 1.
+
+Opciones:
+  --provider <nombre>   Proveedor a comprobar (default: DeepSeek)
+  --model <modelo>      Modelo de la solicitud de prueba (default: %s)
+  --no-completion       Comprobar solo la clave, sin consumir tokens
+  --json                Salida en JSON
+  -v, --verbose         Mostrar logs detallados
+`, os.Args[0], defaultModel)
+	}
+	if len(args) == 0 || args[0] != "test" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	fs.Parse(args[1:])
+	setupSubcommand()
+
+	report := testAuth(!*noCompletion)
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printAuthReport(report)
+	}
+	if !report.Valid {
+		os.Exit(1)
+	}
+}
+
+func printAuthReport(r AuthReport) {
+	unknown := "no lo indica el proveedor"
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Proveedor:\t%s (%s)\n", r.Provider, r.BaseURL)
+	key := r.KeySource
+	if r.Key != "" {
+		key = r.Key + " de " + r.KeySource
+	}
+	fmt.Fprintf(w, "API key:\t%s\n", key)
+	switch {
+	case !r.Valid:
+		fmt.Fprintf(w, "Estado:\tERROR: %s\n", r.Problem)
+	case r.Models > 0:
+		fmt.Fprintf(w, "Estado:\tválida (%d modelos disponibles)\n", r.Models)
+	default:
+		fmt.Fprintf(w, "Estado:\tválida\n")
+	}
+	if r.Completion != "" {
+		fmt.Fprintf(w, "Generación:\t%s\n", r.Completion)
+	}
+	if r.Valid {
+		fmt.Fprintf(w, "Organización:\t%s\n", orDefault(r.Organization, unknown))
+		fmt.Fprintf(w, "Nivel:\t%s\n", orDefault(r.Tier, unknown))
+		if len(r.RateLimits) == 0 {
+			fmt.Fprintf(w, "Límites:\t%s\n", unknown)
+		} else {
+			names := make([]string, 0, len(r.RateLimits))
+			for name := range r.RateLimits {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for i, name := range names {
+				label := ""
+				if i == 0 {
+					label = "Límites:"
+				}
+				fmt.Fprintf(w, "%s\t%s: %s\n", label, name, r.RateLimits[name])
+			}
+		}
+		for i, info := range r.Balance {
+			label := ""
+			if i == 0 {
+				label = "Saldo:"
+			}
+			fmt.Fprintf(w, "%s\t%s %s\n", label, info.TotalBalance, info.Currency)
+		}
+	}
+	w.Flush()
+}
+
+// orDefault devuelve s o, si está vacío, def
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// El saldo se consulta como mucho una vez por hora para el aviso automático
+const balanceCheckInterval = time.Hour
+
+// BalanceInfo es el saldo de la cuenta en una moneda
+type BalanceInfo struct {
+	Currency        string `json:"currency"`
+	TotalBalance    string `json:"total_balance"`
+	GrantedBalance  string `json:"granted_balance"`
+	ToppedUpBalance string `json:"topped_up_balance"`
+}
+
+// Balance es la respuesta de GET /user/balance de DeepSeek
+type Balance struct {
+	IsAvailable  bool          `json:"is_available"`
+	BalanceInfos []BalanceInfo `json:"balance_infos"`
+}
+
+// balanceEndpoint devuelve la URL del saldo; está en la raíz de la API, sin
+// el /v1 de la URL base
+func balanceEndpoint() string {
+	return strings.TrimSuffix(apiBaseURL, "/v1") + "/user/balance"
+}
+
+// fetchBalance consulta el saldo de la cuenta de DeepSeek
+func fetchBalance(ctx context.Context) (*Balance, error) {
+	if provider != nil && activeProvider != "deepseek" {
+		return nil, fmt.Errorf("la consulta del saldo solo está disponible para la API de DeepSeek")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", balanceEndpoint(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo crear la solicitud HTTP: %v", err)
+	}
+	if err := authorizeRequest(req, nil); err != nil {
+		return nil, err
+	}
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo consultar el saldo: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer el saldo: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("la API devolvió el código %d al consultar el saldo: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var balance Balance
+	if err := json.Unmarshal(body, &balance); err != nil {
+		return nil, fmt.Errorf("respuesta inesperada al consultar el saldo: %v", err)
+	}
+	return &balance, nil
+}
+
+// lowBalances devuelve los saldos por debajo del umbral
+func lowBalances(b *Balance, threshold float64) []BalanceInfo {
+	var low []BalanceInfo
+	for _, info := range b.BalanceInfos {
+		if total, err := strconv.ParseFloat(info.TotalBalance, 64); err == nil && total < threshold {
+			low = append(low, info)
+		}
+	}
+	return low
+}
+
+func balanceCheckFile() string {
+	return filepath.Join(dataDir(), "balance_check")
+}
+
+// warnLowBalance avisa si el saldo está por debajo de balance_warning (de
+// config.yaml o DEEPCLI_BALANCE_WARNING). El saldo se consulta como mucho
+// una vez por hora y los fallos se ignoran.
+func warnLowBalance() {
+	cfg, _ := loadConfig()
+	threshold := cfg.BalanceWarning
+	if v := os.Getenv("DEEPCLI_BALANCE_WARNING"); v != "" {
+		threshold, _ = strconv.ParseFloat(v, 64)
+	}
+	if threshold <= 0 || (provider != nil && activeProvider != "deepseek") {
+		return
+	}
+	if info, err := os.Stat(balanceCheckFile()); err == nil && time.Since(info.ModTime()) < balanceCheckInterval {
+		return
+	}
+	if err := os.MkdirAll(dataDir(), 0700); err == nil {
+		os.WriteFile(balanceCheckFile(), nil, 0600)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	balance, err := fetchBalance(ctx)
+	if err != nil {
+		logger.Printf("No se pudo comprobar el saldo: %v\n", err)
+		return
+	}
+	for _, info := range lowBalances(balance, threshold) {
+		statusf("Advertencia: saldo de DeepSeek bajo: %s %s (umbral: %.2f); recárgalo en https://platform.deepseek.com/top_up\n", info.TotalBalance, info.Currency, threshold)
+	}
+}
+
+func runBalance(args []string) {
+	fs := flag.NewFlagSet("balance", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "Mostrar el saldo en JSON")
+	warnBelow := fs.Float64("warn-below", 0, "Terminar con código 2 si el saldo es menor")
+	fs.BoolVar(&verbose, "v", false, "Mostrar mensajes detallados de ejecución")
+	fs.BoolVar(&verbose, "verbose", false, "Mostrar mensajes detallados de ejecución")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s balance [opciones]
+
+Muestra el saldo restante de la cuenta de DeepSeek (endpoint /user/balance).
+
+Con balance_warning en config.yaml (o DEEPCLI_BALANCE_WARNING) deepcli
+avisa tras cada respuesta cuando el saldo baja del umbral; el saldo se
+consulta como mucho una vez por hora.
+
+Opciones:
+  --warn-below <cantidad>   Avisar y terminar con código 2 si el saldo es
+                            menor (para scripts y cron)
+  --json                    Salida en JSON
+  -v, --verbose             Mostrar logs detallados
+`, os.Args[0])
+	}
+	fs.Parse(args)
+	if !verbose {
+		logger.SetOutput(io.Discard)
+	}
+	if err := loadEnv(); err != nil {
+		statusf("Advertencia: %v\n", err)
+	}
+	loadAPIConfig()
+
+	balance, err := fetchBalance(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(balance)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MONEDA\tTOTAL\tREGALADO\tRECARGADO")
+		for _, info := range balance.BalanceInfos {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", info.Currency, info.TotalBalance, info.GrantedBalance, info.ToppedUpBalance)
+		}
+		w.Flush()
+		if !balance.IsAvailable {
+			fmt.Fprintln(os.Stderr, "Advertencia: el saldo no es suficiente para usar la API")
+		}
+	}
+
+	if *warnBelow > 0 {
+		if low := lowBalances(balance, *warnBelow); len(low) > 0 {
+			for _, info := range low {
+				fmt.Fprintf(os.Stderr, "Advertencia: saldo bajo: %s %s (umbral: %.2f)\n", info.TotalBalance, info.Currency, *warnBelow)
+			}
+			os.Exit(2)
+		}
+	}
+}
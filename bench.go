@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// benchRun es una ejecución de la prueba de rendimiento
+type benchRun struct {
+	Total  time.Duration
+	TTFT   time.Duration
+	Tokens int
+	Err    error
+}
+
+// BenchSummary resume las ejecuciones de un proveedor y modelo
+type BenchSummary struct {
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	Runs         int     `json:"runs"`
+	Errors       int     `json:"errors"`
+	LatencyP50Ms int64   `json:"latency_p50_ms"`
+	LatencyP90Ms int64   `json:"latency_p90_ms"`
+	LatencyP99Ms int64   `json:"latency_p99_ms"`
+	TTFTP50Ms    int64   `json:"ttft_p50_ms"`
+	TTFTP90Ms    int64   `json:"ttft_p90_ms"`
+	TokensPerSec float64 `json:"tokens_per_sec"`
+	// EstimatedTokens indica que el proveedor no informó del uso en el
+	// stream y los tokens se estimaron a partir del texto
+	EstimatedTokens bool   `json:"estimated_tokens,omitempty"`
+	FirstError      string `json:"first_error,omitempty"`
+}
+
+// percentile devuelve el percentil p (0-100) de valores ya ordenados, por
+// el método del rango más cercano
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// benchOnce mide una solicitud en streaming: el tiempo total, el del
+// primer token y los tokens generados
+func benchOnce(requestBody RequestBody) (benchRun, bool) {
+	var run benchRun
+	start := time.Now()
+	result, err := streamCompletion(chatEndpoint(false), requestBody, func(string) {
+		if run.TTFT == 0 {
+			run.TTFT = time.Since(start)
+		}
+	})
+	run.Total = time.Since(start)
+	run.Err = err
+	run.Tokens = result.Usage.CompletionTokens
+	if run.Tokens == 0 {
+		run.Tokens = estimateTokens(result.Content)
+		return run, true
+	}
+	return run, false
+}
+
+// benchTarget ejecuta runs solicitudes con la concurrencia indicada contra
+// el proveedor activo y resume los resultados
+func benchTarget(providerLabel, modelName, prompt string, runs, concurrency int) BenchSummary {
+	requestBody := newRequestBody([]Message{{Role: "user", Content: prompt}})
+	requestBody.Model = modelName
+
+	results := make([]benchRun, runs)
+	estimated := make([]bool, runs)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], estimated[i] = benchOnce(requestBody)
+			logger.Printf("%s/%s: ejecución %d/%d en %s\n", providerLabel, modelName, i+1, runs, results[i].Total.Round(time.Millisecond))
+		}(i)
+	}
+	wg.Wait()
+
+	s := BenchSummary{Provider: providerLabel, Model: providerModel(modelName), Runs: runs}
+	var totals, ttfts []time.Duration
+	var rates []float64
+	for i, r := range results {
+		if r.Err != nil {
+			s.Errors++
+			if s.FirstError == "" {
+				s.FirstError = r.Err.Error()
+			}
+			continue
+		}
+		totals = append(totals, r.Total)
+		if r.TTFT > 0 {
+			ttfts = append(ttfts, r.TTFT)
+			if gen := (r.Total - r.TTFT).Seconds(); gen > 0 && r.Tokens > 1 {
+				// El primer token llega con el TTFT; el ritmo es el del resto
+				rates = append(rates, float64(r.Tokens-1)/gen)
+			}
+		}
+		s.EstimatedTokens = s.EstimatedTokens || estimated[i]
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i] < totals[j] })
+	sort.Slice(ttfts, func(i, j int) bool { return ttfts[i] < ttfts[j] })
+	sort.Float64s(rates)
+	s.LatencyP50Ms = percentile(totals, 50).Milliseconds()
+	s.LatencyP90Ms = percentile(totals, 90).Milliseconds()
+	s.LatencyP99Ms = percentile(totals, 99).Milliseconds()
+	s.TTFTP50Ms = percentile(ttfts, 50).Milliseconds()
+	s.TTFTP90Ms = percentile(ttfts, 90).Milliseconds()
+	if len(rates) > 0 {
+		s.TokensPerSec = math.Round(rates[len(rates)/2]*10) / 10
+	}
+	return s
+}
+
+// splitList separa una lista de valores por comas, sin los vacíos
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	instruction := fs.String("i", "", "Prompt de la prueba")
+	fs.StringVar(instruction, "instruction", "", "Prompt de la prueba")
+	runs := fs.Int("runs", 10, "Ejecuciones por proveedor y modelo")
+	concurrency := fs.Int("c", 1, "Ejecuciones en paralelo")
+	fs.IntVar(concurrency, "concurrency", 1, "Ejecuciones en paralelo")
+	models := fs.String("model", defaultModel, "Modelos a medir, separados por comas")
+	providers := fs.String("provider", "", "Proveedores a medir, separados por comas (default: el configurado)")
+	asJSON := fs.Bool("json", false, "Emitir el resumen como JSON")
+	addModelFlags(fs, 0.0)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s bench -i <prompt> [opciones]
+
+Mide el rendimiento de la API con el mismo prompt en streaming: percentiles
+de la latencia total, tiempo hasta el primer token (TTFT) y tokens por
+segundo de la generación, por proveedor y modelo. Sirve para elegir
+gateway, región o modelo. Las ejecuciones consumen tokens.
+
+Opciones:
+  -i, --instruction <texto>   Prompt de la prueba
+  --runs <n>                  Ejecuciones por proveedor y modelo (default: 10)
+  -c, --concurrency <n>       Ejecuciones en paralelo (default: 1)
+  --model <lista>             Modelos, separados por comas (default: %s)
+  --provider <lista>          Proveedores de config.yaml o presets,
+                              separados por comas (default: el configurado)
+  -m, --maxtokens <n>         Máximo de tokens por respuesta
+  --json                      Emitir el resumen como JSON
+  -v, --verbose               Mostrar cada ejecución
+
+Ejemplos:
+  %s bench -i "Explica qué es un mutex" --runs 10 --concurrency 3
+  %s bench -i "hola" --provider deepseek,openrouter --model deepseek-chat
+`, os.Args[0], defaultModel, os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+	if *instruction == "" || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *runs < 1 || *concurrency < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --runs y --concurrency deben ser mayores que 0\n")
+		os.Exit(1)
+	}
+	modelList := splitList(*models)
+	providerList := splitList(*providers)
+	if len(modelList) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: --model no indica ningún modelo\n")
+		os.Exit(1)
+	}
+	if len(providerList) > 0 {
+		providerName = providerList[0]
+	}
+	setupSubcommand()
+	if len(providerList) == 0 {
+		providerList = []string{orDefault(activeProvider, "deepseek")}
+	}
+
+	var summaries []BenchSummary
+	for i, p := range providerList {
+		// Los proveedores se miden uno tras otro: activarlo cambia la
+		// configuración global de la conexión
+		if i > 0 {
+			if err := activateProvider(p); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		for _, m := range modelList {
+			statusf("Midiendo %s/%s (%d ejecuciones, %d en paralelo)...\n", p, m, *runs, *concurrency)
+			summaries = append(summaries, benchTarget(p, m, *instruction, *runs, *concurrency))
+		}
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(summaries, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROVEEDOR\tMODELO\tOK\tERRORES\tLATENCIA P50\tP90\tP99\tTTFT P50\tTTFT P90\tTOKENS/S")
+		for _, s := range summaries {
+			ms := func(v int64) string {
+				if s.Errors == s.Runs {
+					return "-"
+				}
+				return fmt.Sprintf("%dms", v)
+			}
+			rate := "-"
+			if s.Errors < s.Runs {
+				rate = fmt.Sprintf("%.1f", s.TokensPerSec)
+			}
+			if s.EstimatedTokens {
+				rate += "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Provider, s.Model, s.Runs-s.Errors, s.Errors,
+				ms(s.LatencyP50Ms), ms(s.LatencyP90Ms), ms(s.LatencyP99Ms), ms(s.TTFTP50Ms), ms(s.TTFTP90Ms), rate)
+		}
+		w.Flush()
+		for _, s := range summaries {
+			if s.EstimatedTokens {
+				fmt.Println("\n* Tokens estimados: el proveedor no informó del uso en el stream")
+				break
+			}
+		}
+	}
+
+	failed := false
+	for _, s := range summaries {
+		if s.FirstError != "" {
+			fmt.Fprintf(os.Stderr, "Error en %s/%s (%d de %d): %s\n", s.Provider, s.Model, s.Errors, s.Runs, s.FirstError)
+			failed = failed || s.Errors == s.Runs
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
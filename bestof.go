@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const bestOfJudgePrompt = `Eres un evaluador imparcial. Recibirás una tarea y varias respuestas ` +
+	`candidatas numeradas. Elige la que mejor la resuelve: corrección, completitud, claridad y ajuste a ` +
+	`lo pedido; la longitud no es un mérito por sí misma. Responde solo con un objeto JSON: ` +
+	`{"best": <número de la candidata>, "rationale": "<motivo breve>"}.`
+
+var (
+	// bestOf es el número de candidatas de --best-of y judgeModel el modelo
+	// que elige la mejor (default: el mismo modelo)
+	bestOf     int
+	judgeModel string
+)
+
+// sampleCandidates pide n respuestas en una sola solicitud; si el proveedor
+// devuelve menos (no todos admiten n), pide las que faltan por separado
+func sampleCandidates(requestBody RequestBody, n int) (ResponseBody, error) {
+	var response ResponseBody
+	requestBody.N = n
+	body, _, err := sendWithFailover(false, requestBody)
+	if err != nil {
+		return response, err
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return response, fmt.Errorf("no se pudo parsear la respuesta JSON: %v", err)
+	}
+	if response.Error.Message != "" {
+		return response, fmt.Errorf("error de la API: %s", response.Error.Message)
+	}
+
+	missing := n - len(response.Choices)
+	if missing > 0 {
+		logger.Printf("El proveedor devolvió %d de %d candidatas; se piden las demás por separado\n", len(response.Choices), n)
+	}
+	requestBody.N = 0
+	requestBody.distinct = true
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxParallelChunks)
+	for i := 0; i < missing; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			_, r, err := complete(requestBody)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				statusf("Advertencia: no se pudo generar una candidata: %v\n", err)
+				return
+			}
+			response.Choices = append(response.Choices, r.Choices[0])
+			response.Usage.add(r.Usage)
+		}()
+	}
+	wg.Wait()
+	if len(response.Choices) == 0 {
+		return response, fmt.Errorf("no se recibió ninguna candidata válida de la API")
+	}
+	return response, nil
+}
+
+// judgeCandidates pide al modelo evaluador la mejor candidata para la tarea
+// y devuelve su índice (desde 0) y el motivo. Las candidatas se recortan
+// para que quepan todas en la ventana del evaluador.
+func judgeCandidates(task string, candidates []string, judge string) (int, string, Usage, error) {
+	const judgeMaxTokens = 512
+	per := (contextWindow(judge) - judgeMaxTokens - estimateTokens(bestOfJudgePrompt+task) - contextMargin) / len(candidates)
+	per = max(per, 256)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Tarea:\n<<<\n%s\n>>>\n", task)
+	for i, c := range candidates {
+		if tokens := estimateTokens(c); tokens > per {
+			c = truncateContext(c, per*4, tokens-per)
+		}
+		fmt.Fprintf(&sb, "\nCandidata %d:\n<<<\n%s\n>>>\n", i+1, c)
+	}
+
+	requestBody := newRequestBody([]Message{
+		{Role: "system", Content: bestOfJudgePrompt},
+		{Role: "user", Content: sb.String()},
+	})
+	requestBody.Model = judge
+	requestBody.Temperature = 0
+	requestBody.MaxTokens = judgeMaxTokens
+	requestBody.Stop = nil
+	content, response, err := complete(requestBody)
+	if err != nil {
+		return 0, "", response.Usage, err
+	}
+
+	var verdict struct {
+		Best      int    `json:"best"`
+		Rationale string `json:"rationale"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(content)), &verdict); err != nil {
+		return 0, "", response.Usage, fmt.Errorf("el evaluador no devolvió JSON válido: %v", err)
+	}
+	if verdict.Best < 1 || verdict.Best > len(candidates) {
+		return 0, "", response.Usage, fmt.Errorf("el evaluador eligió una candidata inexistente (%d)", verdict.Best)
+	}
+	return verdict.Best - 1, verdict.Rationale, response.Usage, nil
+}
+
+// bestOfResponse genera n candidatas y devuelve la respuesta con solo la
+// elegida por el evaluador; si el evaluador falla se queda la primera
+func bestOfResponse(requestBody RequestBody, n int) ([]byte, error) {
+	statusf("Generando %d candidatas...\n", n)
+	response, err := sampleCandidates(requestBody, n)
+	if err != nil {
+		return nil, err
+	}
+
+	var task string
+	for _, m := range requestBody.Messages {
+		if m.Role == "user" {
+			task = m.Content
+		}
+	}
+	var candidates []string
+	for _, c := range response.Choices {
+		candidates = append(candidates, c.Message.Content)
+	}
+
+	judge := judgeModel
+	if judge == "" {
+		judge = requestBody.Model
+	}
+	winner := 0
+	if len(candidates) > 1 {
+		statusf("Eligiendo la mejor con %s...\n", judge)
+		var rationale string
+		var judgeUsage Usage
+		winner, rationale, judgeUsage, err = judgeCandidates(task, candidates, judge)
+		if err != nil {
+			statusf("Advertencia: %v; se usa la primera candidata\n", err)
+		} else {
+			logger.Printf("El evaluador (%s) eligió la candidata %d de %d: %s\n", judge, winner+1, len(candidates), rationale)
+		}
+		if cost, ok := estimateCost(activeProvider, providerModel(judge), judgeUsage); ok {
+			logger.Printf("Coste estimado del evaluador: $%.6f\n", cost)
+		}
+	}
+
+	choice := response.Choices[winner]
+	choice.Index = 0
+	response.Choices = []Choice{choice}
+	return json.Marshal(response)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Prioridades de las fuentes de contexto: con el presupuesto agotado se
+// recortan primero las de menor prioridad
+const (
+	priorityLow = iota
+	priorityNormal
+	priorityHigh
+)
+
+var priorityNames = map[string]int{
+	"low":    priorityLow,
+	"normal": priorityNormal,
+	"high":   priorityHigh,
+}
+
+// Ventana de contexto en tokens por modelo; los desconocidos usan la de
+// deepseek-chat. context_windows en config.yaml y la que anuncia el
+// proveedor tienen prioridad.
+var contextWindows = map[string]int{
+	"deepseek-chat":     131072,
+	"deepseek-reasoner": 131072,
+}
+
+const defaultContextWindow = 131072
+
+// Margen reservado para el mensaje de sistema, el preámbulo y los errores
+// de la estimación
+const contextMargin = 1024
+
+// inputTokenBudget es el espacio para una entrada que se resume al leerla
+// (capturas HTTP, tablas): --context-budget o lo que deja libre la ventana
+func inputTokenBudget() int {
+	if contextBudget > 0 {
+		return contextBudget
+	}
+	return contextWindow(model) - maxTokens - contextMargin
+}
+
+// contextSource es una fuente de contexto (stdin, un archivo, la salida de
+// un comando, el estado de git) con su prioridad en el presupuesto
+type contextSource struct {
+	Name     string // stdin, la ruta del archivo, el comando o git
+	Kind     string // stdin, file, exec o git
+	Content  string
+	Raw      string // contenido sin etiqueta (stdin y archivos)
+	Labeled  bool   // ya incluye su etiqueta y se separa con una línea en blanco
+	Priority int
+	Tokens   int // tokens estimados del contenido original
+	Kept     int // tokens incluidos tras aplicar el presupuesto
+}
+
+func newContextSource(kind, name, content string, labeled bool) *contextSource {
+	tokens := estimateTokens(content)
+	return &contextSource{
+		Name:     name,
+		Kind:     kind,
+		Content:  content,
+		Labeled:  labeled,
+		Priority: priorityNormal,
+		Tokens:   tokens,
+		Kept:     tokens,
+	}
+}
+
+// estimateTokens aproxima los tokens de un texto (unos 4 bytes por token)
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// advertisedContextWindow devuelve la ventana de contexto del modelo solo si
+// se conoce: la de context_windows en config.yaml, la que anuncia el
+// proveedor en la lista de modelos en caché o la de la tabla, por este
+// orden. Los modelos desconocidos no se suponen de 128K.
+func advertisedContextWindow(modelName string) (int, bool) {
+	if cfg, err := loadConfig(); err == nil {
+		if w, ok := cfg.ContextWindows[providerModel(modelName)]; ok && w > 0 {
+			return w, true
+		}
+		if w, ok := cfg.ContextWindows[modelName]; ok && w > 0 {
+			return w, true
+		}
+	}
+	if m, ok := cachedModelInfo(providerModel(modelName)); ok && m.ContextLength > 0 {
+		return m.ContextLength, true
+	}
+	if w, ok := contextWindows[providerModel(modelName)]; ok {
+		return w, true
+	}
+	if w, ok := contextWindows[modelName]; ok {
+		return w, true
+	}
+	return 0, false
+}
+
+// requestTokens estima los tokens de entrada de la solicitud, con unos pocos
+// por mensaje para los roles y separadores
+func requestTokens(requestBody RequestBody) int {
+	tokens := 0
+	for _, m := range requestBody.Messages {
+		tokens += estimateTokens(m.Content) + 4
+	}
+	return tokens
+}
+
+// checkContextFits comprueba antes de enviar la solicitud que la entrada
+// estimada más la respuesta (max_tokens) cabe en la ventana del modelo, para
+// fallar al momento con sugerencias en lugar de esperar al rechazo de la API
+func checkContextFits(requestBody RequestBody) error {
+	window, ok := advertisedContextWindow(requestBody.Model)
+	if !ok {
+		return nil
+	}
+	tokens := requestTokens(requestBody)
+	if tokens+requestBody.MaxTokens <= window {
+		return nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "la solicitud no cabe en la ventana de contexto de %s: ~%d tokens de entrada + %d de respuesta (-m) frente a un límite de %d", providerModel(requestBody.Model), tokens, requestBody.MaxTokens, window)
+	sb.WriteString("\nSugerencias:")
+	if tokens < window {
+		fmt.Fprintf(&sb, "\n  - reduce la respuesta con -m %d o menos", window-tokens)
+	}
+	sb.WriteString("\n  - pasa el texto largo como archivo o por stdin en lugar de en el prompt: un diff o un archivo que no cabe se procesa por partes (sin --stream, -n, --best-of ni --prefill)")
+	sb.WriteString("\n  - recorta el contexto con --context-budget <tokens> y --priority <fuente>=low")
+	sb.WriteString("\n  - en el chat, empieza una sesión nueva o usa /fork <n> para seguir desde una respuesta anterior")
+	fmt.Fprintf(&sb, "\n  - si el modelo admite más contexto, indícalo en config.yaml: context_windows: {%s: <tokens>}", providerModel(requestBody.Model))
+	return errors.New(sb.String())
+}
+
+func contextWindow(modelName string) int {
+	if w, ok := advertisedContextWindow(modelName); ok {
+		return w
+	}
+	return defaultContextWindow
+}
+
+func totalTokens(sources []*contextSource) int {
+	total := 0
+	for _, s := range sources {
+		total += s.Tokens
+	}
+	return total
+}
+
+// applyPriorities asigna las prioridades de --priority (nombre=high|normal|low).
+// El nombre es la ruta de un archivo, el comando de --exec, stdin, git, exec
+// (todos los comandos), about o gopkg (el resumen de --go-package).
+func applyPriorities(sources []*contextSource, values []string) error {
+	for _, v := range values {
+		i := strings.LastIndex(v, "=")
+		if i < 0 {
+			return fmt.Errorf("--priority espera nombre=high|normal|low, no %q", v)
+		}
+		name := v[:i]
+		p, known := priorityNames[strings.ToLower(v[i+1:])]
+		if !known {
+			return fmt.Errorf("--priority espera nombre=high|normal|low, no %q", v)
+		}
+		matched := false
+		for _, s := range sources {
+			if s.Name == name || s.Kind == name {
+				s.Priority = p
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("--priority: ninguna fuente de contexto se llama %q", name)
+		}
+	}
+	return nil
+}
+
+// fitBudget recorta las fuentes hasta que quepan en el presupuesto: primero
+// las de menor prioridad y, con igual prioridad, las últimas en añadirse.
+// Una fuente se trunca si basta con eso y se omite si no. Devuelve true si
+// hubo que recortar algo.
+func fitBudget(sources []*contextSource, budget int) bool {
+	total := totalTokens(sources)
+	if total <= budget {
+		return false
+	}
+
+	order := make([]*contextSource, len(sources))
+	copy(order, sources)
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].Priority < order[j].Priority
+	})
+	// Con igual prioridad se recortan antes las últimas
+	for i, j := 0, 0; i < len(order); i = j {
+		for j = i; j < len(order) && order[j].Priority == order[i].Priority; j++ {
+		}
+		for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+			order[a], order[b] = order[b], order[a]
+		}
+	}
+
+	excess := total - budget
+	for _, s := range order {
+		if excess <= 0 {
+			break
+		}
+		if s.Tokens <= excess {
+			excess -= s.Tokens
+			s.Kept = 0
+			s.Content = ""
+			continue
+		}
+		s.Kept = s.Tokens - excess
+		truncateSource(s)
+		excess = 0
+	}
+	return true
+}
+
+// truncateSource recorta la fuente a s.Kept tokens. El código de stdin y de
+// los archivos se corta entre funciones o tipos completos si es posible.
+func truncateSource(s *contextSource) {
+	if s.Raw != "" && !isUnifiedDiff(s.Raw) {
+		overhead := len(s.Content) - len(s.Raw)
+		if code, ok := truncateCode(s.Name, s.Raw, s.Kept*4-overhead); ok {
+			content := code
+			if s.Labeled {
+				content = labelFile(s.Name, code)
+			}
+			s.Kept = estimateTokens(content)
+			s.Content = truncateContext(content, len(content), s.Tokens-s.Kept)
+			return
+		}
+	}
+	s.Content = truncateContext(s.Content, s.Kept*4, s.Tokens-s.Kept)
+}
+
+// truncateContext conserva el principio del texto e indica lo omitido,
+// cerrando el bloque de código si el corte lo deja abierto
+func truncateContext(content string, maxBytes, omitted int) string {
+	cut := content
+	if len(cut) > maxBytes {
+		cut = cut[:maxBytes]
+		if i := strings.LastIndex(cut, "\n"); i > 0 {
+			cut = cut[:i]
+		}
+		cut = strings.ToValidUTF8(cut, "")
+	}
+	if strings.Count(cut, "```")%2 == 1 {
+		cut += "\n```"
+	}
+	return cut + fmt.Sprintf("\n[... truncado para ajustarse a la ventana de contexto: ~%d tokens omitidos]", omitted)
+}
+
+// joinSources une las fuentes en el texto de contexto; las que llevan
+// etiqueta se separan con una línea en blanco
+func joinSources(sources []*contextSource) string {
+	var input string
+	for _, s := range sources {
+		if s.Content == "" {
+			continue
+		}
+		if input != "" {
+			input = strings.TrimSpace(input) + "\n"
+			if s.Labeled {
+				input += "\n"
+			}
+		}
+		input += s.Content
+	}
+	return input
+}
+
+// printContextReport muestra qué se incluyó de cada fuente
+func printContextReport(w io.Writer, sources []*contextSource, budget int) {
+	levels := map[int]string{priorityLow: "low", priorityNormal: "normal", priorityHigh: "high"}
+	fmt.Fprintf(w, "Contexto (presupuesto ~%d tokens):\n", budget)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, s := range sources {
+		state := "incluido"
+		switch {
+		case s.Kept == 0 && s.Tokens > 0:
+			state = "omitido"
+		case s.Kept < s.Tokens:
+			state = "truncado"
+		}
+		fmt.Fprintf(tw, "  %s\t%d/%d tokens\t%s\t%s\n", state, s.Kept, s.Tokens, levels[s.Priority], s.Name)
+	}
+	tw.Flush()
+}
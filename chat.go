@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const chatPrompt = "> "
+
+func runChat(args []string) {
+	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+	sessionID := fs.String("session", "", "Continuar una sesión guardada")
+	system := fs.String("system", "", "Mensaje de sistema para una sesión nueva")
+	viMode := fs.Bool("vi", false, "Usar los atajos de Vi para editar")
+	recoverLast := fs.Bool("recover", false, "Retomar la última sesión del chat")
+	addModelFlags(fs, defaultTemperature)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s chat [opciones]
+
+Chat en la terminal, línea a línea, con respuestas en streaming. Las
+conversaciones se guardan como sesiones (ver "history") tras cada mensaje, y
+lo escrito se conserva entre ejecuciones en %s.
+
+Si el chat se cierra de forma inesperada (caída, desconexión SSH, Ctrl+D sin
+querer), --recover retoma la última sesión; una pregunta que quedó sin
+respuesta se vuelve a enviar.
+
+Edición (estilo readline):
+  Enter               Enviar el mensaje
+  Alt+Enter/Ctrl+J    Nueva línea; el texto pegado con varias líneas se
+                      inserta completo sin enviarse
+  ↑/↓, Ctrl+P/Ctrl+N  Recorrer el historial
+  Ctrl+R              Búsqueda inversa en el historial (Ctrl+R otra vez
+                      para la coincidencia anterior, Esc cancela)
+  Ctrl+A/E, Alt+B/F   Inicio/fin de línea, palabra anterior/siguiente
+  Ctrl+K/U/W          Borrar hasta el final, hasta el inicio, la palabra
+  Ctrl+C              Descartar la línea
+  Ctrl+D, /salir      Salir
+  /fork [n]           Seguir en una rama nueva de la sesión que parte de
+                      la respuesta n (default: la última); la original se
+                      conserva tal cual
+
+Con --vi (o "set editing-mode vi" en ~/.inputrc) Esc pasa al modo normal:
+h l w b 0 $ para moverse, x X D dd dw cw C S para borrar, i a I A para
+insertar, k j para el historial y / para buscar.
+
+Opciones:
+  --session <id>          Continuar una sesión guardada
+  --recover               Retomar la última sesión del chat
+  --system <texto>        Mensaje de sistema para una sesión nueva
+  --vi                    Atajos de Vi
+  -t, --temperature       Temperatura (default: 0.7)
+  -m, --maxtokens         Máximo de tokens por respuesta (default: 2048)
+`, os.Args[0], replHistoryFile())
+	}
+	fs.Parse(args)
+	setupSubcommand()
+
+	if *recoverLast && *sessionID != "" {
+		fmt.Fprintf(os.Stderr, "Error: --recover y --session no se pueden usar juntos\n")
+		os.Exit(1)
+	}
+
+	session := newSession()
+	switch {
+	case *recoverLast:
+		var err error
+		session, err = recoverChatSession()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		statusf("Sesión %s recuperada (%d mensajes)\n", session.ID, len(session.Messages))
+		printLastExchange(session)
+	case *sessionID != "":
+		var err error
+		session, err = loadSession(*sessionID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		statusf("Sesión %s (%d mensajes)\n", session.ID, len(session.Messages))
+	case *system != "":
+		session.Messages = append(session.Messages, Message{Role: "system", Content: *system})
+	}
+
+	// Una pregunta sin respuesta (la sesión se cortó mientras se generaba)
+	// se vuelve a enviar
+	if n := len(session.Messages); n > 0 && session.Messages[n-1].Role == "user" {
+		statusf("Repitiendo la última pregunta, que quedó sin respuesta...\n")
+		chatTurn(session)
+	}
+
+	reader := newLineReader(*viMode)
+	for {
+		line, err := reader.ReadLine(chatPrompt)
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		text := strings.TrimSpace(line)
+		switch text {
+		case "":
+			continue
+		case "/salir", "/exit", "/quit":
+			return
+		}
+		if arg, ok := strings.CutPrefix(text, "/fork"); ok && (arg == "" || arg[0] == ' ') {
+			if forked, err := forkChat(session, strings.TrimSpace(arg)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			} else {
+				session = forked
+			}
+			continue
+		}
+
+		session.Messages = append(session.Messages, Message{Role: "user", Content: text})
+		chatTurn(session)
+	}
+}
+
+// chatTurn responde al último mensaje del usuario. La sesión se guarda antes
+// de la petición y después de la respuesta, de modo que un cierre inesperado
+// no pierde la pregunta y "chat --recover" puede retomarla.
+func chatTurn(session *Session) {
+	autosaveChat(session)
+
+	// Ctrl+C detiene la respuesta y vuelve al prompt; lo recibido se
+	// conserva marcado como incompleto
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	requestBody := newRequestBody(session.Messages)
+	requestBody.Model = session.Model
+	requestBody.ctx = ctx
+	result, err := streamWithResume(requestBody, func(delta string) {
+		fmt.Print(delta)
+	}, nil)
+	fmt.Println()
+	switch {
+	case errors.Is(err, errStreamCanceled) && result.Content != "":
+		statusf("[interrumpido: la respuesta está incompleta]\n\n")
+		session.addPartial(result.Content)
+		autosaveChat(session)
+		return
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	if result.Content == "" {
+		// Sin respuesta se descarta la pregunta para poder repetirla
+		session.Messages = session.Messages[:len(session.Messages)-1]
+	} else {
+		session.Messages = append(session.Messages, Message{Role: "assistant", Content: result.Content})
+		fmt.Println()
+	}
+	autosaveChat(session)
+}
+
+// forkChat crea una rama de la sesión desde la respuesta indicada (la
+// última si turn está vacío) y la guarda; el chat continúa en ella
+func forkChat(session *Session, turn string) (*Session, error) {
+	n := 0
+	if turn != "" {
+		var err error
+		if n, err = strconv.Atoi(turn); err != nil {
+			return nil, fmt.Errorf("uso: /fork [n], con n el número de la respuesta")
+		}
+	}
+	forked, err := session.Fork(n)
+	if err != nil {
+		return nil, err
+	}
+	autosaveChat(forked)
+	statusf("Rama %s de %s (%d mensajes); la sesión original se conserva\n", forked.ID, forked.ForkedFrom, len(forked.Messages))
+	return forked, nil
+}
+
+func chatRecoveryFile() string {
+	return filepath.Join(dataDir(), "chat_recovery")
+}
+
+// autosaveChat guarda la sesión y la marca como la última del chat
+func autosaveChat(session *Session) {
+	if len(session.Messages) == 0 {
+		return
+	}
+	if err := session.Save(); err != nil {
+		statusf("Advertencia: no se pudo guardar la sesión: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(chatRecoveryFile(), []byte(session.ID+"\n"), 0600); err != nil {
+		logger.Printf("No se pudo guardar el marcador de recuperación: %v\n", err)
+	}
+}
+
+// recoverChatSession carga la última sesión del chat
+func recoverChatSession() (*Session, error) {
+	data, err := os.ReadFile(chatRecoveryFile())
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no hay ninguna sesión de chat que recuperar")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return loadSession(strings.TrimSpace(string(data)))
+}
+
+// printLastExchange muestra la última pregunta (y su respuesta) como
+// contexto al retomar una sesión
+func printLastExchange(session *Session) {
+	for i := len(session.Messages) - 1; i >= 0; i-- {
+		if session.Messages[i].Role != "user" {
+			continue
+		}
+		for _, m := range session.Messages[i:] {
+			if m.Role == "user" {
+				fmt.Printf("%s%s\n", chatPrompt, m.Content)
+			} else {
+				fmt.Printf("%s\n\n", m.Content)
+			}
+		}
+		return
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// loadAPIConfig obtiene la API key y la URL base del entorno (o del
+// proveedor elegido) y configura la conexión; termina si no hay API key
+func loadAPIConfig() {
+	// DEEPCLI_OFFLINE se respeta también en los subcomandos sin --offline
+	if v, _ := strconv.ParseBool(os.Getenv("DEEPCLI_OFFLINE")); v {
+		offlineMode = true
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case providerName != "":
+		baseURL := apiBaseURL
+		if err := activateProvider(providerName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if isFlagSet("base-url") {
+			apiBaseURL = strings.TrimRight(baseURL, "/")
+		}
+
+	case len(cfg.Failover) > 0 && !isFlagSet("base-url"):
+		failoverChain = cfg.Failover
+		failoverIndex = -1
+		if !nextProvider() {
+			fmt.Fprintf(os.Stderr, "Error: ningún proveedor de la cadena de failover (%s) está configurado\n", strings.Join(cfg.Failover, ", "))
+			os.Exit(1)
+		}
+
+	default:
+		if v := os.Getenv("DEEPSEEK_BASE_URL"); v != "" && !isFlagSet("base-url") {
+			apiBaseURL = v
+		}
+		apiBaseURL = strings.TrimRight(apiBaseURL, "/")
+
+		// Sin conexión no se usa la API key (y key_command podría acceder a
+		// la red)
+		if offlineMode {
+			break
+		}
+		apiKey, err = resolveSecret("DEEPSEEK_API_KEY", cfg.KeyCommand)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if apiKey == "" {
+			fmt.Fprintf(os.Stderr, "Error: No se ha configurado la API key de DeepSeek. Por favor, establece la variable de entorno DEEPSEEK_API_KEY o crea un archivo .env con la clave.\n")
+			os.Exit(1)
+		}
+	}
+
+	if err := configureNetwork(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// chatEndpoint devuelve la URL de chat completions; beta (o --beta) usa el
+// endpoint beta de DeepSeek (prefix completion) salvo con una URL base
+// personalizada
+func chatEndpoint(beta bool) string {
+	if (beta || betaMode) && apiBaseURL == defaultBaseURL {
+		return betaBaseURL + "/chat/completions"
+	}
+	if provider != nil && provider.Query != "" {
+		return apiBaseURL + "/chat/completions?" + provider.Query
+	}
+	return apiBaseURL + "/chat/completions"
+}
+
+// newRequestBody crea el cuerpo de la solicitud con la configuración actual
+func newRequestBody(messages []Message) RequestBody {
+	return RequestBody{
+		Model:       model,
+		Messages:    messages,
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+		Stream:      false,
+		Stop:        stopSequences,
+	}
+}
+
+// preflightRequest aplica a una solicitud ya serializada la política de envío,
+// la ventana de contexto, los presupuestos y --confirm-cost, y la anota en
+// el registro de solicitudes
+func preflightRequest(endpoint string, requestBody RequestBody, jsonBody []byte) error {
+	if err := checkPolicyRequest(endpoint, jsonBody); err != nil {
+		return err
+	}
+	if err := checkContextFits(requestBody); err != nil {
+		return err
+	}
+	if err := checkBudgets(); err != nil {
+		return err
+	}
+	if err := checkCostConfirmation(requestBody); err != nil {
+		return err
+	}
+	recordRequest(endpoint, requestBody, len(jsonBody))
+	return nil
+}
+
+// newHTTPRequest crea la solicitud HTTP a la API con sus cabeceras
+func newHTTPRequest(endpoint string, requestBody RequestBody) (*http.Request, error) {
+	requestBody.Model = providerModel(requestBody.Model)
+	if anon != nil {
+		requestBody.Messages = anonymizeMessages(requestBody.Messages)
+	}
+
+	// Convertir a JSON
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo crear el cuerpo JSON: %v", err)
+	}
+
+	if verbose {
+		logger.Printf("Cuerpo de la solicitud:\n%s\n", jsonBody)
+	}
+	if err := preflightRequest(endpoint, requestBody, jsonBody); err != nil {
+		return nil, err
+	}
+
+	// Crear la solicitud HTTP
+	ctx := requestBody.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo crear la solicitud HTTP: %v", err)
+	}
+
+	// Configurar headers
+	req.Header.Set("Content-Type", "application/json")
+	if err := authorizeRequest(req, jsonBody); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// inflight agrupa las solicitudes idénticas que están en curso a la vez
+// (serve, lotes en paralelo): solo la primera llega a la API y todas
+// reciben su respuesta
+var (
+	inflightMu sync.Mutex
+	inflight   = map[string]*flight{}
+)
+
+// flight es una solicitud compartida en curso. Se envía con un contexto
+// propio, que solo se cancela cuando ya no la espera nadie: un cliente que
+// se desconecta no cancela la respuesta de los demás.
+type flight struct {
+	done    chan struct{}
+	body    []byte
+	status  int
+	err     error
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// canCoalesce indica si las solicitudes idénticas se pueden agrupar: con
+// --confirm-cost, presupuestos o registro de auditoría cada solicitud se
+// confirma, se contabiliza y se registra por separado
+func canCoalesce(requestBody RequestBody) bool {
+	return !requestBody.distinct && !confirmCost && !budgetsActive() && auditFile() == ""
+}
+
+// sendRequest envía la solicitud a la API y devuelve el cuerpo crudo de la
+// respuesta junto con el código de estado HTTP; si el código no es 2xx
+// devuelve también un *APIError. Si ya hay en curso una solicitud idéntica
+// (mismo destino, proveedor, cabeceras y cuerpo) espera a su respuesta en
+// lugar de enviarla otra vez.
+func sendRequest(endpoint string, requestBody RequestBody) ([]byte, int, error) {
+	if !canCoalesce(requestBody) {
+		return sendRequestOnce(endpoint, requestBody)
+	}
+	data, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, 0, fmt.Errorf("no se pudo crear el cuerpo JSON: %v", err)
+	}
+	key := strings.Join([]string{endpoint, activeProvider, customHeadersKey(), sha256Hex(data)}, "\x00")
+	ctx := requestBody.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	inflightMu.Lock()
+	f, shared := inflight[key]
+	if shared {
+		f.waiters++
+		logger.Println("Respuesta compartida con una solicitud idéntica en curso")
+	} else {
+		sharedCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		inflight[key] = f
+		sharedBody := requestBody
+		sharedBody.ctx = sharedCtx
+		go func() {
+			f.body, f.status, f.err = sendRequestOnce(endpoint, sharedBody)
+			inflightMu.Lock()
+			if inflight[key] == f {
+				delete(inflight, key)
+			}
+			inflightMu.Unlock()
+			cancel()
+			close(f.done)
+		}()
+	}
+	inflightMu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.body, f.status, f.err
+	case <-ctx.Done():
+		inflightMu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			if inflight[key] == f {
+				delete(inflight, key)
+			}
+		}
+		inflightMu.Unlock()
+		return nil, 0, ctx.Err()
+	}
+}
+
+func sendRequestOnce(endpoint string, requestBody RequestBody) ([]byte, int, error) {
+	logger.Println("Enviando solicitud a la API...")
+
+	// Realizar la solicitud, repitiéndola si la API está saturada
+	resp, err := doWithRetry(func() (*http.Request, error) {
+		return newHTTPRequest(endpoint, requestBody)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	// Leer la respuesta
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		auditFailure(resp.Request, resp.StatusCode, err)
+		return nil, resp.StatusCode, fmt.Errorf("no se pudo leer la respuesta HTTP: %v", err)
+	}
+	finishTimings(resp)
+	auditResponse(resp, body, false)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, resp.StatusCode, newAPIError(resp.StatusCode, resp.Header, body, requestBody)
+	}
+	var parsed ResponseBody
+	if json.Unmarshal(body, &parsed) == nil {
+		recordUsage(requestBody, parsed.Usage, plainText(parsed))
+	}
+	return body, resp.StatusCode, nil
+}
+
+// complete envía la solicitud y devuelve el contenido de la primera
+// alternativa junto con la respuesta parseada
+func complete(requestBody RequestBody) (string, ResponseBody, error) {
+	var response ResponseBody
+
+	body, statusCode, err := sendRequest(chatEndpoint(false), requestBody)
+	if err != nil {
+		return "", response, err
+	}
+	if verbose {
+		logger.Printf("Respuesta cruda (%d):\n%s\n", statusCode, body)
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", response, fmt.Errorf("no se pudo parsear la respuesta JSON: %v", err)
+	}
+	if response.Error.Message != "" {
+		return "", response, fmt.Errorf("error de la API: %s", response.Error.Message)
+	}
+	if len(response.Choices) == 0 {
+		return "", response, fmt.Errorf("no se recibió ninguna respuesta válida de la API")
+	}
+	if anon != nil {
+		for i := range response.Choices {
+			response.Choices[i].Message.Content = anon.Restore(response.Choices[i].Message.Content)
+		}
+	}
+	return response.Choices[0].Message.Content, response, nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// CommandRecord es una sugerencia de comando ejecutada
+type CommandRecord struct {
+	Time     time.Time `json:"time"`
+	Request  string    `json:"request"`
+	Command  string    `json:"command"`
+	Dir      string    `json:"dir"`
+	ExitCode int       `json:"exit_code"`
+}
+
+func commandHistoryFile() string {
+	return filepath.Join(dataDir(), "commands.jsonl")
+}
+
+// cmdSystemPrompt restringe la respuesta a un único comando para el shell
+// con el que se ejecutará
+func cmdSystemPrompt() string {
+	shell := "sh (POSIX)"
+	if runtime.GOOS == "windows" {
+		shell = "cmd.exe"
+	}
+	return fmt.Sprintf(`Convierte la petición del usuario en exactamente un comando de shell para %s en %s. `+
+		`Responde solo con el comando, en una única línea, sin explicaciones, comentarios ni bloques de código. `+
+		`Si hacen falta varios pasos, únelos con tuberías o &&.`, shell, runtime.GOOS)
+}
+
+// parseSuggestedCommand extrae el comando de la respuesta; falla si el
+// modelo no devolvió exactamente una línea
+func parseSuggestedCommand(output string) (string, error) {
+	text := strings.TrimSpace(output)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if i := strings.Index(text, "\n"); i >= 0 {
+			text = text[i+1:]
+		}
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	text = strings.Trim(strings.TrimSpace(text), "`")
+	text = strings.TrimPrefix(text, "$ ")
+	if text == "" {
+		return "", fmt.Errorf("el modelo no devolvió ningún comando")
+	}
+	if strings.Contains(text, "\n") {
+		return "", fmt.Errorf("el modelo devolvió más de un comando:\n%s", escapeControl(text))
+	}
+	// Un carácter de control (secuencias ANSI, \r) o invisible haría que lo
+	// que se muestra para confirmar no fuera lo que se ejecuta
+	if strings.IndexFunc(text, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+		return "", fmt.Errorf("el comando contiene caracteres de control o no imprimibles: %s", escapeControl(text))
+	}
+	return text, nil
+}
+
+// escapeControl muestra los caracteres no imprimibles (salvo los saltos de
+// línea) como secuencias de escape, para que el terminal no los interprete
+func escapeControl(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\n' || unicode.IsPrint(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteString(strings.Trim(strconv.QuoteRune(r), "'"))
+		}
+	}
+	return b.String()
+}
+
+// confirm hace la pregunta en el terminal; solo "s" o "si" confirman,
+// Enter sin respuesta cancela
+func confirm(question string) (bool, error) {
+	in := os.Stdin
+	if !isTerminal(os.Stdin) {
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			return false, fmt.Errorf("se necesita un terminal para confirmar")
+		}
+		defer tty.Close()
+		in = tty
+	}
+	fmt.Fprintf(os.Stderr, "%s [s/N]: ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "s", "si", "sí", "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// appendCommandHistory registra un comando ejecutado
+func appendCommandHistory(rec CommandRecord) error {
+	if err := os.MkdirAll(dataDir(), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(commandHistoryFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// printCommandHistory muestra los últimos n comandos ejecutados
+func printCommandHistory(n int) error {
+	data, err := os.ReadFile(commandHistoryFile())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	for _, line := range lines {
+		var rec CommandRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			continue
+		}
+		fmt.Printf("%s  [%d]  %s\n      # %s\n", rec.Time.Local().Format("2006-01-02 15:04"), rec.ExitCode, escapeControl(rec.Command), escapeControl(rec.Request))
+	}
+	return nil
+}
+
+func runCmd(args []string) {
+	fs := flag.NewFlagSet("cmd", flag.ExitOnError)
+	printOnly := fs.Bool("print", false, "Mostrar el comando sugerido sin ejecutarlo")
+	showHistory := fs.Bool("history", false, "Mostrar los últimos comandos ejecutados")
+	addModelFlags(fs, 0.0)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s cmd "<lo que quieres hacer>" [opciones]
+
+Pide al modelo un único comando de shell, lo muestra y lo ejecuta solo si se
+confirma escribiendo "s" (Enter sin respuesta cancela). Se rechazan los
+comandos con caracteres de control o no imprimibles (secuencias ANSI, \r),
+que podrían mostrar algo distinto de lo que se ejecuta. Los comandos
+ejecutados se guardan en %s.
+
+Opciones:
+  --print             Mostrar el comando sin ejecutarlo
+  --history           Mostrar los últimos comandos ejecutados
+  -t, --temperature   Temperatura (default: 0.0)
+  -v, --verbose       Mostrar logs detallados
+`, os.Args[0], commandHistoryFile())
+	}
+	fs.Parse(args)
+
+	if *showHistory {
+		if err := printCommandHistory(20); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	request := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if request == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	setupSubcommand()
+
+	requestBody := newRequestBody([]Message{
+		{Role: "system", Content: cmdSystemPrompt()},
+		{Role: "user", Content: request},
+	})
+	output, _, err := complete(requestBody)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	command, err := parseSuggestedCommand(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *printOnly {
+		fmt.Println(command)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\n  %s\n\n", escapeControl(command))
+	ok, err := confirm("¿Ejecutar?")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Cancelado")
+		os.Exit(1)
+	}
+
+	run := shellCommand(command)
+	run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+	exitCode := 0
+	if err := run.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	dir, _ := os.Getwd()
+	rec := CommandRecord{Time: time.Now(), Request: request, Command: command, Dir: dir, ExitCode: exitCode}
+	if err := appendCommandHistory(rec); err != nil {
+		statusf("Advertencia: no se pudo guardar el historial: %v\n", err)
+	}
+	os.Exit(exitCode)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// codeUnit es una unidad de nivel superior de un archivo de código (una
+// función, un tipo, una clase) con sus comentarios; las unidades de un
+// archivo, concatenadas, reproducen el archivo completo
+type codeUnit struct {
+	Name string // símbolo que define, si se reconoce
+	Line int    // línea en la que empieza (desde 1)
+	Text string
+}
+
+// Definiciones de nivel superior de los lenguajes más habituales
+var symbolRe = regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:pub(?:\([a-z]+\))?\s+)?(?:async\s+)?` +
+	`(?:def|class|function\*?|interface|type|enum|struct|trait|impl|fn|const|let|var)\s+([A-Za-z_$][\w$]*)`)
+
+// codeUnits divide el código en unidades de nivel superior: con go/parser
+// para Go y con una heurística de sangría y llaves para el resto
+func codeUnits(name, content string) []codeUnit {
+	if strings.HasSuffix(name, ".go") {
+		if units, ok := goUnits(name, content); ok {
+			return units
+		}
+	}
+	return heuristicUnits(name, content)
+}
+
+// goUnits corta el archivo antes de cada declaración (o de su comentario)
+func goUnits(name, content string) ([]codeUnit, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, content, parser.ParseComments)
+	if err != nil {
+		return nil, false
+	}
+	type cut struct {
+		offset int
+		name   string
+	}
+	var cuts []cut
+	for _, decl := range f.Decls {
+		pos, declName := decl.Pos(), ""
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+			declName = d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				declName = receiverName(d.Recv.List[0].Type) + "." + declName
+			}
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+			var names []string
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names = append(names, s.Name.Name)
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						names = append(names, n.Name)
+					}
+				}
+			}
+			declName = strings.Join(names, ",")
+		}
+		// El corte se hace al inicio de la línea
+		offset := fset.Position(pos).Offset
+		offset = strings.LastIndex(content[:offset], "\n") + 1
+		cuts = append(cuts, cut{offset, declName})
+	}
+
+	var units []codeUnit
+	start, startName := 0, ""
+	for _, c := range cuts {
+		if c.offset > start {
+			units = append(units, codeUnit{Name: startName, Text: content[start:c.offset]})
+			start = c.offset
+		}
+		startName = c.name
+	}
+	units = append(units, codeUnit{Name: startName, Text: content[start:]})
+	numberUnits(units)
+	return units, true
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+// heuristicUnits corta antes de cada línea sin sangría que está fuera de
+// llaves, paréntesis y cadenas multilínea, subiendo el corte por encima de
+// los comentarios y decoradores que la preceden
+func heuristicUnits(name, content string) []codeUnit {
+	lines := strings.SplitAfter(content, "\n")
+	ext := strings.ToLower(filepath.Ext(name))
+	hashComments := ext == ".py" || ext == ".rb" || ext == ".sh" || ext == ".yaml" || ext == ".yml" || ext == ".toml"
+
+	isPreamble := func(line string) bool {
+		t := strings.TrimSpace(line)
+		return strings.HasPrefix(t, "//") || strings.HasPrefix(t, "/*") || strings.HasPrefix(t, "*") ||
+			strings.HasPrefix(t, "@") || strings.HasPrefix(t, "#[") || (hashComments && strings.HasPrefix(t, "#"))
+	}
+
+	var cuts []int // índices de línea en los que empieza una unidad
+	depth := 0
+	inTriple := ""
+	for i, line := range lines {
+		t := strings.TrimSpace(line)
+		topLevel := depth == 0 && inTriple == "" && t != "" && line[0] != ' ' && line[0] != '\t' &&
+			!strings.ContainsAny(t[:1], ")]}") && !isPreamble(line)
+		if topLevel && i > 0 {
+			c := i
+			for c > 0 && isPreamble(lines[c-1]) && strings.TrimSpace(lines[c-1]) != "" {
+				c--
+			}
+			if len(cuts) == 0 || c > cuts[len(cuts)-1] {
+				cuts = append(cuts, c)
+			}
+		}
+		depth, inTriple = scanBrackets(line, depth, inTriple, hashComments)
+	}
+
+	var units []codeUnit
+	start := 0
+	for _, c := range append(cuts, len(lines)) {
+		if c <= start {
+			continue
+		}
+		text := strings.Join(lines[start:c], "")
+		unit := codeUnit{Text: text}
+		if m := symbolRe.FindStringSubmatch(strings.TrimSpace(firstCodeLine(text))); m != nil {
+			unit.Name = m[1]
+		}
+		units = append(units, unit)
+		start = c
+	}
+	if len(units) == 0 {
+		units = []codeUnit{{Text: content}}
+	}
+	numberUnits(units)
+	return units
+}
+
+// firstCodeLine devuelve la primera línea que no es comentario ni decorador
+func firstCodeLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		t := strings.TrimSpace(line)
+		if t != "" && !strings.HasPrefix(t, "//") && !strings.HasPrefix(t, "#") &&
+			!strings.HasPrefix(t, "/*") && !strings.HasPrefix(t, "*") && !strings.HasPrefix(t, "@") {
+			return t
+		}
+	}
+	return ""
+}
+
+// scanBrackets actualiza la profundidad de llaves y paréntesis tras la línea,
+// ignorando cadenas y comentarios de línea; inTriple es el delimitador de la
+// cadena multilínea abierta (comillas triples de Python, ` de JavaScript y Go)
+func scanBrackets(line string, depth int, inTriple string, hashComments bool) (int, string) {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		if inTriple != "" {
+			if strings.HasPrefix(line[i:], inTriple) {
+				i += len(inTriple) - 1
+				inTriple = ""
+			}
+			continue
+		}
+		c := line[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line[i:], `"""`), strings.HasPrefix(line[i:], `'''`):
+			inTriple = line[i : i+3]
+			i += 2
+		case c == '`':
+			inTriple = "`"
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '/' && i+1 < len(line) && line[i+1] == '/', hashComments && c == '#':
+			return depth, inTriple
+		case c == '{' || c == '(' || c == '[':
+			depth++
+		case c == '}' || c == ')' || c == ']':
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+	return depth, inTriple
+}
+
+func numberUnits(units []codeUnit) {
+	line := 1
+	for i := range units {
+		units[i].Line = line
+		line += strings.Count(units[i].Text, "\n")
+	}
+}
+
+// chunkCode agrupa las unidades del archivo en partes de como mucho
+// maxTokens; una unidad que no cabe sola se corta por líneas
+func chunkCode(name, content string, maxTokens int) []inputChunk {
+	// Espacio para la etiqueta y las vallas de cada parte
+	maxTokens -= estimateTokens(name) + 16
+	var pieces []codeUnit
+	for _, u := range codeUnits(name, content) {
+		if estimateTokens(u.Text) <= maxTokens {
+			pieces = append(pieces, u)
+			continue
+		}
+		piece := codeUnit{Name: u.Name, Line: u.Line}
+		line := u.Line
+		for _, l := range strings.SplitAfter(u.Text, "\n") {
+			if piece.Text != "" && estimateTokens(piece.Text+l) > maxTokens {
+				pieces = append(pieces, piece)
+				piece = codeUnit{Name: u.Name, Line: line}
+			}
+			piece.Text += l
+			line++
+		}
+		pieces = append(pieces, piece)
+	}
+
+	var chunks []inputChunk
+	var text strings.Builder
+	first := 0
+	flush := func(end int) {
+		if text.Len() == 0 {
+			return
+		}
+		label := fmt.Sprintf("%s:%d-%d", name, pieces[first].Line, end)
+		chunks = append(chunks, inputChunk{
+			Text:  fencedFile(fmt.Sprintf("Archivo %s (líneas %d-%d)", name, pieces[first].Line, end), name, text.String()),
+			Files: []string{label},
+		})
+		text.Reset()
+	}
+	for i, p := range pieces {
+		if text.Len() > 0 && estimateTokens(text.String()+p.Text) > maxTokens {
+			flush(p.Line - 1)
+			first = i
+		}
+		text.WriteString(p.Text)
+	}
+	if n := len(pieces); n > 0 {
+		flush(pieces[n-1].Line + strings.Count(strings.TrimRight(pieces[n-1].Text, "\n"), "\n"))
+	}
+	return chunks
+}
+
+// truncateCode conserva las unidades completas del principio del archivo que
+// caben en maxBytes; devuelve false si ni la primera cabe
+func truncateCode(name, content string, maxBytes int) (string, bool) {
+	var kept strings.Builder
+	for _, u := range codeUnits(name, content) {
+		if kept.Len()+len(u.Text) > maxBytes {
+			break
+		}
+		kept.WriteString(u.Text)
+	}
+	return kept.String(), kept.Len() > 0
+}
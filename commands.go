@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Subcomandos disponibles: deepcli <subcomando> [opciones]
+var subcommands = map[string]func(args []string){
+	"eval":           runEval,
+	"test":           runPromptTests,
+	"mockserver":     runMockServer,
+	"tui":            runTUI,
+	"chat":           runChat,
+	"serve":          runServe,
+	"history":        runHistory,
+	"prompt":         runPrompt,
+	"ab":             runAB,
+	"shell-init":     runShellInit,
+	"wtf":            runWtf,
+	"cmd":            runCmd,
+	"fix":            runFix,
+	"models":         runModels,
+	"balance":        runBalance,
+	"testgen":        runTestgen,
+	"docgen":         runDocgen,
+	"hooks":          runHooks,
+	"mr":             runMR,
+	"triage":         runTriage,
+	"scan-report":    runScanReport,
+	"logs":           runLogs,
+	"urls":           runURLs,
+	"diff-responses": runDiffResponses,
+	"audit-log":      runAuditLog,
+	"bench":          runBench,
+	"report":         runReport,
+	"each":           runEach,
+}
+
+// addModelFlags registra en un subcomando las opciones comunes del modelo
+func addModelFlags(fs *flag.FlagSet, defaultTemp float64) {
+	fs.Float64Var(&temperature, "t", defaultTemp, "Temperatura para la generación (0.0-2.0)")
+	fs.Float64Var(&temperature, "temperature", defaultTemp, "Temperatura para la generación (0.0-2.0)")
+	fs.IntVar(&maxTokens, "m", defaultMaxTokens, "Máximo número de tokens a generar")
+	fs.IntVar(&maxTokens, "maxtokens", defaultMaxTokens, "Máximo número de tokens a generar")
+	fs.BoolVar(&verbose, "v", false, "Mostrar mensajes detallados de ejecución")
+	fs.BoolVar(&verbose, "verbose", false, "Mostrar mensajes detallados de ejecución")
+	fs.StringVar(&policyOverride, "override-policy", "", "Continuar pese a la política de envío, con la justificación que se registra")
+	fs.Var(&extraHeaders, "header", "Cabecera HTTP adicional para la API: 'Nombre: valor' (repetible)")
+	fs.BoolVar(&offlineMode, "offline", false, "Bloquear cualquier acceso a la red")
+	fs.Var(&requestParams, "param", "Parámetro adicional del cuerpo de la solicitud: clave=valor, con valor JSON (repetible)")
+	fs.BoolVar(&confirmCost, "confirm-cost", false, "Pedir confirmación antes de enviar solicitudes que superen --confirm-threshold")
+	fs.StringVar(&confirmThreshold, "confirm-threshold", "", "Umbral de --confirm-cost: tokens de entrada (20000, 20k) o coste en USD ($0.05)")
+	subcommandFlags = fs
+}
+
+// setupSubcommand valida las opciones comunes, configura el logger y carga
+// la API key antes de ejecutar un subcomando
+func setupSubcommand() {
+	var envApplied []string
+	if subcommandFlags != nil {
+		envApplied = loadEnvDefaults(subcommandFlags)
+	}
+	if !verbose {
+		logger.SetOutput(io.Discard)
+	}
+	for _, v := range envApplied {
+		logger.Printf("Del entorno: %s\n", v)
+	}
+
+	if temperature < 0.0 || temperature > 2.0 {
+		fmt.Fprintf(os.Stderr, "Error: La temperatura debe estar entre 0.0 y 2.0\n")
+		os.Exit(1)
+	}
+	if maxTokens <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: maxTokens debe ser mayor que 0\n")
+		os.Exit(1)
+	}
+
+	if err := loadEnv(); err != nil {
+		statusf("Advertencia: %v\n", err)
+	}
+	loadAPIConfig()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config es el archivo de configuración de deepcli
+// (~/.config/deepcli/config.yaml, o la ruta de DEEPCLI_CONFIG)
+type Config struct {
+	Providers map[string]ProviderConfig `yaml:"providers"`
+	Failover  []string                  `yaml:"failover"`
+
+	// Comando que imprime la API key de DeepSeek (vault, pass, etc.)
+	KeyCommand string `yaml:"key_command"`
+
+	// Precios por proveedor y modelo; sobrescriben la tabla incluida
+	Pricing map[string]map[string]Price `yaml:"pricing"`
+
+	// Saldo de DeepSeek por debajo del cual se avisa tras cada respuesta
+	BalanceWarning float64 `yaml:"balance_warning"`
+
+	// Alias compartidos: nombre -> argumentos (ver "deepcli alias")
+	Aliases map[string]string `yaml:"aliases"`
+
+	// Ruta de la política de envío (default: policy.yaml junto a config.yaml)
+	Policy string `yaml:"policy"`
+
+	// Registro de auditoría encadenado (ver "deepcli audit-log")
+	AuditLog string `yaml:"audit_log"`
+
+	// Presupuestos de gasto por API key y por proyecto (ver "deepcli usage")
+	Budgets BudgetsConfig `yaml:"budgets"`
+
+	// Umbral de --confirm-cost: tokens de entrada (20000, 20k) o coste en
+	// USD ($0.05)
+	ConfirmThreshold string `yaml:"confirm_threshold"`
+
+	// Conjuntos de contexto con nombre para --context: nombre -> rutas o
+	// globs (** abarca directorios)
+	Contexts map[string][]string `yaml:"contexts"`
+
+	// Ventana de contexto en tokens por modelo; sobrescribe la tabla
+	// incluida y la que anuncia el proveedor
+	ContextWindows map[string]int `yaml:"context_windows"`
+}
+
+// ProviderConfig define un proveedor con nombre; puede partir de un preset
+// y sobrescribir sus campos
+type ProviderConfig struct {
+	Preset     string            `yaml:"preset"`
+	BaseURL    string            `yaml:"base_url"`
+	APIKeyEnv  string            `yaml:"api_key_env"`
+	KeyCommand string            `yaml:"key_command"`
+	AuthHeader string            `yaml:"auth_header"`
+	AuthPrefix *string           `yaml:"auth_prefix"`
+	Query      string            `yaml:"query"`
+	Model      string            `yaml:"model"`
+	Models     map[string]string `yaml:"models"`
+	Auth       *AuthConfig       `yaml:"auth"`
+	Vision     bool              `yaml:"vision"`
+	Headers    map[string]string `yaml:"headers"`
+}
+
+var config *Config
+
+func configFile() string {
+	if path := os.Getenv("DEEPCLI_CONFIG"); path != "" {
+		return path
+	}
+	return filepath.Join(configDir(), "config.yaml")
+}
+
+// loadConfig lee el archivo de configuración una sola vez; si no existe se
+// usa una configuración vacía
+func loadConfig() (*Config, error) {
+	if config != nil {
+		return config, nil
+	}
+	config = &Config{}
+	data, err := os.ReadFile(configFile())
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("no se pudo leer %s: %v", configFile(), err)
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return config, fmt.Errorf("%s inválido: %v", configFile(), err)
+	}
+	logger.Printf("Configuración cargada de %s\n", configFile())
+	return config, nil
+}
+
+// resolveSecret obtiene una API key de la variable de entorno, del archivo
+// indicado en <VARIABLE>_FILE (secretos de Kubernetes, Docker, etc.) o de la
+// salida de keyCommand, por este orden; devuelve "" si no hay ninguna
+func resolveSecret(envName, keyCommand string) (string, error) {
+	if v := os.Getenv(envName); v != "" {
+		return v, nil
+	}
+	if path := os.Getenv(envName + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("no se pudo leer %s_FILE: %v", envName, err)
+		}
+		logger.Printf("API key leída de %s\n", path)
+		return strings.TrimSpace(string(data)), nil
+	}
+	if keyCommand != "" {
+		cmd := shellCommand(keyCommand)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("key_command falló: %v: %s", err, msg)
+			}
+			return "", fmt.Errorf("key_command falló: %v", err)
+		}
+		logger.Println("API key obtenida con key_command")
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", nil
+}
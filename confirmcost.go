@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Umbral de --confirm-cost si no se indica otro: unos 20K tokens de entrada
+const defaultConfirmThreshold = "20000"
+
+var (
+	// confirmCost (--confirm-cost) pide confirmación antes de enviar una
+	// solicitud cuya estimación supera confirmThreshold
+	confirmCost      bool
+	confirmThreshold string
+
+	// confirmed es la mayor estimación ya aceptada en esta ejecución: las
+	// solicitudes siguientes (otro turno, otra parte) solo se vuelven a
+	// confirmar si la superan
+	confirmMu sync.Mutex
+	confirmed int
+)
+
+// costThreshold es el umbral de --confirm-threshold: tokens de entrada
+// (20000, 20k) o coste en USD ($0.05)
+type costThreshold struct {
+	tokens int
+	usd    float64
+}
+
+func parseCostThreshold(s string) (costThreshold, error) {
+	s = strings.TrimSpace(s)
+	if v, ok := strings.CutPrefix(s, "$"); ok {
+		usd, err := strconv.ParseFloat(v, 64)
+		if err != nil || usd <= 0 {
+			return costThreshold{}, fmt.Errorf("--confirm-threshold: coste no válido %q", s)
+		}
+		return costThreshold{usd: usd}, nil
+	}
+	mult := 1
+	lower := strings.ToLower(s)
+	if v, ok := strings.CutSuffix(lower, "k"); ok {
+		lower, mult = v, 1000
+	}
+	n, err := strconv.Atoi(lower)
+	if err != nil || n <= 0 {
+		return costThreshold{}, fmt.Errorf("--confirm-threshold espera tokens (20000, 20k) o un coste en USD ($0.05), no %q", s)
+	}
+	return costThreshold{tokens: n * mult}, nil
+}
+
+// resolveConfirmThreshold devuelve el umbral: el del flag, el de
+// confirm_threshold en config.yaml o el predeterminado
+func resolveConfirmThreshold() (costThreshold, error) {
+	spec := confirmThreshold
+	if spec == "" {
+		if cfg, err := loadConfig(); err == nil && cfg.ConfirmThreshold != "" {
+			spec = cfg.ConfirmThreshold
+		}
+	}
+	return parseCostThreshold(orDefault(spec, defaultConfirmThreshold))
+}
+
+// checkCostConfirmation muestra la estimación de tokens y coste de la
+// solicitud y pide confirmación si supera el umbral. El coste proyectado
+// incluye la respuesta completa (max_tokens), que es el peor caso.
+func checkCostConfirmation(requestBody RequestBody) error {
+	if !confirmCost {
+		return nil
+	}
+	threshold, err := resolveConfirmThreshold()
+	if err != nil {
+		return err
+	}
+	tokens := requestTokens(requestBody)
+	modelName := providerModel(requestBody.Model)
+	maxCost, priced := estimateCost(activeProvider, modelName, Usage{PromptTokens: tokens, CompletionTokens: requestBody.MaxTokens})
+	inputCost, _ := estimateCost(activeProvider, modelName, Usage{PromptTokens: tokens})
+
+	exceeds := tokens > threshold.tokens
+	if threshold.usd > 0 {
+		// Sin precio conocido no se puede comparar: se pregunta por si acaso
+		exceeds = !priced || maxCost > threshold.usd
+	}
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+	if !exceeds || tokens <= confirmed {
+		return nil
+	}
+
+	estimate := fmt.Sprintf("~%d tokens de entrada para %s", tokens, modelName)
+	if priced {
+		estimate += fmt.Sprintf("; coste estimado $%.4f (hasta $%.4f con %d tokens de respuesta)", inputCost, maxCost, requestBody.MaxTokens)
+	} else {
+		estimate += "; precio del modelo desconocido (sección pricing de config.yaml)"
+	}
+	fmt.Fprintf(os.Stderr, "La solicitud supera el umbral de --confirm-cost: %s\n", estimate)
+	ok, err := confirm("¿Enviar la solicitud?")
+	if err != nil {
+		return fmt.Errorf("--confirm-cost: %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("solicitud cancelada (--confirm-cost)")
+	}
+	confirmed = tokens
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// contextSets son los conjuntos de contexto de --context (repetible)
+var contextSets stringList
+
+// Directorios que no se recorren al expandir los globs de un conjunto
+var skipContextDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, ".venv": true, "__pycache__": true}
+
+// namedContext busca un conjunto de contexto: el .deepcli.yaml del proyecto
+// tiene prioridad sobre config.yaml. Devuelve sus patrones y el directorio
+// respecto al que se interpretan (el del proyecto, o "" para el actual).
+func namedContext(name string) ([]string, string, bool) {
+	if p := currentProject(); p != nil {
+		if patterns, ok := p.Contexts[name]; ok {
+			return patterns, p.dir, true
+		}
+	}
+	if cfg, err := loadConfig(); err == nil {
+		if patterns, ok := cfg.Contexts[name]; ok {
+			return patterns, "", true
+		}
+	}
+	return nil, "", false
+}
+
+// contextSetNames lista los conjuntos definidos, para los mensajes de error
+func contextSetNames() []string {
+	seen := map[string]bool{}
+	if p := currentProject(); p != nil {
+		for name := range p.Contexts {
+			seen[name] = true
+		}
+	}
+	if cfg, err := loadConfig(); err == nil {
+		for name := range cfg.Contexts {
+			seen[name] = true
+		}
+	}
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandContextSets convierte los conjuntos de --context en la lista de
+// archivos para -f, sin repetidos y en orden
+func expandContextSets(names []string) ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		patterns, base, ok := namedContext(name)
+		if !ok {
+			defined := "no hay ninguno definido (sección contexts de config.yaml o .deepcli.yaml)"
+			if names := contextSetNames(); len(names) > 0 {
+				defined = "definidos: " + strings.Join(names, ", ")
+			}
+			return nil, fmt.Errorf("--context: conjunto desconocido %q; %s", name, defined)
+		}
+		for _, pattern := range patterns {
+			matches, err := expandContextPattern(pattern, base)
+			if err != nil {
+				return nil, fmt.Errorf("--context %s: %v", name, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("--context %s: ningún archivo coincide con %q", name, pattern)
+			}
+			for _, m := range matches {
+				if !seen[m] {
+					seen[m] = true
+					files = append(files, m)
+				}
+			}
+		}
+		logger.Printf("Conjunto de contexto %s: %d archivos\n", name, len(files))
+	}
+	return files, nil
+}
+
+// expandContextPattern expande un patrón del conjunto: una ruta (con rango
+// de líneas opcional, como en -f) o un glob en el que ** abarca directorios.
+// Las rutas relativas se interpretan respecto a base (o al directorio
+// actual) y se devuelven respecto al directorio actual.
+func expandContextPattern(pattern, base string) ([]string, error) {
+	pattern = expandHome(pattern)
+	if !filepath.IsAbs(pattern) {
+		if base == "" {
+			base, _ = os.Getwd()
+		}
+		pattern = filepath.Join(base, pattern)
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{relativeToCwd(pattern)}, nil
+	}
+
+	// Se recorre desde el directorio anterior al primer comodín
+	root := pattern[:strings.IndexAny(pattern, "*?[")]
+	root = root[:strings.LastIndexAny(root, `/\`)+1]
+	var matches []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && skipContextDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if globMatch(pattern, p) {
+			matches = append(matches, relativeToCwd(p))
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// relativeToCwd acorta la ruta respecto al directorio actual si está dentro
+func relativeToCwd(p string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return p
+	}
+	if rel, err := filepath.Rel(cwd, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return p
+}
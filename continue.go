@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// withAssistantPrefix devuelve los mensajes sin el prefijo del asistente
+// que pudiera haber, añadiendo en su lugar uno con el texto indicado
+func withAssistantPrefix(messages []Message, prefix string) []Message {
+	if n := len(messages); n > 0 && messages[n-1].Prefix {
+		messages = messages[:n-1]
+	}
+	return append(append([]Message{}, messages...), Message{
+		Role:    "assistant",
+		Content: prefix,
+		Prefix:  true,
+	})
+}
+
+// continueResponse pide continuaciones mientras la respuesta termine por
+// max_tokens, usando lo ya generado como prefijo del asistente para que las
+// partes se unan sin costuras. Acumula contenido, logprobs y uso en response.
+func continueResponse(requestBody RequestBody, response *ResponseBody, max int) error {
+	choice := &response.Choices[0]
+	for i := 1; i <= max && choice.FinishReason == "length"; i++ {
+		statusf("Respuesta cortada por max_tokens; continuando (%d/%d)...\n", i, max)
+
+		body := requestBody
+		body.N = 0
+		body.Messages = withAssistantPrefix(requestBody.Messages, prefill+choice.Message.Content)
+
+		data, statusCode, err := sendRequest(chatEndpoint(true), body)
+		if err != nil {
+			return err
+		}
+		logger.Printf("Continuación %d recibida, código de estado: %d\n", i, statusCode)
+
+		var part ResponseBody
+		if err := json.Unmarshal(data, &part); err != nil {
+			return fmt.Errorf("no se pudo parsear la continuación: %v", err)
+		}
+		if part.Error.Message != "" {
+			return fmt.Errorf("error de la API al continuar: %s", part.Error.Message)
+		}
+		if len(part.Choices) == 0 {
+			return fmt.Errorf("la continuación no contiene ninguna respuesta")
+		}
+
+		next := part.Choices[0]
+		choice.Message.Content += next.Message.Content
+		choice.FinishReason = next.FinishReason
+		if choice.Logprobs != nil && next.Logprobs != nil {
+			choice.Logprobs.Content = append(choice.Logprobs.Content, next.Logprobs.Content...)
+		}
+		response.Usage.add(part.Usage)
+	}
+	if choice.FinishReason == "length" {
+		statusf("Advertencia: la respuesta sigue cortada tras %d continuaciones\n", max)
+	}
+	return nil
+}
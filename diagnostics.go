@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// version se fija al compilar: go build -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// requestInfo son los metadatos (sin contenido) de la última solicitud
+type requestInfo struct {
+	Time        time.Time
+	Endpoint    string
+	Model       string
+	Messages    int
+	BodyBytes   int
+	MaxTokens   int
+	Temperature float64
+	Stream      bool
+}
+
+var lastRequest struct {
+	sync.Mutex
+	info *requestInfo
+}
+
+func recordRequest(endpoint string, body RequestBody, size int) {
+	lastRequest.Lock()
+	defer lastRequest.Unlock()
+	lastRequest.info = &requestInfo{
+		Time:        time.Now(),
+		Endpoint:    redactURL(endpoint),
+		Model:       body.Model,
+		Messages:    len(body.Messages),
+		BodyBytes:   size,
+		MaxTokens:   body.MaxTokens,
+		Temperature: body.Temperature,
+		Stream:      body.Stream,
+	}
+}
+
+var (
+	// Flags cuyo valor es un secreto o contenido del usuario
+	secretFlagRe  = regexp.MustCompile(`(?i)(key|token|secret|password|auth|header)`)
+	contentFlags  = map[string]bool{"i": true, "instruction": true, "prefill": true, "messages-json": true, "system": true, "context-system": true, "context-template": true}
+	secretValueRe = regexp.MustCompile(`(sk-[A-Za-z0-9_-]{8,}|gh[pousr]_[A-Za-z0-9]{16,}|Bearer\s+\S+)`)
+)
+
+// redactURL quita credenciales y parámetros de consulta de una URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<url inválida>"
+	}
+	if u.User != nil {
+		u.User = url.User("oculto")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "oculto"
+	}
+	return u.String()
+}
+
+// redactArgs oculta los valores de flags sensibles y el texto de los prompts
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	pending := ""
+	for i, arg := range args {
+		switch {
+		case pending != "":
+			out[i] = redactValue(pending, arg)
+			pending = ""
+		case i == 0 && subcommands[arg] != nil:
+			out[i] = arg
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			out[i] = fmt.Sprintf("<argumento: %d caracteres>", len(arg))
+		default:
+			name := strings.TrimLeft(arg, "-")
+			if j := strings.IndexByte(name, '='); j >= 0 {
+				out[i] = arg[:len(arg)-len(name)+j+1] + redactValue(name[:j], name[j+1:])
+				continue
+			}
+			out[i] = arg
+			if takesValue(name, args[i+1:]) {
+				pending = name
+			}
+		}
+	}
+	return out
+}
+
+// takesValue indica si el flag consume el siguiente argumento; para flags
+// de subcomandos se supone que sí cuando el siguiente no es otro flag
+func takesValue(name string, rest []string) bool {
+	if f := flag.CommandLine.Lookup(name); f != nil {
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			return false
+		}
+		return true
+	}
+	return len(rest) > 0 && !strings.HasPrefix(rest[0], "-")
+}
+
+func redactValue(flagName, value string) string {
+	switch {
+	case secretFlagRe.MatchString(flagName):
+		return "<oculto>"
+	case contentFlags[flagName]:
+		return fmt.Sprintf("<texto: %d caracteres>", len(value))
+	case strings.Contains(value, "://"):
+		return redactURL(value)
+	}
+	return secretValueRe.ReplaceAllString(value, "<oculto>")
+}
+
+// writeDiagnosticBundle guarda un informe del fallo en un archivo temporal
+func writeDiagnosticBundle(panicValue interface{}, stack []byte) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "deepcli %s - informe de diagnóstico\n", version)
+	fmt.Fprintf(&sb, "Fecha: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&sb, "Argumentos: %s\n", strings.Join(redactArgs(os.Args[1:]), " "))
+	fmt.Fprintf(&sb, "URL base: %s\n", redactURL(apiBaseURL))
+
+	lastRequest.Lock()
+	if info := lastRequest.info; info != nil {
+		fmt.Fprintf(&sb, "\nÚltima solicitud (%s):\n", info.Time.Format(time.RFC3339))
+		fmt.Fprintf(&sb, "  endpoint:    %s\n", info.Endpoint)
+		fmt.Fprintf(&sb, "  modelo:      %s\n", info.Model)
+		fmt.Fprintf(&sb, "  mensajes:    %d (%d bytes)\n", info.Messages, info.BodyBytes)
+		fmt.Fprintf(&sb, "  max_tokens:  %d\n", info.MaxTokens)
+		fmt.Fprintf(&sb, "  temperatura: %.2f\n", info.Temperature)
+		fmt.Fprintf(&sb, "  streaming:   %v\n", info.Stream)
+	} else {
+		sb.WriteString("\nÚltima solicitud: ninguna\n")
+	}
+	lastRequest.Unlock()
+
+	fmt.Fprintf(&sb, "\nPánico: %s\n\n%s", secretValueRe.ReplaceAllString(fmt.Sprint(panicValue), "<oculto>"), stack)
+
+	f, err := os.CreateTemp("", "deepcli-diagnostico-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(sb.String()); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// recoverPanic convierte un pánico en un mensaje claro y un informe de
+// diagnóstico en lugar de la traza cruda
+func recoverPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	fmt.Fprintf(os.Stderr, "Error interno inesperado: %v\n", secretValueRe.ReplaceAllString(fmt.Sprint(r), "<oculto>"))
+	if path, err := writeDiagnosticBundle(r, stack); err == nil {
+		fmt.Fprintf(os.Stderr, "Se guardó un informe de diagnóstico en %s\nAdjúntalo al reportar el problema (no contiene la API key ni el texto de los prompts).\n", path)
+	} else {
+		fmt.Fprintf(os.Stderr, "No se pudo guardar el informe de diagnóstico (%v):\n%s", err, stack)
+	}
+	finish(false, "error interno")
+	os.Exit(2)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffOp es una operación del diff: ' ' igual, '-' eliminada, '+' añadida
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// diffOps calcula la secuencia de operaciones (basada en LCS) que convierte
+// los elementos de x en los de y
+func diffOps(x, y []string) []diffOp {
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			ops = append(ops, diffOp{' ', x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', x[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		ops = append(ops, diffOp{'-', x[i]})
+	}
+	for ; j < len(y); j++ {
+		ops = append(ops, diffOp{'+', y[j]})
+	}
+	return ops
+}
+
+// lineDiff devuelve un diff unificado simple (por líneas, basado en LCS) entre
+// dos textos, con el número de líneas de contexto indicado. Devuelve "" si
+// los textos son iguales.
+func lineDiff(oldName, newName, a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+	ops := diffOps(strings.Split(a, "\n"), strings.Split(b, "\n"))
+
+	// Mostrar solo los cambios con su contexto
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	lastPrinted := -1
+	for k := range ops {
+		near := false
+		for d := -context; d <= context; d++ {
+			if k+d >= 0 && k+d < len(ops) && ops[k+d].kind != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if lastPrinted < 0 || k > lastPrinted+1 {
+			sb.WriteString("@@\n")
+		}
+		fmt.Fprintf(&sb, "%c%s\n", ops[k].kind, ops[k].text)
+		lastPrinted = k
+	}
+	return sb.String()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Tamaño máximo (palabras de un bloque por palabras del otro) de un bloque
+// cambiado que se compara palabra a palabra; los mayores se muestran enteros
+const maxWordDiffCells = 4_000_000
+
+// wordTokenRe divide el texto en palabras y espacios, que se conservan para
+// reconstruirlo tal cual
+var wordTokenRe = regexp.MustCompile(`\s+|\S+`)
+
+// loadResponse obtiene una de las respuestas a comparar: un archivo o
+// sesión[:turno], donde el turno es el número de la respuesta del asistente
+// (la última si se omite). Devuelve también la etiqueta para la cabecera.
+func loadResponse(ref string) (label, content string, err error) {
+	if data, err := os.ReadFile(ref); err == nil {
+		text, _ := decodeText(data)
+		return ref, text, nil
+	}
+	id, turnSpec, hasTurn := strings.Cut(ref, ":")
+	s, err := loadSession(id)
+	if err != nil {
+		return "", "", fmt.Errorf("%s no es un archivo ni una sesión: %v", ref, err)
+	}
+	var answers []string
+	for _, m := range s.Messages {
+		if m.Role == "assistant" {
+			answers = append(answers, m.Content)
+		}
+	}
+	if len(answers) == 0 {
+		return "", "", fmt.Errorf("la sesión %s no tiene respuestas", s.ID)
+	}
+	turn := len(answers)
+	if hasTurn {
+		turn, err = strconv.Atoi(turnSpec)
+		if err != nil || turn < 1 || turn > len(answers) {
+			return "", "", fmt.Errorf("turno no válido en %q: la sesión %s tiene %d respuestas", ref, s.ID, len(answers))
+		}
+	}
+	return fmt.Sprintf("%s:%d (%s)", s.ID, turn, s.Model), answers[turn-1], nil
+}
+
+// responseDiff compara por líneas y, dentro de cada bloque de líneas
+// cambiadas, por palabras: las eliminadas se marcan [-así-] y las añadidas
+// {+así+}, o en rojo y verde con color. Devuelve también las palabras
+// eliminadas y añadidas.
+func responseDiff(a, b string, color bool) (string, int, int) {
+	var sb strings.Builder
+	removed, added := 0, 0
+	var oldBlock, newBlock []string
+	flush := func() {
+		if len(oldBlock) == 0 && len(newBlock) == 0 {
+			return
+		}
+		x := wordTokenRe.FindAllString(strings.Join(oldBlock, "\n"), -1)
+		y := wordTokenRe.FindAllString(strings.Join(newBlock, "\n"), -1)
+		var ops []diffOp
+		if len(x)*len(y) <= maxWordDiffCells {
+			ops = diffOps(x, y)
+		} else {
+			for _, t := range x {
+				ops = append(ops, diffOp{'-', t})
+			}
+			for _, t := range y {
+				ops = append(ops, diffOp{'+', t})
+			}
+		}
+		for k := 0; k < len(ops); {
+			kind := ops[k].kind
+			var run strings.Builder
+			for ; k < len(ops) && ops[k].kind == kind; k++ {
+				run.WriteString(ops[k].text)
+				if strings.TrimSpace(ops[k].text) == "" {
+					continue
+				}
+				switch kind {
+				case '-':
+					removed++
+				case '+':
+					added++
+				}
+			}
+			sb.WriteString(markChange(kind, run.String(), color))
+		}
+		sb.WriteString("\n")
+		oldBlock, newBlock = nil, nil
+	}
+
+	for _, op := range diffOps(strings.Split(a, "\n"), strings.Split(b, "\n")) {
+		switch op.kind {
+		case '-':
+			oldBlock = append(oldBlock, op.text)
+		case '+':
+			newBlock = append(newBlock, op.text)
+		default:
+			flush()
+			sb.WriteString(op.text + "\n")
+		}
+	}
+	flush()
+	return sb.String(), removed, added
+}
+
+// markChange marca un tramo eliminado o añadido; los espacios en los bordes
+// quedan fuera de la marca
+func markChange(kind byte, text string, color bool) string {
+	if kind == ' ' || strings.TrimSpace(text) == "" {
+		return text
+	}
+	core := strings.TrimSpace(text)
+	start := strings.Index(text, core)
+	lead, trail := text[:start], text[start+len(core):]
+	switch {
+	case color && kind == '-':
+		core = ansiRed + core + ansiReset
+	case color:
+		core = ansiGreen + core + ansiReset
+	case kind == '-':
+		core = "[-" + core + "-]"
+	default:
+		core = "{+" + core + "+}"
+	}
+	return lead + core + trail
+}
+
+func runDiffResponses(args []string) {
+	fs := flag.NewFlagSet("diff-responses", flag.ExitOnError)
+	lines := fs.Bool("lines", false, "Diff unificado por líneas en lugar de por palabras")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s diff-responses [--lines] <a> <b>
+
+Muestra las diferencias entre dos respuestas del modelo, para comparar
+variantes de prompt o de modelo. Cada respuesta es un archivo (por ejemplo,
+guardado con -o) o sesión[:turno] del historial, donde el turno es el
+número de la respuesta del asistente en la sesión (la última si se omite).
+
+Por defecto el diff es por palabras: lo eliminado se marca [-así-] y lo
+añadido {+así+} (en rojo y verde en un terminal).
+
+Opciones:
+  --lines                       Diff unificado por líneas
+
+Ejemplos:
+  %s diff-responses 20250101-101500-ab12:2 20250101-103000-cd34:2
+  %s diff-responses respuesta-chat.md respuesta-reasoner.md
+`, os.Args[0], os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var labels, contents [2]string
+	for i, ref := range fs.Args() {
+		var err error
+		if labels[i], contents[i], err = loadResponse(ref); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		contents[i] = strings.TrimSpace(contents[i])
+	}
+	if contents[0] == contents[1] {
+		statusf("Las respuestas son idénticas\n")
+		return
+	}
+
+	color := isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	if *lines {
+		fmt.Print(lineDiff(labels[0], labels[1], contents[0], contents[1], 3))
+		return
+	}
+	text, removed, added := responseDiff(contents[0], contents[1], color)
+	fmt.Printf("--- %s\n+++ %s\n\n%s", labels[0], labels[1], text)
+	statusf("\n%d palabras eliminadas, %d añadidas\n", removed, added)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+)
+
+const docgenSystemPrompt = `Eres un experto en documentar código Go. Recibirás un archivo y una lista ` +
+	`de símbolos exportados. Escribe el comentario de documentación de cada uno siguiendo las ` +
+	`convenciones de Go: empieza por el nombre del símbolo, explica qué hace y no cómo, y es breve ` +
+	`(una o dos frases salvo que haga falta más). Usa el idioma de los comentarios que ya tiene el ` +
+	`archivo o inglés si no tiene. Responde solo con un objeto JSON {"símbolo": "comentario"} con el ` +
+	`texto de cada comentario sin los // iniciales.`
+
+// docTarget es una declaración exportada a la que se le añade documentación
+type docTarget struct {
+	Symbol string
+	Node   ast.Node          // declaración o, en un grupo, la especificación
+	Doc    *ast.CommentGroup // documentación actual
+}
+
+// docTargets devuelve las declaraciones exportadas del archivo; con all
+// también las que ya están documentadas
+func docTargets(f *ast.File, all bool) []docTarget {
+	var targets []docTarget
+	add := func(symbol string, node ast.Node, doc *ast.CommentGroup) {
+		if all || doc == nil {
+			targets = append(targets, docTarget{symbol, node, doc})
+		}
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			symbol := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverName(d.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				symbol = recv + "." + symbol
+			}
+			add(symbol, d, d.Doc)
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			// Una declaración sin paréntesis se documenta entera; en un grupo,
+			// cada especificación
+			grouped := d.Lparen.IsValid()
+			for _, spec := range d.Specs {
+				var names []string
+				var doc *ast.CommentGroup
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						names = append(names, s.Name.Name)
+					}
+					doc = s.Doc
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						if n.IsExported() {
+							names = append(names, n.Name)
+						}
+					}
+					doc = s.Doc
+				}
+				if len(names) == 0 {
+					continue
+				}
+				if grouped {
+					add(strings.Join(names, ","), spec, doc)
+				} else {
+					add(strings.Join(names, ","), d, d.Doc)
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// spliceDocs inserta los comentarios antes de cada declaración, con su
+// sangría, sustituyendo la documentación que tuviera
+func spliceDocs(fset *token.FileSet, content string, targets []docTarget, docs map[string]string) (string, int) {
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	for _, t := range targets {
+		text := strings.TrimSpace(docs[t.Symbol])
+		if text == "" {
+			continue
+		}
+		pos := t.Node.Pos()
+		if t.Doc != nil {
+			pos = t.Doc.Pos()
+		}
+		start := fset.Position(pos).Offset
+		start = strings.LastIndex(content[:start], "\n") + 1
+		end := start
+		if t.Doc != nil {
+			end = fset.Position(t.Node.Pos()).Offset
+			end = strings.LastIndex(content[:end], "\n") + 1
+		}
+		line := content[start:]
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+		var sb strings.Builder
+		for _, l := range strings.Split(text, "\n") {
+			l = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(l), "//"), " ")
+			if l == "" {
+				sb.WriteString(indent + "//\n")
+			} else {
+				sb.WriteString(indent + "// " + l + "\n")
+			}
+		}
+		edits = append(edits, edit{start, end, sb.String()})
+	}
+	// De atrás hacia delante para que los desplazamientos sigan siendo válidos
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		content = content[:e.start] + e.text + content[e.end:]
+	}
+	return content, len(edits)
+}
+
+func runDocgen(args []string) {
+	fs := flag.NewFlagSet("docgen", flag.ExitOnError)
+	var source string
+	fs.StringVar(&source, "f", "", "Archivo Go a documentar")
+	fs.StringVar(&source, "file", "", "Archivo Go a documentar")
+	write := fs.Bool("write", false, "Modificar el archivo en lugar de mostrarlo")
+	all := fs.Bool("all", false, "Reescribir también la documentación existente")
+	addModelFlags(fs, 0.2)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s docgen -f <archivo.go> [opciones]
+
+Pide al modelo los comentarios de documentación de los símbolos exportados
+que no los tienen y los inserta en el archivo, cada uno delante de su
+declaración (o de su especificación dentro de un grupo const, var o type).
+Sin --write se muestra el archivo resultante.
+
+Opciones:
+  -f, --file <archivo>   Archivo Go a documentar
+  --write                Modificar el archivo
+  --all                  Reescribir también la documentación existente
+  -t, --temperature      Temperatura (default: 0.2)
+  -m, --maxtokens        Máximo de tokens a generar
+  -v, --verbose          Mostrar logs detallados
+
+Ejemplo:
+  %s docgen -f module.go --write
+`, os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+	if source == "" || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if !strings.HasSuffix(source, ".go") {
+		fmt.Fprintf(os.Stderr, "Error: docgen solo admite archivos Go\n")
+		os.Exit(1)
+	}
+	data, err := readContextFile(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	content := string(data)
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, source, content, parser.ParseComments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	targets := docTargets(f, *all)
+	if len(targets) == 0 {
+		statusf("Todos los símbolos exportados de %s ya están documentados\n", source)
+		if !*write {
+			fmt.Print(content)
+		}
+		return
+	}
+	setupSubcommand()
+
+	symbols := make([]string, len(targets))
+	for i, t := range targets {
+		symbols[i] = t.Symbol
+	}
+	statusf("Documentando %d símbolos de %s...\n", len(symbols), source)
+	requestBody := newRequestBody([]Message{
+		{Role: "system", Content: docgenSystemPrompt},
+		{Role: "user", Content: fmt.Sprintf("Símbolos: %s\n\n%s", strings.Join(symbols, ", "), labelFile(source, content))},
+	})
+	requestBody.ResponseFormat = &ResponseFormat{Type: "json_object"}
+	response, _, err := complete(requestBody)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var docs map[string]string
+	if err := json.Unmarshal([]byte(response), &docs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: el modelo no devolvió JSON válido: %v\n", err)
+		os.Exit(1)
+	}
+	for _, s := range symbols {
+		if strings.TrimSpace(docs[s]) == "" {
+			statusf("Advertencia: no se recibió documentación para %s\n", s)
+		}
+	}
+
+	result, n := spliceDocs(fset, content, targets, docs)
+	formatted, err := format.Source([]byte(result))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: el archivo documentado no es Go válido: %v\n", err)
+		os.Exit(1)
+	}
+	if !*write {
+		fmt.Print(string(formatted))
+		return
+	}
+	if err := os.WriteFile(source, formatted, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	statusf("Añadidos %d comentarios a %s\n", n, source)
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Tamaño máximo de cada XML que se lee del documento, por si el ZIP está
+// manipulado para descomprimirse en algo enorme
+const maxDocumentXML = 64 << 20
+
+// isDocument indica si el archivo es un documento de Word u OpenDocument
+func isDocument(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".docx" || ext == ".odt"
+}
+
+// docPara es un párrafo en construcción
+type docPara struct {
+	text    strings.Builder
+	heading int
+	level   int // profundidad en la lista, 0 si no es un elemento
+	ordered bool
+	// En una lista de ODT solo el primer párrafo del elemento lleva marca
+	continued bool
+}
+
+// docWriter genera el Markdown de un documento: títulos, párrafos, listas
+// y tablas
+type docWriter struct {
+	sb         strings.Builder
+	paras      []*docPara
+	table      [][]string
+	tableDepth int
+	inList     bool
+}
+
+func (w *docWriter) startPara() *docPara {
+	p := &docPara{}
+	w.paras = append(w.paras, p)
+	return p
+}
+
+func (w *docWriter) current() *docPara {
+	if len(w.paras) == 0 {
+		return nil
+	}
+	return w.paras[len(w.paras)-1]
+}
+
+func (w *docWriter) write(s string) {
+	if p := w.current(); p != nil {
+		p.text.WriteString(s)
+	}
+}
+
+// endPara cierra el párrafo en curso: dentro de una tabla se añade a la
+// celda; fuera, se escribe como título, elemento de lista o párrafo
+func (w *docWriter) endPara() {
+	p := w.current()
+	if p == nil {
+		return
+	}
+	w.paras = w.paras[:len(w.paras)-1]
+	text := strings.TrimSpace(p.text.String())
+	if w.tableDepth > 0 && len(w.table) > 0 {
+		row := w.table[len(w.table)-1]
+		if len(row) > 0 && text != "" {
+			if row[len(row)-1] != "" {
+				row[len(row)-1] += " "
+			}
+			row[len(row)-1] += text
+		}
+		return
+	}
+	if text == "" {
+		return
+	}
+	switch {
+	case p.heading > 0:
+		w.separate(false)
+		w.sb.WriteString(strings.Repeat("#", min(p.heading, 6)) + " " + text + "\n")
+	case p.level > 0:
+		w.separate(true)
+		indent := strings.Repeat("  ", p.level-1)
+		marker := "- "
+		if p.ordered {
+			marker = "1. "
+		}
+		if p.continued {
+			marker = strings.Repeat(" ", len(marker))
+		}
+		w.sb.WriteString(indent + marker + strings.ReplaceAll(text, "\n", "\n"+indent+"  ") + "\n")
+	default:
+		w.separate(false)
+		w.sb.WriteString(text + "\n")
+	}
+}
+
+// separate deja una línea en blanco entre bloques, salvo entre elementos
+// de la misma lista
+func (w *docWriter) separate(listItem bool) {
+	if w.sb.Len() > 0 && !(listItem && w.inList) {
+		w.sb.WriteString("\n")
+	}
+	w.inList = listItem
+}
+
+func (w *docWriter) startTable() {
+	w.tableDepth++
+	if w.tableDepth == 1 {
+		w.table = nil
+	}
+}
+
+func (w *docWriter) startRow() {
+	if w.tableDepth == 1 {
+		w.table = append(w.table, nil)
+	}
+}
+
+func (w *docWriter) startCell() {
+	if w.tableDepth == 1 && len(w.table) > 0 {
+		w.table[len(w.table)-1] = append(w.table[len(w.table)-1], "")
+	}
+}
+
+// endTable escribe la tabla en Markdown
+func (w *docWriter) endTable() {
+	w.tableDepth--
+	if w.tableDepth > 0 {
+		return
+	}
+	if table := markdownTable(w.table); table != "" {
+		w.separate(false)
+		w.sb.WriteString(table)
+	}
+	w.table = nil
+}
+
+// markdownTable escribe las filas como tabla de Markdown; la primera fila
+// hace de cabecera
+func markdownTable(rows [][]string) string {
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	if cols == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for i, row := range rows {
+		cells := make([]string, cols)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = strings.ReplaceAll(strings.ReplaceAll(row[j], "|", `\|`), "\n", " ")
+			}
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		if i == 0 {
+			sb.WriteString(strings.Repeat("| --- ", cols) + "|\n")
+		}
+	}
+	return sb.String()
+}
+
+// readZipXML lee un archivo del documento; nil si no existe
+func readZipXML(archive *zip.Reader, name string) ([]byte, error) {
+	f, err := archive.Open(name)
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxDocumentXML+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDocumentXML {
+		return nil, fmt.Errorf("%s supera %d MB", name, maxDocumentXML>>20)
+	}
+	return data, nil
+}
+
+// attr devuelve el valor del atributo por su nombre local
+func attr(se xml.StartElement, local string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+var headingStyleRe = regexp.MustCompile(`(?i)^(heading|título|titulo|titre|überschrift)\s*(\d)$`)
+
+// docxHeadingStyles asigna a cada estilo de párrafo de styles.xml su nivel
+// de título, por su nivel de esquema o por su nombre
+func docxHeadingStyles(data []byte) map[string]int {
+	levels := map[string]int{}
+	if data == nil {
+		return levels
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var id string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return levels
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "style":
+			id = attr(se, "styleId")
+		case "name":
+			name := attr(se, "val")
+			if m := headingStyleRe.FindStringSubmatch(name); m != nil {
+				levels[id], _ = strconv.Atoi(m[2])
+			} else if strings.EqualFold(name, "title") {
+				levels[id] = 1
+			}
+		case "outlineLvl":
+			if n, err := strconv.Atoi(attr(se, "val")); err == nil && n < 9 && id != "" {
+				levels[id] = n + 1
+			}
+		}
+	}
+}
+
+// docxOrderedLists indica qué niveles de cada lista de numbering.xml son
+// numerados (el resto son viñetas)
+func docxOrderedLists(data []byte) map[string]map[string]bool {
+	abstract := map[string]map[string]bool{}
+	lists := map[string]map[string]bool{}
+	if data == nil {
+		return lists
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var abstractID, level, numID string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return lists
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "abstractNum":
+			abstractID, numID = attr(se, "abstractNumId"), ""
+			abstract[abstractID] = map[string]bool{}
+		case "lvl":
+			level = attr(se, "ilvl")
+		case "numFmt":
+			if numID == "" && abstract[abstractID] != nil {
+				format := attr(se, "val")
+				abstract[abstractID][level] = format != "bullet" && format != "none"
+			}
+		case "num":
+			numID = attr(se, "numId")
+		case "abstractNumId":
+			if numID != "" {
+				lists[numID] = abstract[attr(se, "val")]
+			}
+		}
+	}
+}
+
+// docxToMarkdown convierte word/document.xml a Markdown
+func docxToMarkdown(archive *zip.Reader) (string, error) {
+	data, err := readZipXML(archive, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		return "", fmt.Errorf("no contiene word/document.xml")
+	}
+	styles, err := readZipXML(archive, "word/styles.xml")
+	if err != nil {
+		return "", err
+	}
+	numbering, err := readZipXML(archive, "word/numbering.xml")
+	if err != nil {
+		return "", err
+	}
+	headings := docxHeadingStyles(styles)
+	ordered := docxOrderedLists(numbering)
+
+	var w docWriter
+	var numID, ilvl string
+	inText := false
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			p := w.current()
+			switch t.Name.Local {
+			case "p":
+				w.startPara()
+				numID, ilvl = "", "0"
+			case "pStyle":
+				if p != nil {
+					p.heading = headings[attr(t, "val")]
+				}
+			case "outlineLvl":
+				if n, err := strconv.Atoi(attr(t, "val")); p != nil && err == nil && n < 9 {
+					p.heading = n + 1
+				}
+			case "ilvl", "numId":
+				if t.Name.Local == "ilvl" {
+					ilvl = attr(t, "val")
+				} else {
+					numID = attr(t, "val")
+				}
+				// numId 0 quita la numeración heredada del estilo
+				if n, err := strconv.Atoi(ilvl); p != nil && err == nil && numID != "0" {
+					p.level = n + 1
+					p.ordered = ordered[numID][ilvl]
+				}
+			case "t":
+				inText = true
+			case "tab":
+				w.write("\t")
+			case "br", "cr":
+				w.write("\n")
+			case "tbl":
+				w.startTable()
+			case "tr":
+				w.startRow()
+			case "tc":
+				w.startCell()
+			case "Fallback":
+				// Los cuadros de texto se repiten en la versión alternativa
+				if err := dec.Skip(); err != nil {
+					return "", err
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				w.endPara()
+			case "t":
+				inText = false
+			case "tbl":
+				w.endTable()
+			}
+		case xml.CharData:
+			if inText {
+				w.write(string(t))
+			}
+		}
+	}
+	return w.sb.String(), nil
+}
+
+// odtOrderedLists indica qué niveles de cada estilo de lista son numerados
+func odtOrderedLists(lists map[string]map[int]bool, data []byte) {
+	if data == nil {
+		return
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var name string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "list-style":
+			name = attr(se, "name")
+			lists[name] = map[int]bool{}
+		case "list-level-style-number", "list-level-style-bullet":
+			if level, err := strconv.Atoi(attr(se, "level")); err == nil && lists[name] != nil {
+				lists[name][level] = se.Name.Local == "list-level-style-number"
+			}
+		}
+	}
+}
+
+// odtToMarkdown convierte content.xml de un documento OpenDocument a Markdown
+func odtToMarkdown(archive *zip.Reader) (string, error) {
+	data, err := readZipXML(archive, "content.xml")
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		return "", fmt.Errorf("no contiene content.xml")
+	}
+	styles, err := readZipXML(archive, "styles.xml")
+	if err != nil {
+		return "", err
+	}
+	ordered := map[string]map[int]bool{}
+	odtOrderedLists(ordered, styles)
+	odtOrderedLists(ordered, data)
+
+	var w docWriter
+	// Estilo de cada lista abierta; las anidadas heredan el de la exterior
+	var lists []string
+	itemStarted := false
+	inBody := false
+	notes := 0
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "body" {
+				inBody = true
+			}
+			if !inBody {
+				continue
+			}
+			switch t.Name.Local {
+			case "h", "p":
+				if notes > 0 {
+					continue
+				}
+				p := w.startPara()
+				if t.Name.Local == "h" {
+					p.heading = 1
+					if n, err := strconv.Atoi(attr(t, "outline-level")); err == nil {
+						p.heading = n
+					}
+				}
+				if len(lists) > 0 && w.tableDepth == 0 {
+					p.level = len(lists)
+					p.ordered = ordered[lists[0]][len(lists)]
+					p.continued = itemStarted
+					itemStarted = true
+				}
+			case "list":
+				style := attr(t, "style-name")
+				if style == "" && len(lists) > 0 {
+					style = lists[0]
+				}
+				lists = append(lists, style)
+			case "list-item", "list-header":
+				itemStarted = false
+			case "s":
+				n, err := strconv.Atoi(attr(t, "c"))
+				if err != nil {
+					n = 1
+				}
+				w.write(strings.Repeat(" ", n))
+			case "tab":
+				w.write("\t")
+			case "line-break":
+				w.write("\n")
+			case "table":
+				w.startTable()
+			case "table-row":
+				w.startRow()
+			case "table-cell", "covered-table-cell":
+				w.startCell()
+			case "note-body":
+				// Las notas al pie se incluyen en línea en su párrafo
+				notes++
+				w.write(" [nota: ")
+			case "annotation", "note-citation", "tracked-changes":
+				if err := dec.Skip(); err != nil {
+					return "", err
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "h", "p":
+				if notes == 0 {
+					w.endPara()
+				}
+			case "note-body":
+				notes--
+				w.write("]")
+			case "list":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+			case "table":
+				w.endTable()
+			}
+		case xml.CharData:
+			w.write(string(t))
+		}
+	}
+	return w.sb.String(), nil
+}
+
+// extractDocument convierte un documento DOCX u ODT a Markdown, conservando
+// títulos, listas y tablas
+func extractDocument(name string, data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("%s: no es un documento válido: %v", name, err)
+	}
+	format := strings.ToUpper(strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), "."))
+	var text string
+	if format == "DOCX" {
+		text, err = docxToMarkdown(archive)
+	} else {
+		text, err = odtToMarkdown(archive)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("%s: el documento no contiene texto", name)
+	}
+	logger.Printf("%s: documento %s convertido a Markdown (~%d tokens)\n", name, format, estimateTokens(text))
+	return fmt.Sprintf("Documento %s %s (convertido a Markdown):\n\n%s", format, name, text), nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eachResult es el resultado de la instrucción sobre un archivo
+type eachResult struct {
+	File     string
+	Output   string
+	Content  string
+	Err      error
+	Duration time.Duration
+}
+
+// eachOutputPath es la ruta de salida de un archivo de entrada: la misma
+// ruta relativa dentro de outDir, con la extensión indicada ("same" conserva
+// la del archivo). Las rutas absolutas o que salen del directorio actual
+// pierden la raíz y los ".." iniciales, para no escribir fuera de outDir.
+func eachOutputPath(outDir, file, ext string) string {
+	rel := filepath.Clean(file)
+	if filepath.IsAbs(rel) {
+		rel = strings.TrimLeft(strings.TrimPrefix(rel, filepath.VolumeName(rel)), `/\`)
+	}
+	for rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = strings.TrimPrefix(strings.TrimPrefix(rel, ".."), string(filepath.Separator))
+	}
+	if ext != "same" {
+		rel += "." + strings.TrimPrefix(ext, ".")
+	}
+	return filepath.Join(outDir, rel)
+}
+
+// eachFile aplica la instrucción a un archivo y escribe la respuesta en
+// su ruta de salida. Con la extensión del propio archivo se escribe solo el
+// código de la respuesta.
+func eachFile(file, instruction, outDir, ext string) (result eachResult) {
+	result.File = file
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	data, err := readContextFile(file)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	content, err := prepareInput(file, data)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	prompt := instruction
+	if ext == "same" {
+		prompt += codeOnlyInstruction
+	}
+	output, _, err := complete(newRequestBody(buildMessages(prompt, labelFile(file, content), "")))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if ext == "same" {
+		if blocks := extractCodeBlocks(output); len(blocks) > 0 {
+			output = blocks[0]
+		}
+	}
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+
+	result.Output = eachOutputPath(outDir, file, ext)
+	if err := os.MkdirAll(filepath.Dir(result.Output), 0755); err != nil {
+		result.Err = err
+		return result
+	}
+	if err := writeOutputFile(result.Output, []byte(output), false, false); err != nil {
+		result.Err = err
+	}
+	result.Content = output
+	return result
+}
+
+func runEach(args []string) {
+	fs := flag.NewFlagSet("each", flag.ExitOnError)
+	instruction := fs.String("i", "", "Instrucción que se aplica a cada archivo")
+	fs.StringVar(instruction, "instruction", "", "Instrucción que se aplica a cada archivo")
+	var globs stringList
+	fs.Var(&globs, "glob", "Archivos a procesar (glob con **, repetible)")
+	outDir := fs.String("out-dir", "", "Directorio de las salidas, una por archivo")
+	ext := fs.String("ext", "md", "Extensión de las salidas; same conserva la del archivo y guarda solo el código")
+	merge := fs.String("merge", "", "Combinar los resultados en un artefacto: summarize, concat o json-array")
+	mergeOut := fs.String("merge-out", "", "Archivo del artefacto de --merge (default: merged.md o merged.json en --out-dir)")
+	concurrency := fs.Int("c", 4, "Archivos procesados en paralelo")
+	fs.IntVar(concurrency, "concurrency", 4, "Archivos procesados en paralelo")
+	addModelFlags(fs, 0.2)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s each -i <instrucción> --glob <patrón> --out-dir <dir> [archivos...] [opciones]
+
+Aplica la misma instrucción a cada archivo por separado, en paralelo, y
+escribe una salida por archivo con su misma ruta relativa dentro de
+--out-dir (src/app.py -> results/src/app.py.md). Al terminar resume los
+archivos correctos y los fallidos; termina con código 1 si alguno falla.
+
+Opciones:
+  -i, --instruction <texto>   Instrucción para cada archivo
+  --glob <patrón>             Archivos a procesar; ** abarca directorios
+                              (repetible; también se aceptan rutas sueltas)
+  --out-dir <dir>             Directorio de las salidas
+  --ext <ext>                 Extensión de las salidas (default: md); con
+                              same se conserva la del archivo y se guarda
+                              solo el código de la respuesta
+  --merge <modo>              Combinar los resultados en un artefacto final:
+                                summarize   resumen ejecutivo del modelo
+                                            con enlaces a cada salida
+                                concat      todas las salidas, una sección
+                                            por archivo
+                                json-array  [{file, output_file, content,
+                                            error}]
+  --merge-out <archivo>       Archivo del artefacto (default: merged.md o
+                              merged.json en --out-dir)
+  -c, --concurrency <n>       Archivos en paralelo (default: 4)
+  -t, --temperature           Temperatura (default: 0.2)
+  -m, --maxtokens             Máximo de tokens por respuesta
+  -v, --verbose               Mostrar logs detallados
+
+Ejemplos:
+  %s each -i "añade docstrings" --glob 'src/**/*.py' --out-dir results/ --ext same
+  %s each -i "busca vulnerabilidades" --glob 'src/**/*.js' --out-dir audit --merge summarize
+`, os.Args[0], os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+	if *instruction == "" || *outDir == "" || (len(globs) == 0 && fs.NArg() == 0) {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+	if *merge != "" && !slices.Contains(eachMergeModes, *merge) {
+		fmt.Fprintf(os.Stderr, "Error: --merge debe ser summarize, concat o json-array, no %q\n", *merge)
+		os.Exit(1)
+	}
+	if *mergeOut != "" && *merge == "" {
+		fmt.Fprintf(os.Stderr, "Error: --merge-out solo tiene sentido junto con --merge\n")
+		os.Exit(1)
+	}
+
+	var files []string
+	seen := map[string]bool{}
+	for _, pattern := range globs {
+		matches, err := expandContextPattern(pattern, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(matches) == 0 {
+			statusf("Advertencia: ningún archivo coincide con %q\n", pattern)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	for _, f := range fs.Args() {
+		if !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no hay archivos que procesar\n")
+		os.Exit(1)
+	}
+
+	// Dos entradas con la misma ruta de salida se sobrescribirían
+	outputs := map[string]string{}
+	for _, f := range files {
+		out := eachOutputPath(*outDir, f, *ext)
+		if prev, ok := outputs[out]; ok {
+			fmt.Fprintf(os.Stderr, "Error: %s y %s tendrían la misma salida (%s)\n", prev, f, out)
+			os.Exit(1)
+		}
+		outputs[out] = f
+	}
+
+	setupSubcommand()
+
+	statusf("Procesando %d archivos (%d en paralelo)...\n", len(files), *concurrency)
+	results := make([]eachResult, len(files))
+	sem := make(chan struct{}, *concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i, f := range files {
+		wg.Add(1)
+		go func(i int, f string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			logger.Printf("Procesando %s...\n", f)
+			results[i] = eachFile(f, *instruction, *outDir, *ext)
+		}(i, f)
+	}
+	wg.Wait()
+
+	passed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("ERROR %s (%s): %v\n", r.File, r.Duration.Round(time.Millisecond), r.Err)
+			continue
+		}
+		passed++
+		fmt.Printf("OK    %s -> %s (%s)\n", r.File, r.Output, r.Duration.Round(time.Millisecond))
+	}
+	fmt.Printf("\n%d/%d archivos correctos en %s\n", passed, len(results), time.Since(start).Round(time.Millisecond))
+
+	if *merge != "" {
+		path := orDefault(*mergeOut, eachMergePath(*outDir, *merge))
+		if *merge == "summarize" {
+			statusf("Redactando el resumen ejecutivo...\n")
+		}
+		merged, err := mergeEachResults(results, *merge, *instruction, filepath.Dir(path))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al combinar los resultados: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := writeOutputFile(path, []byte(merged), false, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		statusf("Resultados combinados (%s) en %s\n", *merge, path)
+	}
+	if passed != len(results) {
+		os.Exit(1)
+	}
+}
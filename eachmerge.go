@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const eachMergePrompt = `Eres un analista que redacta resúmenes ejecutivos. Recibirás los resultados ` +
+	`de aplicar una misma instrucción a varios archivos, cada uno con su ruta, y la instrucción original. ` +
+	`Escribe en Markdown un resumen ejecutivo conjunto: las conclusiones principales, los hallazgos ` +
+	`repetidos en varios archivos y los más graves o relevantes, citando los archivos por su ruta. ` +
+	`No repitas cada resultado por separado ni inventes datos que no aparezcan en ellos.`
+
+// Modos de --merge
+var eachMergeModes = []string{"summarize", "concat", "json-array"}
+
+// eachMergeItem es un elemento del artefacto de --merge json-array
+type eachMergeItem struct {
+	File    string `json:"file"`
+	Output  string `json:"output_file,omitempty"`
+	Content string `json:"content,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// eachMergePath es la ruta predeterminada del artefacto combinado
+func eachMergePath(outDir, mode string) string {
+	if mode == "json-array" {
+		return filepath.Join(outDir, "merged.json")
+	}
+	return filepath.Join(outDir, "merged.md")
+}
+
+// mergeEachResults combina los resultados por archivo en un único
+// artefacto según el modo de --merge
+func mergeEachResults(results []eachResult, mode, instruction, outDir string) (string, error) {
+	switch mode {
+	case "json-array":
+		items := make([]eachMergeItem, len(results))
+		for i, r := range results {
+			items[i] = eachMergeItem{File: r.File, Output: r.Output, Content: r.Content}
+			if r.Err != nil {
+				items[i] = eachMergeItem{File: r.File, Error: r.Err.Error()}
+			}
+		}
+		data, err := json.MarshalIndent(items, "", "  ")
+		return string(data) + "\n", err
+	case "concat":
+		var sb strings.Builder
+		for i, r := range results {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "## %s\n\n", r.File)
+			if r.Err != nil {
+				fmt.Fprintf(&sb, "_No se pudo procesar: %v_\n", r.Err)
+			} else {
+				sb.WriteString(strings.TrimRight(r.Content, "\n") + "\n")
+			}
+		}
+		return sb.String(), nil
+	}
+
+	// summarize: un resumen ejecutivo del modelo y la lista de salidas
+	var parts []string
+	for _, r := range results {
+		if r.Err == nil {
+			parts = append(parts, fmt.Sprintf("Archivo %s:\n%s", r.File, strings.TrimSpace(r.Content)))
+		}
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("no hay resultados que resumir")
+	}
+	summary, err := summarizeEachParts(parts, instruction)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString("# Resumen ejecutivo\n\n" + summary + "\n\n# Archivos\n\n")
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(&sb, "- %s: _no se pudo procesar: %v_\n", r.File, r.Err)
+			continue
+		}
+		link := r.Output
+		if rel, err := filepath.Rel(outDir, r.Output); err == nil {
+			link = filepath.ToSlash(rel)
+		}
+		fmt.Fprintf(&sb, "- [%s](%s)\n", r.File, link)
+	}
+	return sb.String(), nil
+}
+
+// summarizeEachParts pide el resumen ejecutivo de los resultados. Si no
+// caben en una solicitud se resumen por lotes y después se resumen los
+// resúmenes de los lotes.
+func summarizeEachParts(parts []string, instruction string) (string, error) {
+	budget := contextWindow(model) - maxTokens - estimateTokens(eachMergePrompt+instruction) - contextMargin
+	var batches [][]string
+	var batch []string
+	size := 0
+	for _, p := range parts {
+		tokens := estimateTokens(p)
+		if tokens > budget {
+			// Un resultado que no cabe solo se recorta
+			p = truncateContext(p, budget*4, tokens-budget)
+			tokens = budget
+		}
+		if len(batch) > 0 && size+tokens > budget {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, p)
+		size += tokens
+	}
+	batches = append(batches, batch)
+
+	var summaries []string
+	for i, b := range batches {
+		if len(batches) > 1 {
+			statusf("Resumiendo el lote %d de %d...\n", i+1, len(batches))
+		}
+		content, _, err := complete(newRequestBody([]Message{
+			{Role: "system", Content: eachMergePrompt},
+			{Role: "user", Content: strings.Join(b, "\n\n")},
+			{Role: "user", Content: "Instrucción original: " + instruction},
+		}))
+		if err != nil {
+			return "", err
+		}
+		summaries = append(summaries, strings.TrimSpace(content))
+	}
+	if len(summaries) == 1 {
+		return summaries[0], nil
+	}
+	if len(summaries) >= len(parts) {
+		// Los lotes no reducen el texto (ventana muy pequeña para -m)
+		return strings.Join(summaries, "\n\n"), nil
+	}
+	for i := range summaries {
+		summaries[i] = fmt.Sprintf("Resumen del lote %d:\n%s", i+1, summaries[i])
+	}
+	return summarizeEachParts(summaries, instruction)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Bytes que se muestran en la vista previa hexadecimal de archivos binarios
+const hexPreviewBytes = 512
+
+// decodeText convierte el contenido a UTF-8. Detecta BOM de UTF-8 y UTF-16
+// (LE/BE) y, si no es UTF-8 válido, lo interpreta como Latin-1. Devuelve el
+// nombre de la codificación detectada.
+func decodeText(data []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), "UTF-8 con BOM"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], false), "UTF-16LE"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], true), "UTF-16BE"
+	case utf8.Valid(data):
+		return string(data), "UTF-8"
+	}
+
+	// Latin-1: cada byte es directamente un punto de código
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes), "Latin-1"
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// isBinary indica si el contenido parece binario: bytes NUL (fuera de
+// UTF-16 con BOM) o una proporción alta de caracteres de control
+func isBinary(data []byte) bool {
+	if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+		return false
+	}
+	sample := data
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+	}
+	if len(sample) == 0 {
+		return false
+	}
+	control := 0
+	for _, b := range sample {
+		if b == 0 {
+			return true
+		}
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' && b != '\b' && b != 0x1b {
+			control++
+		}
+	}
+	return control*10 > len(sample)
+}
+
+// prepareInput convierte a texto UTF-8 el contenido de una fuente (archivo o
+// stdin). Los binarios se rechazan o, con --binary hex, se envía una vista
+// previa hexadecimal. Las imágenes se adjuntan o pasan por OCR, de los PDF se
+// extrae el texto y los documentos DOCX y ODT se convierten a Markdown.
+func prepareInput(name string, data []byte) (string, error) {
+	if mime := imageType(name, data); mime != "" {
+		return prepareImage(name, mime, data)
+	}
+	if isPDF(name, data) {
+		return extractPDF(name, data)
+	}
+	if isDocument(name) {
+		return extractDocument(name, data)
+	}
+	if isBinary(data) {
+		if binaryMode != "hex" {
+			return "", fmt.Errorf("%s parece un archivo binario (usa --binary hex para enviar una vista previa hexadecimal)", name)
+		}
+		preview := data
+		if len(preview) > hexPreviewBytes {
+			preview = preview[:hexPreviewBytes]
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Vista previa hexadecimal de %s (%d de %d bytes):\n", name, len(preview), len(data))
+		sb.WriteString(hex.Dump(preview))
+		logger.Printf("%s es binario, enviando vista previa hexadecimal\n", name)
+		return sb.String(), nil
+	}
+
+	text, encoding := decodeText(data)
+	if encoding != "UTF-8" {
+		logger.Printf("%s convertido de %s a UTF-8\n", name, encoding)
+	}
+	// Las tablas y las capturas HTTP (HAR, Burp) se resumen y el HTML se
+	// convierte a Markdown
+	if isTableFile(name) {
+		return summarizeTable(name, text)
+	}
+	if isHTML(name, text) {
+		md := htmlToMarkdown(text, "")
+		if title := htmlTitle(text); title != "" && !strings.HasPrefix(md, "# ") {
+			md = "# " + title + "\n\n" + md
+		}
+		logger.Printf("%s: HTML convertido a Markdown (%d → %d bytes)\n", name, len(text), len(md))
+		return md, nil
+	}
+	if summary, ok, err := summarizeTraffic(name, text); err != nil || ok {
+		return summary, err
+	}
+	return text, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Variables con un nombre más legible que el derivado del flag; el
+// derivado también se acepta
+var envFlagAliases = map[string]string{
+	"maxtokens":      "DEEPCLI_MAX_TOKENS",
+	"context-system": "DEEPCLI_SYSTEM",
+}
+
+// subcommandFlags es el FlagSet del subcomando en curso, para aplicarle los
+// valores del entorno en setupSubcommand
+var subcommandFlags *flag.FlagSet
+
+// flagEnvNames devuelve las variables de entorno de un flag: DEEPCLI_ y el
+// nombre en mayúsculas con _ en lugar de -
+func flagEnvNames(name string) []string {
+	names := []string{"DEEPCLI_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))}
+	if alias, ok := envFlagAliases[name]; ok {
+		names = append([]string{alias}, names...)
+	}
+	return names
+}
+
+// applyEnvDefaults da a los flags que no se indicaron en la línea de
+// comandos el valor de su variable DEEPCLI_*, si existe, como si se hubieran
+// escrito antes que el resto. Los de una letra se omiten (tienen versión
+// larga) y también los que comparten variable con un flag indicado (-t y
+// --temperature). Devuelve los valores aplicados para el modo verboso.
+func applyEnvDefaults(fs *flag.FlagSet) ([]string, error) {
+	set := map[flag.Value]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Value] = true })
+	var applied []string
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || len(f.Name) == 1 || set[f.Value] {
+			return
+		}
+		for _, env := range flagEnvNames(f.Name) {
+			value, ok := os.LookupEnv(env)
+			if !ok {
+				continue
+			}
+			if serr := fs.Set(f.Name, value); serr != nil {
+				err = fmt.Errorf("%s: valor no válido %q para --%s: %v", env, value, f.Name, serr)
+				return
+			}
+			applied = append(applied, fmt.Sprintf("--%s=%s (%s)", f.Name, value, env))
+			set[f.Value] = true
+			return
+		}
+	})
+	return applied, err
+}
+
+// loadEnvDefaults carga .env sin avisar (loadEnv informa después) y aplica
+// las variables DEEPCLI_* a los flags de fs; termina si alguna no es válida
+func loadEnvDefaults(fs *flag.FlagSet) []string {
+	godotenv.Load(envFile)
+	applied, err := applyEnvDefaults(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return applied
+}
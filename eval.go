@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rubric define los criterios con los que se evalúan las respuestas
+type Rubric struct {
+	Task     string      `yaml:"task"`
+	Scale    int         `yaml:"scale"`
+	Criteria []Criterion `yaml:"criteria"`
+}
+
+type Criterion struct {
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description"`
+	Weight      float64 `yaml:"weight"`
+}
+
+type CriterionScore struct {
+	Criterion string  `json:"criterion"`
+	Score     float64 `json:"score"`
+	Reason    string  `json:"reason"`
+}
+
+// EvalResult es la evaluación de un candidato; Total es la media ponderada
+type EvalResult struct {
+	Candidate string           `json:"candidate"`
+	Scores    []CriterionScore `json:"scores"`
+	Total     float64          `json:"total"`
+	Error     string           `json:"error,omitempty"`
+}
+
+const evalSystemPrompt = `Eres un evaluador imparcial y riguroso. Puntúas respuestas según una rúbrica.
+Responde únicamente con un objeto JSON con esta forma:
+{"scores": [{"criterion": "<nombre>", "score": <número>, "reason": "<justificación breve>"}]}`
+
+func loadRubric(path string) (*Rubric, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer la rúbrica: %v", err)
+	}
+	var rubric Rubric
+	if err := yaml.Unmarshal(data, &rubric); err != nil {
+		return nil, fmt.Errorf("rúbrica inválida: %v", err)
+	}
+	if len(rubric.Criteria) == 0 {
+		return nil, fmt.Errorf("la rúbrica no define criterios")
+	}
+	if rubric.Scale <= 0 {
+		rubric.Scale = 10
+	}
+	for i := range rubric.Criteria {
+		if rubric.Criteria[i].Name == "" {
+			return nil, fmt.Errorf("el criterio %d no tiene nombre", i+1)
+		}
+		if rubric.Criteria[i].Weight <= 0 {
+			rubric.Criteria[i].Weight = 1
+		}
+	}
+	return &rubric, nil
+}
+
+// judgePrompt construye la instrucción para el modelo evaluador
+func (r *Rubric) judgePrompt(candidate string) string {
+	var sb strings.Builder
+	if r.Task != "" {
+		fmt.Fprintf(&sb, "Tarea que debía resolver la respuesta:\n%s\n\n", r.Task)
+	}
+	fmt.Fprintf(&sb, "Puntúa la respuesta de 0 a %d en cada criterio:\n", r.Scale)
+	for _, c := range r.Criteria {
+		fmt.Fprintf(&sb, "- %s: %s\n", c.Name, c.Description)
+	}
+	fmt.Fprintf(&sb, "\nRespuesta a evaluar:\n<<<\n%s\n>>>\n", candidate)
+	return sb.String()
+}
+
+// judge evalúa un texto según la rúbrica y calcula la media ponderada
+func (r *Rubric) judge(candidate string) ([]CriterionScore, float64, error) {
+	requestBody := newRequestBody([]Message{
+		{Role: "system", Content: evalSystemPrompt},
+		{Role: "user", Content: r.judgePrompt(candidate)},
+	})
+	requestBody.ResponseFormat = &ResponseFormat{Type: "json_object"}
+
+	content, _, err := complete(requestBody)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var verdict struct {
+		Scores []CriterionScore `json:"scores"`
+	}
+	if err := json.Unmarshal([]byte(content), &verdict); err != nil {
+		return nil, 0, fmt.Errorf("el evaluador no devolvió JSON válido: %v", err)
+	}
+
+	weights := make(map[string]float64)
+	for _, c := range r.Criteria {
+		weights[c.Name] = c.Weight
+	}
+	var sum, totalWeight float64
+	for _, s := range verdict.Scores {
+		w, ok := weights[s.Criterion]
+		if !ok {
+			continue
+		}
+		sum += s.Score * w
+		totalWeight += w
+	}
+	if totalWeight == 0 {
+		return verdict.Scores, 0, fmt.Errorf("el evaluador no puntuó ningún criterio de la rúbrica")
+	}
+	return verdict.Scores, sum / totalWeight, nil
+}
+
+func runEval(args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	rubricFile := fs.String("rubric", "", "Archivo YAML con la rúbrica")
+	firstCandidate := fs.String("candidates", "", "Archivos con las respuestas a evaluar")
+	asJSON := fs.Bool("json", false, "Emitir las puntuaciones como JSON")
+	addModelFlags(fs, 0.0)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s eval --rubric <rúbrica.yaml> --candidates <archivo> [archivo...]
+
+Puntúa cada respuesta candidata según los criterios de la rúbrica usando el
+modelo como evaluador, y muestra las puntuaciones por criterio y la media
+ponderada.
+
+Formato de la rúbrica:
+  task: "Explica qué hace la función"   # opcional
+  scale: 10                             # puntuación máxima (default: 10)
+  criteria:
+    - name: correccion
+      description: La explicación es técnicamente correcta
+      weight: 2
+    - name: claridad
+      description: Es clara y concisa
+
+Opciones:
+  --rubric <archivo>      Rúbrica en YAML (requerido)
+  --candidates <archivos> Respuestas a evaluar (debe ser la última opción)
+  --json                  Emitir las puntuaciones como JSON
+  -t, --temperature       Temperatura del evaluador (default: 0.0)
+  -v, --verbose           Mostrar logs detallados
+`, os.Args[0])
+	}
+	fs.Parse(args)
+
+	var candidates []string
+	if *firstCandidate != "" {
+		candidates = append(candidates, *firstCandidate)
+	}
+	candidates = append(candidates, fs.Args()...)
+
+	if *rubricFile == "" || len(candidates) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	rubric, err := loadRubric(*rubricFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupSubcommand()
+
+	var results []EvalResult
+	failed := false
+	for _, path := range candidates {
+		result := EvalResult{Candidate: path}
+		data, err := readContextFile(path)
+		if err == nil {
+			logger.Printf("Evaluando %s...\n", path)
+			result.Scores, result.Total, err = rubric.judge(string(data))
+		}
+		if err != nil {
+			result.Error = err.Error()
+			failed = true
+		}
+		results = append(results, result)
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printEvalTable(rubric, results)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func printEvalTable(rubric *Rubric, results []EvalResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := []string{"CANDIDATO"}
+	for _, c := range rubric.Criteria {
+		header = append(header, strings.ToUpper(c.Name))
+	}
+	header = append(header, "TOTAL")
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, r := range results {
+		row := []string{r.Candidate}
+		if r.Error != "" {
+			row = append(row, "error: "+r.Error)
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+			continue
+		}
+		scores := make(map[string]float64)
+		for _, s := range r.Scores {
+			scores[s.Criterion] = s.Score
+		}
+		for _, c := range rubric.Criteria {
+			if v, ok := scores[c.Name]; ok {
+				row = append(row, fmt.Sprintf("%g", v))
+			} else {
+				row = append(row, "-")
+			}
+		}
+		row = append(row, fmt.Sprintf("%.2f/%d", r.Total, rubric.Scale))
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
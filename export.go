@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Palabras clave resaltadas en los bloques de código (unión de los
+// lenguajes más habituales)
+var codeKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`break case catch class const continue def default defer do elif else
+		enum except export extends false finally fn for func function go if impl import in interface
+		let loop match mod mut new nil None null package pub raise return self static struct switch
+		this throw true True False try type typeof var void while with yield async await lambda
+		from as select range map chan fallthrough goto use where`) {
+		codeKeywords[k] = true
+	}
+}
+
+var codeToken = regexp.MustCompile(`(?s)(//[^\n]*|#[^\n]*|/\*.*?\*/)|("(?:\\.|[^"\\\n])*"|'(?:\\.|[^'\\\n])*'|` + "`[^`]*`" + `)|\b(\d+(?:\.\d+)?)\b|\b([A-Za-z_]\w*)\b`)
+
+// highlightCode devuelve el código escapado para HTML con clases CSS para
+// comentarios, cadenas, números y palabras clave
+func highlightCode(code, lang string) string {
+	hashComments := map[string]bool{"python": true, "py": true, "bash": true, "sh": true, "shell": true, "ruby": true, "rb": true, "yaml": true, "yml": true, "toml": true, "perl": true, "r": true, "": true}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range codeToken.FindAllStringSubmatchIndex(code, -1) {
+		sb.WriteString(html.EscapeString(code[last:m[0]]))
+		token := code[m[0]:m[1]]
+		class := ""
+		switch {
+		case m[2] >= 0:
+			class = "c"
+			if strings.HasPrefix(token, "#") && !hashComments[lang] {
+				class = ""
+			}
+		case m[4] >= 0:
+			class = "s"
+		case m[6] >= 0:
+			class = "n"
+		case m[8] >= 0 && codeKeywords[token]:
+			class = "k"
+		}
+		if class != "" {
+			fmt.Fprintf(&sb, `<span class="%s">%s</span>`, class, html.EscapeString(token))
+		} else {
+			sb.WriteString(html.EscapeString(token))
+		}
+		last = m[1]
+	}
+	sb.WriteString(html.EscapeString(code[last:]))
+	return sb.String()
+}
+
+var (
+	htmlInlineCode = regexp.MustCompile("`([^`]+)`")
+	htmlBold       = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	htmlItalic     = regexp.MustCompile(`(^|[^*\w])\*([^*\s][^*]*)\*`)
+	htmlLink       = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+)
+
+func inlineHTML(s string) string {
+	s = html.EscapeString(s)
+	s = htmlInlineCode.ReplaceAllString(s, "<code>$1</code>")
+	s = htmlBold.ReplaceAllString(s, "<strong>$1</strong>")
+	s = htmlItalic.ReplaceAllString(s, "$1<em>$2</em>")
+	s = htmlLink.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	return s
+}
+
+// markdownToHTML convierte el Markdown de una respuesta en HTML: párrafos,
+// títulos, listas, citas y bloques de código resaltados
+func markdownToHTML(text string) string {
+	var sb strings.Builder
+	var paragraph []string
+	inList := false
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			sb.WriteString("<p>" + strings.Join(paragraph, "<br>\n") + "</p>\n")
+			paragraph = nil
+		}
+		if inList {
+			sb.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			flush()
+			lang := strings.ToLower(strings.TrimSpace(trimmed[3:]))
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			label := ""
+			if lang != "" {
+				label = `<div class="lang">` + html.EscapeString(lang) + `</div>`
+			}
+			sb.WriteString(`<div class="code">` + label + "<pre><code>" + highlightCode(strings.Join(code, "\n"), lang) + "</code></pre></div>\n")
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flush()
+		case mdHeading.MatchString(trimmed):
+			flush()
+			m := mdHeading.FindStringSubmatch(trimmed)
+			fmt.Fprintf(&sb, "<h%d>%s</h%d>\n", len(m[1])+2, inlineHTML(m[2]), len(m[1])+2)
+		case mdBullet.MatchString(line):
+			if len(paragraph) > 0 {
+				sb.WriteString("<p>" + strings.Join(paragraph, "<br>\n") + "</p>\n")
+				paragraph = nil
+			}
+			if !inList {
+				sb.WriteString("<ul>\n")
+				inList = true
+			}
+			sb.WriteString("<li>" + inlineHTML(mdBullet.FindStringSubmatch(line)[2]) + "</li>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			sb.WriteString("<blockquote>" + inlineHTML(strings.TrimSpace(trimmed[1:])) + "</blockquote>\n")
+		default:
+			if inList {
+				sb.WriteString("</ul>\n")
+				inList = false
+			}
+			paragraph = append(paragraph, inlineHTML(line))
+		}
+	}
+	flush()
+	return sb.String()
+}
+
+const exportCSS = `body{font-family:system-ui,sans-serif;max-width:860px;margin:2em auto;padding:0 1em;color:#222;line-height:1.5}
+header{border-bottom:1px solid #ddd;margin-bottom:1.5em}header p{color:#777;font-size:.9em}
+.msg{margin-bottom:1.8em}.role{font-weight:bold;margin-bottom:.3em}
+.user .role{color:#1a5fd0}.assistant .role{color:#178a3a}.system .role{color:#888}
+.user .body{white-space:pre-wrap;background:#f4f7fd;padding:.6em .9em;border-radius:6px}
+.code{background:#1e1e2e;color:#e0e0e0;border-radius:6px;margin:.8em 0;overflow:hidden}
+.code .lang{font-size:.75em;color:#aaa;padding:.3em .9em;border-bottom:1px solid #333}
+.code pre{margin:0;padding:.8em .9em;overflow-x:auto}code{font-family:ui-monospace,monospace;font-size:.9em}
+p code,li code{background:#eee;padding:.1em .3em;border-radius:3px}
+.k{color:#c792ea}.s{color:#c3e88d}.c{color:#7f848e;font-style:italic}.n{color:#f78c6c}
+blockquote{border-left:3px solid #ccc;margin:0;padding-left:1em;color:#555}`
+
+// sessionToHTML genera un documento HTML autocontenido con la conversación
+func sessionToHTML(s *Session) string {
+	var sb strings.Builder
+	title := s.Title
+	if title == "" {
+		title = s.ID
+	}
+	sb.WriteString("<!DOCTYPE html>\n<html lang=\"es\">\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&sb, "<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", html.EscapeString(title), exportCSS)
+	fmt.Fprintf(&sb, "<header><h1>%s</h1><p>Sesión %s · %s · %s</p></header>\n",
+		html.EscapeString(title), html.EscapeString(s.ID), html.EscapeString(s.Model), s.CreatedAt.Format("2006-01-02 15:04"))
+
+	for i, m := range s.Messages {
+		label := map[string]string{"user": "Usuario", "assistant": "DeepSeek", "system": "Sistema"}[m.Role]
+		if label == "" {
+			label = m.Role
+		}
+		if s.isPartial(i) {
+			label += " (interrumpida)"
+		}
+		fmt.Fprintf(&sb, "<div class=\"msg %s\"><div class=\"role\">%s</div>\n", html.EscapeString(m.Role), label)
+		if m.Role == "assistant" {
+			sb.WriteString("<div class=\"body\">" + markdownToHTML(m.Content) + "</div>")
+		} else {
+			sb.WriteString("<div class=\"body\">" + html.EscapeString(m.Content) + "</div>")
+		}
+		sb.WriteString("</div>\n")
+	}
+	sb.WriteString("</body>\n</html>\n")
+	return sb.String()
+}
+
+// sessionToMarkdown genera la transcripción en Markdown
+func sessionToMarkdown(s *Session) string {
+	var sb strings.Builder
+	title := s.Title
+	if title == "" {
+		title = s.ID
+	}
+	fmt.Fprintf(&sb, "# %s\n\n_Sesión %s · %s · %s_\n\n", title, s.ID, s.Model, s.CreatedAt.Format("2006-01-02 15:04"))
+	for i, m := range s.Messages {
+		role := m.Role
+		if s.isPartial(i) {
+			role += " (interrumpida)"
+		}
+		fmt.Fprintf(&sb, "## %s\n\n%s\n\n", role, strings.TrimSpace(m.Content))
+	}
+	return sb.String()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// aroundSpec es el valor de --around (línea:contexto), que se aplica a los
+// archivos de -f sin rango propio
+var aroundSpec string
+
+// fileRangeRe reconoce el rango de líneas al final de -f: archivo:120-240,
+// archivo:120- o archivo:120
+var fileRangeRe = regexp.MustCompile(`^(.+):(\d+)(-(\d*))?$`)
+
+// lineRange es un rango de líneas (desde 1); To 0 llega hasta el final
+type lineRange struct {
+	From, To int
+}
+
+// parseFileArg separa la ruta y el rango de líneas de un argumento de -f.
+// Si existe un archivo con el nombre literal, no se interpreta el rango.
+func parseFileArg(arg string) (string, *lineRange, error) {
+	m := fileRangeRe.FindStringSubmatch(arg)
+	if m == nil {
+		return arg, nil, nil
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return arg, nil, nil
+	}
+	from, _ := strconv.Atoi(m[2])
+	to := from
+	if m[3] != "" {
+		to, _ = strconv.Atoi(m[4])
+	}
+	if from < 1 || (to != 0 && to < from) {
+		return "", nil, fmt.Errorf("rango de líneas no válido en %q", arg)
+	}
+	return m[1], &lineRange{from, to}, nil
+}
+
+// parseAround interpreta --around línea:contexto
+func parseAround(spec string) (*lineRange, error) {
+	line, context, ok := strings.Cut(spec, ":")
+	center, err1 := strconv.Atoi(line)
+	n, err2 := strconv.Atoi(context)
+	if !ok || err1 != nil || err2 != nil || center < 1 || n < 0 {
+		return nil, fmt.Errorf("--around espera línea:contexto (180:40), no %q", spec)
+	}
+	return &lineRange{max(center-n, 1), center + n}, nil
+}
+
+// sliceLines devuelve las líneas del rango y el rango efectivo, recortado
+// al final del archivo, junto con el total de líneas
+func sliceLines(content string, r lineRange) (string, lineRange, int, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	total := len(lines)
+	if r.From > total {
+		return "", r, total, fmt.Errorf("la línea %d está fuera del archivo, que tiene %d líneas", r.From, total)
+	}
+	if r.To == 0 || r.To > total {
+		r.To = total
+	}
+	return strings.Join(lines[r.From-1:r.To], ""), r, total, nil
+}
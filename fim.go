@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// FIMRequest es el cuerpo de una solicitud de fill-in-the-middle (beta):
+// el modelo genera el texto entre prompt y suffix
+type FIMRequest struct {
+	Model       string   `json:"model"`
+	Prompt      string   `json:"prompt"`
+	Suffix      string   `json:"suffix,omitempty"`
+	MaxTokens   int      `json:"max_tokens"`
+	Temperature float64  `json:"temperature"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// FIMResponse es la respuesta del endpoint de completions
+type FIMResponse struct {
+	Choices []struct {
+		Text         string `json:"text"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage Usage `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// fimEndpoint devuelve la URL de completions; en la API de DeepSeek solo
+// existe en el endpoint beta
+func fimEndpoint() string {
+	if apiBaseURL == defaultBaseURL {
+		return betaBaseURL + "/completions"
+	}
+	return apiBaseURL + "/completions"
+}
+
+// sendFIM envía la solicitud de fill-in-the-middle y devuelve el cuerpo crudo
+func sendFIM(fim FIMRequest) ([]byte, error) {
+	fim.Model = providerModel(fim.Model)
+	jsonBody, err := json.Marshal(fim)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo crear el cuerpo JSON: %v", err)
+	}
+	if verbose {
+		logger.Printf("Cuerpo de la solicitud FIM:\n%s\n", jsonBody)
+	}
+	// Las comprobaciones y el registro son los mismos que en el chat, con
+	// el prefijo y el sufijo como único mensaje
+	request := RequestBody{
+		Model:       fim.Model,
+		Messages:    []Message{{Role: "user", Content: fim.Prompt + fim.Suffix}},
+		MaxTokens:   fim.MaxTokens,
+		Temperature: fim.Temperature,
+	}
+	if err := preflightRequest(fimEndpoint(), request, jsonBody); err != nil {
+		return nil, err
+	}
+
+	resp, err := doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", fimEndpoint(), bytes.NewReader(jsonBody))
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo crear la solicitud HTTP: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, authorizeRequest(req, jsonBody)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		auditFailure(resp.Request, resp.StatusCode, err)
+		return nil, fmt.Errorf("no se pudo leer la respuesta HTTP: %v", err)
+	}
+	finishTimings(resp)
+	auditResponse(resp, body, false)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, newAPIError(resp.StatusCode, resp.Header, body, request)
+	}
+	var parsed struct {
+		Usage   Usage `json:"usage"`
+		Choices []struct {
+			Text string `json:"text"`
+		} `json:"choices"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		var text string
+		if len(parsed.Choices) > 0 {
+			text = parsed.Choices[0].Text
+		}
+		recordUsage(request, parsed.Usage, text)
+	}
+	return body, nil
+}
+
+// runFIM completa el código entre la entrada (stdin o los archivos) y el
+// contenido de suffixFile, y muestra solo el texto generado
+func runFIM(inputFiles []string, suffixFile string) {
+	sources, _ := readInput(inputFiles)
+	var prefix strings.Builder
+	for _, s := range sources {
+		prefix.WriteString(s.Raw)
+	}
+	if prefix.Len() == 0 {
+		fmt.Fprintf(os.Stderr, "Error: --fim necesita el código anterior al hueco en stdin o con -f\n")
+		os.Exit(1)
+	}
+	var suffix string
+	if suffixFile != "" {
+		data, err := readContextFile(suffixFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: no se pudo leer el sufijo: %v\n", err)
+			os.Exit(1)
+		}
+		suffix = string(data)
+	}
+	promptHash = hashPrompt(prefix.String() + suffix)
+
+	// Con --review-context se revisan el prefijo y el sufijo tal como se envían
+	if reviewContext && !offlineMode {
+		var review []*contextSource
+		for _, s := range sources {
+			review = append(review, newContextSource(s.Kind, s.Name, s.Raw, false))
+		}
+		if suffixFile != "" {
+			review = append(review, newContextSource("file", suffixFile, suffix, false))
+		}
+		payload := []Message{{Role: "prefijo", Content: prefix.String()}, {Role: "sufijo", Content: suffix}}
+		if err := confirmContext(review, payload, nil); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+	}
+
+	body, err := sendFIM(FIMRequest{
+		Model:       model,
+		Prompt:      prefix.String(),
+		Suffix:      suffix,
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+		Stop:        stopSequences,
+	})
+	if err != nil {
+		if rawOutput && len(body) > 0 {
+			printRaw(body)
+		}
+		fatalf("Error: %v\n", err)
+	}
+
+	var response FIMResponse
+	parseErr := json.Unmarshal(body, &response)
+	usage = response.Usage
+	if rawOutput {
+		printRaw(body)
+		if parseErr == nil && response.Error.Message != "" {
+			fatalf("Error de la API: %s\n", response.Error.Message)
+		}
+		finish(true, "")
+		return
+	}
+	if parseErr != nil {
+		fatalf("Error al parsear la respuesta JSON: %v\n", parseErr)
+	}
+	if response.Error.Message != "" {
+		fatalf("Error de la API: %s\n", response.Error.Message)
+	}
+	if len(response.Choices) == 0 {
+		fatalf("No se recibió ninguna respuesta válida de la API\n")
+	}
+	fmt.Println(response.Choices[0].Text)
+	finish(true, "")
+}
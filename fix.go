@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Máximo de salida del comando que se envía al modelo (se conserva el final)
+	maxFixOutput = 24 * 1024
+	// Archivos mencionados en la salida que se adjuntan como contexto
+	maxFixFiles    = 8
+	maxFixFileSize = 64 * 1024
+)
+
+const fixSystemPrompt = `Eres un experto depurando código. El usuario ejecuta un comando que falla; ` +
+	`recibirás su salida y el contenido de los archivos relevantes. Explica la causa en una o dos frases ` +
+	`y propón la corrección como un único diff unificado (rutas relativas con prefijos a/ y b/, como git diff) ` +
+	"dentro de un bloque ```diff. No incluyas nada más que la explicación y el diff."
+
+// fileRefPattern detecta rutas con número de línea en la salida de
+// compiladores y tests (main.go:12:3, src/app.py:40, ...)
+var fileRefPattern = regexp.MustCompile(`([\w./-]+\.\w+):\d+`)
+
+// runCaptured ejecuta el comando mostrando su salida y devuelve la salida
+// combinada y el código de salida
+func runCaptured(argv []string) (string, int, error) {
+	var cmd *exec.Cmd
+	if len(argv) == 1 {
+		cmd = shellCommand(argv[0])
+	} else {
+		cmd = exec.Command(argv[0], argv[1:]...)
+	}
+	var out bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, &out)
+	cmd.Stderr = io.MultiWriter(os.Stderr, &out)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return out.String(), 0, nil
+}
+
+// referencedFiles devuelve los archivos indicados y los mencionados en la
+// salida que existen dentro del directorio actual
+func referencedFiles(explicit []string, output string) []string {
+	seen := map[string]bool{}
+	var files []string
+	add := func(path string) {
+		path = filepath.Clean(path)
+		if seen[path] || len(files) >= maxFixFiles || filepath.IsAbs(path) || strings.HasPrefix(path, "..") {
+			return
+		}
+		seen[path] = true
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Size() <= maxFixFileSize {
+			files = append(files, path)
+		}
+	}
+	for _, f := range explicit {
+		add(f)
+	}
+	for _, m := range fileRefPattern.FindAllStringSubmatch(output, -1) {
+		add(m[1])
+	}
+	return files
+}
+
+// fixPrompt describe el fallo y adjunta los archivos relevantes
+func fixPrompt(command string, exitCode int, output string, files []string) string {
+	if len(output) > maxFixOutput {
+		output = output[len(output)-maxFixOutput:]
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Comando: %s\nCódigo de salida: %d\nSalida:\n```\n%s\n```\n", command, exitCode, strings.TrimSpace(output))
+	for _, path := range files {
+		data, err := readContextFile(path)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "\nArchivo %s:\n```\n%s\n```\n", filepath.ToSlash(path), strings.TrimRight(string(data), "\n"))
+	}
+	return sb.String()
+}
+
+// extractDiff obtiene el diff unificado de la respuesta del modelo
+func extractDiff(response string) string {
+	for _, fence := range []string{"```diff", "```patch"} {
+		if i := strings.Index(response, fence); i >= 0 {
+			rest := response[i+len(fence):]
+			if j := strings.Index(rest, "\n"); j >= 0 {
+				rest = rest[j+1:]
+			}
+			if j := strings.Index(rest, "\n```"); j >= 0 {
+				rest = rest[:j]
+			}
+			return rest + "\n"
+		}
+	}
+	if i := strings.Index(response, "--- "); i >= 0 {
+		return strings.TrimSpace(response[i:]) + "\n"
+	}
+	return ""
+}
+
+// applyPatch aplica el diff con git apply, que también funciona fuera de un
+// repositorio
+func applyPatch(diff string) error {
+	cmd := exec.Command("git", "apply", "--recount", "--whitespace=nowarn", "-")
+	cmd.Stdin = strings.NewReader(diff)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("no se pudo aplicar el parche: %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func runFix(args []string) {
+	fs := flag.NewFlagSet("fix", flag.ExitOnError)
+	maxIterations := fs.Int("n", 3, "Máximo de iteraciones")
+	fs.IntVar(maxIterations, "max-iterations", 3, "Máximo de iteraciones")
+	var files stringList
+	fs.Var(&files, "f", "Archivo a incluir como contexto (repetible)")
+	fs.Var(&files, "file", "Archivo a incluir como contexto (repetible)")
+	yes := fs.Bool("yes", false, "Aplicar los parches sin pedir confirmación")
+	addModelFlags(fs, 0.0)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s fix [opciones] -- <comando> [argumentos]
+
+Ejecuta el comando y, si falla, envía su salida al modelo junto con los
+archivos mencionados en ella (y los indicados con -f), muestra el parche
+propuesto, lo aplica tras confirmar y vuelve a ejecutar el comando, hasta que
+termine bien o se agoten las iteraciones.
+
+Opciones:
+  -n, --max-iterations <n>   Máximo de parches a intentar (default: 3)
+  -f, --file <archivo>       Archivo a incluir siempre como contexto (repetible)
+  --yes                      Aplicar los parches sin pedir confirmación
+  -t, --temperature          Temperatura (default: 0.0)
+  -m, --maxtokens            Máximo de tokens a generar
+  -v, --verbose              Mostrar logs detallados
+
+Ejemplo:
+  %s fix -- make test
+`, os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+	argv := fs.Args()
+	if len(argv) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	setupSubcommand()
+	command := strings.Join(argv, " ")
+
+	messages := []Message{{Role: "system", Content: fixSystemPrompt}}
+	for i := 0; ; i++ {
+		statusf("$ %s\n", command)
+		output, exitCode, err := runCaptured(argv)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: no se pudo ejecutar el comando: %v\n", err)
+			os.Exit(1)
+		}
+		if exitCode == 0 {
+			if i > 0 {
+				statusf("El comando terminó correctamente tras %d parche(s)\n", i)
+			}
+			return
+		}
+		if i >= *maxIterations {
+			statusf("El comando sigue fallando tras %d iteraciones\n", *maxIterations)
+			os.Exit(exitCode)
+		}
+
+		statusf("\nEl comando falló (código %d); pidiendo una corrección (%d/%d)...\n", exitCode, i+1, *maxIterations)
+		messages = append(messages, Message{
+			Role:    "user",
+			Content: fixPrompt(command, exitCode, output, referencedFiles(files, output)),
+		})
+		response, _, err := complete(newRequestBody(messages))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		messages = append(messages, Message{Role: "assistant", Content: response})
+
+		text := response
+		if isTerminal(os.Stderr) && os.Getenv("NO_COLOR") == "" {
+			text = renderMarkdown(response)
+		}
+		fmt.Fprintln(os.Stderr, text)
+
+		diff := extractDiff(response)
+		if diff == "" {
+			fmt.Fprintln(os.Stderr, "Error: la respuesta no contiene ningún parche")
+			os.Exit(exitCode)
+		}
+		if !*yes {
+			ok, err := confirm("¿Aplicar el parche?")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if !ok {
+				fmt.Fprintln(os.Stderr, "Cancelado")
+				os.Exit(exitCode)
+			}
+		}
+		if err := applyPatch(diff); err != nil {
+			// Se informa al modelo en la siguiente iteración
+			statusf("Advertencia: %v\n", err)
+			messages = append(messages, Message{Role: "user", Content: "El parche no se pudo aplicar: " + err.Error()})
+			continue
+		}
+		statusf("Parche aplicado\n")
+	}
+}
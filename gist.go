@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultGitHubAPIURL = "https://api.github.com"
+
+// githubAPIURL permite usar GitHub Enterprise mediante GITHUB_API_URL
+func githubAPIURL() string {
+	if v := os.Getenv("GITHUB_API_URL"); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return defaultGitHubAPIURL
+}
+
+// createGist sube el contenido como gist y devuelve su URL
+func createGist(filename, description, content string, public bool) (string, error) {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		return "", fmt.Errorf("se requiere la variable de entorno GITHUB_TOKEN (con permiso gist)")
+	}
+
+	payload := map[string]interface{}{
+		"description": description,
+		"public":      public,
+		"files": map[string]interface{}{
+			filename: map[string]string{"content": content},
+		},
+	}
+	jsonBody, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", githubAPIURL()+"/gists", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo contactar con GitHub: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	var result struct {
+		HTMLURL string `json:"html_url"`
+		Message string `json:"message"`
+	}
+	json.Unmarshal(body, &result)
+	if resp.StatusCode != http.StatusCreated {
+		if result.Message != "" {
+			return "", fmt.Errorf("GitHub respondió %d: %s", resp.StatusCode, result.Message)
+		}
+		return "", fmt.Errorf("GitHub respondió con código %d", resp.StatusCode)
+	}
+	return result.HTMLURL, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// gitContext describe el estado del repositorio del directorio actual: rama,
+// último commit, git status --short y, si withDiff, el diff de los cambios
+// sin confirmar (preparados o no)
+func gitContext(withDiff bool) (string, error) {
+	if _, err := git("", "rev-parse", "--is-inside-work-tree"); err != nil {
+		return "", fmt.Errorf("--git-context requiere estar dentro de un repositorio git")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Estado del repositorio git:\n")
+
+	branch, err := git("", "branch", "--show-current")
+	if err != nil {
+		return "", err
+	}
+	if branch == "" {
+		branch = "(HEAD separado)"
+	}
+	fmt.Fprintf(&sb, "Rama: %s\n", branch)
+
+	hasHead := true
+	if last, err := git("", "log", "-1", "--format=%h %s"); err == nil {
+		fmt.Fprintf(&sb, "Último commit: %s\n", last)
+	} else {
+		hasHead = false
+		sb.WriteString("Último commit: (ninguno)\n")
+	}
+
+	status, err := git("", "status", "--short")
+	if err != nil {
+		return "", err
+	}
+	if status == "" {
+		sb.WriteString("Cambios: ninguno (árbol de trabajo limpio)\n")
+	} else {
+		fmt.Fprintf(&sb, "Cambios (git status --short):\n```\n%s\n```\n", status)
+	}
+
+	if withDiff && status != "" {
+		args := []string{"diff", "HEAD"}
+		if !hasHead {
+			args = []string{"diff", "--cached"}
+		}
+		diff, err := git("", args...)
+		if err != nil {
+			return "", err
+		}
+		if diff != "" {
+			diff, err = prepareInput("git diff", []byte(diff))
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&sb, "Diff de los cambios sin confirmar:\n```diff\n%s\n```\n", diff)
+		}
+	}
+
+	logger.Printf("Contexto git: rama %s, diff %v\n", branch, withDiff)
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultGitLabURL = "https://gitlab.com"
+
+const mrReviewSystemPrompt = `Eres un revisor de código experto. Revisa los cambios de la merge request ` +
+	`y señala errores, problemas de seguridad, casos límite sin tratar y mejoras importantes; no comentes ` +
+	`cuestiones de estilo menores. Responde solo con JSON: {"summary": "valoración general en Markdown", ` +
+	`"comments": [{"path": "ruta del archivo", "line": número de línea en la versión nueva, "body": "comentario en Markdown"}]}. ` +
+	`Las líneas deben ser líneas añadidas o de contexto del diff.`
+
+// mrURLPattern reconoce https://host/grupo/proyecto/-/merge_requests/42
+var mrURLPattern = regexp.MustCompile(`^(https?://[^/]+)/(.+?)/-/merge_requests/(\d+)`)
+
+// mrRef identifica una merge request
+type mrRef struct {
+	BaseURL string // URL de la instancia de GitLab
+	Project string // ruta del proyecto (grupo/subgrupo/proyecto) o ID
+	IID     int
+}
+
+// parseMRRef acepta la URL de la merge request o grupo/proyecto!42
+func parseMRRef(ref, baseURL string) (mrRef, error) {
+	if m := mrURLPattern.FindStringSubmatch(ref); m != nil {
+		iid, _ := strconv.Atoi(m[3])
+		if baseURL == "" {
+			baseURL = m[1]
+		}
+		return mrRef{strings.TrimRight(baseURL, "/"), m[2], iid}, nil
+	}
+	if i := strings.LastIndex(ref, "!"); i > 0 {
+		if iid, err := strconv.Atoi(ref[i+1:]); err == nil {
+			if baseURL == "" {
+				baseURL = defaultGitLabURL
+			}
+			return mrRef{strings.TrimRight(baseURL, "/"), ref[:i], iid}, nil
+		}
+	}
+	return mrRef{}, fmt.Errorf("referencia de merge request no válida %q (usa su URL o grupo/proyecto!42)", ref)
+}
+
+// gitlabMR son los datos de la merge request que se usan en la revisión
+type gitlabMR struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	WebURL       string `json:"web_url"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	DiffRefs     struct {
+		BaseSHA  string `json:"base_sha"`
+		HeadSHA  string `json:"head_sha"`
+		StartSHA string `json:"start_sha"`
+	} `json:"diff_refs"`
+}
+
+// gitlabDiff es el diff de un archivo de la merge request
+type gitlabDiff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	Diff        string `json:"diff"`
+	NewFile     bool   `json:"new_file"`
+	DeletedFile bool   `json:"deleted_file"`
+}
+
+// mrComment es un comentario de la revisión sobre una línea
+type mrComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Body string `json:"body"`
+}
+
+// mrReview es la revisión que devuelve el modelo
+type mrReview struct {
+	Summary  string      `json:"summary"`
+	Comments []mrComment `json:"comments"`
+}
+
+// gitlabClient llama a la API v4 de GitLab con GITLAB_TOKEN
+type gitlabClient struct {
+	ref    mrRef
+	token  string
+	client *http.Client
+}
+
+func (c *gitlabClient) mrURL(suffix string) string {
+	return fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%d%s", c.ref.BaseURL, url.PathEscape(c.ref.Project), c.ref.IID, suffix)
+}
+
+// do envía la solicitud y decodifica la respuesta en out si no es nil
+func (c *gitlabClient) do(method, endpoint string, payload, out interface{}) (http.Header, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", c.token)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo contactar con GitLab: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var result struct {
+			Message interface{} `json:"message"`
+			Error   string      `json:"error"`
+		}
+		json.Unmarshal(data, &result)
+		msg := result.Error
+		if result.Message != nil {
+			msg = fmt.Sprint(result.Message)
+		}
+		return resp.Header, &gitlabError{resp.StatusCode, msg}
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.Header, fmt.Errorf("respuesta inesperada de GitLab: %v", err)
+		}
+	}
+	return resp.Header, nil
+}
+
+type gitlabError struct {
+	Status  int
+	Message string
+}
+
+func (e *gitlabError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("GitLab respondió %d: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("GitLab respondió con código %d", e.Status)
+}
+
+// diffs obtiene los diffs de la merge request página a página; las
+// instancias anteriores a GitLab 15.7 solo tienen /changes
+func (c *gitlabClient) diffs() ([]gitlabDiff, error) {
+	var all []gitlabDiff
+	for page := 1; page > 0; {
+		var diffs []gitlabDiff
+		header, err := c.do("GET", c.mrURL(fmt.Sprintf("/diffs?per_page=100&page=%d", page)), nil, &diffs)
+		if ge, ok := err.(*gitlabError); ok && ge.Status == http.StatusNotFound && page == 1 {
+			var changes struct {
+				Changes []gitlabDiff `json:"changes"`
+			}
+			if _, err := c.do("GET", c.mrURL("/changes"), nil, &changes); err != nil {
+				return nil, err
+			}
+			return changes.Changes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, diffs...)
+		page, _ = strconv.Atoi(header.Get("X-Next-Page"))
+	}
+	return all, nil
+}
+
+// unifiedDiff reconstruye un diff de git a partir de los diffs de GitLab
+func unifiedDiff(diffs []gitlabDiff) string {
+	var sb strings.Builder
+	for _, d := range diffs {
+		oldName, newName := "a/"+d.OldPath, "b/"+d.NewPath
+		if d.NewFile {
+			oldName = "/dev/null"
+		}
+		if d.DeletedFile {
+			newName = "/dev/null"
+		}
+		fmt.Fprintf(&sb, "diff --git a/%s b/%s\n--- %s\n+++ %s\n%s", d.OldPath, d.NewPath, oldName, newName, d.Diff)
+		if !strings.HasSuffix(d.Diff, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// reviewMR revisa el diff, en varias partes si no cabe en la ventana del
+// modelo, y une los comentarios de todas ellas
+func reviewMR(mr *gitlabMR, diff, instruction string) (*mrReview, error) {
+	header := fmt.Sprintf("Merge request: %s (%s → %s)\n", mr.Title, mr.SourceBranch, mr.TargetBranch)
+	if strings.TrimSpace(mr.Description) != "" {
+		header += "Descripción:\n" + strings.TrimSpace(mr.Description) + "\n"
+	}
+	if instruction != "" {
+		header += "Instrucciones adicionales: " + instruction + "\n"
+	}
+	budget := contextWindow(model) - maxTokens - estimateTokens(mrReviewSystemPrompt+header) - contextMargin
+	chunks := []inputChunk{{Text: diff}}
+	if estimateTokens(diff) > budget {
+		chunks = chunkDiff(diff, budget)
+		statusf("El diff no cabe en la ventana del modelo; revisándolo en %d partes...\n", len(chunks))
+	}
+
+	review := &mrReview{}
+	var summaries []string
+	for i, chunk := range chunks {
+		note := ""
+		if len(chunks) > 1 {
+			note = fmt.Sprintf("(Parte %d de %d del diff: %s)\n", i+1, len(chunks), strings.Join(chunk.Files, ", "))
+		}
+		requestBody := newRequestBody([]Message{
+			{Role: "system", Content: mrReviewSystemPrompt},
+			{Role: "user", Content: header + note + "\nCambios:\n```diff\n" + strings.TrimRight(chunk.Text, "\n") + "\n```"},
+		})
+		requestBody.ResponseFormat = &ResponseFormat{Type: "json_object"}
+		content, _, err := complete(requestBody)
+		if err != nil {
+			return nil, err
+		}
+		var part mrReview
+		if err := json.Unmarshal([]byte(content), &part); err != nil {
+			return nil, fmt.Errorf("el modelo no devolvió JSON válido: %v", err)
+		}
+		if s := strings.TrimSpace(part.Summary); s != "" {
+			summaries = append(summaries, s)
+		}
+		review.Comments = append(review.Comments, part.Comments...)
+	}
+	review.Summary = strings.Join(summaries, "\n\n")
+	return review, nil
+}
+
+// postReview publica cada comentario como discusión en su línea y la
+// valoración general como nota; los comentarios que GitLab no acepta en una
+// línea (fuera del diff) se añaden a la nota
+func (c *gitlabClient) postReview(mr *gitlabMR, review *mrReview) (int, error) {
+	posted := 0
+	var unplaced []string
+	for _, cm := range review.Comments {
+		position := map[string]interface{}{
+			"position_type": "text",
+			"base_sha":      mr.DiffRefs.BaseSHA,
+			"start_sha":     mr.DiffRefs.StartSHA,
+			"head_sha":      mr.DiffRefs.HeadSHA,
+			"old_path":      cm.Path,
+			"new_path":      cm.Path,
+			"new_line":      cm.Line,
+		}
+		_, err := c.do("POST", c.mrURL("/discussions"), map[string]interface{}{"body": cm.Body, "position": position}, nil)
+		if err != nil {
+			logger.Printf("No se pudo comentar %s:%d: %v\n", cm.Path, cm.Line, err)
+			unplaced = append(unplaced, fmt.Sprintf("- **%s:%d**: %s", cm.Path, cm.Line, cm.Body))
+			continue
+		}
+		posted++
+	}
+
+	note := strings.TrimSpace(review.Summary)
+	if len(unplaced) > 0 {
+		note += "\n\n**Otros comentarios:**\n\n" + strings.Join(unplaced, "\n")
+	}
+	if note != "" {
+		if _, err := c.do("POST", c.mrURL("/notes"), map[string]string{"body": strings.TrimSpace(note)}, nil); err != nil {
+			return posted, err
+		}
+	}
+	return posted, nil
+}
+
+// formatMRReview muestra la revisión en Markdown
+func formatMRReview(review *mrReview) string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(review.Summary) + "\n")
+	if len(review.Comments) > 0 {
+		sb.WriteString("\n## Comentarios\n")
+		for _, cm := range review.Comments {
+			fmt.Fprintf(&sb, "\n**%s:%d**\n%s\n", cm.Path, cm.Line, strings.TrimSpace(cm.Body))
+		}
+	}
+	return sb.String()
+}
+
+func runMR(args []string) {
+	fs := flag.NewFlagSet("mr", flag.ExitOnError)
+	post := fs.Bool("post", false, "Publicar la revisión en la merge request")
+	gitlabURL := fs.String("gitlab-url", os.Getenv("GITLAB_URL"), "URL de la instancia de GitLab")
+	instruction := fs.String("i", "", "Instrucciones adicionales para la revisión")
+	fs.StringVar(instruction, "instruction", "", "Instrucciones adicionales para la revisión")
+	jsonOut := fs.Bool("json", false, "Mostrar la revisión en JSON")
+	addModelFlags(fs, 0.2)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s mr <url|grupo/proyecto!iid> [opciones]
+
+Revisa una merge request de GitLab: descarga sus cambios con la API, los
+revisa (en partes si no caben en la ventana del modelo) y muestra la
+revisión o, con --post, la publica como discusiones en las líneas
+comentadas y una nota con la valoración general.
+
+El token se lee de GITLAB_TOKEN (permiso api; read_api basta sin --post).
+Para instancias propias usa la URL completa de la merge request, GITLAB_URL
+o --gitlab-url.
+
+Opciones:
+  --post                 Publicar la revisión en la merge request
+  --gitlab-url <url>     URL de la instancia (default: la de la referencia o
+                         https://gitlab.com)
+  -i, --instruction      Instrucciones adicionales para la revisión
+  --json                 Mostrar la revisión en JSON
+  -t, --temperature      Temperatura (default: 0.2)
+  -m, --maxtokens        Máximo de tokens a generar
+  -v, --verbose          Mostrar logs detallados
+
+Ejemplos:
+  %s mr https://gitlab.example.com/equipo/api/-/merge_requests/42 --post
+  %s mr equipo/api!42 -i "céntrate en la seguridad"
+`, os.Args[0], os.Args[0], os.Args[0])
+	}
+	// La referencia puede ir antes de las opciones
+	var refArg string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		refArg, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if refArg == "" && fs.NArg() > 0 {
+		refArg = fs.Arg(0)
+	}
+	if refArg == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	ref, err := parseMRRef(refArg, *gitlabURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	token := os.Getenv("GITLAB_TOKEN")
+	if *post && token == "" {
+		fmt.Fprintf(os.Stderr, "Error: --post requiere la variable de entorno GITLAB_TOKEN (con permiso api)\n")
+		os.Exit(1)
+	}
+	setupSubcommand()
+
+	gl := &gitlabClient{ref: ref, token: token, client: &http.Client{Timeout: 60 * time.Second}}
+	var mr gitlabMR
+	if _, err := gl.do("GET", gl.mrURL(""), nil, &mr); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	diffs, err := gl.diffs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(diffs) == 0 {
+		statusf("La merge request no tiene cambios\n")
+		return
+	}
+	statusf("Revisando !%d %s (%d archivos)...\n", ref.IID, mr.Title, len(diffs))
+
+	review, err := reviewMR(&mr, unifiedDiff(diffs), *instruction)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(review)
+	} else {
+		text := formatMRReview(review)
+		if isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" {
+			text = renderMarkdown(text)
+		}
+		fmt.Print(text)
+	}
+
+	if *post {
+		posted, err := gl.postReview(&mr, review)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		statusf("Publicados %d comentarios en línea y la nota de la revisión en %s\n", posted, mr.WebURL)
+	}
+}
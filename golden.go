@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// GoldenRecord es una respuesta de referencia guardada con --golden
+type GoldenRecord struct {
+	RequestHash string    `json:"request_hash"`
+	Model       string    `json:"model"`
+	Temperature float64   `json:"temperature"`
+	Messages    []Message `json:"messages"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// requestHash identifica una solicitud por su contenido
+func requestHash(requestBody RequestBody) string {
+	data, _ := json.Marshal(requestBody)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func goldenPath(dir, hash string) string {
+	return filepath.Join(dir, hash[:16]+".json")
+}
+
+// checkGolden compara el contenido con la respuesta dorada de la solicitud.
+// Si no existe (o update es true) la guarda y devuelve created=true; si
+// existe y difiere, devuelve el diff.
+func checkGolden(dir string, requestBody RequestBody, content string, update bool) (diff string, created bool, err error) {
+	hash := requestHash(requestBody)
+	path := goldenPath(dir, hash)
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, fmt.Errorf("no se pudo leer la respuesta dorada: %v", err)
+	}
+
+	if os.IsNotExist(err) || update {
+		record := GoldenRecord{
+			RequestHash: hash,
+			Model:       requestBody.Model,
+			Temperature: requestBody.Temperature,
+			Messages:    requestBody.Messages,
+			Content:     content,
+			CreatedAt:   time.Now().UTC(),
+		}
+		data, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			return "", false, err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", false, fmt.Errorf("no se pudo crear el directorio %s: %v", dir, err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return "", false, fmt.Errorf("no se pudo guardar la respuesta dorada: %v", err)
+		}
+		return "", true, nil
+	}
+
+	var record GoldenRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return "", false, fmt.Errorf("respuesta dorada inválida en %s: %v", path, err)
+	}
+	return lineDiff(path, "respuesta actual", record.Content, content, 3), false, nil
+}
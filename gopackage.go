@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goModule son los datos de go.mod que se incluyen con --go-package
+type goModule struct {
+	Dir      string // directorio de go.mod
+	Path     string
+	Go       string
+	Requires []string // dependencias directas (ruta versión)
+}
+
+// findGoModule busca go.mod en dir o en sus directorios superiores
+func findGoModule(dir string) (*goModule, error) {
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			return parseGoMod(dir, string(data)), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("no se encontró go.mod")
+		}
+		dir = parent
+	}
+}
+
+// parseGoMod lee la ruta del módulo, la versión de Go y las dependencias
+// directas; las indirectas se omiten
+func parseGoMod(dir, content string) *goModule {
+	mod := &goModule{Dir: dir}
+	inRequire := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		indirect := strings.HasSuffix(line, "// indirect")
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		fields := strings.Fields(line)
+		switch {
+		case inRequire && line == ")":
+			inRequire = false
+		case inRequire && len(fields) >= 2 && !indirect:
+			mod.Requires = append(mod.Requires, fields[0]+" "+fields[1])
+		case len(fields) >= 2 && fields[0] == "module":
+			mod.Path = strings.Trim(fields[1], `"`)
+		case len(fields) >= 2 && fields[0] == "go":
+			mod.Go = fields[1]
+		case line == "require (":
+			inRequire = true
+		case len(fields) >= 3 && fields[0] == "require" && !indirect:
+			mod.Requires = append(mod.Requires, fields[1]+" "+fields[2])
+		}
+	}
+	return mod
+}
+
+// goPackageSources devuelve las fuentes de contexto de un paquete Go: un
+// resumen con los datos del módulo y la API exportada (prioridad alta) y
+// cada archivo del paquete sin los tests
+func goPackageSources(dir string) ([]*contextSource, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("--go-package: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("--go-package: %s no es un directorio", dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("--go-package: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	var paths []string
+	contents := map[string]string{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		data, err := readContextFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("--go-package: %v", err)
+		}
+		f, err := parser.ParseFile(fset, path, data, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("--go-package: %v", err)
+		}
+		if len(files) > 0 && f.Name.Name != files[0].Name.Name {
+			return nil, fmt.Errorf("--go-package: %s contiene varios paquetes (%s y %s)", dir, files[0].Name.Name, f.Name.Name)
+		}
+		files = append(files, f)
+		paths = append(paths, path)
+		contents[path] = string(data)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("--go-package: %s no contiene archivos .go", dir)
+	}
+
+	importPath := files[0].Name.Name
+	abs, _ := filepath.Abs(dir)
+	mod, modErr := findGoModule(abs)
+	if modErr == nil && mod.Path != "" {
+		if rel, err := filepath.Rel(mod.Dir, abs); err == nil {
+			importPath = mod.Path
+			if rel != "." {
+				importPath += "/" + filepath.ToSlash(rel)
+			}
+		}
+	} else {
+		logger.Printf("--go-package: %v\n", modErr)
+	}
+
+	pkg, err := doc.NewFromFiles(fset, files, importPath)
+	if err != nil {
+		return nil, fmt.Errorf("--go-package: %v", err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Paquete Go %s (%s, directorio %s, %d archivos):\n", pkg.Name, importPath, dir, len(files))
+	if mod != nil {
+		fmt.Fprintf(&sb, "Módulo: %s", mod.Path)
+		if mod.Go != "" {
+			fmt.Fprintf(&sb, " (go %s)", mod.Go)
+		}
+		sb.WriteString("\n")
+		if len(mod.Requires) > 0 {
+			fmt.Fprintf(&sb, "Dependencias directas: %s\n", strings.Join(mod.Requires, ", "))
+		}
+	}
+	if imports := packageImports(files); len(imports) > 0 {
+		fmt.Fprintf(&sb, "Importa: %s\n", strings.Join(imports, ", "))
+	}
+	if pkg.Doc != "" {
+		fmt.Fprintf(&sb, "\n%s", commentLines(pkg.Doc))
+	}
+	fmt.Fprintf(&sb, "\nAPI exportada:\n```go\n%s```", exportedAPI(fset, pkg))
+
+	summary := newContextSource("gopkg", dir, sb.String(), true)
+	summary.Priority = priorityHigh
+	sources := []*contextSource{summary}
+	for _, path := range paths {
+		source := newContextSource("file", path, labelFile(path, contents[path]), true)
+		source.Raw = contents[path]
+		sources = append(sources, source)
+	}
+	logger.Printf("--go-package %s: %s, %d archivos\n", dir, importPath, len(paths))
+	return sources, nil
+}
+
+// packageImports devuelve los paquetes que importan los archivos, sin repetir
+func packageImports(files []*ast.File) []string {
+	seen := map[string]bool{}
+	var imports []string
+	for _, f := range files {
+		for _, imp := range f.Imports {
+			path := strings.Trim(imp.Path.Value, `"`)
+			if !seen[path] {
+				seen[path] = true
+				imports = append(imports, path)
+			}
+		}
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// exportedAPI imprime las declaraciones exportadas del paquete con su
+// documentación; las funciones y métodos, solo con la firma
+func exportedAPI(fset *token.FileSet, pkg *doc.Package) string {
+	var buf bytes.Buffer
+	writeDecl := func(docText string, decl ast.Decl) {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			sig := *fn
+			sig.Body = nil
+			sig.Doc = nil
+			decl = &sig
+		} else if gen, ok := decl.(*ast.GenDecl); ok {
+			g := *gen
+			g.Doc = nil
+			decl = &g
+		}
+		buf.WriteString(commentLines(docText))
+		printer.Fprint(&buf, fset, decl)
+		buf.WriteString("\n\n")
+	}
+	values := func(vs []*doc.Value) {
+		for _, v := range vs {
+			writeDecl(v.Doc, v.Decl)
+		}
+	}
+	funcs := func(fs []*doc.Func) {
+		for _, f := range fs {
+			writeDecl(f.Doc, f.Decl)
+		}
+	}
+
+	values(pkg.Consts)
+	values(pkg.Vars)
+	funcs(pkg.Funcs)
+	for _, t := range pkg.Types {
+		writeDecl(t.Doc, t.Decl)
+		values(t.Consts)
+		values(t.Vars)
+		funcs(t.Funcs)
+		funcs(t.Methods)
+	}
+	if buf.Len() == 0 {
+		return "// (el paquete no exporta nada)\n"
+	}
+	return strings.TrimRight(buf.String(), "\n") + "\n"
+}
+
+// commentLines convierte un texto de documentación en líneas de comentario
+func commentLines(text string) string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return ""
+	}
+	return "// " + strings.ReplaceAll(text, "\n", "\n// ") + "\n"
+}
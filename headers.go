@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// extraHeaders son las cabeceras de --header, que se añaden a cada solicitud
+// a la API después de las del proveedor
+var extraHeaders = headersFlag{}
+
+// headersFlag es un flag repetible "Nombre: valor"
+type headersFlag http.Header
+
+func (h *headersFlag) String() string {
+	var parts []string
+	for name, values := range *h {
+		for _, v := range values {
+			parts = append(parts, name+": "+v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *headersFlag) Set(v string) error {
+	name, value, ok := strings.Cut(v, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("se espera 'Nombre: valor', no %q", v)
+	}
+	http.Header(*h).Add(name, strings.TrimSpace(value))
+	return nil
+}
+
+// providerHeaders devuelve las cabeceras de un proveedor de config.yaml con
+// las variables de entorno (${VAR}) ya sustituidas
+func providerHeaders(headers map[string]string) map[string]string {
+	expanded := make(map[string]string, len(headers))
+	for name, value := range headers {
+		expanded[name] = os.ExpandEnv(value)
+	}
+	return expanded
+}
+
+// applyCustomHeaders añade las cabeceras del proveedor activo y las de
+// --header, que tienen prioridad; ambas sustituyen a las de deepcli
+// (incluida la autenticación). Host cambia el host virtual de la solicitud.
+func applyCustomHeaders(req *http.Request) {
+	if provider != nil {
+		for name, value := range provider.Headers {
+			req.Header.Set(name, value)
+		}
+	}
+	for name, values := range extraHeaders {
+		req.Header[name] = values
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+		req.Header.Del("Host")
+	}
+}
+
+// Cabeceras que genera la firma SigV4 y que no se pueden sustituir
+var sigV4Headers = []string{"Authorization", "X-Amz-Date", "X-Amz-Content-Sha256", "X-Amz-Security-Token"}
+
+// checkSignedHeaders rechaza las cabeceras del proveedor o de --header que
+// sustituirían a las de la firma SigV4
+func checkSignedHeaders() error {
+	var names []string
+	if provider != nil {
+		for name := range provider.Headers {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	for name := range extraHeaders {
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	for _, name := range names {
+		if slices.Contains(sigV4Headers, name) {
+			return fmt.Errorf("la cabecera %s la genera la firma SigV4 del proveedor %s y no se puede sustituir", name, activeProvider)
+		}
+	}
+	return nil
+}
+
+// customHeadersKey resume las cabeceras del proveedor activo y de --header
+// para distinguir solicitudes por lo demás idénticas
+func customHeadersKey() string {
+	var parts []string
+	if provider != nil {
+		for name, value := range provider.Headers {
+			parts = append(parts, "p:"+http.CanonicalHeaderKey(name)+": "+value)
+		}
+	}
+	for name, values := range extraHeaders {
+		parts = append(parts, "h:"+name+": "+strings.Join(values, ", "))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\n")
+}
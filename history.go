@@ -0,0 +1,339 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Session es una conversación guardada en el historial
+type Session struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Messages  []Message `json:"messages"`
+
+	// ForkedFrom es la sesión y el turno (sesión:turno) de los que parte
+	// una rama creada con "history fork"
+	ForkedFrom string `json:"forked_from,omitempty"`
+
+	// Partial son las posiciones en Messages de las respuestas que se
+	// interrumpieron con Ctrl+C antes de terminar
+	Partial []int `json:"partial,omitempty"`
+}
+
+// addPartial añade a la conversación una respuesta interrumpida, marcada
+// como incompleta
+func (s *Session) addPartial(content string) {
+	s.Partial = append(s.Partial, len(s.Messages))
+	s.Messages = append(s.Messages, Message{Role: "assistant", Content: content})
+}
+
+// isPartial indica si el mensaje i es una respuesta interrumpida
+func (s *Session) isPartial(i int) bool {
+	return slices.Contains(s.Partial, i)
+}
+
+// dataDir devuelve el directorio de datos de deepcli
+// ($XDG_DATA_HOME/deepcli o ~/.local/share/deepcli)
+func dataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "deepcli")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "deepcli")
+	}
+	return filepath.Join(home, ".local", "share", "deepcli")
+}
+
+func sessionsDir() string {
+	return filepath.Join(dataDir(), "sessions")
+}
+
+func newSession() *Session {
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	now := time.Now()
+	return &Session{
+		ID:        now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix),
+		Model:     model,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Save guarda la sesión; el título se toma del primer mensaje del usuario
+func (s *Session) Save() error {
+	if s.Title == "" {
+		for _, m := range s.Messages {
+			if m.Role == "user" {
+				s.Title = sessionTitle(m.Content)
+				break
+			}
+		}
+	}
+	s.UpdatedAt = time.Now()
+
+	if err := os.MkdirAll(sessionsDir(), 0700); err != nil {
+		return fmt.Errorf("no se pudo crear el directorio de sesiones: %v", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(sessionsDir(), s.ID+".json"), data, 0600)
+}
+
+// Fork crea una sesión nueva, sin guardar, con los mensajes hasta la
+// respuesta número turn (desde 1) incluida; con turn 0 copia la conversación
+// completa. La sesión original no cambia.
+func (s *Session) Fork(turn int) (*Session, error) {
+	end := len(s.Messages)
+	answers := 0
+	for i, m := range s.Messages {
+		if m.Role != "assistant" {
+			continue
+		}
+		answers++
+		if answers == turn {
+			end = i + 1
+		}
+	}
+	if turn < 0 || turn > answers {
+		return nil, fmt.Errorf("turno %d no válido: la sesión %s tiene %d respuestas", turn, s.ID, answers)
+	}
+	if turn == 0 {
+		turn = answers
+	}
+
+	f := newSession()
+	f.Model = s.Model
+	if s.Title != "" {
+		f.Title = s.Title + " (rama)"
+	}
+	f.ForkedFrom = fmt.Sprintf("%s:%d", s.ID, turn)
+	f.Messages = append([]Message(nil), s.Messages[:end]...)
+	for _, i := range s.Partial {
+		if i < end {
+			f.Partial = append(f.Partial, i)
+		}
+	}
+	return f, nil
+}
+
+func sessionTitle(content string) string {
+	title := strings.Join(strings.Fields(content), " ")
+	if r := []rune(title); len(r) > 60 {
+		title = string(r[:60]) + "…"
+	}
+	return title
+}
+
+// loadSession carga una sesión por ID (o por un prefijo único del ID)
+func loadSession(id string) (*Session, error) {
+	path := filepath.Join(sessionsDir(), id+".json")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		matches, _ := filepath.Glob(filepath.Join(sessionsDir(), id+"*.json"))
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("no existe la sesión %q", id)
+		case 1:
+			path = matches[0]
+		default:
+			return nil, fmt.Errorf("el ID %q es ambiguo (%d sesiones)", id, len(matches))
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer la sesión: %v", err)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("sesión inválida en %s: %v", path, err)
+	}
+	return &s, nil
+}
+
+// listSessions devuelve las sesiones guardadas, de la más reciente a la
+// más antigua
+func listSessions() ([]*Session, error) {
+	paths, err := filepath.Glob(filepath.Join(sessionsDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*Session
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var s Session
+		if json.Unmarshal(data, &s) == nil {
+			sessions = append(sessions, &s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+func runHistory(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s history <acción> [opciones]
+
+Acciones:
+  list                              Listar las sesiones guardadas
+  show <id>                         Mostrar la conversación de una sesión
+  export <id> [--format html|markdown|json] [-o archivo]
+                                    Exportar la conversación; html genera un
+                                    documento autocontenido con el código
+                                    resaltado (default: html)
+  fork <id> [--at <turno>]          Crear una sesión nueva que parte de la
+                                    respuesta número <turno> (default: la
+                                    última), para explorar otra dirección sin
+                                    tocar la original
+
+El <id> puede abreviarse con un prefijo único.
+`, os.Args[0])
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		sessions, err := listSessions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tACTUALIZADA\tMENSAJES\tTÍTULO")
+		for _, s := range sessions {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.ID, s.UpdatedAt.Format("2006-01-02 15:04"), len(s.Messages), s.Title)
+		}
+		w.Flush()
+
+	case "show":
+		if len(args) < 2 {
+			usage()
+			os.Exit(1)
+		}
+		s, err := loadSession(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		text := sessionToMarkdown(s)
+		if isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" {
+			text = renderMarkdown(text)
+		}
+		fmt.Print(text)
+
+	case "export":
+		fs := flag.NewFlagSet("history export", flag.ExitOnError)
+		format := fs.String("format", "html", "Formato: html, markdown o json")
+		output := fs.String("o", "", "Archivo de salida (default: stdout)")
+		fs.StringVar(output, "output", "", "Archivo de salida (default: stdout)")
+		fs.Usage = usage
+
+		var id string
+		rest := args[1:]
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+			id, rest = rest[0], rest[1:]
+		}
+		fs.Parse(rest)
+		if id == "" && fs.NArg() > 0 {
+			id = fs.Arg(0)
+		}
+		if id == "" {
+			usage()
+			os.Exit(1)
+		}
+
+		s, err := loadSession(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var data string
+		switch *format {
+		case "html":
+			data = sessionToHTML(s)
+		case "markdown", "md":
+			data = sessionToMarkdown(s)
+		case "json":
+			b, _ := json.MarshalIndent(s, "", "  ")
+			data = string(b) + "\n"
+		default:
+			fmt.Fprintf(os.Stderr, "Error: formato desconocido %q (html, markdown o json)\n", *format)
+			os.Exit(1)
+		}
+
+		if *output == "" {
+			fmt.Print(data)
+			return
+		}
+		if err := writeOutputFile(*output, []byte(data), false, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Error al escribir %s: %v\n", *output, err)
+			os.Exit(1)
+		}
+		statusf("Sesión exportada en %s\n", *output)
+
+	case "fork":
+		fs := flag.NewFlagSet("history fork", flag.ExitOnError)
+		at := fs.Int("at", 0, "Respuesta de la que parte la rama (default: la última)")
+		fs.Usage = usage
+
+		var id string
+		rest := args[1:]
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+			id, rest = rest[0], rest[1:]
+		}
+		fs.Parse(rest)
+		if id == "" && fs.NArg() > 0 {
+			id = fs.Arg(0)
+		}
+		if id == "" || *at < 0 {
+			usage()
+			os.Exit(1)
+		}
+
+		s, err := loadSession(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		forked, err := s.Fork(*at)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := forked.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(forked.ID)
+		statusf("Rama de %s con %d mensajes; continúala con: %s chat --session %s\n", forked.ForkedFrom, len(forked.Messages), os.Args[0], forked.ID)
+
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
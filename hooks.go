@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker identifica los hooks instalados por deepcli
+const hookMarker = "# Instalado por deepcli hooks install"
+
+// Máximo del diff preparado que se envía para redactar el mensaje
+const maxHookDiffTokens = 12000
+
+// Hooks que gestiona deepcli; el código de salida 2 de "hooks run" rechaza el
+// commit y cualquier otro fallo (sin red, sin API key) lo deja pasar
+var gitHooks = map[string]string{
+	"prepare-commit-msg": `#!/bin/sh
+%s
+# Redacta el mensaje del commit con deepcli. DEEPCLI_SKIP_HOOKS=1 lo omite.
+[ "$DEEPCLI_SKIP_HOOKS" = "1" ] && exit 0
+DEEPCLI=%s
+[ -x "$DEEPCLI" ] || DEEPCLI=$(command -v deepcli) || exit 0
+"$DEEPCLI" hooks run prepare-commit-msg "$@" </dev/null
+exit 0
+`,
+	"commit-msg": `#!/bin/sh
+%s
+# Revisa el mensaje del commit con deepcli. DEEPCLI_SKIP_HOOKS=1 lo omite.
+[ "$DEEPCLI_SKIP_HOOKS" = "1" ] && exit 0
+DEEPCLI=%s
+[ -x "$DEEPCLI" ] || DEEPCLI=$(command -v deepcli) || exit 0
+"$DEEPCLI" hooks run commit-msg "$@" </dev/null
+[ $? -eq 2 ] && exit 1
+exit 0
+`,
+}
+
+const commitDraftSystemPrompt = `Eres un experto en git. Redacta el mensaje de commit para los cambios ` +
+	`preparados: una línea de resumen en imperativo de como mucho 72 caracteres y, si los cambios no son ` +
+	`triviales, una línea en blanco y un cuerpo breve que explique qué cambia y por qué. Sigue el estilo ` +
+	`(idioma, prefijos, mayúsculas) de los commits recientes del repositorio. Responde solo con el mensaje, ` +
+	`sin comillas ni bloques de código.`
+
+const commitLintSystemPrompt = `Eres un revisor de mensajes de commit. Comprueba que el mensaje tenga ` +
+	`un resumen claro en imperativo de como mucho 72 caracteres, una línea en blanco antes del cuerpo, ` +
+	`que describa los cambios preparados y que siga el estilo de los commits recientes y las reglas ` +
+	`indicadas. Responde solo con JSON: {"ok": true|false, "problems": ["..."]}.`
+
+// hookSetting lee una opción deepcli.<clave> de la configuración de git del
+// repositorio
+func hookSetting(key, def string) string {
+	if v, err := git("", "config", "--get", "deepcli."+key); err == nil && v != "" {
+		return v
+	}
+	return def
+}
+
+// hooksDir devuelve el directorio de hooks del repositorio (respeta
+// core.hooksPath)
+func hooksDir() (string, error) {
+	dir, err := git("", "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", fmt.Errorf("no es un repositorio git: %v", err)
+	}
+	return dir, nil
+}
+
+// stripCommitComments quita las líneas de comentario de git y el diff que
+// añade commit -v
+func stripCommitComments(message string) string {
+	if i := strings.Index(message, "# ------------------------ >8 ------------------------"); i >= 0 {
+		message = message[:i]
+	}
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// commitContext describe los cambios preparados, los commits recientes y las
+// reglas del repositorio
+func commitContext() (string, error) {
+	diff, err := git("", "diff", "--cached", "--no-color", "--no-ext-diff")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return "", fmt.Errorf("no hay cambios preparados")
+	}
+	var sb strings.Builder
+	if log, err := git("", "log", "-n", "10", "--format=%s"); err == nil && log != "" {
+		fmt.Fprintf(&sb, "Commits recientes:\n%s\n\n", log)
+	}
+	if style := hookSetting("commitStyle", ""); style != "" {
+		fmt.Fprintf(&sb, "Reglas del repositorio: %s\n\n", style)
+	}
+	if stat, err := git("", "diff", "--cached", "--stat"); err == nil {
+		fmt.Fprintf(&sb, "Resumen:\n%s\n\n", stat)
+	}
+	if tokens := estimateTokens(diff); tokens > maxHookDiffTokens {
+		diff = truncateContext(diff, maxHookDiffTokens*4, tokens-maxHookDiffTokens)
+	}
+	fmt.Fprintf(&sb, "Cambios preparados:\n```diff\n%s\n```", diff)
+	return sb.String(), nil
+}
+
+// runHookDraft redacta el mensaje cuando git commit se ejecuta sin -m, -F,
+// plantilla, merge, squash ni --amend
+func runHookDraft(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: falta el archivo del mensaje\n")
+		os.Exit(1)
+	}
+	if len(args) > 1 && args[1] != "" {
+		return
+	}
+	if hookSetting("draft", "true") == "false" {
+		return
+	}
+	file := args[0]
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if stripCommitComments(string(data)) != "" {
+		return
+	}
+	context, err := commitContext()
+	if err != nil {
+		logger.Printf("No se redacta el mensaje: %v\n", err)
+		return
+	}
+
+	statusf("deepcli: redactando el mensaje del commit...\n")
+	message, _, err := complete(newRequestBody([]Message{
+		{Role: "system", Content: commitDraftSystemPrompt},
+		{Role: "user", Content: context},
+	}))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "deepcli: no se pudo redactar el mensaje: %v\n", err)
+		os.Exit(1)
+	}
+	message = strings.TrimSpace(strings.Trim(strings.TrimSpace(message), "`"))
+	if err := os.WriteFile(file, []byte(message+"\n\n"+string(data)), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runHookLint revisa el mensaje; con deepcli.lint=strict los problemas
+// rechazan el commit (código 2) y con warn solo se muestran
+func runHookLint(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: falta el archivo del mensaje\n")
+		os.Exit(1)
+	}
+	mode := hookSetting("lint", "warn")
+	if mode == "off" {
+		return
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	message := stripCommitComments(string(data))
+	if message == "" || strings.HasPrefix(message, "fixup! ") || strings.HasPrefix(message, "squash! ") ||
+		strings.HasPrefix(message, "Merge ") {
+		return
+	}
+	context, err := commitContext()
+	if err != nil {
+		logger.Printf("No se revisa el mensaje: %v\n", err)
+		return
+	}
+
+	requestBody := newRequestBody([]Message{
+		{Role: "system", Content: commitLintSystemPrompt},
+		{Role: "user", Content: fmt.Sprintf("%s\n\nMensaje de commit:\n<<<\n%s\n>>>", context, message)},
+	})
+	requestBody.ResponseFormat = &ResponseFormat{Type: "json_object"}
+	response, _, err := complete(requestBody)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "deepcli: no se pudo revisar el mensaje: %v\n", err)
+		os.Exit(1)
+	}
+	var verdict struct {
+		OK       bool     `json:"ok"`
+		Problems []string `json:"problems"`
+	}
+	if err := json.Unmarshal([]byte(response), &verdict); err != nil {
+		fmt.Fprintf(os.Stderr, "deepcli: el modelo no devolvió JSON válido: %v\n", err)
+		os.Exit(1)
+	}
+	if verdict.OK || len(verdict.Problems) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "deepcli: problemas en el mensaje del commit:")
+	for _, p := range verdict.Problems {
+		fmt.Fprintf(os.Stderr, "  • %s\n", p)
+	}
+	if mode == "strict" {
+		fmt.Fprintln(os.Stderr, "Commit rechazado (deepcli.lint=strict); corrige el mensaje o usa DEEPCLI_SKIP_HOOKS=1 o --no-verify")
+		os.Exit(2)
+	}
+}
+
+// installHooks escribe los hooks; los que no son de deepcli solo se
+// sustituyen con force y se conservan como .bak
+func installHooks(names []string, force bool) error {
+	dir, err := hooksDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "deepcli"
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if data, err := os.ReadFile(path); err == nil && !strings.Contains(string(data), hookMarker) {
+			if !force {
+				return fmt.Errorf("ya existe el hook %s; usa --force para sustituirlo (se guardará como %s.bak)", path, name)
+			}
+			if err := os.WriteFile(path+".bak", data, 0755); err != nil {
+				return err
+			}
+		}
+		script := fmt.Sprintf(gitHooks[name], hookMarker, shellQuote(exe))
+		if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+			return err
+		}
+		fmt.Printf("Instalado %s\n", path)
+	}
+	return nil
+}
+
+// uninstallHooks borra los hooks instalados por deepcli y restaura las copias
+func uninstallHooks(names []string) error {
+	dir, err := hooksDir()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(data), hookMarker) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		if _, err := os.Stat(path + ".bak"); err == nil {
+			if err := os.Rename(path+".bak", path); err != nil {
+				return err
+			}
+			fmt.Printf("Eliminado %s (restaurado el hook anterior)\n", path)
+		} else {
+			fmt.Printf("Eliminado %s\n", path)
+		}
+	}
+	return nil
+}
+
+func runHooks(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s hooks <install|uninstall> [opciones]
+
+Instala en el repositorio actual hooks de git que usan deepcli:
+  prepare-commit-msg   Redacta el mensaje cuando se ejecuta git commit sin -m
+  commit-msg           Revisa el mensaje y avisa de los problemas
+
+Opciones de install:
+  --only <hook>   Instalar solo prepare-commit-msg o commit-msg
+  --force         Sustituir los hooks existentes (se guardan como .bak)
+
+Configuración por repositorio (git config):
+  deepcli.draft false          No redactar mensajes
+  deepcli.lint warn|strict|off Con strict los problemas rechazan el commit
+                               (default: warn)
+  deepcli.commitStyle <texto>  Reglas del repositorio para los mensajes
+                               (p. ej. "Conventional Commits en inglés")
+  deepcli.model <modelo>       Modelo que usan los hooks
+
+Sin red o sin API key los hooks no bloquean el commit. DEEPCLI_SKIP_HOOKS=1
+los desactiva para un commit (o usa git commit --no-verify).
+
+Ejemplo:
+  %s hooks install
+  git config deepcli.lint strict
+`, os.Args[0], os.Args[0])
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("hooks", flag.ExitOnError)
+	only := fs.String("only", "", "Instalar solo este hook")
+	force := fs.Bool("force", false, "Sustituir los hooks existentes")
+	fs.BoolVar(&verbose, "v", false, "Mostrar mensajes detallados de ejecución")
+	fs.BoolVar(&verbose, "verbose", false, "Mostrar mensajes detallados de ejecución")
+	fs.Usage = usage
+
+	names := []string{"prepare-commit-msg", "commit-msg"}
+	switch args[0] {
+	case "install", "uninstall":
+		fs.Parse(args[1:])
+		if *only != "" {
+			if _, ok := gitHooks[*only]; !ok {
+				fmt.Fprintf(os.Stderr, "Error: hook desconocido %q (prepare-commit-msg o commit-msg)\n", *only)
+				os.Exit(1)
+			}
+			names = []string{*only}
+		}
+		var err error
+		if args[0] == "install" {
+			err = installHooks(names, *force)
+		} else {
+			err = uninstallHooks(names)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "run":
+		// Lo invocan los hooks instalados: hooks run <hook> <argumentos de git>
+		if len(args) < 2 || gitHooks[args[1]] == "" {
+			usage()
+			os.Exit(1)
+		}
+		verbose = os.Getenv("DEEPCLI_HOOKS_VERBOSE") == "1"
+		if !verbose {
+			logger.SetOutput(io.Discard)
+		}
+		if err := loadEnv(); err != nil {
+			statusf("Advertencia: %v\n", err)
+		}
+		model = hookSetting("model", model)
+		temperature = 0.2
+		loadAPIConfig()
+		if args[1] == "prepare-commit-msg" {
+			runHookDraft(args[2:])
+		} else {
+			runHookLint(args[2:])
+		}
+
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"html"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Elementos que se descartan con su contenido: código, estilos y la
+// estructura repetida de las páginas (navegación, cabeceras, pies, barras
+// laterales, formularios)
+var htmlSkipped = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "svg": true, "template": true,
+	"iframe": true, "nav": true, "header": true, "footer": true, "aside": true, "form": true,
+	"button": true, "select": true, "dialog": true,
+}
+
+// Elementos cuyo contenido es texto literal hasta su etiqueta de cierre
+var htmlRawText = map[string]bool{"script": true, "style": true, "textarea": true}
+
+// Elementos de bloque, que empiezan en una línea nueva
+var htmlBlocks = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true, "figure": true,
+	"figcaption": true, "details": true, "summary": true, "dl": true, "dt": true, "dd": true,
+	"address": true, "center": true,
+}
+
+var (
+	htmlTitleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title\s*>`)
+	htmlAttrRe  = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+	spacesRe    = regexp.MustCompile(`[ \t\r\n\f\v\x{a0}]+`)
+	blankRunRe  = regexp.MustCompile(`\n{3,}`)
+	codeLangRe  = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#-]+)`)
+)
+
+// htmlToken es una etiqueta de apertura o cierre, o un fragmento de texto
+type htmlToken struct {
+	Tag   string
+	End   bool
+	Attrs map[string]string
+	Text  string
+}
+
+// tokenizeHTML divide la página en etiquetas y texto. Es tolerante con el
+// HTML mal formado: lo que no se reconoce como etiqueta se trata como texto
+func tokenizeHTML(page string) []htmlToken {
+	var tokens []htmlToken
+	for len(page) > 0 {
+		lt := strings.IndexByte(page, '<')
+		if lt < 0 {
+			tokens = append(tokens, htmlToken{Text: page})
+			break
+		}
+		if lt > 0 {
+			tokens = append(tokens, htmlToken{Text: page[:lt]})
+			page = page[lt:]
+		}
+		switch {
+		case strings.HasPrefix(page, "<!--"):
+			end := strings.Index(page, "-->")
+			if end < 0 {
+				return tokens
+			}
+			page = page[end+3:]
+			continue
+		case strings.HasPrefix(page, "<!") || strings.HasPrefix(page, "<?"):
+			end := strings.IndexByte(page, '>')
+			if end < 0 {
+				return tokens
+			}
+			page = page[end+1:]
+			continue
+		}
+		end := strings.IndexByte(page, '>')
+		isEnd := strings.HasPrefix(page, "</")
+		nameStart := 1
+		if isEnd {
+			nameStart = 2
+		}
+		nameEnd := nameStart
+		for nameEnd < len(page) && (isASCIILetter(page[nameEnd]) || (nameEnd > nameStart && page[nameEnd] >= '0' && page[nameEnd] <= '9')) {
+			nameEnd++
+		}
+		if end < 0 || nameEnd == nameStart {
+			// Un < suelto es texto
+			tokens = append(tokens, htmlToken{Text: "<"})
+			page = page[1:]
+			continue
+		}
+		tag := strings.ToLower(page[nameStart:nameEnd])
+		tok := htmlToken{Tag: tag, End: isEnd}
+		if !isEnd {
+			tok.Attrs = map[string]string{}
+			for _, m := range htmlAttrRe.FindAllStringSubmatch(page[nameEnd:end], -1) {
+				tok.Attrs[strings.ToLower(m[1])] = html.UnescapeString(strings.Trim(m[2], `"'`))
+			}
+		}
+		tokens = append(tokens, tok)
+		page = page[end+1:]
+		if !isEnd && htmlRawText[tag] {
+			close := strings.Index(strings.ToLower(page), "</"+tag)
+			if close < 0 {
+				close = len(page)
+			}
+			tokens = append(tokens, htmlToken{Text: page[:close]})
+			page = page[close:]
+		}
+	}
+	return tokens
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isHTML indica si la entrada es una página HTML, por su extensión o por
+// cómo empieza
+func isHTML(name, text string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == ".html" || ext == ".htm" {
+		return true
+	}
+	start := strings.ToLower(strings.TrimSpace(text[:min(len(text), 512)]))
+	return strings.HasPrefix(start, "<!doctype html") || strings.HasPrefix(start, "<html")
+}
+
+// htmlTitle devuelve el título de la página, o "" si no tiene
+func htmlTitle(page string) string {
+	if m := htmlTitleRe.FindStringSubmatch(page); m != nil {
+		return strings.TrimSpace(spacesRe.ReplaceAllString(html.UnescapeString(m[1]), " "))
+	}
+	return ""
+}
+
+// mainContent recorta la página a su contenido principal (<main> o, si no
+// hay, <article>) cuando lo marca
+func mainContent(page string) (string, bool) {
+	lower := strings.ToLower(page)
+	for _, tag := range []string{"main", "article"} {
+		start := strings.Index(lower, "<"+tag)
+		end := strings.LastIndex(lower, "</"+tag)
+		if start >= 0 && end > start && !isASCIILetter(lower[start+len(tag)+1]) {
+			return page[start:end], true
+		}
+	}
+	return page, false
+}
+
+// markdownWriter escribe Markdown con los prefijos de línea de las citas
+// y las listas
+type markdownWriter struct {
+	sb          strings.Builder
+	cell        *strings.Builder // celda de tabla en curso
+	lineStart   bool
+	blank       bool // la última línea es la separación entre bloques
+	quoteDepth  int
+	listIndent  string
+	pendingLink []string
+}
+
+func (w *markdownWriter) out() *strings.Builder {
+	if w.cell != nil {
+		return w.cell
+	}
+	return &w.sb
+}
+
+// text escribe texto en línea con los espacios colapsados
+func (w *markdownWriter) text(s string) {
+	s = spacesRe.ReplaceAllString(s, " ")
+	if w.lineStart || w.cell != nil && w.cell.Len() == 0 {
+		s = strings.TrimLeft(s, " ")
+	}
+	if s == "" {
+		return
+	}
+	w.startLine()
+	w.out().WriteString(s)
+}
+
+// raw escribe texto sin tocar los espacios (bloques de código)
+func (w *markdownWriter) raw(s string) {
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			w.newline()
+		}
+		if line != "" {
+			w.startLine()
+			w.out().WriteString(line)
+		}
+	}
+}
+
+// startLine escribe el prefijo de la línea si es el primer texto de ella
+func (w *markdownWriter) startLine() {
+	if w.lineStart && w.cell == nil {
+		w.sb.WriteString(strings.Repeat("> ", w.quoteDepth) + w.listIndent)
+		w.lineStart, w.blank = false, false
+	}
+}
+
+func (w *markdownWriter) newline() {
+	if w.cell != nil {
+		w.cell.WriteString(" ")
+		return
+	}
+	w.sb.WriteString("\n")
+	w.lineStart = true
+}
+
+// block separa un bloque del anterior con una línea en blanco
+func (w *markdownWriter) block() {
+	if w.cell != nil {
+		w.newline()
+		return
+	}
+	if w.sb.Len() == 0 {
+		w.lineStart = true
+		return
+	}
+	if !w.lineStart {
+		w.newline()
+	}
+	if !w.blank {
+		w.sb.WriteString(strings.TrimRight(strings.Repeat("> ", w.quoteDepth), " ") + "\n")
+		w.blank = true
+	}
+}
+
+// htmlToMarkdown convierte una página HTML a Markdown: títulos, párrafos,
+// listas, enlaces, código y tablas, sin scripts, estilos ni la navegación
+// y demás estructura repetida. Los enlaces relativos se resuelven con base
+// si se indica.
+func htmlToMarkdown(page, base string) string {
+	baseURL, _ := url.Parse(base)
+	content, found := mainContent(page)
+	tokens := tokenizeHTML(content)
+	var w markdownWriter
+	w.lineStart = true
+	type list struct {
+		ordered bool
+		n       int
+	}
+	var lists []list
+	var table [][]string
+	var inPre, inTable int
+	skip, skipDepth := "", 0
+	// flushCell guarda el texto de la celda en curso en la tabla
+	flushCell := func() {
+		if w.cell != nil && len(table) > 0 {
+			if row := table[len(table)-1]; len(row) > 0 {
+				row[len(row)-1] = strings.TrimSpace(spacesRe.ReplaceAllString(w.cell.String(), " "))
+			}
+		}
+		w.cell = nil
+	}
+
+	for i, tok := range tokens {
+		if skip != "" {
+			if tok.Tag == skip {
+				if tok.End {
+					skipDepth--
+				} else {
+					skipDepth++
+				}
+				if skipDepth == 0 {
+					skip = ""
+				}
+			}
+			continue
+		}
+		if tok.Tag == "" {
+			if inPre > 0 {
+				w.raw(html.UnescapeString(tok.Text))
+			} else {
+				w.text(html.UnescapeString(tok.Text))
+			}
+			continue
+		}
+		// Dentro del contenido principal, <header> suele llevar el título
+		if !tok.End && htmlSkipped[tok.Tag] && !(found && tok.Tag == "header") {
+			skip, skipDepth = tok.Tag, 1
+			continue
+		}
+
+		switch tag := tok.Tag; {
+		case len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6':
+			w.block()
+			if !tok.End {
+				w.startLine()
+				w.out().WriteString(strings.Repeat("#", int(tag[1]-'0')) + " ")
+			}
+		case htmlBlocks[tag]:
+			w.block()
+		case tag == "br":
+			w.newline()
+		case tag == "hr" && !tok.End:
+			w.block()
+			w.startLine()
+			w.out().WriteString("---")
+			w.block()
+		case tag == "blockquote":
+			if tok.End {
+				if !w.lineStart {
+					w.newline()
+				}
+				// La separación del último párrafo de la cita sobra
+				if s := w.sb.String(); w.blank && w.cell == nil {
+					w.sb.Reset()
+					w.sb.WriteString(s[:strings.LastIndex(strings.TrimSuffix(s, "\n"), "\n")+1])
+				}
+				w.quoteDepth = max(w.quoteDepth-1, 0)
+				w.blank = false
+				w.block()
+				continue
+			}
+			w.block()
+			w.quoteDepth++
+		case tag == "ul" || tag == "ol":
+			if tok.End {
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+				w.listIndent = strings.Repeat("   ", max(len(lists)-1, 0))
+				if len(lists) == 0 {
+					w.listIndent = ""
+					w.block()
+				}
+				continue
+			}
+			if len(lists) == 0 {
+				w.block()
+			}
+			start := 1
+			if n, err := strconv.Atoi(tok.Attrs["start"]); err == nil {
+				start = n
+			}
+			lists = append(lists, list{ordered: tag == "ol", n: start - 1})
+		case tag == "li" && !tok.End:
+			if w.cell != nil {
+				w.text(" ")
+				continue
+			}
+			if !w.lineStart {
+				w.newline()
+			}
+			marker := "- "
+			if len(lists) > 0 {
+				l := &lists[len(lists)-1]
+				if l.ordered {
+					l.n++
+					marker = strconv.Itoa(l.n) + ". "
+				}
+			}
+			w.listIndent = strings.Repeat("   ", max(len(lists)-1, 0))
+			w.startLine()
+			w.out().WriteString(marker)
+			w.listIndent = strings.Repeat("   ", len(lists))
+		case tag == "pre":
+			if tok.End {
+				inPre = max(inPre-1, 0)
+				if !w.lineStart {
+					w.newline()
+				}
+				w.startLine()
+				w.out().WriteString("```")
+				w.block()
+				continue
+			}
+			w.block()
+			inPre++
+			lang := codeLangRe.FindStringSubmatch(tok.Attrs["class"])
+			if lang == nil && i+1 < len(tokens) && tokens[i+1].Tag == "code" {
+				lang = codeLangRe.FindStringSubmatch(tokens[i+1].Attrs["class"])
+			}
+			w.startLine()
+			w.out().WriteString("```")
+			if lang != nil {
+				w.out().WriteString(lang[1])
+			}
+			w.newline()
+		case tag == "code" && inPre == 0:
+			w.startLine()
+			w.out().WriteString("`")
+		case tag == "strong" || tag == "b":
+			w.startLine()
+			w.out().WriteString("**")
+		case tag == "em" || tag == "i":
+			w.startLine()
+			w.out().WriteString("_")
+		case tag == "a":
+			if tok.End {
+				if n := len(w.pendingLink); n > 0 {
+					if href := w.pendingLink[n-1]; href != "" {
+						w.out().WriteString("](" + href + ")")
+					}
+					w.pendingLink = w.pendingLink[:n-1]
+				}
+				continue
+			}
+			href := tok.Attrs["href"]
+			if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+				href = ""
+			} else if baseURL != nil {
+				if ref, err := baseURL.Parse(href); err == nil {
+					href = ref.String()
+				}
+			}
+			if href != "" {
+				w.startLine()
+				w.out().WriteString("[")
+			}
+			w.pendingLink = append(w.pendingLink, href)
+		case tag == "img" && !tok.End:
+			if alt := strings.TrimSpace(tok.Attrs["alt"]); alt != "" {
+				w.text(" [imagen: " + alt + "] ")
+			}
+		case tag == "table":
+			if tok.End {
+				inTable--
+				if inTable == 0 {
+					flushCell()
+					w.block()
+					w.startLine()
+					w.out().WriteString(strings.ReplaceAll(strings.TrimSuffix(markdownTable(table), "\n"), "\n", "\n"+strings.Repeat("> ", w.quoteDepth)+w.listIndent))
+					w.block()
+					table = nil
+				}
+				continue
+			}
+			inTable++
+		case tag == "tr" && inTable == 1:
+			flushCell()
+			if !tok.End {
+				table = append(table, nil)
+			}
+		case (tag == "td" || tag == "th") && inTable == 1 && !tok.End && len(table) > 0:
+			flushCell()
+			table[len(table)-1] = append(table[len(table)-1], "")
+			w.cell = &strings.Builder{}
+		}
+	}
+
+	md := w.sb.String()
+	lines := strings.Split(md, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.TrimSpace(blankRunRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
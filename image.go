@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Modo de --vision e idioma de --ocr-lang para las imágenes de entrada
+var (
+	visionMode string
+	ocrLang    string
+)
+
+// Fragmentos de nombres de modelo que aceptan imágenes, para --vision auto
+var visionModelHints = []string{"gpt-4o", "gpt-4.1", "gpt-5", "claude", "gemini", "vision", "-vl", "llava", "pixtral", "llama-4"}
+
+// attachedImages son las imágenes de la entrada que se envían como partes
+// image_url del mensaje de contexto
+var attachedImages []imagePart
+
+type imagePart struct {
+	Name string
+	URL  string // data URL con la imagen en base64
+}
+
+// MarshalJSON envía el contenido como lista de partes (texto e imágenes)
+// cuando el mensaje lleva imágenes; si no, como texto
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	if len(m.Images) == 0 {
+		return json.Marshal(plain(m))
+	}
+	type part struct {
+		Type     string `json:"type"`
+		Text     string `json:"text,omitempty"`
+		ImageURL *struct {
+			URL string `json:"url"`
+		} `json:"image_url,omitempty"`
+	}
+	parts := []part{{Type: "text", Text: m.Content}}
+	for _, img := range m.Images {
+		p := part{Type: "image_url", ImageURL: &struct {
+			URL string `json:"url"`
+		}{img.URL}}
+		parts = append(parts, p)
+	}
+	return json.Marshal(struct {
+		Role    string `json:"role"`
+		Content []part `json:"content"`
+		Prefix  bool   `json:"prefix,omitempty"`
+	}{m.Role, parts, m.Prefix})
+}
+
+// imageType devuelve el tipo MIME si la entrada es una imagen admitida
+func imageType(name string, data []byte) string {
+	mime := http.DetectContentType(data)
+	switch mime {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return mime
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	}
+	return ""
+}
+
+// supportsVision indica si el proveedor o el modelo activos aceptan
+// imágenes: según --vision, la opción vision del proveedor en config.yaml
+// o el nombre del modelo
+func supportsVision() bool {
+	switch visionMode {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+	if provider != nil && provider.Vision {
+		return true
+	}
+	name := strings.ToLower(providerModel(model))
+	for _, hint := range visionModelHints {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareImage adjunta la imagen al mensaje si el modelo la admite, o
+// extrae su texto con tesseract en caso contrario, para poder analizar
+// capturas de pantalla de trazas y errores
+func prepareImage(name, mime string, data []byte) (string, error) {
+	size := ""
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		size = fmt.Sprintf(", %dx%d", cfg.Width, cfg.Height)
+	}
+	if supportsVision() {
+		attachedImages = append(attachedImages, imagePart{
+			Name: name,
+			URL:  "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data),
+		})
+		logger.Printf("%s: imagen adjunta (%s%s)\n", name, mime, size)
+		return fmt.Sprintf("[Imagen adjunta: %s (%s%s)]", name, mime, size), nil
+	}
+
+	if _, err := exec.LookPath("tesseract"); err != nil {
+		return "", fmt.Errorf("%s: el modelo %s no admite imágenes y no se encontró tesseract para extraer el texto (usa --vision on si el modelo las admite)", name, providerModel(model))
+	}
+	args := []string{"stdin", "stdout"}
+	if ocrLang != "" {
+		args = append(args, "-l", ocrLang)
+	}
+	cmd := exec.Command("tesseract", args...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: tesseract falló: %v: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	text := strings.TrimSpace(string(out))
+	if text == "" {
+		return "", fmt.Errorf("%s: el OCR no encontró texto en la imagen", name)
+	}
+	logger.Printf("%s: texto extraído por OCR (%d bytes)\n", name, len(text))
+	return fmt.Sprintf("Texto extraído por OCR de la imagen %s%s (puede contener errores de reconocimiento):\n%s", name, size, text), nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// readInput lee la entrada de stdin (pipe) y de los archivos indicados.
+// Devuelve las fuentes de contexto y el contenido crudo de stdin; cada
+// archivo se etiqueta con su ruta y su lenguaje.
+func readInput(inputFiles []string) (sources []*contextSource, stdinData string) {
+	// Verificar si hay datos en stdin (pipe)
+	stat, _ := os.Stdin.Stat()
+	if (stat.Mode() & os.ModeCharDevice) == 0 {
+		logger.Println("Leyendo datos de stdin...")
+		var buf bytes.Buffer
+		_, err := io.Copy(&buf, os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al leer de stdin: %v\n", err)
+			os.Exit(1)
+		}
+		stdinData, err = prepareInput("stdin", buf.Bytes())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		logger.Printf("Leídos %d bytes de stdin (usados como %s)\n", len(stdinData), stdinAs)
+		if stdinAs == "context" && stdinData != "" {
+			source := newContextSource("stdin", "stdin", stdinData, false)
+			source.Raw = stdinData
+			sources = append(sources, source)
+		}
+	}
+
+	// Leer los archivos de entrada indicados; archivo:120-240 o --around
+	// limitan el contenido a un rango de líneas
+	var around *lineRange
+	if aroundSpec != "" {
+		around, _ = parseAround(aroundSpec)
+	}
+	for _, arg := range inputFiles {
+		inputFile, lines, err := parseFileArg(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if lines == nil {
+			lines = around
+		}
+		logger.Printf("Leyendo archivo de entrada: %s\n", inputFile)
+		data, err := readContextFile(inputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al leer el archivo de entrada: %v\n", err)
+			os.Exit(1)
+		}
+		fileContent, err := prepareInput(inputFile, data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		// Cada archivo va en un bloque con su lenguaje y una cabecera con su
+		// ruta, tamaño y líneas; un rango indica además qué líneas son
+		var labeled string
+		if lines == nil {
+			labeled = labelFile(inputFile, fileContent)
+		} else {
+			var total int
+			var r lineRange
+			fileContent, r, total, err = sliceLines(fileContent, *lines)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", inputFile, err)
+				os.Exit(1)
+			}
+			labeled = fencedFile(fmt.Sprintf("Archivo %s (líneas %d-%d de %d)", inputFile, r.From, r.To, total), inputFile, fileContent)
+			logger.Printf("%s: se envían las líneas %d-%d de %d\n", inputFile, r.From, r.To, total)
+		}
+		source := newContextSource("file", inputFile, labeled, true)
+		source.Raw = fileContent
+		sources = append(sources, source)
+	}
+
+	return sources, stdinData
+}
+
+// labelFile etiqueta el contenido de un archivo con su ruta, su lenguaje,
+// su tamaño y sus líneas, en un bloque con el lenguaje detectado
+func labelFile(name, content string) string {
+	return fencedFile(fmt.Sprintf("Archivo %s (%s)", name, fileStats(name, content)), name, content)
+}
+
+// shellCommand prepara un comando para ejecutarlo con el shell del sistema
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// execContext ejecuta los comandos de --exec y devuelve su salida etiquetada
+// con el comando; un código de salida distinto de cero no es un error, ya que
+// suele ser justo lo que se quiere analizar
+func execContext(commands []string) ([]*contextSource, error) {
+	var sources []*contextSource
+	for _, command := range commands {
+		logger.Printf("Ejecutando: %s\n", command)
+		cmd := shellCommand(command)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("no se pudo ejecutar %q: %v", command, err)
+		}
+
+		out, perr := prepareInput(command, stdout.Bytes())
+		if perr != nil {
+			return nil, perr
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Salida de `%s`:\n```\n%s\n```\n", command, strings.TrimRight(out, "\n"))
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			fmt.Fprintf(&sb, "Errores (stderr):\n```\n%s\n```\n", s)
+		}
+		if exitErr != nil {
+			fmt.Fprintf(&sb, "(código de salida %d)\n", exitErr.ExitCode())
+		}
+		sources = append(sources, newContextSource("exec", command, strings.TrimRight(sb.String(), "\n"), true))
+	}
+	return sources, nil
+}
+
+// resolvePrompt obtiene la instrucción de -i, de los argumentos o de stdin
+func resolvePrompt(instruction string, args []string, stdinData string) string {
+	var prompt string
+	if instruction != "" {
+		prompt = instruction
+	} else if len(args) > 0 {
+		prompt = strings.Join(args, " ")
+	}
+
+	// Con --stdin-as prompt, stdin es la instrucción (o se añade a ella)
+	if stdinAs == "prompt" && strings.TrimSpace(stdinData) != "" {
+		if prompt != "" {
+			prompt += "\n\n" + stdinData
+		} else {
+			prompt = stdinData
+		}
+	}
+	return prompt
+}
+
+// priorInstructions son las instrucciones de -i repetido anteriores a la
+// última, que se envían en orden como mensajes propios antes que ella
+var priorInstructions []string
+
+// buildMessages construye los mensajes para la API a partir de la
+// instrucción y el contexto
+func buildMessages(prompt, input, stdinData string) []Message {
+	var messages []Message
+	// contextIndex es la posición del mensaje de contexto, si lo hay
+	contextIndex := -1
+
+	// Con --stdin-as system, stdin reemplaza el mensaje de sistema
+	if stdinAs == "system" && strings.TrimSpace(stdinData) != "" {
+		messages = append(messages, Message{
+			Role:    "system",
+			Content: stdinData,
+		})
+	}
+
+	// Si hay input (de pipe o archivo), agregarlo como contexto
+	if input != "" {
+		if len(messages) == 0 && !noPreamble && contextSystem != "" {
+			messages = append(messages, Message{
+				Role:    "system",
+				Content: contextSystem,
+			})
+		}
+
+		content := input
+		if !noPreamble {
+			var err error
+			content, err = renderContext(contextTemplate, input)
+			if err != nil {
+				fatalf("Error: %v\n", err)
+			}
+		}
+		contextIndex = len(messages)
+		messages = append(messages, Message{
+			Role:    "user",
+			Content: content,
+		})
+	}
+
+	// Agregar las instrucciones del usuario
+	for _, p := range priorInstructions {
+		messages = append(messages, Message{Role: "user", Content: p})
+	}
+	messages = append(messages, Message{
+		Role:    "user",
+		Content: prompt,
+	})
+
+	// Las imágenes de la entrada van con el contexto o, si no lo hay, con la
+	// instrucción
+	if len(attachedImages) > 0 {
+		i := contextIndex
+		if i < 0 {
+			i = len(messages) - 1
+		}
+		messages[i].Images = attachedImages
+	}
+
+	return messages
+}
+
+// loadMessages lee un array de mensajes en JSON, ya sea del valor de
+// --messages-json o de un archivo ("-" para stdin)
+func loadMessages(jsonText, file string) ([]Message, error) {
+	data := []byte(jsonText)
+	if file != "" {
+		var err error
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error al leer el archivo de mensajes: %v", err)
+		}
+	}
+
+	var messages []Message
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("el array de mensajes no es JSON válido: %v", err)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("el array de mensajes está vacío")
+	}
+	for i, m := range messages {
+		switch m.Role {
+		case "system", "user", "assistant", "tool":
+		default:
+			return nil, fmt.Errorf("mensaje %d: rol inválido %q", i, m.Role)
+		}
+	}
+	return messages, nil
+}
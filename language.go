@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Lenguaje de los bloques de código según la extensión del archivo; la
+// etiqueta es la que reconocen los renderizadores de Markdown
+var extLanguages = map[string]string{
+	".go": "go", ".py": "python", ".pyi": "python", ".rb": "ruby", ".rs": "rust",
+	".js": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".jsx": "jsx",
+	".ts": "typescript", ".mts": "typescript", ".cts": "typescript", ".tsx": "tsx",
+	".java": "java", ".kt": "kotlin", ".kts": "kotlin", ".scala": "scala", ".groovy": "groovy",
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp", ".hpp": "cpp", ".hh": "cpp",
+	".cs": "csharp", ".fs": "fsharp", ".swift": "swift", ".m": "objectivec", ".dart": "dart",
+	".php": "php", ".pl": "perl", ".pm": "perl", ".lua": "lua", ".r": "r", ".jl": "julia",
+	".ex": "elixir", ".exs": "elixir", ".erl": "erlang", ".hs": "haskell", ".ml": "ocaml",
+	".clj": "clojure", ".zig": "zig", ".nim": "nim", ".v": "verilog", ".vhd": "vhdl",
+	".sh": "bash", ".bash": "bash", ".zsh": "zsh", ".fish": "fish", ".ps1": "powershell", ".bat": "batch",
+	".sql": "sql", ".graphql": "graphql", ".proto": "protobuf", ".tf": "hcl", ".hcl": "hcl",
+	".html": "html", ".htm": "html", ".css": "css", ".scss": "scss", ".sass": "sass", ".less": "less",
+	".vue": "vue", ".svelte": "svelte", ".xml": "xml", ".svg": "xml",
+	".json": "json", ".jsonl": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml", ".ini": "ini",
+	".md": "markdown", ".rst": "rst", ".tex": "latex", ".csv": "csv",
+	".diff": "diff", ".patch": "diff", ".mk": "makefile", ".cmake": "cmake", ".nix": "nix",
+}
+
+// Archivos sin extensión (o con una que no dice nada) que se reconocen por
+// su nombre
+var nameLanguages = map[string]string{
+	"makefile": "makefile", "gnumakefile": "makefile", "dockerfile": "dockerfile",
+	"containerfile": "dockerfile", "cmakelists.txt": "cmake", "go.mod": "go-mod",
+	"jenkinsfile": "groovy", "vagrantfile": "ruby", "gemfile": "ruby", "rakefile": "ruby",
+	".bashrc": "bash", ".zshrc": "zsh", ".profile": "bash",
+}
+
+// Intérpretes de la línea #! de los scripts
+var shebangLanguages = map[string]string{
+	"sh": "bash", "bash": "bash", "dash": "bash", "ash": "bash", "zsh": "zsh", "fish": "fish",
+	"python": "python", "python2": "python", "python3": "python", "node": "javascript",
+	"deno": "typescript", "bun": "javascript", "ts-node": "typescript", "ruby": "ruby",
+	"perl": "perl", "php": "php", "lua": "lua", "Rscript": "r", "pwsh": "powershell",
+}
+
+// detectLanguage devuelve la etiqueta del lenguaje de un archivo por su
+// extensión, su nombre o la línea #!, o "" si no se reconoce
+func detectLanguage(name, content string) string {
+	base := strings.ToLower(filepath.Base(name))
+	if lang, ok := nameLanguages[base]; ok {
+		return lang
+	}
+	if strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile") {
+		return "dockerfile"
+	}
+	if lang, ok := extLanguages[filepath.Ext(base)]; ok {
+		return lang
+	}
+	return shebangLanguage(content)
+}
+
+// shebangLanguage reconoce el intérprete de la línea #! (también con
+// /usr/bin/env y sus opciones)
+func shebangLanguage(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(content[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interpreter = f
+				break
+			}
+		}
+	}
+	if lang, ok := shebangLanguages[interpreter]; ok {
+		return lang
+	}
+	// python3.12, perl5.36...
+	if i := strings.IndexAny(interpreter, "0123456789"); i > 0 {
+		return shebangLanguages[interpreter[:i]]
+	}
+	return ""
+}
+
+// codeFence devuelve el delimitador de bloque para el contenido: uno más
+// largo que cualquier secuencia de comillas invertidas que contenga, para
+// que un README o un archivo con bloques propios no cierre el bloque antes
+func codeFence(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// fencedFile devuelve el contenido de un archivo con una cabecera y en un
+// bloque etiquetado con su lenguaje
+func fencedFile(header, name, content string) string {
+	content = strings.TrimRight(content, "\n")
+	fence := codeFence(content)
+	return fmt.Sprintf("%s:\n%s%s\n%s\n%s", header, fence, detectLanguage(name, content), content, fence)
+}
+
+// fileStats resume el lenguaje, el tamaño y las líneas de un archivo para
+// su cabecera: "go, 2.1 KB, 80 líneas"
+func fileStats(name, content string) string {
+	var parts []string
+	if lang := detectLanguage(name, content); lang != "" {
+		parts = append(parts, lang)
+	}
+	return strings.Join(append(parts, formatSize(len(content)), lineCount(countLines(content))), ", ")
+}
+
+// countLines cuenta las líneas del texto, con o sin salto final
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
+
+func lineCount(n int) string {
+	if n == 1 {
+		return "1 línea"
+	}
+	return fmt.Sprintf("%d líneas", n)
+}
+
+// formatSize expresa un tamaño en bytes en la unidad más legible
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
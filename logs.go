@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const logsSystemPrompt = `Eres un experto en operaciones y depuración de sistemas. Recibirás un log ` +
+	`resumido: las líneas similares se han agrupado en plantillas (<*> marca las partes variables) con su ` +
+	`número de apariciones, su primera y última línea y algunos ejemplos. Identifica los problemas, ` +
+	`relaciona los errores que comparten causa, explica la causa raíz más probable de cada uno y propón ` +
+	`cómo confirmarla y corregirla, de lo más a lo menos grave.`
+
+const (
+	// Plantillas como máximo; las líneas que no encajan en ninguna cuando
+	// se alcanza el límite se cuentan aparte
+	maxLogClusters = 5000
+	// Ejemplos por plantilla y tamaño máximo de cada uno
+	logSamples      = 3
+	maxLogSampleLen = 1000
+	// Tokens de la primera línea que se usan para agrupar las plantillas
+	logPrefixTokens = 2
+)
+
+// Partes variables de una línea: marcas de tiempo, UUID, direcciones IP,
+// hexadecimales y números
+var logVariableRes = []*regexp.Regexp{
+	regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}(:\d{2}([.,]\d+)?)?(Z|[+-]\d{2}:?\d{2})?)?$`),
+	regexp.MustCompile(`^\d{2}:\d{2}:\d{2}([.,]\d+)?$`),
+	regexp.MustCompile(`(?i)^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`),
+	regexp.MustCompile(`^\d{1,3}(\.\d{1,3}){3}(:\d+)?$`),
+	regexp.MustCompile(`(?i)^(0x)?[0-9a-f]*\d[0-9a-f]*$`),
+	regexp.MustCompile(`^[-+]?\d+([.,]\d+)?(ms|s|µs|ns|b|kb|mb|gb|%)?$`),
+}
+
+// logLevelRe detecta el nivel de la línea
+var logLevelRe = regexp.MustCompile(`(?i)\b(fatal|panic|critical|error|err|warn|warning|info|debug|trace)\b`)
+
+// logCluster es una plantilla de líneas similares
+type logCluster struct {
+	Template []string
+	Count    int
+	First    int // número de la primera línea
+	Last     int
+	Level    string
+	Samples  []string
+}
+
+// logClusterer agrupa líneas con un algoritmo del estilo de Drain: las
+// líneas se clasifican por número de tokens y primeros tokens fijos, y
+// dentro de cada grupo se asignan a la plantilla más parecida
+type logClusterer struct {
+	similarity float64
+	groups     map[string][]*logCluster
+	clusters   []*logCluster
+	unmatched  int
+}
+
+func newLogClusterer(similarity float64) *logClusterer {
+	return &logClusterer{similarity: similarity, groups: map[string][]*logCluster{}}
+}
+
+// logTokens divide la línea en tokens y sustituye las partes variables
+func logTokens(line string) []string {
+	tokens := strings.Fields(line)
+	for i, t := range tokens {
+		trimmed := strings.Trim(t, "[](),;\"'")
+		for _, re := range logVariableRes {
+			if re.MatchString(trimmed) {
+				tokens[i] = "<*>"
+				break
+			}
+		}
+		// Pares clave=valor: solo el valor es variable
+		if k, v, ok := strings.Cut(t, "="); ok && tokens[i] != "<*>" && v != "" && k != "" {
+			if strings.ContainsAny(v, "0123456789") {
+				tokens[i] = k + "=<*>"
+			}
+		}
+	}
+	return tokens
+}
+
+// add asigna un registro (una línea y sus líneas de continuación) a una
+// plantilla
+func (c *logClusterer) add(record string, lineNo int) {
+	first := record
+	if i := strings.IndexByte(record, '\n'); i >= 0 {
+		first = record[:i]
+	}
+	tokens := logTokens(first)
+	key := fmt.Sprint(len(tokens))
+	for i, n := 0, 0; n < logPrefixTokens && i < len(tokens); i++ {
+		if tokens[i] != "<*>" {
+			key += " " + tokens[i]
+			n++
+		}
+	}
+
+	var best *logCluster
+	bestScore := -1.0
+	for _, cl := range c.groups[key] {
+		if score := templateSimilarity(cl.Template, tokens); score >= c.similarity && score > bestScore {
+			best, bestScore = cl, score
+		}
+	}
+	if best == nil {
+		if len(c.clusters) >= maxLogClusters {
+			c.unmatched++
+			return
+		}
+		best = &logCluster{Template: tokens, First: lineNo}
+		c.groups[key] = append(c.groups[key], best)
+		c.clusters = append(c.clusters, best)
+	} else {
+		for i := range best.Template {
+			if best.Template[i] != tokens[i] {
+				best.Template[i] = "<*>"
+			}
+		}
+	}
+	best.Count++
+	best.Last = lineNo
+	if m := logLevelRe.FindString(first); m != "" && best.Level == "" {
+		best.Level = normalizeLevel(m)
+	}
+	if len(best.Samples) < logSamples {
+		best.Samples = append(best.Samples, clip(record, maxLogSampleLen))
+	}
+}
+
+// templateSimilarity es la fracción de tokens fijos de la plantilla que
+// coinciden con los de la línea
+func templateSimilarity(template, tokens []string) float64 {
+	fixed, same := 0, 0
+	for i := range template {
+		if template[i] == "<*>" {
+			continue
+		}
+		fixed++
+		if template[i] == tokens[i] {
+			same++
+		}
+	}
+	if fixed == 0 {
+		return 1
+	}
+	return float64(same) / float64(fixed)
+}
+
+func normalizeLevel(level string) string {
+	switch strings.ToLower(level) {
+	case "fatal", "panic", "critical":
+		return "FATAL"
+	case "error", "err":
+		return "ERROR"
+	case "warn", "warning":
+		return "WARN"
+	case "info":
+		return "INFO"
+	}
+	return "DEBUG"
+}
+
+var levelRank = map[string]int{"FATAL": 0, "ERROR": 1, "WARN": 2, "": 3, "INFO": 4, "DEBUG": 5}
+
+// isContinuation indica si la línea continúa el registro anterior (trazas
+// de pila, mensajes multilínea)
+func isContinuation(line string) bool {
+	return line != "" && (line[0] == ' ' || line[0] == '\t' || strings.HasPrefix(line, "Caused by:") ||
+		strings.HasPrefix(line, "goroutine ") || strings.HasPrefix(line, "Traceback"))
+}
+
+// clusterLog lee el log línea a línea, sin cargarlo entero en memoria
+func clusterLog(r io.Reader, c *logClusterer) (int, error) {
+	reader := bufio.NewReaderSize(r, 1024*1024)
+	lines := 0
+	var record strings.Builder
+	recordLine := 0
+	flush := func() {
+		if record.Len() > 0 {
+			c.add(record.String(), recordLine)
+			record.Reset()
+		}
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lines++
+			line = strings.TrimRight(line, "\r\n")
+			if isContinuation(line) && record.Len() > 0 {
+				if record.Len() < maxLogSampleLen {
+					record.WriteString("\n" + line)
+				}
+			} else if strings.TrimSpace(line) != "" {
+				flush()
+				record.WriteString(line)
+				recordLine = lines
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+	flush()
+	return lines, nil
+}
+
+// logReport ordena las plantillas (primero las más graves y frecuentes) y
+// las describe hasta llenar el presupuesto
+func logReport(c *logClusterer, lines, top, budget int) string {
+	clusters := c.clusters
+	sort.SliceStable(clusters, func(i, j int) bool {
+		a, b := clusters[i], clusters[j]
+		if levelRank[a.Level] != levelRank[b.Level] {
+			return levelRank[a.Level] < levelRank[b.Level]
+		}
+		return a.Count > b.Count
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d líneas agrupadas en %d plantillas", lines, len(clusters))
+	if c.unmatched > 0 {
+		fmt.Fprintf(&sb, " (%d registros sin plantilla al alcanzar el límite de %d)", c.unmatched, maxLogClusters)
+	}
+	sb.WriteString(".\n")
+	shown := 0
+	for i, cl := range clusters {
+		if shown >= top {
+			break
+		}
+		var entry strings.Builder
+		level := cl.Level
+		if level == "" {
+			level = "-"
+		}
+		fmt.Fprintf(&entry, "\n[%d] %s ×%d (líneas %d-%d)\n  %s\n", i+1, level, cl.Count, cl.First, cl.Last, strings.Join(cl.Template, " "))
+		for _, s := range cl.Samples {
+			entry.WriteString("  ejemplo: " + strings.ReplaceAll(s, "\n", "\n    ") + "\n")
+		}
+		if estimateTokens(sb.String()+entry.String()) > budget {
+			break
+		}
+		sb.WriteString(entry.String())
+		shown++
+	}
+	if shown < len(clusters) {
+		rest := 0
+		for _, cl := range clusters[shown:] {
+			rest += cl.Count
+		}
+		fmt.Fprintf(&sb, "\n(%d plantillas más con %d registros omitidas)\n", len(clusters)-shown, rest)
+	}
+	return sb.String()
+}
+
+func runLogs(args []string) {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	var files stringList
+	fs.Var(&files, "f", "Archivo de log (repetible)")
+	fs.Var(&files, "file", "Archivo de log (repetible)")
+	instruction := fs.String("i", "", "Instrucciones adicionales para el análisis")
+	fs.StringVar(instruction, "instruction", "", "Instrucciones adicionales para el análisis")
+	top := fs.Int("top", 100, "Máximo de plantillas que se envían")
+	similarity := fs.Float64("similarity", 0.5, "Similitud mínima para agrupar líneas (0-1)")
+	summaryOnly := fs.Bool("summary-only", false, "Mostrar solo las plantillas, sin consultar al modelo")
+	addModelFlags(fs, 0.3)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s logs -f <archivo> [opciones]
+
+Analiza un log: agrupa localmente las líneas similares en plantillas (las
+partes variables como fechas, IDs, IPs y números se sustituyen por <*>) y
+envía al modelo las plantillas con su recuento y algunos ejemplos, primero
+las más graves y frecuentes, para que explique la causa raíz. El log se lee
+línea a línea, por lo que admite archivos de varios GB. Las líneas que
+empiezan por espacios (trazas de pila) se unen a la anterior. Sin -f el
+log se lee de stdin.
+
+Opciones:
+  -f, --file <archivo>   Archivo de log (repetible)
+  -i, --instruction      Instrucciones adicionales (p. ej. "errores desde las 14:00")
+  --top <n>              Máximo de plantillas a enviar (default: 100)
+  --similarity <0-1>     Similitud mínima para agrupar líneas (default: 0.5)
+  --summary-only         Mostrar las plantillas sin consultar al modelo
+  -t, --temperature      Temperatura (default: 0.3)
+  -m, --maxtokens        Máximo de tokens a generar
+  -v, --verbose          Mostrar logs detallados
+
+Ejemplos:
+  %s logs -f app.log
+  journalctl -u api --since today | %s logs -i "¿por qué se reinicia?"
+`, os.Args[0], os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 || *similarity <= 0 || *similarity > 1 || *top <= 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if len(files) == 0 && isTerminal(os.Stdin) {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	c := newLogClusterer(*similarity)
+	lines := 0
+	if len(files) == 0 {
+		n, err := clusterLog(os.Stdin, c)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al leer de stdin: %v\n", err)
+			os.Exit(1)
+		}
+		lines += n
+	}
+	for _, name := range files {
+		if err := checkPolicyFile(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		n, err := clusterLog(f, c)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al leer %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		lines += n
+	}
+	if lines == 0 {
+		fmt.Fprintf(os.Stderr, "Error: el log está vacío\n")
+		os.Exit(1)
+	}
+
+	if *summaryOnly {
+		fmt.Print(logReport(c, lines, *top, 1<<30))
+		return
+	}
+	setupSubcommand()
+	budget := contextWindow(model) - maxTokens - estimateTokens(logsSystemPrompt+*instruction) - contextMargin
+	report := logReport(c, lines, *top, budget)
+	prompt := "Log resumido:\n" + report
+	if *instruction != "" {
+		prompt += "\nInstrucciones adicionales: " + *instruction
+	}
+	statusf("%d líneas en %d plantillas; analizando...\n", lines, len(c.clusters))
+	response, _, err := complete(newRequestBody([]Message{
+		{Role: "system", Content: logsSystemPrompt},
+		{Role: "user", Content: prompt},
+	}))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" {
+		response = renderMarkdown(response)
+	}
+	fmt.Println(response)
+}
@@ -2,38 +2,106 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 const (
-	apiURL             = "https://api.deepseek.com/v1/chat/completions"
-	model              = "deepseek-chat"
+	defaultBaseURL     = "https://api.deepseek.com/v1"
+	betaBaseURL        = "https://api.deepseek.com/beta"
+	defaultModel       = "deepseek-chat"
 	defaultMaxTokens   = 2048
 	defaultTemperature = 0.7
 	envFile            = ".env"
+
+	// Preámbulo por defecto para el contexto (stdin o archivo)
+	defaultContextSystem   = "Eres un asistente de programación experto. Ayudarás con código proporcionado por el usuario."
+	defaultContextTemplate = "Este es el código con el que necesito ayuda:\n{{.Input}}"
 )
 
 var (
 	apiKey      string
+	apiBaseURL  = defaultBaseURL
+	model       = defaultModel
 	verbose     bool
 	maxTokens   int
 	temperature float64
 	rawOutput   bool
+	rawPretty   bool
+	betaMode    bool
+	notifyURL   string
+	notifyDone  bool
+	quiet       bool
+	stdinAs     string
+	binaryMode  string
 	logger      *log.Logger
+
+	contextSystem   = defaultContextSystem
+	contextTemplate = defaultContextTemplate
+	noPreamble      bool
+
+	messagesJSON      string
+	messagesFile      string
+	prefill           string
+	jsonOutput        bool
+	logprobs          logprobsFlag
+	codeOnly          codeOnlyFlag
+	numChoices        int
+	goldenDir         string
+	updateGolden      bool
+	appendOutput      bool
+	backupOutput      bool
+	gistUpload        bool
+	gistPublic        bool
+	streamOutput      bool
+	execCommands      stringList
+	contextPriorities stringList
+	aboutSymbols      stringList
+	goPackages        stringList
+	contextBudget     int
+	gitContextOn      bool
+	gitDiff           bool
+	autoContinue      bool
+	maxContinues      int
+
+	forceRender    bool
+	forcePlain     bool
+	outputWidth    int
+	formatTemplate string
+	outputTemplate *template.Template
+
+	promptName     string
+	promptTemplate *PromptTemplate
+	stopSequences  []string
+
+	// Estado de la ejecución para las notificaciones de fin
+	startTime  time.Time
+	promptHash string
+	usage      Usage
 )
 
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	Prefix  bool   `json:"prefix,omitempty"`
+
+	// Imágenes adjuntas, que se envían como partes del contenido
+	Images []imagePart `json:"-"`
 }
 
 type RequestBody struct {
@@ -42,15 +110,67 @@ type RequestBody struct {
 	MaxTokens   int       `json:"max_tokens"`
 	Temperature float64   `json:"temperature"`
 	Stream      bool      `json:"stream"`
+	Logprobs    bool      `json:"logprobs,omitempty"`
+	TopLogprobs int       `json:"top_logprobs,omitempty"`
+	N           int       `json:"n,omitempty"`
+	Stop        []string  `json:"stop,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+
+	// distinct impide agrupar la solicitud con otra idéntica en curso,
+	// cuando se quieren respuestas independientes (--best-of)
+	distinct bool
+
+	// ctx permite cancelar la solicitud (Ctrl+C durante el streaming)
+	ctx context.Context
+}
+
+type ResponseFormat struct {
+	Type string `json:"type"`
+}
+
+type Usage struct {
+	PromptTokens         int `json:"prompt_tokens"`
+	CompletionTokens     int `json:"completion_tokens"`
+	TotalTokens          int `json:"total_tokens"`
+	PromptCacheHitTokens int `json:"prompt_cache_hit_tokens,omitempty"`
+}
+
+// add acumula el uso de otra solicitud (continuaciones, reconexiones)
+func (u *Usage) add(o Usage) {
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.TotalTokens += o.TotalTokens
+	u.PromptCacheHitTokens += o.PromptCacheHitTokens
+}
+
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes,omitempty"`
+}
+
+type Logprobs struct {
+	Content []struct {
+		TokenLogprob
+		TopLogprobs []TokenLogprob `json:"top_logprobs,omitempty"`
+	} `json:"content"`
+}
+
+type Choice struct {
+	Index   int `json:"index"`
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	FinishReason string    `json:"finish_reason"`
+	Logprobs     *Logprobs `json:"logprobs,omitempty"`
 }
 
 type ResponseBody struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
-	Error struct {
+	Model   string   `json:"model"`
+	Choices []Choice `json:"choices"`
+	Usage   Usage    `json:"usage"`
+	Error   struct {
 		Message string `json:"message"`
 	} `json:"error"`
 }
@@ -73,7 +193,118 @@ func loadEnv() error {
 	return nil
 }
 
-func printHelp() {
+// finish ejecuta las notificaciones de fin de ejecución
+func finish(success bool, errMsg string) {
+	if success {
+		warnLowBalance()
+	}
+	if notifyURL == "" && !notifyDone {
+		return
+	}
+	summary := runSummary{
+		PromptHash: promptHash,
+		Model:      model,
+		DurationMs: time.Since(startTime).Milliseconds(),
+		Usage:      usage,
+		Success:    success,
+		Error:      errMsg,
+	}
+	summary.CostUSD, _ = estimateCost(activeProvider, providerModel(model), usage)
+	if notifyDone {
+		title, message := notifySummaryText(summary)
+		if err := desktopNotify(title, message); err != nil {
+			statusf("Advertencia: no se pudo enviar la notificación de escritorio: %v\n", err)
+		}
+	}
+	if notifyURL != "" {
+		logger.Printf("Enviando notificación a %s\n", notifyURL)
+		if err := sendWebhook(notifyURL, summary); err != nil {
+			statusf("Advertencia: %v\n", err)
+		}
+	}
+}
+
+// saveOutput escribe la respuesta en el archivo (o el objeto remoto) de -o
+// y, con --with-metadata, los metadatos de la ejecución al lado
+func saveOutput(path, output string, requestBody RequestBody, partial bool) {
+	logger.Printf("Escribiendo respuesta en archivo: %s\n", path)
+	var err error
+	if isObjectStorageURL(path) {
+		err = uploadObject(path, []byte(output))
+	} else {
+		err = writeOutputFile(path, []byte(output), appendOutput, backupOutput)
+	}
+	if err != nil {
+		fatalf("Error al escribir en el archivo de salida: %v\n", err)
+	}
+	statusf("Respuesta escrita en %s\n", path)
+
+	if !withMetadata {
+		return
+	}
+	data, _ := json.MarshalIndent(newRunMetadata(path, output, requestBody, partial), "", "  ")
+	data = append(data, '\n')
+	if isObjectStorageURL(path) {
+		err = uploadObject(metadataPath(path), data)
+	} else {
+		// Los metadatos describen la última ejecución, también con --append
+		err = writeOutputFile(metadataPath(path), data, false, false)
+	}
+	if err != nil {
+		fatalf("Error al escribir los metadatos de la salida: %v\n", err)
+	}
+	logger.Printf("Metadatos escritos en %s\n", metadataPath(path))
+}
+
+// fatalf muestra el error, notifica el fallo y termina la ejecución
+func fatalf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprint(os.Stderr, msg)
+	finish(false, strings.TrimSpace(msg))
+	os.Exit(1)
+}
+
+// statusf muestra mensajes de estado en stderr, salvo en modo silencioso,
+// para que stdout contenga solo la respuesta del modelo
+func statusf(format string, args ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// isFlagSet indica si alguno de los flags indicados se pasó explícitamente
+func isFlagSet(names ...string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			if f.Name == name {
+				set = true
+			}
+		}
+	})
+	return set
+}
+
+// renderContext aplica la plantilla de contexto al contenido de entrada
+func renderContext(tmpl, input string) (string, error) {
+	t, err := template.New("context").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("plantilla de contexto inválida: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, struct{ Input string }{input}); err != nil {
+		return "", fmt.Errorf("error al aplicar la plantilla de contexto: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// unescapeFlag interpreta las secuencias \n, \t y \\ escritas en un flag
+func unescapeFlag(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t").Replace(s)
+}
+
+func printHelp(w io.Writer) {
 	helpText := `
 deepcli - Asistente de desarrollo por terminal en español usando DeepSeek,
 programado por nchgroup con <3 para la comunidad.
@@ -88,16 +319,214 @@ Descripción:
   ideal para análisis de código, asistencia técnica y generación de contenido.
 
 Argumentos principales:
-  -i, --instruction <texto>   Consulta/prompt para la IA (requerido)
-  -f, --file <archivo>        Archivo a analizar (opcional)
-  -o, --output <archivo>      Guardar respuesta en archivo (opcional)
+  -i, --instruction <texto>   Consulta/prompt para la IA (requerido). Con
+                              varios -i, cada uno se envía como un mensaje
+                              del usuario, en orden: -i "resume el código"
+                              -i "después enumera los riesgos"
+  -f, --file <archivo>        Archivo a analizar (opcional, repetible). Cada
+                              uno se envía con su ruta, tamaño y líneas, en
+                              un bloque con el lenguaje detectado por la
+                              extensión, el nombre o la línea #!.
+                              Las capturas HAR y las exportaciones XML de
+                              Burp se resumen (solicitudes, respuestas,
+                              cabeceras de autenticación y seguridad y
+                              cuerpos recortados) para caber en la ventana.
+                              Los documentos DOCX y ODT se convierten a
+                              Markdown (títulos, listas y tablas).
+                              Las páginas HTML (también por stdin) se
+                              convierten a Markdown, sin scripts, estilos,
+                              navegación, cabeceras ni pies.
+                              Con archivo:120-240 (o archivo:120-,
+                              archivo:120) solo se envían esas líneas.
+                              Con usuario@host:/ruta (o host:~/ruta) el
+                              archivo se lee por ssh, con la configuración
+                              y el agente locales (sin pedir contraseña).
+  --context <nombre>          Añadir los archivos de un conjunto de contexto
+                              con nombre (repetible), definido en la sección
+                              contexts de config.yaml o del .deepcli.yaml del
+                              proyecto (rutas relativas a su directorio)
+  --ssh-max-size <tamaño>     Límite de los archivos remotos por ssh
+                              (default: 10MB)
+  --around <línea:contexto>   Enviar solo las líneas alrededor de una de
+                              cada -f sin rango: --around 180:40 envía
+                              de la 140 a la 220
+  --rows <filas>              Filas de las tablas CSV/TSV (-f) que se envían:
+                              1-100,250,900-. Por defecto, una muestra de
+                              hasta 200 filas espaciadas uniformemente;
+                              siempre se incluye el esquema con los tipos
+                              inferidos y estadísticas de cada columna
+  --columns <columnas>        Columnas de las tablas CSV/TSV, por nombre o
+                              posición: --columns fecha,importe,3
+  --pages <páginas>           Páginas de los PDF (-f) que se envían: 1-5,8,20-.
+                              El texto se extrae localmente con pdftotext
+                              (poppler-utils); por defecto, todas
+  --pdf-layout                Conservar la disposición física de los PDF,
+                              para que las tablas mantengan sus columnas
+  --vision <modo>             Imágenes (-f o stdin): auto = adjuntarlas si el
+                              modelo o el proveedor (vision: true en
+                              config.yaml) las admite y, si no, extraer su
+                              texto por OCR con tesseract (default); on =
+                              adjuntarlas siempre; off = OCR siempre
+  --ocr-lang <idiomas>        Idiomas del OCR: --ocr-lang spa+eng
+  --exec <comando>            Ejecutar un comando y añadir su salida
+                              (etiquetada con el comando) al contexto;
+                              repetible: --exec 'git log --oneline -20'
+  --about <símbolo>           En lugar de archivos completos, añadir al
+                              contexto solo la definición del símbolo, las
+                              funciones y tipos que usa y el código que lo
+                              llama (Go, Python, JavaScript/TypeScript; de los
+                              archivos de -f o, sin -f, del directorio actual).
+                              Repetible
+  --go-package <directorio>   Añadir al contexto un paquete Go: sus archivos
+                              (sin los tests), un resumen de su API exportada
+                              y los datos de go.mod (módulo, versión de Go y
+                              dependencias directas). Repetible
+  --git-context               Añadir al contexto la rama actual, el último
+                              commit y git status --short
+  --git-diff                  Como --git-context, incluyendo además el diff
+                              de los cambios sin confirmar (git diff HEAD)
+  --review-context            Antes de enviar, mostrar cada fuente (archivo,
+                              comando, stdin) con sus bytes y tokens, el
+                              destino y el total, y pedir confirmación; "v"
+                              abre el contenido exacto en $PAGER (o less),
+                              ya anonimizado si se usa --anonymize.
+                              Complementa a la política de envío
+  --priority <fuente>=<nivel> Prioridad de una fuente de contexto (high,
+                              normal o low; repetible). La fuente es la ruta
+                              de un archivo, un comando de --exec, stdin, git
+                              o exec. Si el contexto no cabe en la ventana del
+                              modelo se recortan primero las de menor
+                              prioridad y se muestra qué se incluyó
+  --context-budget <tokens>   Tokens máximos de contexto (default: ventana del
+                              modelo menos -m y la instrucción). Un diff
+                              unificado que no cabe no se recorta: se revisa
+                              por archivos o hunks en paralelo y las
+                              revisiones se combinan en una sola. Un archivo
+                              que no cabe se procesa igual, en partes que
+                              respetan funciones, clases y tipos; al recortar
+                              el código también se corta entre definiciones
+                              Si aun así la solicitud no cabe en la ventana
+                              conocida del modelo, falla antes de enviarla
+                              con la estimación, el límite y sugerencias
+  -p, --prompt <nombre|archivo>
+                              Plantilla de prompt (ver "Plantillas de prompt");
+                              -i se añade al texto de la plantilla
+  -o, --output <archivo>      Guardar respuesta en archivo (opcional); la
+                              escritura es atómica
+                              También admite almacenamiento de objetos:
+                              s3://bucket/clave, gs://bucket/objeto y
+                              az://cuenta/contenedor/blob (usa las CLIs aws,
+                              gcloud/gsutil y az con sus credenciales
+                              estándar)
+  --append                    Añadir la respuesta al final del archivo -o
+  --backup                    Guardar la versión anterior en <archivo>.bak
+  --validate-cmd <comando>    Validar la respuesta con un comando, que la
+                              recibe en stdin y en el archivo $DEEPCLI_OUTPUT
+                              (el de -o si se indica): 'python -m json.tool',
+                              'go build ./...'. Si termina con error, su
+                              salida se envía al modelo para que corrija la
+                              respuesta; si no lo consigue, código 1
+  --validate-retries <n>      Correcciones como máximo (default: 2)
+  --with-metadata             Guardar además <archivo>.meta.json con el
+                              prompt, la huella del contexto, los parámetros,
+                              el uso, el coste y las fechas de la ejecución,
+                              para auditar después cómo se generó
+  --gist [--public]           Subir la respuesta (o el archivo -o) como gist
+                              de GitHub y mostrar la URL; usa GITHUB_TOKEN
+                              (secreto salvo --public)
 
 Opciones de modelo:
+  --model <nombre>            Modelo a utilizar (default: deepseek-chat); se
+                              valida contra la lista del proveedor
   -t, --temperature <0.0-2.0> Controla aleatoriedad:
                               0.0 = preciso/factico
                               0.7 = balanceado (default)
                               1.5+ = creativo/arriesgado
   -m, --maxtokens <número>    Longitud máxima de respuesta (default: 2048)
+  --auto-continue             Si la respuesta se corta por max_tokens, pedir
+                              continuaciones y unirlas en una sola respuesta
+  --max-continues <número>    Máximo de continuaciones (default: 5)
+  --confirm-cost              Antes de enviar una solicitud grande, mostrar
+                              los tokens de entrada estimados y el coste
+                              proyectado (con la respuesta completa, -m) y
+                              pedir confirmación; una vez aceptada, solo se
+                              vuelve a preguntar por una mayor
+  --confirm-threshold <umbral>
+                              Umbral de --confirm-cost: tokens de entrada
+                              (20000, 20k) o coste en USD ('$0.05'); default:
+                              confirm_threshold de config.yaml o 20000
+  --n <número>                Generar varias respuestas alternativas en una
+                              sola llamada (con --json: array de respuestas)
+  --best-of <N> [--judge <modelo>]
+                              Generar N candidatas y mostrar solo la que el
+                              modelo evaluador (--judge, p. ej. uno más
+                              barato; default: el mismo) considera mejor;
+                              con -v se muestra el motivo de la elección
+  --prefill <texto>           Fuerza a que la respuesta comience con el texto
+                              dado (prefix completion, endpoint beta).
+                              Admite \n y \t: --prefill '` + "```" + `python\n'
+  --beta                      Usar el endpoint beta de DeepSeek para todas las
+                              solicitudes y habilitar sus funciones (FIM)
+  --fim [--suffix-file <arch>]
+                              Fill-in-the-middle (requiere --beta): completa
+                              el código entre la entrada (stdin o -f) y el
+                              del archivo sufijo, y muestra solo lo generado
+
+Entrada estándar:
+  --stdin-as <modo>           Cómo se usa lo recibido por stdin:
+                              context = código a analizar (default)
+                              prompt  = instrucción (se añade a -i si existe)
+                              system  = mensaje de sistema
+  --binary <modo>             Entrada binaria: refuse = rechazar (default),
+                              hex = enviar vista previa hexadecimal
+
+  Las entradas en UTF-16 (con BOM) o Latin-1 se convierten a UTF-8 antes
+  de enviarse.
+
+Preámbulo del contexto:
+  --context-system <texto>    Mensaje de sistema al enviar contexto
+                              ("" para omitirlo)
+  --context-template <texto>  Plantilla del mensaje de contexto; {{.Input}}
+                              se reemplaza por el contenido
+                              (default: "Este es el código con el que
+                              necesito ayuda:\n{{.Input}}")
+  --no-preamble               Enviar el contenido tal cual, sin preámbulo
+
+Mensajes personalizados:
+  --messages-json <json>      Array de mensajes (con roles) enviado tal cual,
+                              sin construcción de deepcli:
+                              '[{"role":"system","content":"..."},
+                                {"role":"user","content":"..."}]'
+  --messages-file <archivo>   Igual, leyendo el array de un archivo
+                              ("-" para stdin)
+  --script <guion.yaml>       Conversación de varios turnos: cada turno user
+                              se envía con las respuestas anteriores y se
+                              comprueba con sus aserciones (las de "test");
+                              un turno assistant fija la respuesta anterior.
+                              Con -o se escribe la transcripción; termina
+                              con error si alguna aserción falla:
+                                system: "Responde en una línea"
+                                input_file: main.go    # contexto opcional
+                                turns:
+                                  - user: "¿Qué hace main?"
+                                    assert: [{contains: "flag"}]
+                                  - user: "Reescríbelo sin flags"
+
+Plantillas de prompt:
+  Un archivo de texto, o un nombre de la biblioteca
+  ~/.config/deepcli/prompts/<nombre>[.md|.txt|.tmpl], con una cabecera YAML
+  opcional que configura la llamada (los flags explícitos tienen prioridad):
+    ---
+    description: Revisión de seguridad
+    model: deepseek-chat
+    temperature: 0.2
+    max_tokens: 4096
+    stop: ["FIN"]
+    schema:                   # exige una respuesta JSON que lo cumpla
+      type: object
+      required: [hallazgos]
+    ---
+    Revisa el siguiente código en busca de vulnerabilidades.
 
 Modos de entrada:
   1. Consulta directa:
@@ -109,6 +538,13 @@ Modos de entrada:
   3. Pipeline Unix:
      $ git diff | ` + os.Args[0] + ` -i "Explica los cambios"
 
+  4. Salida de comandos:
+     $ ` + os.Args[0] + ` -i "¿Por qué fallan?" --exec 'go test ./...' --exec 'git diff'
+     $ ` + os.Args[0] + ` --git-diff "¿Por qué no compila?"
+
+  5. Pregunta por stdin:
+     $ echo "¿Por qué el cielo es azul?" | ` + os.Args[0] + ` --stdin-as prompt
+
 Ejemplos detallados:
   # Análisis de código con salida a archivo
   $ ` + os.Args[0] + ` -i "Detecta errores" -f codigo.py -o errores.txt
@@ -126,55 +562,460 @@ código y sugiere mejoras de rendimiento
 EOF
 )" -f sistema.py
 
+Subcomandos:
+  eval --rubric <rúbrica.yaml> --candidates <archivo> [archivo...]
+                              Puntúa respuestas según una rúbrica usando el
+                              modelo como evaluador
+  test <pruebas.yaml> [--junit informe.xml] [-c 4]
+                              Ejecuta casos de prueba de prompts con
+                              aserciones (contains, regex, json_schema, judge)
+  ab --variants <a.tmpl,b.tmpl> --inputs <casos.jsonl>
+                              Compara variantes de prompt: puntuación del
+                              evaluador, victorias, latencia y tokens
+  mockserver [--addr 127.0.0.1:8089] [--error-rate 0.1]
+                              Servidor local que simula la API (incluye
+                              streaming e inyección de errores)
+  chat [--session <id>|--recover] [--vi]
+                              Chat línea a línea con historial persistente,
+                              atajos de Emacs/Vi, búsqueda inversa (Ctrl+R)
+                              y pegado multilínea. Ctrl+C durante una
+                              respuesta la detiene y la guarda en el
+                              historial como interrumpida
+  tui [--session <id>]        Interfaz de chat a pantalla completa con
+                              streaming, barra de estado y sesiones
+  serve --web [--addr 127.0.0.1:8787]
+                              Interfaz web de chat en localhost que comparte
+                              las sesiones con la CLI
+  history list|show|export|fork
+                              Listar, ver, exportar (html, markdown, json)
+                              o bifurcar (fork <id> --at 4) las sesiones
+                              guardadas
+  prompt pull <repo>[@versión] | prompt list
+                              Sincronizar repositorios git de plantillas
+                              compartidas en la biblioteca local
+  shell-init <bash|zsh|fish> [--capture-stderr]
+                              Hook para el shell que registra el último
+                              comando: eval "$(deepcli shell-init bash)"
+  wtf [pregunta]              Explica por qué falló el último comando del
+                              shell y cómo arreglarlo
+  cmd "<tarea>" [--print] [--history]
+                              Sugiere un único comando de shell y lo ejecuta
+                              solo tras confirmar con "s"
+  fix [-n 3] -- <comando>     Ejecuta el comando y, mientras falle, pide un
+                              parche al modelo, lo aplica tras confirmar y
+                              lo vuelve a ejecutar
+  models [--provider <nombre>] [--json]
+                              Modelos del proveedor con su ventana de
+                              contexto y precio
+  balance [--warn-below <cantidad>]
+                              Saldo restante de la cuenta de DeepSeek
+  testgen -f <archivo.go> [--framework testify] [--write] [--check]
+                              Genera un archivo _test.go con tests de tabla
+                              y, con --check, lo corrige hasta que compile
+  docgen -f <archivo.go> [--write] [--all]
+                              Añade al archivo los comentarios de
+                              documentación de los símbolos exportados
+  hooks install|uninstall     Hooks de git que redactan y revisan los
+                              mensajes de commit (DEEPCLI_SKIP_HOOKS=1 los
+                              omite)
+  mr <url|grupo/proyecto!iid> [--post]
+                              Revisa una merge request de GitLab y, con
+                              --post, publica los comentarios en sus líneas
+  triage --repo <org/nombre> [--since 7d] [--format json]
+                              Clasifica los issues recientes de GitHub por
+                              tipo, gravedad y duplicados
+  scan-report -f <escaneo>    Resume un escaneo de Nmap, masscan o naabu y
+                              propone los siguientes pasos por servicio
+  logs -f <archivo>           Agrupa las líneas similares del log y pide un
+                              análisis de la causa raíz de los errores
+  urls -f <enlaces>           Descarga y resume varias URL en paralelo, con
+                              un resumen conjunto que cita cada fuente
+  diff-responses <a> <b>      Diff por palabras de dos respuestas (archivos o
+                              sesión:turno del historial), para comparar
+                              variantes de prompt o de modelo
+  auth test                   Comprueba la API key: si es válida, si puede
+                              generar, la organización, los límites de uso
+                              y el saldo, con la causa de cualquier fallo
+  bench -i <prompt>           Latencia (p50/p90/p99), tiempo hasta el primer
+                              token y tokens/s por proveedor y modelo:
+                              bench -i "hola" --runs 10 --concurrency 3
+  report --template <nombre>  Asistente guiado: pregunta, el modelo revisa
+                              cada respuesta y redacta el documento final
+                              (pentest-finding, incident-postmortem,
+                              bug-report o plantillas propias)
+  each -i <instrucción> --glob <patrón> --out-dir <dir>
+                              Aplica la instrucción a cada archivo en
+                              paralelo, con una salida por archivo y un
+                              resumen de los correctos y los fallidos;
+                              --merge summarize|concat|json-array los
+                              combina en un artefacto final
+  usage report [--csv]        Gasto por periodo, proyecto, API key y modelo,
+                              y estado de los presupuestos
+  audit-log verify [archivo]  Comprueba que el registro de auditoría no se
+                              ha modificado, borrado ni reordenado
+  alias add|list|rm           Atajos para invocaciones frecuentes:
+                              alias add explain '-p explain-code -t 0.2'
+                              y después: deepcli explain -f x.go
+
 Configuración:
   La API key se configura mediante:
   • Variable de entorno: $ export DEEPSEEK_API_KEY="tu_key"
   • Archivo .env: $ echo 'DEEPSEEK_API_KEY=tu_key' > .env
+  • Archivo de secreto: $ export DEEPSEEK_API_KEY_FILE=/var/run/secrets/deepseek
+  • Comando en config.yaml: key_command: "vault kv get -field=key secret/deepseek"
+    (también por proveedor; las API keys de otros proveedores admiten
+    igualmente <VARIABLE>_FILE)
+
+  La URL base de la API (default: https://api.deepseek.com/v1) se puede
+  cambiar con --base-url o DEEPSEEK_BASE_URL, por ejemplo para usar el
+  servidor simulado de "deepcli mockserver".
+
+  Con --provider-preset <nombre> se usa un proveedor predefinido, con su
+  URL base, cabecera de autenticación y nombres de modelo equivalentes
+  (deepseek-chat, deepseek-reasoner):
+    openrouter  OPENROUTER_API_KEY
+    together    TOGETHER_API_KEY
+    groq        GROQ_API_KEY
+    azure       AZURE_OPENAI_API_KEY y AZURE_OPENAI_ENDPOINT
+                (--model es el nombre del despliegue)
+    deepseek    DEEPSEEK_API_KEY (default)
+
+  En ~/.config/deepcli/config.yaml (o DEEPCLI_CONFIG) se pueden definir
+  proveedores propios y una cadena de failover: si un proveedor falla por
+  autenticación, cuota o caída (401, 402, 403, 429, 5xx o error de red) la
+  solicitud se repite con el siguiente, y --json indica el proveedor usado:
+    providers:
+      openrouter-deepseek: {preset: openrouter}
+      ollama-local: {base_url: "http://localhost:11434/v1", model: "deepseek-r1"}
+    failover: [deepseek, openrouter-deepseek, ollama-local]
+
+  Cada proveedor puede indicar su autenticación con auth: bearer (default),
+  header (cabecera propia) o sigv4 (firma de AWS con las credenciales
+  AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY, para Bedrock o gateways internos):
+    providers:
+      gateway: {base_url: "...", api_key_env: GW_KEY,
+                auth: {type: header, header: X-Api-Key}}
+      bedrock: {base_url: "https://bedrock-runtime.us-west-2.amazonaws.com/openai/v1",
+                auth: {type: sigv4, region: us-west-2, service: bedrock}}
+
+  Las cabeceras de headers se añaden a cada solicitud al proveedor (admiten
+  variables de entorno); --header tiene prioridad:
+    providers:
+      gateway: {base_url: "...", headers: {X-Org-Id: "42", X-Team-Token: "${TEAM_TOKEN}"}}
+
+  Con vision: true, las imágenes de entrada se envían al proveedor como
+  partes del mensaje en lugar de pasar por OCR:
+    providers:
+      ollama-vl: {base_url: "http://localhost:11434/v1", model: "qwen2.5vl", vision: true}
+
+  El coste estimado (--json, --notify-url) usa una tabla de precios por
+  proveedor y modelo (USD por millón de tokens) que se puede sobrescribir:
+    pricing:
+      deepseek:
+        deepseek-chat: {input: 0.27, cached_input: 0.07, output: 1.10}
+
+  La ventana de contexto de cada modelo (128K para los de DeepSeek, o la
+  que anuncie el proveedor) limita lo que se envía; se puede cambiar:
+    context_windows:
+      qwen2.5-coder: 32768
+
+  Con balance_warning: 5 (o DEEPCLI_BALANCE_WARNING=5) se avisa tras cada
+  respuesta cuando el saldo de DeepSeek baja de esa cantidad.
+
+  Los alias de la sección aliases se comparten con quien use el mismo
+  config.yaml (los de "deepcli alias add" tienen preferencia):
+    aliases:
+      explain: "-p explain-code -t 0.2"
+
+  Una política de envío (policy.yaml junto a config.yaml, o la ruta de
+  policy: en config.yaml) se evalúa antes de cada solicitud: los archivos
+  de contexto deben cumplir las rutas, tipos y tamaño, y la solicitud el
+  host y el tamaño. Una infracción bloquea el envío y explica el motivo;
+  --override-policy "<justificación>" lo permite y deja constancia en
+  ~/.local/share/deepcli/policy-overrides.jsonl:
+    deny_paths: ["**/.env", "*.pem", "~/clientes/*/secretos/**"]
+    allow_types: [.go, .py, .md, .log]
+    max_file_size: 1MB
+    max_request_size: 2MB
+    allowed_hosts: [api.deepseek.com, "*.gateway.interno"]
+
+  Con audit_log: <ruta> (o DEEPCLI_AUDIT_LOG) cada solicitud a la API se
+  añade a un registro JSONL encadenado por hashes al enviarse: usuario,
+  modelo, prompt enviado y huellas SHA-256 del contexto y la solicitud. La
+  respuesta (su huella) o el error se añaden después en otra entrada.
+  "deepcli audit-log verify" detecta cualquier entrada alterada:
+    audit_log: ~/auditoria/deepcli.jsonl
+
+  Los presupuestos de gasto (USD por periodo: daily, weekly o monthly) se
+  fijan por API key (como la muestra "deepcli auth test") y por proyecto,
+  aquí o en el .deepcli.yaml del proyecto (name, budget, budget_period). Se
+  avisa al superar el 80% y se bloquean las solicitudes al llegar al 100%;
+  "deepcli usage report --csv" exporta el gasto:
+    budgets:
+      period: monthly
+      keys: {"sk-…1234": 50}
+      projects: {acme: 20}
+
+  Los conjuntos de contexto de --context agrupan las rutas o globs (**
+  abarca directorios) de un subsistema sobre el que se pregunta a menudo;
+  los del .deepcli.yaml del proyecto tienen prioridad:
+    contexts:
+      auth-module: [internal/auth/**.go, docs/auth.md]
+
+  Si la API está saturada (503) la solicitud se reintenta hasta 3 veces con
+  espera creciente. Los errores de la API indican cómo resolverlos (API key
+  inválida, saldo insuficiente, contexto demasiado largo) e incluyen el ID de
+  la solicitud para el soporte del proveedor.
+
+  Cualquier opción larga toma su valor por defecto de la variable
+  DEEPCLI_<NOMBRE> (en mayúsculas y con _ en lugar de -), en el entorno o en
+  .env, como si se hubiera escrito antes que el resto de flags; los flags
+  tienen prioridad. Útil en CI y dotfiles sin scripts envoltorio:
+    DEEPCLI_TEMPERATURE=0.2  DEEPCLI_MAX_TOKENS=4096  DEEPCLI_MODEL=deepseek-reasoner
+    DEEPCLI_SYSTEM="..." (--context-system)  DEEPCLI_NO_PREAMBLE=1
+  También se aplican a las opciones de los subcomandos.
 
 Opciones avanzadas:
-  -raw                  Salida sin formato (para procesamiento pipeline)
-  -v, --verbose         Mostrar logs detallados
+  -raw                  Salida sin formato (para procesamiento pipeline); si
+                        la API devuelve un error se muestra su cuerpo y se
+                        termina con código 1
+  --raw-pretty          Como -raw, con el JSON indentado
+  --render              Renderizar el Markdown aunque stdout no sea un
+                        terminal (por defecto solo se renderiza en un
+                        terminal y nunca con NO_COLOR definido)
+  --plain               Texto sin formato aunque stdout sea un terminal
+  --width <n>           Ajustar los párrafos y las listas a n columnas (por
+                        defecto, al renderizar, al ancho del terminal); los
+                        bloques de código y las tablas no se cortan. Con
+                        --width también se ajusta el texto sin renderizar.
+                        No se aplica con --stream
+  --golden <dir>        Comparar la respuesta con la respuesta dorada
+                        guardada en <dir> (se crea en la primera
+                        ejecución); si difiere muestra el diff y termina
+                        con código 1. Usa temperatura 0 salvo -t explícito
+  --update-golden       Reemplazar la respuesta dorada con la actual
+  --offline             Bloquear cualquier acceso a la red (API, webhooks,
+                        gists, subidas, DNS) y servir la respuesta de la
+                        grabada con --golden; falla si no hay ninguna. Para
+                        revisar sesiones o reproducir respuestas en equipos
+                        aislados (también en chat, serve y el resto de
+                        subcomandos, o con DEEPCLI_OFFLINE=1)
+  --stream              Mostrar la respuesta a medida que se genera; si la
+                        conexión se corta, reconecta y continúa desde lo
+                        ya recibido (hasta 3 veces). Ctrl+C detiene la
+                        respuesta y, con -o, guarda lo recibido marcado
+                        como incompleto (código de salida 130)
+  --until <regex>       Detener el stream en cuanto una línea de la
+                        respuesta coincide (p. ej. '^END$'); se muestra
+                        hasta la coincidencia y no se generan más tokens.
+                        Implica --stream
+  --code-only[=all]     Mostrar solo el código, sin texto ni vallas de
+                        Markdown: el primer bloque o, con =all, todos los
+                        bloques unidos. Listo para redirigir a un archivo:
+                        deepcli --code-only "función que..." > util.py
+  --json                Emitir un JSON con modelo, contenido, motivo de
+                        fin, uso de tokens, coste estimado, logprobs y
+                        tiempos de la solicitud (DNS, conexión, TLS,
+                        primer byte y total, en ms)
+  --format-template <plantilla>
+                        Formatear la salida con una plantilla Go sobre la
+                        respuesta: {{.Model}}, {{.Content}},
+                        {{.FinishReason}}, {{.Usage.PromptTokens}},
+                        {{.Usage.CompletionTokens}}, {{.Usage.TotalTokens}};
+                        funciones: json, trim, upper, lower. Ejemplo:
+                        '{{.Model}}: {{.Content}} ({{.Usage.TotalTokens}} tok)'
+  --logprobs[=N]        Pedir log-probabilidades de los tokens y, con N
+                        (0-20), las N alternativas más probables; se
+                        incluyen en la salida --json
+  --param <clave=valor> Añadir un parámetro al cuerpo de la solicitud
+                        (repetible), para usar parámetros nuevos de la API
+                        sin esperar a una versión de deepcli. El valor se
+                        interpreta como JSON si es válido y si no como
+                        texto; sustituye al campo de deepcli del mismo
+                        nombre: --param top_p=0.9 --param user=ci
+                        --param 'thinking={"type":"enabled"}'
+  --anonymize           Sustituir en lo que se envía los nombres de
+                        persona, emails, IP y nombres de host por marcadores
+                        estables (PERSONA_1, EMAIL_2, IP_1, HOST_3) y
+                        devolver los datos originales en la respuesta; la
+                        detección de nombres es heurística
+  --anonymize-term <texto>
+                        Texto que se sustituye siempre (DATO_n), como el
+                        nombre de un cliente o un proyecto (repetible;
+                        implica --anonymize)
+  --override-policy <justificación>
+                        Enviar aunque la política de envío lo bloquee; la
+                        justificación y las infracciones se registran
+  --header <'Nombre: valor'>
+                        Añadir una cabecera HTTP a las solicitudes a la API
+                        (repetible), para gateways que enrutan o autentican
+                        por cabecera: --header 'X-Org-Id: 42'. Sustituye a
+                        las de deepcli y a las del proveedor (headers en
+                        config.yaml); con SigV4 se incluyen en la firma y
+                        no pueden sustituir Authorization ni X-Amz-Date
+  --notify-url <url>    POST con resumen JSON al terminar (hash del prompt,
+                        duración, uso de tokens, éxito)
+  --notify              Campana del terminal y notificación de escritorio
+                        (notify-send / osascript) al terminar
+  --resolve <host:ip>   Fijar la IP de un host (repetible), por ejemplo
+                        --resolve api.deepseek.com:203.0.113.10
+  --dns <ip[:puerto]>   Servidor DNS para resolver la API
+  --happy-eyeballs-delay <duración>
+                        Espera antes de probar la otra familia IP (IPv4/IPv6)
+                        (default: 300ms; negativo: desactivar)
+  --tor                 Enviar el tráfico por Tor (SOCKS5, sin fugas DNS)
+                        con un circuito aislado en cada ejecución; la API
+                        key sigue identificando la cuenta
+  --tor-proxy <host:puerto>
+                        Proxy SOCKS5 de Tor (default: 127.0.0.1:9050)
+  -v, --verbose         Mostrar logs detallados, con los tiempos de red y
+                        de espera del servidor de cada solicitud
+  -q, --quiet           Modo silencioso: sin mensajes de estado; stdout
+                        contiene solo la respuesta del modelo
   -h, --help            Mostrar esta ayuda
 
 Sugerencias:
+  • Para obtener solo código usa --prefill '` + "```" + `python\n' y pide que cierre
+    el bloque
   • Para código complejo, usa --maxtokens 4096
   • Combina con jq para procesar JSON: -raw | jq '.choices[0]...'
   • Usa --temperature 1.2 para brainstorming creativo
   • El contexto máximo es 128K tokens
 
 `
-	fmt.Println(helpText)
+	fmt.Fprintln(w, helpText)
 }
 
 func main() {
+	defer recoverPanic()
+
+	// Los alias se expanden antes de elegir el subcomando y de interpretar
+	// los flags, así que aún no se sabe si hay -v
+	logger.SetOutput(io.Discard)
+	if args, err := expandAliases(os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	} else {
+		os.Args = args
+	}
+	logger.SetOutput(os.Stderr)
+
+	// Subcomandos: deepcli <subcomando> [opciones]
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			run(os.Args[2:])
+			return
+		}
+	}
+
 	// Configuración de flags
-	instruction := flag.String("i", "", "Instrucción para DeepSeek")
+	var instructions stringList
+	flag.Var(&instructions, "i", "Instrucción para DeepSeek (repetible: un mensaje por instrucción)")
 	outputFile := flag.String("o", "", "Archivo de salida para escribir la respuesta")
-	inputFile := flag.String("f", "", "Archivo de entrada con el código a analizar")
+	var inputFiles stringList
+	flag.Var(&inputFiles, "f", "Archivo de entrada con el código a analizar (repetible)")
 	flag.Float64Var(&temperature, "t", defaultTemperature, "Temperatura para la generación (0.0-2.0)")
 	flag.Float64Var(&temperature, "temperature", defaultTemperature, "Temperatura para la generación (0.0-2.0)")
 	flag.IntVar(&maxTokens, "m", defaultMaxTokens, "Máximo número de tokens a generar")
 	flag.IntVar(&maxTokens, "maxtokens", defaultMaxTokens, "Máximo número de tokens a generar")
 	flag.BoolVar(&verbose, "v", false, "Mostrar mensajes detallados de ejecución")
 	flag.BoolVar(&verbose, "verbose", false, "Mostrar mensajes detallados de ejecución")
+	flag.BoolVar(&quiet, "q", false, "Modo silencioso, sin mensajes de estado")
+	flag.BoolVar(&quiet, "quiet", false, "Modo silencioso, sin mensajes de estado")
+	flag.StringVar(&stdinAs, "stdin-as", "context", "Uso de stdin: prompt, context o system")
+	flag.StringVar(&binaryMode, "binary", "refuse", "Entrada binaria: refuse (rechazar) o hex (vista previa)")
+	flag.StringVar(&contextSystem, "context-system", defaultContextSystem, "Mensaje de sistema cuando se envía contexto")
+	flag.StringVar(&contextTemplate, "context-template", defaultContextTemplate, "Plantilla del mensaje de contexto ({{.Input}} es el contenido)")
+	flag.BoolVar(&noPreamble, "no-preamble", false, "Enviar el contexto sin preámbulo ni mensaje de sistema")
+	flag.StringVar(&messagesJSON, "messages-json", "", "Array JSON de mensajes a enviar tal cual")
+	flag.StringVar(&messagesFile, "messages-file", "", "Archivo con el array JSON de mensajes (- para stdin)")
+	flag.StringVar(&prefill, "prefill", "", "Texto con el que debe comenzar la respuesta (beta)")
+	flag.BoolVar(&betaMode, "beta", false, "Usar el endpoint beta de DeepSeek y sus funciones (FIM, prefix completion)")
+	fimMode := flag.Bool("fim", false, "Completar el código entre la entrada y --suffix-file (requiere --beta)")
+	suffixFile := flag.String("suffix-file", "", "Con --fim, archivo con el código posterior al hueco")
+	flag.BoolVar(&jsonOutput, "json", false, "Emitir la respuesta como JSON (modelo, contenido, uso, logprobs, tiempos)")
+	flag.StringVar(&goldenDir, "golden", "", "Directorio de respuestas doradas para detectar cambios")
+	flag.BoolVar(&offlineMode, "offline", false, "Bloquear cualquier acceso a la red y servir las respuestas de --golden")
+	flag.BoolVar(&updateGolden, "update-golden", false, "Reemplazar la respuesta dorada con la actual")
+	flag.IntVar(&numChoices, "n", 1, "Número de respuestas alternativas a generar")
+	flag.BoolVar(&anonymizeMode, "anonymize", false, "Sustituir nombres, emails, IP y hosts por marcadores antes de enviar y restaurarlos en la respuesta")
+	flag.Var(&anonymizeTerms, "anonymize-term", "Texto que se sustituye siempre con --anonymize (repetible)")
+	flag.IntVar(&bestOf, "best-of", 1, "Generar N candidatas y mostrar solo la mejor según el evaluador")
+	flag.StringVar(&judgeModel, "judge", "", "Modelo evaluador de --best-of (default: el mismo modelo)")
+	flag.BoolVar(&forceRender, "render", false, "Renderizar Markdown aunque la salida no sea un terminal")
+	flag.BoolVar(&forcePlain, "plain", false, "Mostrar texto sin formato aunque la salida sea un terminal")
+	flag.IntVar(&outputWidth, "width", 0, "Ancho al que se ajusta el texto de la respuesta (default: el del terminal)")
+	flag.StringVar(&formatTemplate, "format-template", "", "Plantilla Go para la salida (campos de la respuesta JSON)")
+	flag.Var(&codeOnly, "code-only", "Mostrar solo el código de la respuesta: el primer bloque o, con =all, todos")
+	flag.Var(&logprobs, "logprobs", "Incluir logprobs de los tokens (--logprobs=N para los N más probables)")
+	flag.BoolVar(&autoContinue, "auto-continue", false, "Continuar automáticamente las respuestas cortadas por max_tokens")
+	flag.IntVar(&maxContinues, "max-continues", 5, "Máximo de continuaciones con --auto-continue")
+	flag.BoolVar(&streamOutput, "stream", false, "Mostrar la respuesta a medida que se genera")
+	untilExpr := flag.String("until", "", "Detener el stream cuando una línea coincide con la expresión regular")
 	flag.BoolVar(&rawOutput, "raw", false, "Mostrar salida cruda en JSON (sin formatear)")
+	flag.BoolVar(&rawPretty, "raw-pretty", false, "Como -raw, con el JSON indentado")
+	flag.StringVar(&model, "model", defaultModel, "Modelo a utilizar")
+	flag.StringVar(&policyOverride, "override-policy", "", "Continuar pese a la política de envío, con la justificación que se registra")
+	flag.Var(&extraHeaders, "header", "Cabecera HTTP adicional para la API: 'Nombre: valor' (repetible)")
+	flag.Var(&requestParams, "param", "Parámetro adicional del cuerpo de la solicitud: clave=valor, con valor JSON (repetible)")
+	flag.StringVar(&promptName, "p", "", "Plantilla de prompt (nombre de la biblioteca o archivo)")
+	flag.StringVar(&promptName, "prompt", "", "Plantilla de prompt (nombre de la biblioteca o archivo)")
+	flag.StringVar(&apiBaseURL, "base-url", defaultBaseURL, "URL base de la API compatible con OpenAI")
+	flag.StringVar(&providerName, "provider-preset", "", "Proveedor predefinido (deepseek, openrouter, together, groq, azure) o de config.yaml")
+	addNetworkFlags(flag.CommandLine)
+	flag.StringVar(&notifyURL, "notify-url", "", "URL de webhook a notificar al terminar")
+	flag.BoolVar(&notifyDone, "notify", false, "Notificar en el escritorio al terminar")
 	showHelp := flag.Bool("h", false, "Mostrar ayuda")
 	flag.BoolVar(showHelp, "help", false, "Mostrar ayuda")
 
 	// Aliases para flags
-	flag.StringVar(instruction, "instruction", "", "Instrucción para DeepSeek")
+	flag.Var(&instructions, "instruction", "Instrucción para DeepSeek (repetible: un mensaje por instrucción)")
 	flag.StringVar(outputFile, "output", "", "Archivo de salida para escribir la respuesta")
-	flag.StringVar(inputFile, "file", "", "Archivo de entrada con el código a analizar")
+	flag.BoolVar(&appendOutput, "append", false, "Añadir la respuesta al final del archivo de salida")
+	flag.StringVar(&scriptFile, "script", "", "Guion YAML de una conversación de varios turnos, con aserciones opcionales")
+	flag.BoolVar(&confirmCost, "confirm-cost", false, "Pedir confirmación antes de enviar solicitudes que superen --confirm-threshold")
+	flag.StringVar(&confirmThreshold, "confirm-threshold", "", "Umbral de --confirm-cost: tokens de entrada (20000, 20k) o coste en USD ($0.05)")
+	flag.StringVar(&validateCmd, "validate-cmd", "", "Comando que valida la respuesta (en stdin y en $DEEPCLI_OUTPUT); si falla, el modelo la corrige")
+	flag.IntVar(&validateRetries, "validate-retries", 2, "Correcciones como máximo con --validate-cmd")
+	flag.BoolVar(&withMetadata, "with-metadata", false, "Guardar junto al archivo de -o un <archivo>.meta.json con el prompt, los parámetros y el uso")
+	flag.BoolVar(&gistUpload, "gist", false, "Subir la respuesta como gist de GitHub (requiere GITHUB_TOKEN)")
+	flag.BoolVar(&gistPublic, "public", false, "Con --gist, crear un gist público")
+	flag.BoolVar(&backupOutput, "backup", false, "Guardar la versión anterior del archivo de salida en <archivo>.bak")
+	flag.Var(&inputFiles, "file", "Archivo de entrada con el código a analizar (repetible)")
+	flag.Var(&contextPriorities, "priority", "Prioridad de una fuente de contexto: nombre=high|normal|low (repetible)")
+	flag.IntVar(&contextBudget, "context-budget", 0, "Tokens máximos de contexto (default: según la ventana del modelo)")
+	flag.Var(&execCommands, "exec", "Ejecutar un comando y usar su salida como contexto (repetible)")
+	flag.Var(&aboutSymbols, "about", "Añadir como contexto solo el símbolo, lo que usa y lo que lo llama (repetible)")
+	flag.StringVar(&aroundSpec, "around", "", "Enviar solo las líneas alrededor de una línea de los archivos -f (línea:contexto)")
+	flag.StringVar(&tableRows, "rows", "", "Filas de las tablas CSV/TSV a enviar (1-100,250)")
+	flag.StringVar(&tableColumns, "columns", "", "Columnas de las tablas CSV/TSV a enviar (nombres o posiciones)")
+	flag.StringVar(&visionMode, "vision", "auto", "Imágenes de entrada: auto, on (adjuntarlas) u off (OCR con tesseract)")
+	flag.StringVar(&ocrLang, "ocr-lang", "", "Idiomas de tesseract para el OCR de imágenes (p. ej. spa+eng)")
+	flag.StringVar(&pdfPages, "pages", "", "Páginas de los PDF a enviar (1-5,8,20-)")
+	flag.BoolVar(&pdfLayout, "pdf-layout", false, "Conservar la disposición de los PDF (columnas y tablas alineadas)")
+	flag.Var(&contextSets, "context", "Añadir los archivos de un conjunto de contexto con nombre de config.yaml o .deepcli.yaml (repetible)")
+	flag.BoolVar(&reviewContext, "review-context", false, "Mostrar las fuentes y los bytes que se van a enviar y pedir confirmación")
+	flag.StringVar(&sshMaxSize, "ssh-max-size", sshMaxSize, "Tamaño máximo de los archivos remotos de -f usuario@host:/ruta (512KB, 10MB)")
+	flag.Var(&goPackages, "go-package", "Añadir como contexto un paquete Go: sus archivos, su API exportada y go.mod (repetible)")
+	flag.BoolVar(&gitContextOn, "git-context", false, "Añadir el estado del repositorio git como contexto")
+	flag.BoolVar(&gitDiff, "git-diff", false, "Con --git-context, incluir el diff de los cambios sin confirmar")
 
 	flag.Usage = func() {
-		printHelp()
+		printHelp(os.Stdout)
 		os.Exit(0)
 	}
 
 	flag.Parse()
+	envApplied := loadEnvDefaults(flag.CommandLine)
+
+	startTime = time.Now()
+
+	if rawPretty {
+		rawOutput = true
+	}
 
 	if *showHelp {
-		printHelp()
+		printHelp(os.Stdout)
 		os.Exit(0)
 	}
 
@@ -184,6 +1025,40 @@ func main() {
 	} else {
 		logger.Println("Modo verboso activado")
 	}
+	for _, v := range envApplied {
+		logger.Printf("Del entorno: %s\n", v)
+	}
+
+	// La cabecera de la plantilla configura la llamada; los flags explícitos
+	// tienen prioridad
+	if promptName != "" {
+		if messagesJSON != "" || messagesFile != "" {
+			fmt.Fprintf(os.Stderr, "Error: --prompt no se puede combinar con --messages-json/--messages-file\n")
+			os.Exit(1)
+		}
+		var err error
+		promptTemplate, err = loadPromptTemplate(promptName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if promptTemplate.Model != "" && !isFlagSet("model") {
+			model = promptTemplate.Model
+		}
+		if promptTemplate.Temperature != nil && !isFlagSet("t", "temperature") {
+			temperature = *promptTemplate.Temperature
+		}
+		if promptTemplate.MaxTokens > 0 && !isFlagSet("m", "maxtokens") {
+			maxTokens = promptTemplate.MaxTokens
+		}
+		stopSequences = promptTemplate.Stop
+		logger.Printf("Usando la plantilla %s\n", promptName)
+	}
+
+	// Las comparaciones con respuestas doradas requieren determinismo
+	if goldenDir != "" && !isFlagSet("t", "temperature") && (promptTemplate == nil || promptTemplate.Temperature == nil) {
+		temperature = 0
+	}
 
 	// Validar temperatura
 	if temperature < 0.0 || temperature > 2.0 {
@@ -191,189 +1066,585 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Validar maxTokens
-	if maxTokens <= 0 {
-		fmt.Fprintf(os.Stderr, "Error: maxTokens debe ser mayor que 0\n")
+	// Validar número de alternativas
+	if numChoices < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --n debe ser mayor que 0\n")
 		os.Exit(1)
 	}
 
-	// Cargar variables de entorno desde .env
-	if err := loadEnv(); err != nil {
-		logger.Printf("Advertencia: %v", err)
+	if bestOf < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --best-of debe ser mayor que 0\n")
+		os.Exit(1)
+	}
+	if judgeModel != "" && bestOf < 2 {
+		fmt.Fprintf(os.Stderr, "Error: --judge solo tiene sentido junto con --best-of\n")
+		os.Exit(1)
+	}
+	if bestOf > 1 && (numChoices > 1 || streamOutput || rawOutput || autoContinue || prefill != "") {
+		fmt.Fprintf(os.Stderr, "Error: --best-of no se puede combinar con --n, --stream, -raw, --auto-continue ni --prefill\n")
+		os.Exit(1)
 	}
 
-	apiKey = os.Getenv("DEEPSEEK_API_KEY")
-	if apiKey == "" {
-		fmt.Fprintf(os.Stderr, "Error: No se ha configurado la API key de DeepSeek. Por favor, establece la variable de entorno DEEPSEEK_API_KEY o crea un archivo .env con la clave.\n")
+	if len(anonymizeTerms) > 0 {
+		anonymizeMode = true
+	}
+	if anonymizeMode {
+		if streamOutput || *untilExpr != "" || rawOutput || *fimMode {
+			fmt.Fprintf(os.Stderr, "Error: --anonymize no se puede combinar con --stream, --until, -raw ni --fim\n")
+			os.Exit(1)
+		}
+		anon = newAnonymizer(anonymizeTerms)
+	}
+
+	// Validar el rol de stdin
+	switch stdinAs {
+	case "prompt", "context", "system":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: --stdin-as debe ser prompt, context o system\n")
 		os.Exit(1)
 	}
 
-	// Leer la entrada (puede ser de pipe, archivo o argumentos)
-	var input string
-	var err error
+	// Validar el tratamiento de binarios
+	if binaryMode != "refuse" && binaryMode != "hex" {
+		fmt.Fprintf(os.Stderr, "Error: --binary debe ser refuse o hex\n")
+		os.Exit(1)
+	}
+
+	if aroundSpec != "" {
+		if _, err := parseAround(aroundSpec); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	// Verificar si hay datos en stdin (pipe)
-	stat, _ := os.Stdin.Stat()
-	if (stat.Mode() & os.ModeCharDevice) == 0 {
-		logger.Println("Leyendo datos de stdin...")
-		var buf bytes.Buffer
-		_, err = io.Copy(&buf, os.Stdin)
+	if visionMode != "auto" && visionMode != "on" && visionMode != "off" {
+		fmt.Fprintf(os.Stderr, "Error: --vision debe ser auto, on u off\n")
+		os.Exit(1)
+	}
+
+	// Validar la plantilla de salida
+	if formatTemplate != "" {
+		if jsonOutput {
+			fmt.Fprintf(os.Stderr, "Error: --format-template y --json no se pueden combinar\n")
+			os.Exit(1)
+		}
+		var err error
+		outputTemplate, err = parseOutputTemplate(formatTemplate)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error al leer de stdin: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		input = buf.String()
-		logger.Printf("Leídos %d bytes de stdin\n", len(input))
 	}
 
-	// Si se especificó un archivo de entrada, leerlo
-	if *inputFile != "" {
-		logger.Printf("Leyendo archivo de entrada: %s\n", *inputFile)
-		fileContent, err := os.ReadFile(*inputFile)
+	if forceRender && forcePlain {
+		fmt.Fprintf(os.Stderr, "Error: --render y --plain no se pueden combinar\n")
+		os.Exit(1)
+	}
+	if outputWidth < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --width debe ser mayor que 0\n")
+		os.Exit(1)
+	}
+
+	if validateCmd != "" && (streamOutput || rawOutput || numChoices > 1 || prefill != "" || appendOutput) {
+		fmt.Fprintf(os.Stderr, "Error: --validate-cmd no se puede combinar con --stream, -raw, --n, --prefill ni --append\n")
+		os.Exit(1)
+	}
+	if validateRetries < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --validate-retries no puede ser negativo\n")
+		os.Exit(1)
+	}
+	if withMetadata && *outputFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: --with-metadata requiere -o\n")
+		os.Exit(1)
+	}
+	if isObjectStorageURL(*outputFile) && (appendOutput || backupOutput) {
+		fmt.Fprintf(os.Stderr, "Error: --append y --backup no están disponibles para destinos s3://, gs:// o az://\n")
+		os.Exit(1)
+	}
+
+	if *untilExpr != "" {
+		re, err := regexp.Compile("(?m)" + *untilExpr)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error al leer el archivo de entrada: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: --until no es una expresión regular válida: %v\n", err)
+			os.Exit(1)
+		}
+		untilPattern = re
+		streamOutput = true
+	}
+
+	if codeOnly.enabled && (streamOutput || jsonOutput || formatTemplate != "" || rawOutput) {
+		fmt.Fprintf(os.Stderr, "Error: --code-only no se puede combinar con --stream, --until, --json, --format-template ni -raw\n")
+		os.Exit(1)
+	}
+
+	if streamOutput && (jsonOutput || formatTemplate != "" || rawOutput || numChoices > 1 || goldenDir != "" || gistUpload || logprobs.enabled) {
+		fmt.Fprintf(os.Stderr, "Error: --stream no se puede combinar con --json, --format-template, -raw, --n, --golden, --gist ni --logprobs\n")
+		os.Exit(1)
+	}
+
+	// Sin conexión la respuesta solo puede salir de las grabadas con --golden
+	if offlineMode {
+		switch {
+		case streamOutput:
+			fmt.Fprintf(os.Stderr, "Error: --offline no se puede combinar con --stream ni --until: las respuestas se reproducen de --golden\n")
+			os.Exit(1)
+		case goldenDir == "":
+			fmt.Fprintf(os.Stderr, "Error: --offline necesita --golden <dir> con las respuestas grabadas\n")
+			os.Exit(1)
+		case updateGolden || autoContinue || numChoices > 1 || bestOf > 1 || *fimMode:
+			fmt.Fprintf(os.Stderr, "Error: --offline no se puede combinar con --update-golden, --auto-continue, --n, --best-of ni --fim\n")
 			os.Exit(1)
 		}
-		// Combinar con input de stdin si hubiera
-		if input != "" {
-			logger.Println("Combinando entrada de stdin con archivo de entrada")
+	}
+
+	if autoContinue && (numChoices > 1 || rawOutput) {
+		fmt.Fprintf(os.Stderr, "Error: --auto-continue no se puede combinar con --n ni -raw\n")
+		os.Exit(1)
+	}
+	if maxContinues < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --max-continues debe ser mayor que 0\n")
+		os.Exit(1)
+	}
+
+	if *fimMode {
+		if !betaMode {
+			fmt.Fprintf(os.Stderr, "Error: --fim es una función beta; añade --beta\n")
+			os.Exit(1)
 		}
-		input = strings.TrimSpace(input) + "\n" + string(fileContent)
-		logger.Printf("Total de %d bytes de entrada\n", len(input))
+		if streamOutput || jsonOutput || numChoices > 1 || prefill != "" || messagesJSON != "" || messagesFile != "" {
+			fmt.Fprintf(os.Stderr, "Error: --fim no se puede combinar con --stream, --json, --n, --prefill ni --messages-json/--messages-file\n")
+			os.Exit(1)
+		}
+	} else if *suffixFile != "" {
+		fmt.Fprintf(os.Stderr, "Error: --suffix-file solo tiene sentido junto con --fim\n")
+		os.Exit(1)
 	}
 
-	// Obtener la instrucción
-	var prompt string
-	if *instruction != "" {
-		prompt = *instruction
-	} else if len(flag.Args()) > 0 {
-		prompt = strings.Join(flag.Args(), " ")
-	} else {
-		logger.Println("Error: No se proporcionó instrucción")
-		printHelp()
+	if scriptFile != "" && (jsonOutput || rawOutput || numChoices > 1 || bestOf > 1 || prefill != "" || formatTemplate != "" || validateCmd != "" || *fimMode || reviewContext) {
+		fmt.Fprintf(os.Stderr, "Error: --script no se puede combinar con --json, -raw, --n, --best-of, --prefill, --format-template, --validate-cmd, --fim ni --review-context\n")
 		os.Exit(1)
 	}
 
-	logger.Printf("Preparando solicitud con prompt: %s\n", prompt)
-	logger.Printf("Configuración - Temperatura: %.2f, MaxTokens: %d\n", temperature, maxTokens)
+	if gistPublic && !gistUpload {
+		fmt.Fprintf(os.Stderr, "Error: --public solo tiene sentido junto con --gist\n")
+		os.Exit(1)
+	}
 
-	// Construir el mensaje para la API
-	var messages []Message
+	// Validar maxTokens
+	if maxTokens <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: maxTokens debe ser mayor que 0\n")
+		os.Exit(1)
+	}
 
-	// Si hay input (de pipe o archivo), agregarlo como contexto
-	if input != "" {
-		messages = append(messages, Message{
-			Role:    "system",
-			Content: "Eres un asistente de programación experto. Ayudarás con código proporcionado por el usuario.",
-		})
+	// Los conjuntos de --context se añaden a los archivos de -f
+	if len(contextSets) > 0 {
+		files, err := expandContextSets(contextSets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		inputFiles = append(inputFiles, files...)
+	}
 
-		messages = append(messages, Message{
-			Role:    "user",
-			Content: "Este es el código con el que necesito ayuda:\n" + input,
-		})
+	// Cargar variables de entorno desde .env
+	if err := loadEnv(); err != nil {
+		statusf("Advertencia: %v\n", err)
 	}
 
-	// Agregar la instrucción del usuario
-	messages = append(messages, Message{
-		Role:    "user",
-		Content: prompt,
-	})
+	loadAPIConfig()
+	if confirmCost {
+		if _, err := resolveConfirmThreshold(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	logger.Printf("Preparando solicitud con %d mensajes de contexto\n", len(messages))
+	// Un --model mal escrito se detecta antes de enviar nada
+	if isFlagSet("model") {
+		if err := validateModel(model); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	// Crear el cuerpo de la solicitud
-	requestBody := RequestBody{
-		Model:       model,
-		Messages:    messages,
-		MaxTokens:   maxTokens,
-		Temperature: temperature,
-		Stream:      false,
+	// Fill-in-the-middle: la entrada es el código anterior al hueco
+	if *fimMode {
+		runFIM(inputFiles, *suffixFile)
+		return
 	}
 
-	// Convertir a JSON
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		logger.Fatalf("Error al crear el cuerpo JSON: %v", err)
+	// Guion de conversación: los turnos sustituyen a -i y a la entrada
+	if scriptFile != "" {
+		if len(instructions) > 0 || len(inputFiles) > 0 || len(flag.Args()) > 0 || messagesJSON != "" || messagesFile != "" {
+			statusf("Advertencia: con --script se ignoran -i, -f, --messages-json/--messages-file y los argumentos\n")
+		}
+		runScript(scriptFile, *outputFile)
+		return
 	}
 
-	if verbose {
-		logger.Printf("Cuerpo de la solicitud:\n%s\n", jsonBody)
+	var messages []Message
+	var err error
+	var chunked *chunkedReview
+	var sources []*contextSource
+
+	if messagesJSON != "" || messagesFile != "" {
+		// Mensajes definidos por el usuario, sin construcción propia
+		messages, err = loadMessages(messagesJSON, messagesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(instructions) > 0 || len(inputFiles) > 0 || len(flag.Args()) > 0 {
+			statusf("Advertencia: con --messages-json/--messages-file se ignoran -i, -f y los argumentos\n")
+		}
+		promptHash = hashPrompt(messages[len(messages)-1].Content)
+		logger.Printf("Usando %d mensajes definidos por el usuario\n", len(messages))
+	} else {
+		// Leer la entrada (puede ser de pipe, archivos o argumentos); con
+		// --about los archivos solo aportan las definiciones relacionadas
+		files := inputFiles
+		if len(aboutSymbols) > 0 {
+			files = nil
+		}
+		var stdinData string
+		sources, stdinData = readInput(files)
+		if len(aboutSymbols) > 0 {
+			about, err := aboutContext(aboutSymbols, inputFiles)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			sources = append(sources, newContextSource("about", "about", about, true))
+		}
+
+		// Paquetes Go de --go-package
+		for _, dir := range goPackages {
+			pkgSources, err := goPackageSources(dir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			sources = append(sources, pkgSources...)
+		}
+
+		// Salida de los comandos de --exec como contexto adicional
+		if len(execCommands) > 0 {
+			execSources, err := execContext(execCommands)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			sources = append(sources, execSources...)
+		}
+
+		// Estado del repositorio git como contexto adicional
+		if gitContextOn || gitDiff {
+			output, err := gitContext(gitDiff)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			sources = append(sources, newContextSource("git", "git", output, true))
+		}
+
+		// Obtener la instrucción; con varios -i, los anteriores al último van
+		// antes como mensajes propios del usuario
+		var instruction string
+		if len(instructions) > 0 {
+			instruction = instructions[len(instructions)-1]
+			priorInstructions = instructions[:len(instructions)-1]
+		}
+		prompt := resolvePrompt(instruction, flag.Args(), stdinData)
+		if promptTemplate != nil {
+			prompt = promptTemplate.apply(prompt)
+		}
+		if prompt == "" {
+			logger.Println("Error: No se proporcionó instrucción")
+			printHelp(os.Stderr)
+			os.Exit(1)
+		}
+
+		promptHash = hashPrompt(prompt)
+		if codeOnly.enabled {
+			prompt += codeOnlyInstruction
+		}
+
+		// Ajustar las fuentes de contexto a la ventana del modelo
+		if err := applyPriorities(sources, contextPriorities); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		budget := contextBudget
+		if budget <= 0 {
+			budget = contextWindow(model) - maxTokens - estimateTokens(prompt+strings.Join(priorInstructions, "\n")) - contextMargin
+		}
+
+		// Un diff o un archivo que no cabe se procesa por partes (por archivos
+		// y hunks, o por funciones y tipos) en lugar de recortarse
+		canChunk := !streamOutput && !rawOutput && numChoices <= 1 && bestOf <= 1 && prefill == ""
+		splitSource := func(big *contextSource, noun string, chunks []inputChunk) {
+			var rest []*contextSource
+			for _, s := range sources {
+				if s != big {
+					rest = append(rest, s)
+				}
+			}
+			fitBudget(rest, budget)
+			chunked = &chunkedReview{prompt: prompt, noun: noun, chunks: chunks, extra: joinSources(rest), budget: budget}
+		}
+		if d := findDiffSource(sources); canChunk && d != nil && totalTokens(sources) > budget {
+			splitSource(d, "diff", chunkDiff(d.Raw, budget))
+		} else if c := findOversizedSource(sources, budget); canChunk && c != nil {
+			splitSource(c, "archivo", chunkCode(c.Name, c.Raw, budget))
+		} else if fitBudget(sources, budget) {
+			statusf("Advertencia: el contexto no cabe en la ventana del modelo; se ha recortado\n")
+			if !quiet {
+				printContextReport(os.Stderr, sources, budget)
+			}
+		} else if verbose && len(sources) > 0 {
+			printContextReport(os.Stderr, sources, budget)
+		}
+		input := joinSources(sources)
+
+		logger.Printf("Preparando solicitud con prompt: %s\n", prompt)
+
+		// Construir el mensaje para la API
+		messages = buildMessages(prompt, input, stdinData)
 	}
 
-	// Crear la solicitud HTTP
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error al crear la solicitud HTTP: %v\n", err)
-		os.Exit(1)
+	// Prefijo del asistente (beta): la respuesta continúa desde este texto
+	if prefill != "" {
+		prefill = unescapeFlag(prefill)
+		messages = append(messages, Message{
+			Role:    "assistant",
+			Content: prefill,
+			Prefix:  true,
+		})
+		logger.Printf("Usando prefijo del asistente: %q\n", prefill)
 	}
 
-	// Configurar headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	// Con --review-context se revisa lo que sale antes de enviarlo
+	if reviewContext && !offlineMode {
+		if err := confirmContext(sources, messages, chunked); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+	}
 
-	logger.Println("Enviando solicitud a la API...")
+	logger.Printf("Configuración - Temperatura: %.2f, MaxTokens: %d\n", temperature, maxTokens)
+	logger.Printf("Preparando solicitud con %d mensajes de contexto\n", len(messages))
 
-	// Realizar la solicitud
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error al realizar la solicitud HTTP: %v\n", err)
-		os.Exit(1)
+	// Crear el cuerpo de la solicitud
+	requestBody := newRequestBody(messages)
+	requestBody.Logprobs = logprobs.enabled
+	requestBody.TopLogprobs = logprobs.top
+	if numChoices > 1 {
+		requestBody.N = numChoices
+	}
+	if promptTemplate != nil && promptTemplate.Schema != nil {
+		requestBody.ResponseFormat = &ResponseFormat{Type: "json_object"}
 	}
-	defer resp.Body.Close()
 
-	logger.Printf("Respuesta recibida, código de estado: %d\n", resp.StatusCode)
+	// En streaming el texto se muestra según llega; si la conexión se corta
+	// se reanuda desde lo ya recibido
+	if streamOutput {
+		// Ctrl+C detiene el stream sin perder lo recibido
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		requestBody.ctx = ctx
+
+		fmt.Print(prefill)
+		printDelta := func(delta string) { fmt.Print(delta) }
+		onResume := func(attempt int) {
+			statusf("\n[conexión interrumpida, reanudando %d/%d]\n", attempt, maxStreamResumes)
+		}
+		result, err := streamWithResume(requestBody, printDelta, onResume)
+		usage = result.Usage
+		content := result.Content
+		for i := 1; err == nil && autoContinue && result.FinishReason == "length" && i <= maxContinues; i++ {
+			logger.Printf("Respuesta cortada por max_tokens; continuando (%d/%d)\n", i, maxContinues)
+			body := requestBody
+			body.Messages = withAssistantPrefix(requestBody.Messages, prefill+content)
+			result, err = streamWithResume(body, printDelta, onResume)
+			content += result.Content
+			usage.add(result.Usage)
+		}
+		fmt.Println()
+		if errors.Is(err, errStreamCanceled) {
+			statusf("[interrumpido: la respuesta está incompleta]\n")
+			if *outputFile != "" {
+				saveOutput(*outputFile, prefill+content+"\n\n"+partialMarker+"\n", requestBody, true)
+			}
+			finish(false, "interrumpido por el usuario")
+			os.Exit(130)
+		}
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		if *outputFile != "" {
+			saveOutput(*outputFile, prefill+content, requestBody, false)
+		}
+		finish(true, "")
+		return
+	}
 
-	// Leer la respuesta
-	body, err := io.ReadAll(resp.Body)
+	var body []byte
+	var statusCode int
+	if offlineMode {
+		body, err = replayGolden(goldenDir, requestBody)
+		statusCode = http.StatusOK
+	} else if chunked != nil {
+		body, err = reviewInChunks(requestBody, *chunked)
+		statusCode = http.StatusOK
+	} else if bestOf > 1 {
+		body, err = bestOfResponse(requestBody, bestOf)
+		statusCode = http.StatusOK
+	} else {
+		body, statusCode, err = sendWithFailover(prefill != "", requestBody)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error al leer la respuesta HTTP: %v\n", err)
-		os.Exit(1)
+		// En modo crudo también se muestra el cuerpo del error
+		if rawOutput && len(body) > 0 {
+			printRaw(body)
+		}
+		fatalf("Error: %v\n", err)
 	}
+	logger.Printf("Respuesta recibida, código de estado: %d\n", statusCode)
 
 	if verbose {
 		logger.Printf("Respuesta cruda:\n%s\n", body)
 	}
 
-	// Si se solicita salida cruda, imprimir y salir
+	// Parsear la respuesta
+	var response ResponseBody
+	parseErr := json.Unmarshal(body, &response)
+	usage = response.Usage
+	if cost, ok := estimateCost(activeProvider, providerModel(model), usage); ok {
+		logger.Printf("Coste estimado: $%.6f\n", cost)
+	}
+
+	// Si se solicita salida cruda, imprimir y salir; un objeto de error en
+	// la respuesta termina con error aunque el código sea 200
 	if rawOutput {
-		fmt.Println(string(body))
+		printRaw(body)
+		if parseErr == nil && response.Error.Message != "" {
+			fatalf("Error de la API: %s\n", response.Error.Message)
+		}
+		finish(true, "")
 		return
 	}
 
-	// Parsear la respuesta
-	var response ResponseBody
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error al parsear la respuesta JSON: %v\n", err)
-		os.Exit(1)
+	if parseErr != nil {
+		fatalf("Error al parsear la respuesta JSON: %v\n", parseErr)
 	}
 
 	// Manejar errores de la API
 	if response.Error.Message != "" {
-		fmt.Fprintf(os.Stderr, "Error de la API: %s\n", response.Error.Message)
-		os.Exit(1)
+		fatalf("Error de la API: %s\n", response.Error.Message)
+	}
+
+	// Unir las continuaciones de una respuesta cortada por max_tokens; con
+	// --anonymize la respuesta aún lleva los marcadores, así que el prefijo
+	// de la continuación sale anonimizado
+	if autoContinue && len(response.Choices) > 0 {
+		if err := continueResponse(requestBody, &response, maxContinues); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		usage = response.Usage
+	}
+
+	// Devolver los datos originales en lugar de los marcadores de --anonymize
+	if anon != nil {
+		for i := range response.Choices {
+			response.Choices[i].Message.Content = anon.Restore(response.Choices[i].Message.Content)
+		}
+		logger.Printf("Datos anonimizados: %s\n", anon.Summary())
 	}
 
 	// Mostrar la respuesta
 	if len(response.Choices) > 0 {
-		output := response.Choices[0].Message.Content
+		// La respuesta debe cumplir el esquema de la plantilla
+		if promptTemplate != nil && promptTemplate.Schema != nil {
+			for _, choice := range response.Choices {
+				if err := promptTemplate.checkSchema(choice.Message.Content); err != nil {
+					fatalf("Error: la respuesta no cumple el esquema de la plantilla: %v\n", err)
+				}
+			}
+		}
+
+		// Validar con --validate-cmd, pidiendo correcciones si falla
+		if validateCmd != "" {
+			if err := validateResponse(requestBody, &response, *outputFile); err != nil {
+				usage = response.Usage
+				fatalf("Error: %v\n", err)
+			}
+			usage = response.Usage
+		}
+
+		output, err := formatOutput(response)
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+
+		// Comparar con la respuesta dorada
+		var goldenDiff string
+		if goldenDir != "" {
+			var created bool
+			goldenDiff, created, err = checkGolden(goldenDir, requestBody, plainText(response), updateGolden)
+			if err != nil {
+				fatalf("Error: %v\n", err)
+			}
+			if created {
+				statusf("Respuesta dorada guardada en %s\n", goldenDir)
+			}
+		}
 
 		// Si se especificó un archivo de salida, escribir en él
 		if *outputFile != "" {
-			logger.Printf("Escribiendo respuesta en archivo: %s\n", *outputFile)
-			err := os.WriteFile(*outputFile, []byte(output), 0644)
+			saveOutput(*outputFile, output, requestBody, false)
+		} else {
+			// Mostrar en consola si no hay archivo de salida; el Markdown se
+			// renderiza solo en un terminal salvo que se fuerce
+			render := isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+			if forceRender {
+				render = true
+			}
+			if forcePlain || jsonOutput || outputTemplate != nil || codeOnly.enabled {
+				render = false
+			}
+			if render {
+				fmt.Println(renderMarkdown(output))
+			} else if outputWidth > 0 && !jsonOutput && outputTemplate == nil && !codeOnly.enabled {
+				// Sin renderizar, el texto solo se ajusta si se pide --width
+				fmt.Println(wrapProse(output, outputWidth))
+			} else {
+				fmt.Println(output)
+			}
+		}
+
+		// Compartir la respuesta (o el archivo generado) como gist
+		if gistUpload {
+			filename := "deepcli-respuesta.md"
+			if *outputFile != "" {
+				filename = filepath.Base(*outputFile)
+			} else if jsonOutput {
+				filename = "deepcli-respuesta.json"
+			}
+			logger.Printf("Subiendo gist %s...\n", filename)
+			gistURL, err := createGist(filename, "Respuesta generada con deepcli ("+model+")", output, gistPublic)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error al escribir en el archivo de salida: %v\n", err)
-				os.Exit(1)
+				fatalf("Error al crear el gist: %v\n", err)
 			}
-			fmt.Printf("Respuesta escrita en %s\n", *outputFile)
-		} else {
-			// Mostrar en consola si no hay archivo de salida
-			fmt.Println(output)
+			fmt.Fprintf(os.Stderr, "Gist creado: %s\n", gistURL)
+		}
+
+		if goldenDiff != "" {
+			fmt.Fprintf(os.Stderr, "La respuesta difiere de la respuesta dorada:\n%s", goldenDiff)
+			finish(false, "la respuesta difiere de la respuesta dorada")
+			os.Exit(1)
 		}
 	} else {
-		fmt.Fprintf(os.Stderr, "No se recibió ninguna respuesta válida de la API")
-		os.Exit(1)
+		fatalf("No se recibió ninguna respuesta válida de la API\n")
 	}
+
+	finish(true, "")
 }
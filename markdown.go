@@ -0,0 +1,208 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+)
+
+// Secuencias ANSI usadas por el renderizado de Markdown
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiCyan      = "\x1b[36m"
+	ansiYellow    = "\x1b[33m"
+	ansiGreen     = "\x1b[32m"
+	ansiBlue      = "\x1b[34m"
+	ansiRed       = "\x1b[31m"
+)
+
+var (
+	mdInlineCode = regexp.MustCompile("`([^`]+)`")
+	mdBold       = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdItalic     = regexp.MustCompile(`(^|[^*\w])\*([^*\s][^*]*)\*|(^|[^_\w])_([^_\s][^_]*)_`)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	mdHeading    = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdBullet     = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdRule       = regexp.MustCompile(`^\s*(-\s*){3,}$|^\s*(\*\s*){3,}$|^\s*(_\s*){3,}$`)
+	mdListItem   = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+`)
+)
+
+// Por debajo de este ancho útil no se ajustan las líneas: quedarían
+// palabras sueltas
+const minWrapWidth = 20
+
+// isTerminal indica si el archivo es un terminal
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return (stat.Mode() & os.ModeCharDevice) != 0
+}
+
+// wrapWidth es el ancho al que se ajusta el texto: --width o el del terminal
+// (o $COLUMNS); 0 si no se conoce
+func wrapWidth() int {
+	if outputWidth > 0 {
+		return outputWidth
+	}
+	if w, _, err := term.GetSize(os.Stdout.Fd()); err == nil && w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return 0
+}
+
+// wrapIndented ajusta s al ancho tras el prefijo first; las líneas siguientes
+// empiezan con rest (la sangría de una lista o la barra de una cita). Las
+// palabras más largas que la línea, como las URL, no se cortan.
+func wrapIndented(first, rest, s string, width int) string {
+	avail := width - ansi.StringWidth(first)
+	if width <= 0 || avail < minWrapWidth || ansi.StringWidth(s) <= avail {
+		return first + s
+	}
+	return first + strings.ReplaceAll(ansi.Wordwrap(s, avail, ""), "\n", "\n"+rest)
+}
+
+// isCodeOrTable indica si una línea fuera de un bloque de código no se debe
+// ajustar: código sangrado con 4 espacios o tabulador, y filas de tablas
+func isCodeOrTable(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(strings.TrimSpace(line), "|")
+}
+
+// wrapProse ajusta al ancho los párrafos y las listas de un texto Markdown
+// sin formato, con sangría francesa en los elementos de las listas. Nunca se
+// tocan los bloques de código, el código sangrado ni las tablas.
+func wrapProse(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	inCode := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		item := mdListItem.FindString(line)
+		if inCode || (isCodeOrTable(line) && item == "") || mdHeading.MatchString(line) {
+			continue
+		}
+		var prefix, rest, body string
+		switch {
+		case item != "":
+			prefix, rest, body = item, strings.Repeat(" ", len(item)), line[len(item):]
+		case strings.HasPrefix(trimmed, ">"):
+			q := strings.Index(line, ">") + 1
+			prefix = line[:q] + " "
+			rest, body = prefix, strings.TrimLeft(line[q:], " ")
+		default:
+			prefix = line[:len(line)-len(strings.TrimLeft(line, " "))]
+			rest, body = prefix, line[len(prefix):]
+		}
+		lines[i] = wrapIndented(prefix, rest, body, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderMarkdown da formato ANSI al Markdown para mostrarlo en un terminal:
+// títulos, listas, citas, énfasis, enlaces y bloques de código. La prosa se
+// ajusta al ancho de wrapWidth; el código nunca.
+func renderMarkdown(text string) string {
+	return renderMarkdownWidth(text, wrapWidth())
+}
+
+// renderMarkdownWidth es renderMarkdown con un ancho dado; 0 no ajusta
+func renderMarkdownWidth(text string, width int) string {
+	var out []string
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// Bloques de código: se muestran tal cual, con color y sin vallas
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			if !inCode {
+				lang := strings.TrimSpace(trimmed[3:])
+				if lang != "" {
+					out = append(out, ansiDim+"── "+lang+" ──"+ansiReset)
+				} else {
+					out = append(out, ansiDim+"──────"+ansiReset)
+				}
+			} else {
+				out = append(out, ansiDim+"──────"+ansiReset)
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			out = append(out, ansiGreen+"  "+line+ansiReset)
+			continue
+		}
+
+		switch {
+		case mdHeading.MatchString(line):
+			m := mdHeading.FindStringSubmatch(line)
+			style := ansiBold + ansiCyan
+			if len(m[1]) == 1 {
+				style += ansiUnderline
+			}
+			out = append(out, style+renderInline(m[2])+ansiReset)
+		case mdRule.MatchString(line):
+			out = append(out, ansiDim+strings.Repeat("─", 40)+ansiReset)
+		case strings.HasPrefix(trimmed, ">"):
+			quote := strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+			bar := ansiDim + "│ " + ansiReset + ansiItalic
+			out = append(out, wrapIndented(bar, bar, renderInline(quote), width)+ansiReset)
+		case mdBullet.MatchString(line):
+			m := mdBullet.FindStringSubmatch(line)
+			out = append(out, wrapIndented(m[1]+ansiYellow+"•"+ansiReset+" ", m[1]+"  ", renderInline(m[2]), width))
+		case isCodeOrTable(line):
+			out = append(out, renderInline(line))
+		default:
+			indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+			if m := mdListItem.FindString(line); m != "" {
+				indent = strings.Repeat(" ", len(m))
+			}
+			out = append(out, wrapIndented("", indent, renderInline(line), width))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderInline aplica el formato de los elementos en línea. El código en
+// línea se protege para no aplicar énfasis dentro de él.
+func renderInline(s string) string {
+	var codes []string
+	s = mdInlineCode.ReplaceAllStringFunc(s, func(m string) string {
+		codes = append(codes, m[1:len(m)-1])
+		return "\x00" + strconv.Itoa(len(codes)-1) + "\x00"
+	})
+
+	s = mdLink.ReplaceAllString(s, ansiUnderline+"$1"+ansiReset+" ("+ansiBlue+"$2"+ansiReset+")")
+	s = mdBold.ReplaceAllStringFunc(s, func(m string) string {
+		return ansiBold + m[2:len(m)-2] + ansiReset
+	})
+	s = mdItalic.ReplaceAllStringFunc(s, func(m string) string {
+		sub := mdItalic.FindStringSubmatch(m)
+		if sub[2] != "" {
+			return sub[1] + ansiItalic + sub[2] + ansiReset
+		}
+		return sub[3] + ansiItalic + sub[4] + ansiReset
+	})
+
+	for i, code := range codes {
+		s = strings.Replace(s, "\x00"+strconv.Itoa(i)+"\x00", ansiYellow+code+ansiReset, 1)
+	}
+	return s
+}
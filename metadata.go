@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// withMetadata (--with-metadata) guarda junto al archivo de -o un
+// <archivo>.meta.json con los datos de la ejecución que lo generó
+var withMetadata bool
+
+// RunMetadata son los datos de la ejecución que generó un archivo de salida:
+// qué se pidió, con qué parámetros, cuánto costó y cuándo
+type RunMetadata struct {
+	Version      string `json:"deepcli_version"`
+	Output       string `json:"output"`
+	OutputSHA256 string `json:"output_sha256"`
+	Partial      bool   `json:"partial,omitempty"`
+
+	// Prompt es el último mensaje del usuario; el resto de los mensajes
+	// (sistema, contexto, instrucciones previas) se resume en su huella
+	Prompt        string `json:"prompt"`
+	ContextSHA256 string `json:"context_sha256,omitempty"`
+	Messages      int    `json:"messages"`
+
+	Parameters RunParameters `json:"parameters"`
+	Usage      Usage         `json:"usage"`
+	CostUSD    *float64      `json:"cost_usd,omitempty"`
+
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// RunParameters son los parámetros de generación de la solicitud
+type RunParameters struct {
+	Provider       string                     `json:"provider"`
+	BaseURL        string                     `json:"base_url"`
+	Model          string                     `json:"model"`
+	Temperature    float64                    `json:"temperature"`
+	MaxTokens      int                        `json:"max_tokens"`
+	N              int                        `json:"n,omitempty"`
+	Stop           []string                   `json:"stop,omitempty"`
+	Stream         bool                       `json:"stream,omitempty"`
+	ResponseFormat string                     `json:"response_format,omitempty"`
+	PromptTemplate string                     `json:"prompt_template,omitempty"`
+	Params         map[string]json.RawMessage `json:"params,omitempty"`
+}
+
+// newRunMetadata reúne los datos de la ejecución actual para el archivo
+// escrito en path con el contenido output
+func newRunMetadata(path, output string, requestBody RequestBody, partial bool) RunMetadata {
+	now := time.Now()
+	meta := RunMetadata{
+		Version:      version,
+		Output:       path,
+		OutputSHA256: sha256Hex([]byte(output)),
+		Partial:      partial,
+		Messages:     len(requestBody.Messages),
+		Parameters: RunParameters{
+			Provider:       orDefault(activeProvider, "deepseek"),
+			BaseURL:        redactURL(apiBaseURL),
+			Model:          providerModel(requestBody.Model),
+			Temperature:    requestBody.Temperature,
+			MaxTokens:      requestBody.MaxTokens,
+			N:              requestBody.N,
+			Stop:           requestBody.Stop,
+			Stream:         streamOutput,
+			PromptTemplate: promptName,
+		},
+		Usage:      usage,
+		StartedAt:  startTime.UTC(),
+		FinishedAt: now.UTC(),
+		DurationMs: now.Sub(startTime).Milliseconds(),
+	}
+	if requestBody.ResponseFormat != nil {
+		meta.Parameters.ResponseFormat = requestBody.ResponseFormat.Type
+	}
+	if len(requestParams) > 0 {
+		meta.Parameters.Params = requestParams
+	}
+
+	context := requestBody.Messages
+	for i := len(context) - 1; i >= 0; i-- {
+		if context[i].Role == "user" {
+			meta.Prompt = context[i].Content
+			context = append(append([]Message{}, context[:i]...), context[i+1:]...)
+			break
+		}
+	}
+	if len(context) > 0 {
+		data, _ := json.Marshal(context)
+		meta.ContextSHA256 = sha256Hex(data)
+	}
+	if cost, ok := estimateCost(activeProvider, meta.Parameters.Model, usage); ok {
+		meta.CostUSD = &cost
+	}
+	return meta
+}
+
+// metadataPath es la ruta del archivo de metadatos de un archivo de salida
+func metadataPath(path string) string {
+	return path + ".meta.json"
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// mockServer simula la API de chat completions para desarrollo y pruebas
+type mockServer struct {
+	response    string
+	errorRate   float64
+	errorStatus int
+	dropRate    float64
+	latency     time.Duration
+	chunkDelay  time.Duration
+	requests    int64
+}
+
+func (m *mockServer) writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-Id", fmt.Sprintf("mock-%d", atomic.LoadInt64(&m.requests)))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    "mock_error",
+			"code":    status,
+		},
+	})
+}
+
+// injectedError decide si la solicitud debe fallar: por la cabecera
+// X-Mock-Error o al azar según --error-rate
+func (m *mockServer) injectedError(r *http.Request) int {
+	if v := r.Header.Get("X-Mock-Error"); v != "" {
+		if status, err := strconv.Atoi(v); err == nil {
+			return status
+		}
+	}
+	if m.errorRate > 0 && rand.Float64() < m.errorRate {
+		return m.errorStatus
+	}
+	return 0
+}
+
+// reply genera el contenido simulado para la solicitud
+func (m *mockServer) reply(req RequestBody) string {
+	if m.response != "" {
+		return m.response
+	}
+	var last string
+	for _, msg := range req.Messages {
+		if msg.Role == "user" {
+			last = msg.Content
+		}
+	}
+	return "Respuesta simulada a: " + last
+}
+
+// estimateMockTokens aproxima el número de tokens de un texto
+func estimateMockTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// handleModels devuelve la lista de modelos de DeepSeek
+func (m *mockServer) handleModels(w http.ResponseWriter, r *http.Request) {
+	if status := m.injectedError(r); status != 0 {
+		m.writeError(w, status, "error simulado")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"object": "list",
+		"data": []map[string]string{
+			{"id": "deepseek-chat", "object": "model", "owned_by": "deepseek"},
+			{"id": "deepseek-reasoner", "object": "model", "owned_by": "deepseek"},
+		},
+	})
+}
+
+// handleBalance devuelve un saldo fijo
+func (m *mockServer) handleBalance(w http.ResponseWriter, r *http.Request) {
+	if status := m.injectedError(r); status != 0 {
+		m.writeError(w, status, "error simulado")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Balance{
+		IsAvailable:  true,
+		BalanceInfos: []BalanceInfo{{Currency: "USD", TotalBalance: "4.20", GrantedBalance: "0.00", ToppedUpBalance: "4.20"}},
+	})
+}
+
+// handleCompletions simula el endpoint de fill-in-the-middle (beta)
+func (m *mockServer) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&m.requests, 1)
+	if status := m.injectedError(r); status != 0 {
+		m.writeError(w, status, fmt.Sprintf("error simulado (%d)", status))
+		return
+	}
+	var req FIMRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Prompt == "" {
+		m.writeError(w, http.StatusBadRequest, "prompt no puede estar vacío")
+		return
+	}
+	text := m.response
+	if text == "" {
+		text = fmt.Sprintf("/* relleno simulado entre %d y %d bytes */", len(req.Prompt), len(req.Suffix))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"object":  "text_completion",
+		"model":   req.Model,
+		"choices": []map[string]interface{}{{"index": 0, "text": text, "finish_reason": "stop"}},
+		"usage": Usage{
+			PromptTokens:     estimateMockTokens(req.Prompt + req.Suffix),
+			CompletionTokens: estimateMockTokens(text),
+			TotalTokens:      estimateMockTokens(req.Prompt+req.Suffix) + estimateMockTokens(text),
+		},
+	})
+}
+
+func (m *mockServer) handleChat(w http.ResponseWriter, r *http.Request) {
+	n := atomic.AddInt64(&m.requests, 1)
+	if r.Method != http.MethodPost {
+		m.writeError(w, http.StatusMethodNotAllowed, "método no permitido")
+		return
+	}
+
+	var req RequestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		m.writeError(w, http.StatusBadRequest, "JSON inválido: "+err.Error())
+		return
+	}
+	logger.Printf("[%d] %s %s modelo=%s mensajes=%d stream=%v\n", n, r.Method, r.URL.Path, req.Model, len(req.Messages), req.Stream)
+
+	if m.latency > 0 {
+		time.Sleep(m.latency)
+	}
+	if status := m.injectedError(r); status != 0 {
+		logger.Printf("[%d] error inyectado: %d\n", n, status)
+		m.writeError(w, status, fmt.Sprintf("error simulado (%d)", status))
+		return
+	}
+	if len(req.Messages) == 0 {
+		m.writeError(w, http.StatusBadRequest, "messages no puede estar vacío")
+		return
+	}
+
+	// Con prefix completion, la respuesta continúa el prefijo del asistente
+	content := m.reply(req)
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role == "assistant" && last.Prefix {
+		content = strings.TrimPrefix(content, last.Content)
+	}
+
+	finishReason := "stop"
+	if req.MaxTokens > 0 && estimateMockTokens(content) > req.MaxTokens {
+		content = content[:req.MaxTokens*4]
+		finishReason = "length"
+	}
+
+	promptTokens := 0
+	for _, msg := range req.Messages {
+		promptTokens += estimateMockTokens(msg.Content)
+	}
+	choices := req.N
+	if choices < 1 {
+		choices = 1
+	}
+	usage := Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: estimateMockTokens(content) * choices,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	id := fmt.Sprintf("mock-%d", n)
+	if req.Stream {
+		m.stream(w, id, req.Model, content, finishReason, usage)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   req.Model,
+		"usage":   usage,
+	}
+	var list []map[string]interface{}
+	for i := 0; i < choices; i++ {
+		list = append(list, map[string]interface{}{
+			"index":         i,
+			"message":       map[string]string{"role": "assistant", "content": content},
+			"finish_reason": finishReason,
+		})
+	}
+	resp["choices"] = list
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// stream envía la respuesta como eventos SSE, palabra a palabra
+func (m *mockServer) stream(w http.ResponseWriter, id, model, content, finishReason string, usage Usage) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		m.writeError(w, http.StatusInternalServerError, "streaming no soportado")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	send := func(v interface{}) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+	chunk := func(delta map[string]string, finish interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   model,
+			"choices": []map[string]interface{}{{"index": 0, "delta": delta, "finish_reason": finish}},
+		}
+	}
+
+	send(chunk(map[string]string{"role": "assistant", "content": ""}, nil))
+	words := strings.SplitAfter(content, " ")
+	for i, word := range words {
+		// Cortar la conexión a mitad de la respuesta según --drop-rate
+		if i > 0 && m.dropRate > 0 && rand.Float64() < m.dropRate {
+			logger.Printf("[%s] stream cortado tras %d fragmentos\n", id, i)
+			return
+		}
+		send(chunk(map[string]string{"content": word}, nil))
+		if m.chunkDelay > 0 {
+			time.Sleep(m.chunkDelay)
+		}
+	}
+	final := chunk(map[string]string{}, finishReason)
+	final["usage"] = usage
+	send(final)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+func runMockServer(args []string) {
+	fs := flag.NewFlagSet("mockserver", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8089", "Dirección en la que escuchar")
+	m := &mockServer{}
+	fs.StringVar(&m.response, "response", "", "Texto fijo de respuesta (default: eco del último mensaje)")
+	responseFile := fs.String("response-file", "", "Archivo con el texto fijo de respuesta")
+	fs.Float64Var(&m.errorRate, "error-rate", 0, "Proporción de solicitudes que fallan (0.0-1.0)")
+	fs.IntVar(&m.errorStatus, "error-status", http.StatusServiceUnavailable, "Código HTTP de los errores inyectados")
+	fs.Float64Var(&m.dropRate, "drop-rate", 0, "Probabilidad de cortar el stream en cada fragmento")
+	fs.DurationVar(&m.latency, "latency", 0, "Latencia añadida a cada solicitud")
+	fs.DurationVar(&m.chunkDelay, "chunk-delay", 20*time.Millisecond, "Pausa entre fragmentos en streaming")
+	fs.BoolVar(&verbose, "v", false, "Registrar cada solicitud")
+	fs.BoolVar(&verbose, "verbose", false, "Registrar cada solicitud")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s mockserver [opciones]
+
+Servidor local que implementa la API de chat completions (incluido el
+streaming SSE, prefix completion y FIM), la lista de modelos y el saldo, para
+desarrollar y probar automatizaciones sin API key ni coste. Para usarlo:
+
+  $ %s mockserver &
+  $ export DEEPSEEK_BASE_URL=http://127.0.0.1:8089/v1 DEEPSEEK_API_KEY=mock
+
+Opciones:
+  --addr <host:puerto>    Dirección en la que escuchar (default: 127.0.0.1:8089)
+  --response <texto>      Respuesta fija (default: eco del último mensaje)
+  --response-file <arch>  Respuesta fija leída de un archivo
+  --error-rate <0-1>      Proporción de solicitudes que fallan
+  --error-status <código> Código HTTP de los errores (default: 503)
+  --drop-rate <0-1>       Probabilidad de cortar el stream en cada fragmento
+  --latency <duración>    Latencia añadida, p. ej. 500ms
+  --chunk-delay <dur.>    Pausa entre fragmentos en streaming (default: 20ms)
+  -v, --verbose           Registrar cada solicitud
+
+La cabecera X-Mock-Error: <código> fuerza un error en una solicitud concreta.
+`, os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+
+	if !verbose {
+		logger.SetOutput(io.Discard)
+	}
+	if *responseFile != "" {
+		data, err := os.ReadFile(*responseFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al leer el archivo de respuesta: %v\n", err)
+			os.Exit(1)
+		}
+		m.response = string(data)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat/completions", m.handleChat)
+	mux.HandleFunc("/v1/chat/completions", m.handleChat)
+	mux.HandleFunc("/beta/chat/completions", m.handleChat)
+	mux.HandleFunc("/completions", m.handleCompletions)
+	mux.HandleFunc("/v1/completions", m.handleCompletions)
+	mux.HandleFunc("/beta/completions", m.handleCompletions)
+	mux.HandleFunc("/models", m.handleModels)
+	mux.HandleFunc("/v1/models", m.handleModels)
+	mux.HandleFunc("/user/balance", m.handleBalance)
+
+	statusf("Servidor simulado escuchando en http://%s (base URL: http://%s/v1)\n", *addr, *addr)
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
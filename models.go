@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// La lista de modelos se guarda en caché para no consultarla en cada
+// ejecución al validar --model
+const modelsCacheTTL = 24 * time.Hour
+
+// ModelInfo es un modelo de la lista del proveedor (GET /models)
+type ModelInfo struct {
+	ID      string `json:"id"`
+	OwnedBy string `json:"owned_by,omitempty"`
+
+	// Ventana de contexto, si el proveedor la anuncia (OpenRouter y otros
+	// gateways)
+	ContextLength int `json:"context_length,omitempty"`
+}
+
+// modelsCache es la lista de modelos de cada URL base
+type modelsCache map[string]struct {
+	Fetched time.Time   `json:"fetched"`
+	Models  []ModelInfo `json:"models"`
+}
+
+func modelsCacheFile() string {
+	return filepath.Join(dataDir(), "models_cache.json")
+}
+
+// modelsEndpoint devuelve la URL de la lista de modelos del proveedor activo
+func modelsEndpoint() string {
+	if provider != nil && provider.Query != "" {
+		return apiBaseURL + "/models?" + provider.Query
+	}
+	return apiBaseURL + "/models"
+}
+
+// fetchModels pide al proveedor la lista de modelos disponibles
+func fetchModels(ctx context.Context) ([]ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", modelsEndpoint(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo crear la solicitud HTTP: %v", err)
+	}
+	if err := authorizeRequest(req, nil); err != nil {
+		return nil, err
+	}
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo obtener la lista de modelos: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer la lista de modelos: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("la API devolvió el código %d al listar los modelos: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var list struct {
+		Data []ModelInfo `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("respuesta inesperada al listar los modelos: %v", err)
+	}
+	sort.Slice(list.Data, func(i, j int) bool { return list.Data[i].ID < list.Data[j].ID })
+	return list.Data, nil
+}
+
+// cachedModels devuelve la lista de modelos de la caché si es reciente y si
+// no la pide al proveedor y la guarda
+func cachedModels() ([]ModelInfo, error) {
+	cache := modelsCache{}
+	if data, err := os.ReadFile(modelsCacheFile()); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	if entry, ok := cache[apiBaseURL]; ok && (offlineMode || time.Since(entry.Fetched) < modelsCacheTTL) {
+		return entry.Models, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	models, err := fetchModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry := cache[apiBaseURL]
+	entry.Fetched, entry.Models = time.Now(), models
+	cache[apiBaseURL] = entry
+	if data, err := json.Marshal(cache); err == nil {
+		if err := os.MkdirAll(dataDir(), 0700); err == nil {
+			os.WriteFile(modelsCacheFile(), data, 0600)
+		}
+	}
+	return models, nil
+}
+
+// cachedModelInfo busca el modelo en la lista guardada en caché del
+// proveedor activo, sin consultar la API
+func cachedModelInfo(name string) (ModelInfo, bool) {
+	cache := modelsCache{}
+	if data, err := os.ReadFile(modelsCacheFile()); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	for _, m := range cache[apiBaseURL].Models {
+		if m.ID == name {
+			return m, true
+		}
+	}
+	return ModelInfo{}, false
+}
+
+// validateModel comprueba que el proveedor ofrece el modelo y, si no, sugiere
+// el más parecido. Si la lista no se puede obtener no se valida.
+func validateModel(name string) error {
+	if provider != nil && provider.Model != "" {
+		return nil
+	}
+	models, err := cachedModels()
+	if err != nil || len(models) == 0 {
+		logger.Printf("No se validó el modelo: %v\n", err)
+		return nil
+	}
+	target := providerModel(name)
+	ids := make([]string, len(models))
+	for i, m := range models {
+		if m.ID == target {
+			return nil
+		}
+		ids[i] = m.ID
+	}
+	msg := fmt.Sprintf("el modelo %q no está disponible", name)
+	if best := closestMatch(target, ids); best != "" {
+		msg += fmt.Sprintf("; ¿quisiste decir %q?", best)
+	}
+	return fmt.Errorf("%s (ver \"%s models\")", msg, filepath.Base(os.Args[0]))
+}
+
+// closestMatch devuelve el candidato más parecido, o "" si ninguno se
+// parece lo suficiente
+func closestMatch(name string, candidates []string) string {
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		d := levenshtein(strings.ToLower(name), strings.ToLower(c))
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	if bestDist < 0 || bestDist > max(len(name)/2, 2) {
+		return ""
+	}
+	return best
+}
+
+// levenshtein calcula la distancia de edición entre dos cadenas
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func runModels(args []string) {
+	fs := flag.NewFlagSet("models", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "Mostrar la lista en JSON")
+	fs.StringVar(&providerName, "provider", "", "Proveedor cuya lista de modelos se consulta")
+	fs.BoolVar(&verbose, "v", false, "Mostrar mensajes detallados de ejecución")
+	fs.BoolVar(&verbose, "verbose", false, "Mostrar mensajes detallados de ejecución")
+	fs.BoolVar(&offlineMode, "offline", false, "Mostrar la lista guardada sin acceder a la red")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s models [opciones]
+
+Lista los modelos que ofrece el proveedor (endpoint /models) con su ventana
+de contexto y su precio en USD por millón de tokens, cuando se conocen. La
+lista se guarda en caché durante 24 horas para validar --model.
+
+Opciones:
+  --provider <nombre>   Proveedor a consultar (default: DeepSeek)
+  --json                Salida en JSON
+  --offline             Mostrar la última lista guardada, sin acceder a la red
+  -v, --verbose         Mostrar logs detallados
+`, os.Args[0])
+	}
+	fs.Parse(args)
+	if !verbose {
+		logger.SetOutput(io.Discard)
+	}
+	if err := loadEnv(); err != nil {
+		statusf("Advertencia: %v\n", err)
+	}
+	loadAPIConfig()
+
+	// Sin conexión se muestra la última lista guardada
+	fetch := fetchModels
+	if offlineMode {
+		fetch = func(context.Context) ([]ModelInfo, error) { return cachedModels() }
+	}
+	models, err := fetch(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	type modelRow struct {
+		ID            string `json:"id"`
+		OwnedBy       string `json:"owned_by,omitempty"`
+		ContextWindow int    `json:"context_window,omitempty"`
+		Price         *Price `json:"price,omitempty"`
+	}
+	rows := make([]modelRow, len(models))
+	for i, m := range models {
+		rows[i] = modelRow{ID: m.ID, OwnedBy: m.OwnedBy, ContextWindow: m.ContextLength}
+		if w, ok := advertisedContextWindow(m.ID); ok {
+			rows[i].ContextWindow = w
+		}
+		if p, ok := lookupPrice(activeProvider, m.ID); ok {
+			rows[i].Price = &p
+		}
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rows)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODELO\tCONTEXTO\tENTRADA\tENTRADA (CACHÉ)\tSALIDA")
+	for _, r := range rows {
+		window, input, cached, output := "-", "-", "-", "-"
+		if r.ContextWindow > 0 {
+			window = fmt.Sprintf("%dK", r.ContextWindow/1024)
+		}
+		if r.Price != nil {
+			input = fmt.Sprintf("$%.2f", r.Price.Input)
+			output = fmt.Sprintf("$%.2f", r.Price.Output)
+			if r.Price.CachedInput > 0 {
+				cached = fmt.Sprintf("$%.2f", r.Price.CachedInput)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.ID, window, input, cached, output)
+	}
+	w.Flush()
+}
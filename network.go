@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	resolveOverrides stringList
+	dnsServer        string
+	happyEyeballs    time.Duration
+	torEnabled       bool
+	torProxy         string
+
+	// apiClient es el cliente HTTP compartido para la API; reutiliza las
+	// conexiones entre solicitudes
+	apiClient = &http.Client{}
+)
+
+// stringList es un flag que se puede repetir
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// addNetworkFlags registra las opciones de conexión con la API
+func addNetworkFlags(fs *flag.FlagSet) {
+	fs.Var(&resolveOverrides, "resolve", "Fijar la IP de un host (host:ip, repetible)")
+	fs.StringVar(&dnsServer, "dns", "", "Servidor DNS a usar (ip[:puerto])")
+	fs.DurationVar(&happyEyeballs, "happy-eyeballs-delay", 0, "Espera antes de probar la otra familia IP (negativo: desactivar)")
+	fs.BoolVar(&torEnabled, "tor", false, "Enviar el tráfico por Tor (SOCKS5) con un circuito aislado por ejecución")
+	fs.StringVar(&torProxy, "tor-proxy", "127.0.0.1:9050", "Dirección del proxy SOCKS5 de Tor")
+}
+
+// torProxyURL devuelve la URL del proxy SOCKS5 de Tor con credenciales
+// aleatorias: Tor aísla en circuitos distintos las conexiones con
+// credenciales distintas (IsolateSOCKSAuth), así cada ejecución sale por su
+// propio circuito. El nombre del host se resuelve en el proxy, sin fugas DNS.
+func torProxyURL() (*url.URL, error) {
+	conn, err := net.DialTimeout("tcp", torProxy, 3*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar con Tor en %s (¿está tor en ejecución?): %v", torProxy, err)
+	}
+	conn.Close()
+
+	user := make([]byte, 8)
+	pass := make([]byte, 8)
+	rand.Read(user)
+	rand.Read(pass)
+	return &url.URL{
+		Scheme: "socks5",
+		User:   url.UserPassword("deepcli-"+hex.EncodeToString(user), hex.EncodeToString(pass)),
+		Host:   torProxy,
+	}, nil
+}
+
+// parseResolve interpreta las entradas host:ip de --resolve; admite IPv6
+// entre corchetes o sin ellos (host:2001:db8::1)
+func parseResolve(entries []string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	for _, entry := range entries {
+		i := strings.IndexByte(entry, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("--resolve %q: formato esperado host:ip", entry)
+		}
+		host := strings.ToLower(entry[:i])
+		ip := strings.Trim(entry[i+1:], "[]")
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("--resolve %q: %q no es una IP válida", entry, ip)
+		}
+		overrides[host] = ip
+	}
+	return overrides, nil
+}
+
+// configureNetwork prepara el transporte del cliente de la API con las
+// resoluciones fijas, el servidor DNS y la configuración de Happy Eyeballs
+func configureNetwork() error {
+	if offlineMode {
+		enableOffline()
+		return nil
+	}
+	overrides, err := parseResolve(resolveOverrides)
+	if err != nil {
+		return err
+	}
+	if torEnabled && (len(overrides) > 0 || dnsServer != "") {
+		return fmt.Errorf("--tor no se puede combinar con --resolve ni --dns: los nombres se resuelven a través de Tor")
+	}
+
+	dialer := &net.Dialer{
+		Timeout:       30 * time.Second,
+		KeepAlive:     30 * time.Second,
+		FallbackDelay: happyEyeballs,
+	}
+	if dnsServer != "" {
+		server := dnsServer
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+		logger.Printf("Usando el servidor DNS %s\n", server)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if ip, ok := overrides[strings.ToLower(host)]; ok {
+				logger.Printf("Resolviendo %s como %s (--resolve)\n", host, ip)
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	if torEnabled {
+		proxy, err := torProxyURL()
+		if err != nil {
+			return err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+		logger.Printf("Enviando el tráfico por Tor (%s) con un circuito aislado\n", torProxy)
+	}
+	apiClient.Transport = transport
+	return nil
+}
+
+// warmupConnection abre de antemano la conexión (TCP y TLS) con la API para
+// que la primera solicitud no pague el coste del establecimiento
+func warmupConnection() {
+	start := time.Now()
+	req, err := http.NewRequest("HEAD", apiBaseURL+"/models", nil)
+	if err != nil {
+		return
+	}
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		logger.Printf("No se pudo precalentar la conexión: %v\n", err)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	logger.Printf("Conexión con la API precalentada en %s\n", time.Since(start).Round(time.Millisecond))
+}
+
+// keepConnectionWarm precalienta la conexión y la mantiene abierta mientras
+// el proceso siga en marcha (modo servidor)
+func keepConnectionWarm(interval time.Duration) {
+	for {
+		warmupConnection()
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Resumen de la ejecución que se envía al webhook de notificación
+type runSummary struct {
+	PromptHash string  `json:"prompt_hash"`
+	Model      string  `json:"model"`
+	DurationMs int64   `json:"duration_ms"`
+	Usage      Usage   `json:"usage"`
+	CostUSD    float64 `json:"cost_usd,omitempty"`
+	Success    bool    `json:"success"`
+	Error      string  `json:"error,omitempty"`
+}
+
+func hashPrompt(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// sendWebhook hace POST del resumen en JSON a la URL indicada
+func sendWebhook(url string, summary runSummary) error {
+	jsonBody, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("error al crear el cuerpo JSON: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return fmt.Errorf("error al crear la solicitud: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error al enviar la notificación: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("el webhook respondió con código %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// desktopNotify hace sonar la campana del terminal y envía una notificación
+// de escritorio (notify-send en Linux, osascript en macOS) si está disponible
+func desktopNotify(title, message string) error {
+	fmt.Fprint(os.Stderr, "\a")
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return fmt.Errorf("notify-send no está disponible")
+		}
+		cmd = exec.Command("notify-send", "-a", "deepcli", title, message)
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", message, title)
+		cmd = exec.Command("osascript", "-e", script)
+	default:
+		return nil
+	}
+	return cmd.Run()
+}
+
+// notifySummaryText construye el texto de la notificación de escritorio
+func notifySummaryText(summary runSummary) (string, string) {
+	duration := time.Duration(summary.DurationMs) * time.Millisecond
+	if !summary.Success {
+		msg := summary.Error
+		if i := strings.IndexByte(msg, '\n'); i >= 0 {
+			msg = msg[:i]
+		}
+		return "deepcli: error", msg
+	}
+	text := fmt.Sprintf("%s en %s (%d tokens)", summary.Model, duration.Round(time.Millisecond), summary.Usage.TotalTokens)
+	if summary.CostUSD > 0 {
+		text = fmt.Sprintf("%s en %s (%d tokens, $%.4f)", summary.Model, duration.Round(time.Millisecond), summary.Usage.TotalTokens, summary.CostUSD)
+	}
+	return "deepcli: respuesta lista", text
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+)
+
+// offlineMode es --offline: cualquier acceso a la red falla y las respuestas
+// solo se sirven de las grabadas con --golden
+var offlineMode bool
+
+var errOffline = errors.New("acceso a la red bloqueado por --offline")
+
+// offlineTransport rechaza todas las solicitudes HTTP
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("%w: %s %s", errOffline, req.Method, redactURL(req.URL.String()))
+}
+
+// enableOffline sustituye el transporte HTTP por defecto (el que usan todos
+// los clientes sin uno propio), el de la API y el resolvedor DNS por otros
+// que fallan siempre, de modo que ningún camino llegue a la red
+func enableOffline() {
+	http.DefaultTransport = offlineTransport{}
+	apiClient.Transport = offlineTransport{}
+	net.DefaultResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, errOffline
+		},
+	}
+	logger.Println("Modo sin conexión: se bloquea el acceso a la red")
+}
+
+// checkOffline falla en modo sin conexión; protege las operaciones que
+// acceden a la red con programas externos (git, aws, gcloud, az)
+func checkOffline(what string) error {
+	if offlineMode {
+		return fmt.Errorf("%w: %s", errOffline, what)
+	}
+	return nil
+}
+
+// replayGolden devuelve, como si viniera de la API, la respuesta dorada
+// grabada para la solicitud
+func replayGolden(dir string, requestBody RequestBody) ([]byte, error) {
+	hash := requestHash(requestBody)
+	data, err := os.ReadFile(goldenPath(dir, hash))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w y no hay respuesta grabada en %s para esta solicitud (grábala antes sin --offline y con --golden %s)", errOffline, dir, dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer la respuesta grabada: %v", err)
+	}
+	var record GoldenRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("respuesta grabada %s inválida: %v", goldenPath(dir, hash), err)
+	}
+	logger.Printf("Respuesta reproducida de %s (grabada el %s)\n", goldenPath(dir, hash), record.CreatedAt.Format("2006-01-02 15:04"))
+
+	response := ResponseBody{Model: record.Model}
+	choice := Choice{FinishReason: "stop"}
+	choice.Message.Content = record.Content
+	response.Choices = []Choice{choice}
+	return json.Marshal(response)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Envelope es la respuesta normalizada que se emite con --json
+type Envelope struct {
+	Model        string    `json:"model"`
+	Provider     string    `json:"provider,omitempty"`
+	Content      string    `json:"content"`
+	FinishReason string    `json:"finish_reason,omitempty"`
+	Usage        Usage     `json:"usage"`
+	CostUSD      *float64  `json:"cost_usd,omitempty"`
+	Logprobs     *Logprobs `json:"logprobs,omitempty"`
+	Timings      *Timings  `json:"timings,omitempty"`
+}
+
+// newEnvelope construye el envoltorio de una de las alternativas de la
+// respuesta de la API
+func newEnvelope(response ResponseBody, choice Choice) Envelope {
+	env := Envelope{
+		Model:        response.Model,
+		Provider:     activeProvider,
+		Content:      prefill + choice.Message.Content,
+		FinishReason: choice.FinishReason,
+		Usage:        response.Usage,
+		Logprobs:     choice.Logprobs,
+		Timings:      latestTimings(),
+	}
+	if env.Model == "" {
+		env.Model = model
+	}
+	if cost, ok := estimateCost(activeProvider, env.Model, env.Usage); ok {
+		env.CostUSD = &cost
+	}
+	return env
+}
+
+// printRaw muestra el cuerpo de la respuesta tal cual o, con --raw-pretty,
+// con el JSON indentado
+func printRaw(body []byte) {
+	if rawPretty {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, bytes.TrimSpace(body), "", "  "); err == nil {
+			body = buf.Bytes()
+		}
+	}
+	fmt.Println(string(bytes.TrimRight(body, "\n")))
+}
+
+// formatOutput genera la salida final: el texto de la respuesta, las
+// alternativas separadas si hay varias, o el JSON con --json
+func formatOutput(response ResponseBody) (string, error) {
+	if outputTemplate != nil {
+		var sb strings.Builder
+		for i, choice := range response.Choices {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			if err := outputTemplate.Execute(&sb, newEnvelope(response, choice)); err != nil {
+				return "", fmt.Errorf("error al aplicar --format-template: %v", err)
+			}
+		}
+		return sb.String(), nil
+	}
+
+	if jsonOutput {
+		var v interface{}
+		if len(response.Choices) == 1 {
+			v = newEnvelope(response, response.Choices[0])
+		} else {
+			envs := make([]Envelope, len(response.Choices))
+			for i, choice := range response.Choices {
+				envs[i] = newEnvelope(response, choice)
+			}
+			v = envs
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("error al crear el JSON de salida: %v", err)
+		}
+		return string(data), nil
+	}
+	if codeOnly.enabled {
+		var parts []string
+		for _, choice := range response.Choices {
+			parts = append(parts, codeOnlyText(prefill+choice.Message.Content))
+		}
+		return strings.Join(parts, "\n\n"), nil
+	}
+	return plainText(response), nil
+}
+
+// plainText devuelve el texto de la respuesta, con las alternativas
+// separadas si hay varias
+func plainText(response ResponseBody) string {
+	if len(response.Choices) == 1 {
+		return prefill + response.Choices[0].Message.Content
+	}
+
+	var sb strings.Builder
+	for i, choice := range response.Choices {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		fmt.Fprintf(&sb, "===== Alternativa %d/%d =====\n\n", i+1, len(response.Choices))
+		sb.WriteString(prefill + choice.Message.Content)
+	}
+	return sb.String()
+}
+
+// Instrucción que se añade al prompt con --code-only
+const codeOnlyInstruction = "\n\nResponde únicamente con el código, en un bloque de código Markdown, sin explicaciones."
+
+// extractCodeBlocks devuelve el contenido de los bloques de código (``` o
+// ~~~) del texto; un bloque sin cerrar llega hasta el final
+func extractCodeBlocks(text string) []string {
+	var blocks []string
+	var current []string
+	fence := ""
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" {
+			for _, f := range []string{"```", "~~~"} {
+				if strings.HasPrefix(trimmed, f) {
+					fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, f[:1]))]
+					current = []string{}
+					break
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			blocks = append(blocks, strings.Join(current, "\n"))
+			fence = ""
+			continue
+		}
+		current = append(current, line)
+	}
+	if fence != "" && len(current) > 0 {
+		blocks = append(blocks, strings.Join(current, "\n"))
+	}
+	return blocks
+}
+
+// codeOnlyText reduce la respuesta a su código según --code-only: el primer
+// bloque o todos los bloques unidos. Si no hay bloques se devuelve el texto
+// completo, que se supone que ya es código.
+func codeOnlyText(text string) string {
+	blocks := extractCodeBlocks(text)
+	if len(blocks) == 0 {
+		statusf("Advertencia: la respuesta no contiene bloques de código; se muestra completa\n")
+		return strings.TrimSpace(text)
+	}
+	if codeOnly.all {
+		return strings.Join(blocks, "\n\n")
+	}
+	return blocks[0]
+}
+
+// codeOnlyFlag permite usar --code-only (primer bloque) o --code-only=all
+type codeOnlyFlag struct {
+	enabled bool
+	all     bool
+}
+
+func (f *codeOnlyFlag) String() string {
+	if f == nil || !f.enabled {
+		return ""
+	}
+	if f.all {
+		return "all"
+	}
+	return "first"
+}
+
+func (f *codeOnlyFlag) Set(value string) error {
+	switch value {
+	case "true", "first":
+		f.enabled, f.all = true, false
+	case "all":
+		f.enabled, f.all = true, true
+	case "false":
+		f.enabled, f.all = false, false
+	default:
+		return fmt.Errorf("debe ser first o all")
+	}
+	return nil
+}
+
+func (f *codeOnlyFlag) IsBoolFlag() bool { return true }
+
+// parseOutputTemplate compila la plantilla de --format-template, que se
+// evalúa sobre el Envelope de cada respuesta
+func parseOutputTemplate(text string) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"trim":  strings.TrimSpace,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}
+	t, err := template.New("output").Funcs(funcs).Parse(unescapeFlag(text))
+	if err != nil {
+		return nil, fmt.Errorf("plantilla de salida inválida: %v", err)
+	}
+	return t, nil
+}
+
+// logprobsFlag permite usar --logprobs solo o --logprobs=N
+type logprobsFlag struct {
+	enabled bool
+	top     int
+}
+
+func (f *logprobsFlag) String() string {
+	if f == nil || !f.enabled {
+		return ""
+	}
+	return strconv.Itoa(f.top)
+}
+
+func (f *logprobsFlag) Set(value string) error {
+	switch value {
+	case "true":
+		f.enabled, f.top = true, 0
+		return nil
+	case "false":
+		f.enabled, f.top = false, 0
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 20 {
+		return fmt.Errorf("debe ser un número entre 0 y 20")
+	}
+	f.enabled, f.top = true, n
+	return nil
+}
+
+func (f *logprobsFlag) IsBoolFlag() bool { return true }
+
+// writeOutputFile escribe la respuesta de forma atómica (archivo temporal +
+// rename) para que una ejecución interrumpida no destruya el archivo
+// existente. Con appendMode añade al final; con backup guarda la versión
+// anterior en <archivo>.bak.
+func writeOutputFile(path string, data []byte, appendMode, backup bool) error {
+	mode := os.FileMode(0644)
+	existing, err := os.ReadFile(path)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if exists {
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if backup {
+			if err := os.WriteFile(path+".bak", existing, mode); err != nil {
+				return fmt.Errorf("no se pudo crear la copia de seguridad: %v", err)
+			}
+			logger.Printf("Copia de seguridad guardada en %s.bak\n", path)
+		}
+		if appendMode {
+			if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+				existing = append(existing, '\n')
+			}
+			data = append(existing, data...)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// requestParams son los parámetros de --param, que se añaden al cuerpo de
+// cada solicitud de chat; sustituyen a los campos de deepcli del mismo nombre
+var requestParams = paramsFlag{}
+
+// paramsFlag es un flag repetible clave=valor. El valor se toma como JSON si
+// es válido (números, booleanos, null, objetos, listas) y si no como texto.
+type paramsFlag map[string]json.RawMessage
+
+func (p *paramsFlag) String() string {
+	var keys []string
+	for k := range *p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, k+"="+string((*p)[k]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (p *paramsFlag) Set(v string) error {
+	key, value, ok := strings.Cut(v, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("se espera clave=valor, no %q", v)
+	}
+	if json.Valid([]byte(value)) {
+		(*p)[key] = json.RawMessage(value)
+		return nil
+	}
+	quoted, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	(*p)[key] = quoted
+	return nil
+}
+
+// MarshalJSON añade al cuerpo los parámetros de --param
+func (b RequestBody) MarshalJSON() ([]byte, error) {
+	type plain RequestBody
+	data, err := json.Marshal(plain(b))
+	if err != nil || len(requestParams) == 0 {
+		return data, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range requestParams {
+		fields[k] = v
+	}
+	return json.Marshal(fields)
+}
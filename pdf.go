@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Selector de --pages y modo de --pdf-layout para los PDF
+var (
+	pdfPages  string
+	pdfLayout bool
+)
+
+// isPDF indica si la entrada es un PDF, por su cabecera o su extensión
+func isPDF(name string, data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%PDF-")) || strings.EqualFold(filepath.Ext(name), ".pdf")
+}
+
+// extractPDF extrae localmente el texto del PDF con pdftotext (poppler-utils),
+// página a página y solo las seleccionadas con --pages. Con --pdf-layout se
+// conserva la disposición física, lo que mantiene alineadas las columnas de
+// las tablas.
+func extractPDF(name string, data []byte) (string, error) {
+	if _, err := exec.LookPath("pdftotext"); err != nil {
+		return "", fmt.Errorf("%s: se necesita pdftotext (poppler-utils) para leer archivos PDF", name)
+	}
+	var ranges [][2]int
+	if pdfPages != "" {
+		var err error
+		if ranges, err = parseRanges("--pages", pdfPages); err != nil {
+			return "", err
+		}
+	}
+
+	// pdftotext necesita un archivo para leer la tabla de referencias cruzadas
+	tmp, err := os.CreateTemp("", "deepcli-*.pdf")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	tmp.Close()
+
+	// Se extrae solo el intervalo que cubre los rangos pedidos
+	args := []string{"-enc", "UTF-8"}
+	if pdfLayout {
+		args = append(args, "-layout")
+	}
+	first := 1
+	if ranges != nil {
+		last := ranges[0][1]
+		first = ranges[0][0]
+		for _, r := range ranges[1:] {
+			first, last = min(first, r[0]), max(last, r[1])
+		}
+		args = append(args, "-f", strconv.Itoa(first))
+		if last != int(^uint(0)>>1) {
+			args = append(args, "-l", strconv.Itoa(last))
+		}
+	}
+	args = append(args, tmp.Name(), "-")
+	cmd := exec.Command("pdftotext", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: pdftotext falló: %v: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	// pdftotext termina cada página con un salto de página (\f)
+	pages := strings.Split(strings.TrimSuffix(string(out), "\f"), "\f")
+	var sb strings.Builder
+	sent, empty := 0, 0
+	for i, page := range pages {
+		n := first + i
+		if ranges != nil && !inRanges(n, ranges) {
+			continue
+		}
+		lines := strings.Split(page, "\n")
+		for j, line := range lines {
+			lines[j] = strings.TrimRight(line, " \t")
+		}
+		text := strings.Trim(strings.Join(lines, "\n"), "\n")
+		if text == "" {
+			empty++
+			continue
+		}
+		fmt.Fprintf(&sb, "\n--- Página %d ---\n%s\n", n, text)
+		sent++
+	}
+	if sent == 0 {
+		return "", fmt.Errorf("%s: el PDF no contiene texto extraíble en las páginas seleccionadas (¿es un documento escaneado?)", name)
+	}
+
+	header := fmt.Sprintf("Documento PDF %s (%d páginas con texto", name, sent)
+	if pdfPages != "" {
+		header += ", páginas " + pdfPages
+	}
+	if empty > 0 {
+		header += fmt.Sprintf(", %d sin texto omitidas", empty)
+	}
+	header += ")\n"
+	logger.Printf("%s: texto extraído de %d páginas del PDF (~%d tokens)\n", name, sent, estimateTokens(sb.String()))
+	return header + sb.String(), nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy restringe lo que se puede enviar a la API. Se lee de policy.yaml
+// en el directorio de configuración, o de la ruta de policy en config.yaml.
+type Policy struct {
+	// Rutas de archivo (globs con **; sin / se comparan con el nombre)
+	AllowPaths []string `yaml:"allow_paths"`
+	DenyPaths  []string `yaml:"deny_paths"`
+
+	// Extensiones de archivo (.pem, .key); las de deny se comprueban antes
+	AllowTypes []string `yaml:"allow_types"`
+	DenyTypes  []string `yaml:"deny_types"`
+
+	// Tamaños máximos de cada archivo y del cuerpo de cada solicitud
+	// (512KB, 2MB o bytes)
+	MaxFileSize    string `yaml:"max_file_size"`
+	MaxRequestSize string `yaml:"max_request_size"`
+
+	// Hosts de la API que pueden recibir solicitudes (admite *.dominio)
+	AllowedHosts []string `yaml:"allowed_hosts"`
+
+	maxFile, maxRequest int64
+}
+
+var (
+	policyMu     sync.Mutex
+	policy       *Policy
+	policyErr    error
+	policyLoaded bool
+
+	// policyOverride es la justificación de --override-policy; con ella las
+	// infracciones se registran en lugar de bloquear
+	policyOverride string
+
+	// Infracciones ya registradas, para no repetirlas en los reintentos
+	policyLogged = map[string]bool{}
+)
+
+func policyFile() string {
+	if cfg, err := loadConfig(); err == nil && cfg.Policy != "" {
+		return expandHome(cfg.Policy)
+	}
+	return filepath.Join(configDir(), "policy.yaml")
+}
+
+func policyOverridesFile() string {
+	return filepath.Join(dataDir(), "policy-overrides.jsonl")
+}
+
+// loadPolicy lee la política una sola vez; sin archivo no hay restricciones.
+// Un error de lectura se devuelve en todas las llamadas, para que una
+// política inválida no deje de aplicarse tras el primer intento.
+func loadPolicy() (*Policy, error) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	if !policyLoaded {
+		policy, policyErr = readPolicy()
+		policyLoaded = true
+	}
+	return policy, policyErr
+}
+
+func readPolicy() (*Policy, error) {
+	data, err := os.ReadFile(policyFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer la política %s: %v", policyFile(), err)
+	}
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("política %s inválida: %v", policyFile(), err)
+	}
+	if p.maxFile, err = parseSize(p.MaxFileSize); err != nil {
+		return nil, fmt.Errorf("política %s: max_file_size: %v", policyFile(), err)
+	}
+	if p.maxRequest, err = parseSize(p.MaxRequestSize); err != nil {
+		return nil, fmt.Errorf("política %s: max_request_size: %v", policyFile(), err)
+	}
+	logger.Printf("Política cargada de %s\n", policyFile())
+	return &p, nil
+}
+
+// parseSize interpreta un tamaño como 512KB, 2MB o un número de bytes; ""
+// es sin límite
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("tamaño no válido %q", s)
+	}
+	return n * mult, nil
+}
+
+// expandHome sustituye ~ por el directorio del usuario
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+// globMatch compara una ruta con un glob en el que ** abarca directorios;
+// los globs sin / se comparan solo con el nombre del archivo
+func globMatch(pattern, file string) bool {
+	pattern = filepath.ToSlash(expandHome(pattern))
+	file = filepath.ToSlash(file)
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**/") {
+				re.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pattern[i:], "**") {
+				re.WriteString(".*")
+				i++
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	ok, _ := regexp.MatchString(re.String(), file)
+	if !ok && !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "**") {
+		// Un glob relativo puede coincidir con el final de la ruta
+		ok, _ = regexp.MatchString("(^|/)"+strings.TrimPrefix(re.String(), "^"), file)
+	}
+	return ok
+}
+
+// checkFile aplica la política a un archivo que se va a enviar
+func (p *Policy) checkFile(name string, size int64) []string {
+	return append(p.checkPath(name), p.checkSize(size)...)
+}
+
+// checkPath aplica las reglas de ruta y tipo, que no dependen del contenido.
+// Un enlace simbólico se evalúa también por el archivo al que apunta, que
+// es el que se lee.
+func (p *Policy) checkPath(name string) []string {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		abs = name
+	}
+	target := abs
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		target = real
+	}
+	return p.checkPaths(abs, target)
+}
+
+// checkPaths aplica las reglas a la ruta indicada y a la del archivo que se
+// lee: las de exclusión a las dos y las de inclusión a la del archivo
+func (p *Policy) checkPaths(name, target string) []string {
+	paths := []string{name}
+	if target != name {
+		paths = append(paths, target)
+	}
+	var violations []string
+	for _, g := range p.DenyPaths {
+		for _, path := range paths {
+			if globMatch(g, path) {
+				violations = append(violations, fmt.Sprintf("la ruta %s coincide con deny_paths %q", path, g))
+				break
+			}
+		}
+	}
+	if len(p.AllowPaths) > 0 {
+		allowed := false
+		for _, g := range p.AllowPaths {
+			allowed = allowed || globMatch(g, target)
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("la ruta %s no está en allow_paths", target))
+		}
+	}
+	for _, t := range p.DenyTypes {
+		for _, path := range paths {
+			if ext := strings.ToLower(filepath.Ext(path)); strings.EqualFold(normalizeExt(t), ext) {
+				violations = append(violations, fmt.Sprintf("el tipo %s está en deny_types", ext))
+				break
+			}
+		}
+	}
+	if len(p.AllowTypes) > 0 {
+		ext := strings.ToLower(filepath.Ext(target))
+		allowed := false
+		for _, t := range p.AllowTypes {
+			allowed = allowed || strings.EqualFold(normalizeExt(t), ext)
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("el tipo %q no está en allow_types", ext))
+		}
+	}
+	return violations
+}
+
+// checkSize aplica max_file_size
+func (p *Policy) checkSize(size int64) []string {
+	if p.maxFile > 0 && size > p.maxFile {
+		return []string{fmt.Sprintf("ocupa %d bytes y max_file_size es %s", size, p.MaxFileSize)}
+	}
+	return nil
+}
+
+func normalizeExt(t string) string {
+	if !strings.HasPrefix(t, ".") {
+		return "." + t
+	}
+	return t
+}
+
+// checkRequest aplica la política a una solicitud a la API
+func (p *Policy) checkRequest(endpoint string, size int) []string {
+	var violations []string
+	if len(p.AllowedHosts) > 0 {
+		host := endpoint
+		if u, err := url.Parse(endpoint); err == nil {
+			host = u.Hostname()
+		}
+		allowed := false
+		for _, h := range p.AllowedHosts {
+			ok, _ := path.Match(strings.ToLower(h), strings.ToLower(host))
+			allowed = allowed || ok
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("el host %s no está en allowed_hosts", host))
+		}
+	}
+	if p.maxRequest > 0 && int64(size) > p.maxRequest {
+		violations = append(violations, fmt.Sprintf("la solicitud ocupa %d bytes y max_request_size es %s", size, p.MaxRequestSize))
+	}
+	return violations
+}
+
+// enforcePolicy bloquea las infracciones o, con --override-policy, las
+// registra junto con la justificación y deja continuar
+func enforcePolicy(subject string, violations []string) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	summary := subject + ": " + strings.Join(violations, "; ")
+	if policyOverride == "" {
+		return fmt.Errorf("bloqueado por la política (%s): %s. Para continuar, indica el motivo con --override-policy \"<justificación>\"", policyFile(), summary)
+	}
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	if policyLogged[summary] {
+		return nil
+	}
+	policyLogged[summary] = true
+	statusf("Advertencia: se omite la política por --override-policy: %s\n", summary)
+
+	entry := struct {
+		Time          time.Time `json:"time"`
+		User          string    `json:"user"`
+		Dir           string    `json:"dir"`
+		Args          []string  `json:"args"`
+		Subject       string    `json:"subject"`
+		Violations    []string  `json:"violations"`
+		Justification string    `json:"justification"`
+	}{Time: time.Now(), User: currentUser(), Args: os.Args[1:], Subject: subject, Violations: violations, Justification: policyOverride}
+	entry.Dir, _ = os.Getwd()
+	data, _ := json.Marshal(entry)
+	if err := os.MkdirAll(dataDir(), 0700); err != nil {
+		return fmt.Errorf("no se pudo registrar la excepción a la política: %v", err)
+	}
+	f, err := os.OpenFile(policyOverridesFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("no se pudo registrar la excepción a la política: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("no se pudo registrar la excepción a la política: %v", err)
+	}
+	return nil
+}
+
+// currentUser devuelve el nombre del usuario para los registros
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// readContextFile lee un archivo que se va a enviar como contexto,
+// aplicando antes la política
+func readContextFile(name string) ([]byte, error) {
+	if host, path, ok := parseSSHPath(name); ok {
+		return readSSHFile(name, host, path)
+	}
+	if err := checkPolicyFile(name); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(name)
+}
+
+// checkPolicyFile aplica la política a un archivo antes de leerlo
+func checkPolicyFile(name string) error {
+	p, err := loadPolicy()
+	if err != nil || p == nil {
+		return err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	return enforcePolicy(name, p.checkFile(name, info.Size()))
+}
+
+// checkPolicyRequest aplica la política al cuerpo ya serializado de una
+// solicitud a la API
+func checkPolicyRequest(endpoint string, body []byte) error {
+	p, err := loadPolicy()
+	if err != nil || p == nil {
+		return err
+	}
+	return enforcePolicy("solicitud a "+endpoint, p.checkRequest(endpoint, len(body)))
+}
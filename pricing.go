@@ -0,0 +1,78 @@
+package main
+
+// Price es el precio en USD por millón de tokens; CachedInput se aplica a
+// los tokens de entrada servidos desde la caché de contexto
+type Price struct {
+	Input       float64 `yaml:"input" json:"input"`
+	CachedInput float64 `yaml:"cached_input" json:"cached_input,omitempty"`
+	Output      float64 `yaml:"output" json:"output"`
+}
+
+// defaultPricing son precios de referencia por proveedor y modelo (nombre
+// del modelo en el proveedor); pueden cambiar, por lo que se pueden
+// sobrescribir en la sección pricing de config.yaml
+var defaultPricing = map[string]map[string]Price{
+	"deepseek": {
+		"deepseek-chat":     {Input: 0.27, CachedInput: 0.07, Output: 1.10},
+		"deepseek-reasoner": {Input: 0.55, CachedInput: 0.14, Output: 2.19},
+	},
+	"openrouter": {
+		"deepseek/deepseek-chat": {Input: 0.38, Output: 0.89},
+		"deepseek/deepseek-r1":   {Input: 0.45, Output: 2.15},
+	},
+	"together": {
+		"deepseek-ai/DeepSeek-V3": {Input: 1.25, Output: 1.25},
+		"deepseek-ai/DeepSeek-R1": {Input: 3.00, Output: 7.00},
+	},
+	"groq": {
+		"deepseek-r1-distill-llama-70b": {Input: 0.75, Output: 0.99},
+	},
+}
+
+// lookupPrice busca el precio del modelo para el proveedor; la
+// configuración tiene prioridad sobre la tabla incluida. Los proveedores
+// propios de config.yaml heredan los precios de su preset.
+func lookupPrice(providerName, modelName string) (Price, bool) {
+	if providerName == "" {
+		providerName = "deepseek"
+	}
+	candidates := []string{providerName}
+	if cfg, err := loadConfig(); err == nil {
+		if prices, ok := cfg.Pricing[providerName]; ok {
+			if p, ok := prices[modelName]; ok {
+				return p, true
+			}
+		}
+		if pc, ok := cfg.Providers[providerName]; ok && pc.Preset != "" {
+			if prices, ok := cfg.Pricing[pc.Preset]; ok {
+				if p, ok := prices[modelName]; ok {
+					return p, true
+				}
+			}
+			candidates = append(candidates, pc.Preset)
+		}
+	}
+	for _, name := range candidates {
+		if p, ok := defaultPricing[name][modelName]; ok {
+			return p, true
+		}
+	}
+	return Price{}, false
+}
+
+// estimateCost calcula el coste en USD del uso de tokens; devuelve false si
+// no se conoce el precio del modelo
+func estimateCost(providerName, modelName string, u Usage) (float64, bool) {
+	p, ok := lookupPrice(providerName, modelName)
+	if !ok {
+		return 0, false
+	}
+	cached := u.PromptCacheHitTokens
+	if p.CachedInput == 0 {
+		p.CachedInput = p.Input
+	}
+	cost := float64(u.PromptTokens-cached)*p.Input +
+		float64(cached)*p.CachedInput +
+		float64(u.CompletionTokens)*p.Output
+	return cost / 1e6, true
+}
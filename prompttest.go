@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PromptSuite es un archivo de casos de prueba de prompts
+type PromptSuite struct {
+	Name  string       `yaml:"name"`
+	Cases []PromptCase `yaml:"cases"`
+}
+
+type PromptCase struct {
+	Name        string      `yaml:"name"`
+	Prompt      string      `yaml:"prompt"`
+	Input       string      `yaml:"input"`
+	InputFile   string      `yaml:"input_file"`
+	Temperature *float64    `yaml:"temperature"`
+	Assertions  []Assertion `yaml:"assert"`
+}
+
+// Assertion es una comprobación sobre la respuesta; solo se usa uno de los
+// campos en cada aserción
+type Assertion struct {
+	Contains    string                 `yaml:"contains"`
+	NotContains string                 `yaml:"not_contains"`
+	Regex       string                 `yaml:"regex"`
+	JSONSchema  map[string]interface{} `yaml:"json_schema"`
+	Judge       *JudgeAssertion        `yaml:"judge"`
+}
+
+// JudgeAssertion exige una puntuación mínima del modelo evaluador
+type JudgeAssertion struct {
+	Rubric   string      `yaml:"rubric"`
+	Criteria []Criterion `yaml:"criteria"`
+	MinScore float64     `yaml:"min_score"`
+}
+
+type CaseResult struct {
+	Name     string
+	Output   string
+	Failures []string
+	Err      error
+	Duration time.Duration
+}
+
+func (r CaseResult) Passed() bool {
+	return r.Err == nil && len(r.Failures) == 0
+}
+
+func loadPromptSuite(path string) (*PromptSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer el archivo de pruebas: %v", err)
+	}
+	var suite PromptSuite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("archivo de pruebas inválido: %v", err)
+	}
+	if len(suite.Cases) == 0 {
+		return nil, fmt.Errorf("el archivo de pruebas no define casos")
+	}
+	if suite.Name == "" {
+		suite.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for i := range suite.Cases {
+		if suite.Cases[i].Name == "" {
+			suite.Cases[i].Name = fmt.Sprintf("caso-%d", i+1)
+		}
+		if suite.Cases[i].Prompt == "" {
+			return nil, fmt.Errorf("el caso %q no tiene prompt", suite.Cases[i].Name)
+		}
+	}
+	return &suite, nil
+}
+
+// runCase ejecuta un caso y evalúa sus aserciones
+func runCase(c PromptCase, baseDir string) (result CaseResult) {
+	result.Name = c.Name
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	input := c.Input
+	if c.InputFile != "" {
+		data, err := readContextFile(filepath.Join(baseDir, c.InputFile))
+		if err != nil {
+			result.Err = fmt.Errorf("no se pudo leer input_file: %v", err)
+			return result
+		}
+		input += string(data)
+	}
+
+	requestBody := newRequestBody(buildMessages(c.Prompt, input, ""))
+	if c.Temperature != nil {
+		requestBody.Temperature = *c.Temperature
+	}
+	output, _, err := complete(requestBody)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Output = output
+
+	for _, a := range c.Assertions {
+		if msg := a.check(output, baseDir); msg != "" {
+			result.Failures = append(result.Failures, msg)
+		}
+	}
+	return result
+}
+
+// check devuelve una descripción del fallo, o "" si la aserción se cumple
+func (a Assertion) check(output, baseDir string) string {
+	switch {
+	case a.Contains != "":
+		if !strings.Contains(output, a.Contains) {
+			return fmt.Sprintf("no contiene %q", a.Contains)
+		}
+	case a.NotContains != "":
+		if strings.Contains(output, a.NotContains) {
+			return fmt.Sprintf("contiene %q", a.NotContains)
+		}
+	case a.Regex != "":
+		re, err := regexp.Compile(a.Regex)
+		if err != nil {
+			return fmt.Sprintf("regex inválida %q: %v", a.Regex, err)
+		}
+		if !re.MatchString(output) {
+			return fmt.Sprintf("no coincide con la regex %q", a.Regex)
+		}
+	case a.JSONSchema != nil:
+		var doc interface{}
+		if err := json.Unmarshal([]byte(extractJSON(output)), &doc); err != nil {
+			return fmt.Sprintf("la respuesta no es JSON válido: %v", err)
+		}
+		if err := validateSchema(a.JSONSchema, doc, "$"); err != nil {
+			return fmt.Sprintf("no cumple el esquema JSON: %v", err)
+		}
+	case a.Judge != nil:
+		rubric := &Rubric{Scale: 10, Criteria: a.Judge.Criteria}
+		if a.Judge.Rubric != "" {
+			r, err := loadRubric(filepath.Join(baseDir, a.Judge.Rubric))
+			if err != nil {
+				return err.Error()
+			}
+			rubric = r
+		}
+		if len(rubric.Criteria) == 0 {
+			return "la aserción judge no define rúbrica ni criterios"
+		}
+		for i := range rubric.Criteria {
+			if rubric.Criteria[i].Weight <= 0 {
+				rubric.Criteria[i].Weight = 1
+			}
+		}
+		_, total, err := rubric.judge(output)
+		if err != nil {
+			return fmt.Sprintf("error del evaluador: %v", err)
+		}
+		if total < a.Judge.MinScore {
+			return fmt.Sprintf("puntuación del evaluador %.2f < %.2f", total, a.Judge.MinScore)
+		}
+	default:
+		return "aserción vacía o desconocida"
+	}
+	return ""
+}
+
+// extractJSON quita las vallas de Markdown que rodean a un bloque JSON
+func extractJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i+1:]
+		}
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	}
+	return s
+}
+
+// validateSchema valida un documento contra un subconjunto de JSON Schema:
+// type, properties, required, additionalProperties, items y enum
+func validateSchema(schema map[string]interface{}, doc interface{}, path string) error {
+	if t, ok := schema["type"].(string); ok && !matchesType(t, doc) {
+		return fmt.Errorf("%s: se esperaba tipo %s", path, t)
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, v := range enum {
+			if fmt.Sprint(v) == fmt.Sprint(doc) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: valor %v fuera de enum", path, doc)
+		}
+	}
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if _, ok := v[fmt.Sprint(r)]; !ok {
+					return fmt.Errorf("%s: falta la propiedad requerida %q", path, r)
+				}
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for key, value := range v {
+			sub, ok := props[key].(map[string]interface{})
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("%s: propiedad no permitida %q", path, key)
+				}
+				continue
+			}
+			if err := validateSchema(sub, value, path+"."+key); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(t string, v interface{}) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true
+}
+
+// Estructuras del informe JUnit XML
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     float64     `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func writeJUnit(path string, suite *PromptSuite, results []CaseResult, elapsed time.Duration) error {
+	js := junitSuite{Name: suite.Name, Tests: len(results), Time: elapsed.Seconds()}
+	for _, r := range results {
+		jc := junitCase{Name: r.Name, ClassName: suite.Name, Time: r.Duration.Seconds(), SystemOut: r.Output}
+		if r.Err != nil {
+			js.Errors++
+			jc.Error = &junitMessage{Message: r.Err.Error()}
+		} else if len(r.Failures) > 0 {
+			js.Failures++
+			jc.Failure = &junitMessage{Message: r.Failures[0], Body: strings.Join(r.Failures, "\n")}
+		}
+		js.Cases = append(js.Cases, jc)
+	}
+
+	data, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{js}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), data...), 0644)
+}
+
+func runPromptTests(args []string) {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	concurrency := fs.Int("c", 4, "Casos ejecutados en paralelo")
+	fs.IntVar(concurrency, "concurrency", 4, "Casos ejecutados en paralelo")
+	junitFile := fs.String("junit", "", "Escribir el informe en formato JUnit XML")
+	addModelFlags(fs, 0.0)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s test <pruebas.yaml> [opciones]
+
+Ejecuta casos de prueba de prompts y comprueba sus aserciones; termina con
+código distinto de cero si alguno falla, para usarlo en CI.
+
+Formato del archivo:
+  name: prompts-api
+  cases:
+    - name: invertir-array
+      prompt: "Cómo invertir un array en Python"
+      input: "..."              # contexto opcional (o input_file: ruta)
+      temperature: 0            # opcional
+      assert:
+        - contains: "reverse"
+        - not_contains: "lo siento"
+        - regex: "\\[::-1\\]"
+        - json_schema: {type: object, required: [nombre]}
+        - judge: {rubric: rubrica.yaml, min_score: 7}
+
+Opciones:
+  -c, --concurrency <n>   Casos ejecutados en paralelo (default: 4)
+  --junit <archivo>       Escribir el informe en JUnit XML
+  -t, --temperature       Temperatura por defecto (default: 0.0)
+  -v, --verbose           Mostrar logs detallados
+`, os.Args[0])
+	}
+
+	// Permitir el archivo antes o después de las opciones
+	var file string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		file, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if file == "" && fs.NArg() > 0 {
+		file = fs.Arg(0)
+	}
+	if file == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+
+	suite, err := loadPromptSuite(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupSubcommand()
+
+	baseDir := filepath.Dir(file)
+	results := make([]CaseResult, len(suite.Cases))
+	sem := make(chan struct{}, *concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i, c := range suite.Cases {
+		wg.Add(1)
+		go func(i int, c PromptCase) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			logger.Printf("Ejecutando caso %s...\n", c.Name)
+			results[i] = runCase(c, baseDir)
+		}(i, c)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	passed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("ERROR %s (%s): %v\n", r.Name, r.Duration.Round(time.Millisecond), r.Err)
+		case len(r.Failures) > 0:
+			fmt.Printf("FAIL  %s (%s)\n", r.Name, r.Duration.Round(time.Millisecond))
+			for _, f := range r.Failures {
+				fmt.Printf("      - %s\n", f)
+			}
+		default:
+			passed++
+			fmt.Printf("PASS  %s (%s)\n", r.Name, r.Duration.Round(time.Millisecond))
+		}
+	}
+	fmt.Printf("\n%d/%d casos correctos en %s\n", passed, len(results), elapsed.Round(time.Millisecond))
+
+	if *junitFile != "" {
+		if err := writeJUnit(*junitFile, suite, results, elapsed); err != nil {
+			fmt.Fprintf(os.Stderr, "Error al escribir el informe JUnit: %v\n", err)
+			os.Exit(1)
+		}
+		statusf("Informe JUnit escrito en %s\n", *junitFile)
+	}
+
+	if passed != len(results) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ProviderPreset describe un proveedor compatible con la API de OpenAI: URL
+// base, cabecera de autenticación y nombres de modelo equivalentes
+type ProviderPreset struct {
+	Description string
+	// BaseURL admite variables de entorno (${VAR}) y {model}, que se
+	// sustituye por el nombre del modelo ya traducido
+	BaseURL    string
+	Query      string
+	KeyEnv     string
+	AuthHeader string
+	AuthPrefix string
+	// AuthType es "" (cabecera AuthHeader), "sigv4" o "none"
+	AuthType   string
+	Region     string
+	Service    string
+	KeyCommand string
+	// Model fija el modelo del proveedor sea cual sea el solicitado
+	Model  string
+	Models map[string]string
+	// Vision indica que los modelos del proveedor aceptan imágenes
+	Vision bool
+	// Headers son cabeceras adicionales de cada solicitud (gateways)
+	Headers map[string]string
+}
+
+var providerPresets = map[string]ProviderPreset{
+	"deepseek": {
+		Description: "API oficial de DeepSeek",
+		BaseURL:     defaultBaseURL,
+		KeyEnv:      "DEEPSEEK_API_KEY",
+		AuthHeader:  "Authorization",
+		AuthPrefix:  "Bearer ",
+	},
+	"openrouter": {
+		Description: "OpenRouter",
+		BaseURL:     "https://openrouter.ai/api/v1",
+		KeyEnv:      "OPENROUTER_API_KEY",
+		AuthHeader:  "Authorization",
+		AuthPrefix:  "Bearer ",
+		Models: map[string]string{
+			"deepseek-chat":     "deepseek/deepseek-chat",
+			"deepseek-reasoner": "deepseek/deepseek-r1",
+		},
+	},
+	"together": {
+		Description: "Together AI",
+		BaseURL:     "https://api.together.xyz/v1",
+		KeyEnv:      "TOGETHER_API_KEY",
+		AuthHeader:  "Authorization",
+		AuthPrefix:  "Bearer ",
+		Models: map[string]string{
+			"deepseek-chat":     "deepseek-ai/DeepSeek-V3",
+			"deepseek-reasoner": "deepseek-ai/DeepSeek-R1",
+		},
+	},
+	"groq": {
+		Description: "Groq",
+		BaseURL:     "https://api.groq.com/openai/v1",
+		KeyEnv:      "GROQ_API_KEY",
+		AuthHeader:  "Authorization",
+		AuthPrefix:  "Bearer ",
+		Models: map[string]string{
+			"deepseek-chat":     "deepseek-r1-distill-llama-70b",
+			"deepseek-reasoner": "deepseek-r1-distill-llama-70b",
+		},
+	},
+	"azure": {
+		Description: "Azure OpenAI (--model es el nombre del despliegue)",
+		BaseURL:     "${AZURE_OPENAI_ENDPOINT}/openai/deployments/{model}",
+		Query:       "api-version=2024-10-21",
+		KeyEnv:      "AZURE_OPENAI_API_KEY",
+		AuthHeader:  "api-key",
+	},
+}
+
+// provider es el preset activo; nil equivale a la API de DeepSeek
+var (
+	providerName   string
+	provider       *ProviderPreset
+	activeProvider string
+
+	// Cadena de proveedores alternativos (failover en config.yaml)
+	failoverChain []string
+	failoverIndex int
+)
+
+// resolveProvider busca un proveedor por nombre: primero los definidos en
+// config.yaml y después los presets incluidos
+func resolveProvider(name string) (*ProviderPreset, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if pc, ok := cfg.Providers[name]; ok {
+		preset := ProviderPreset{AuthHeader: "Authorization", AuthPrefix: "Bearer "}
+		if pc.Preset != "" {
+			base, ok := providerPresets[strings.ToLower(pc.Preset)]
+			if !ok {
+				return nil, fmt.Errorf("el proveedor %s usa un preset desconocido %q", name, pc.Preset)
+			}
+			preset = base
+		} else if pc.BaseURL == "" {
+			return nil, fmt.Errorf("el proveedor %s necesita preset o base_url", name)
+		}
+		if pc.BaseURL != "" {
+			preset.BaseURL = pc.BaseURL
+		}
+		if pc.KeyCommand != "" {
+			preset.KeyCommand = pc.KeyCommand
+		}
+		if pc.APIKeyEnv != "" {
+			preset.KeyEnv = pc.APIKeyEnv
+		} else if pc.Preset == "" {
+			preset.KeyEnv = ""
+		}
+		if pc.AuthHeader != "" {
+			preset.AuthHeader = pc.AuthHeader
+		}
+		if pc.AuthPrefix != nil {
+			preset.AuthPrefix = *pc.AuthPrefix
+		}
+		if pc.Query != "" {
+			preset.Query = pc.Query
+		}
+		if pc.Model != "" {
+			preset.Model = pc.Model
+		}
+		if pc.Models != nil {
+			preset.Models = pc.Models
+		}
+		if pc.Vision {
+			preset.Vision = true
+		}
+		if pc.Headers != nil {
+			preset.Headers = providerHeaders(pc.Headers)
+		}
+		if pc.Auth != nil {
+			if err := applyAuthConfig(name, &preset, pc.Auth); err != nil {
+				return nil, err
+			}
+		}
+		return &preset, nil
+	}
+
+	preset, ok := providerPresets[strings.ToLower(name)]
+	if ok && preset.KeyEnv == "DEEPSEEK_API_KEY" {
+		preset.KeyCommand = cfg.KeyCommand
+	}
+	if !ok {
+		names := providerPresetNames()
+		for n := range cfg.Providers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("proveedor desconocido %q (disponibles: %s)", name, strings.Join(names, ", "))
+	}
+	return &preset, nil
+}
+
+// activateProvider configura la URL base y la API key del proveedor
+func activateProvider(name string) error {
+	preset, err := resolveProvider(name)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(preset.BaseURL, "${") {
+		var missing []string
+		os.Expand(preset.BaseURL, func(v string) string {
+			if os.Getenv(v) == "" {
+				missing = append(missing, v)
+			}
+			return ""
+		})
+		if len(missing) > 0 {
+			return fmt.Errorf("el proveedor %s requiere la variable de entorno %s", name, strings.Join(missing, ", "))
+		}
+	}
+	if preset.AuthType == "sigv4" {
+		if _, _, _, err := awsCredentials(); err != nil {
+			return fmt.Errorf("proveedor %s: %v", name, err)
+		}
+	}
+	key := ""
+	if preset.KeyEnv != "" && preset.AuthType != "sigv4" && preset.AuthType != "none" {
+		if key, err = resolveSecret(preset.KeyEnv, preset.KeyCommand); err != nil {
+			return fmt.Errorf("proveedor %s: %v", name, err)
+		}
+		if key == "" {
+			return fmt.Errorf("no se ha configurado la API key del proveedor %s; establece la variable de entorno %s (o %s_FILE) o añádela al archivo .env", name, preset.KeyEnv, preset.KeyEnv)
+		}
+	}
+
+	provider = preset
+	activeProvider = name
+	apiKey = key
+	apiBaseURL = strings.TrimRight(providerBaseURL(model), "/")
+	logger.Printf("Usando el proveedor %s (%s)\n", name, apiBaseURL)
+	return nil
+}
+
+// nextProvider activa el siguiente proveedor disponible de la cadena de
+// failover; devuelve false si no quedan
+func nextProvider() bool {
+	for failoverIndex+1 < len(failoverChain) {
+		failoverIndex++
+		name := failoverChain[failoverIndex]
+		if err := activateProvider(name); err != nil {
+			statusf("Advertencia: se omite el proveedor %s: %v\n", name, err)
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// shouldFailover indica si un fallo justifica probar otro proveedor: errores
+// de conexión, de autenticación, de cuota o caídas del servicio
+func shouldFailover(statusCode int, err error) bool {
+	var apiErr *APIError
+	if err != nil && !errors.As(err, &apiErr) {
+		return true
+	}
+	switch statusCode {
+	case 401, 402, 403, 429:
+		return true
+	}
+	return statusCode >= 500
+}
+
+// sendWithFailover envía la solicitud y, si el proveedor falla, la repite
+// de forma transparente con el siguiente de la cadena de failover
+func sendWithFailover(beta bool, requestBody RequestBody) ([]byte, int, error) {
+	for {
+		body, statusCode, err := sendRequest(chatEndpoint(beta), requestBody)
+		if len(failoverChain) == 0 || !shouldFailover(statusCode, err) {
+			return body, statusCode, err
+		}
+		failed := activeProvider
+		reason := fmt.Sprintf("código %d", statusCode)
+		if err != nil && statusCode == 0 {
+			reason = err.Error()
+		}
+		if !nextProvider() {
+			return body, statusCode, err
+		}
+		statusf("Advertencia: el proveedor %s falló (%s); reintentando con %s\n", failed, reason, activeProvider)
+	}
+}
+
+func providerPresetNames() []string {
+	names := make([]string, 0, len(providerPresets))
+	for name := range providerPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// providerModel traduce el nombre del modelo al del proveedor activo
+func providerModel(name string) string {
+	if provider != nil {
+		if provider.Model != "" {
+			return provider.Model
+		}
+		if mapped, ok := provider.Models[name]; ok {
+			return mapped
+		}
+	}
+	return name
+}
+
+// providerBaseURL devuelve la URL base del preset para el modelo indicado
+func providerBaseURL(model string) string {
+	base := os.Expand(provider.BaseURL, func(v string) string {
+		return strings.TrimRight(os.Getenv(v), "/")
+	})
+	return strings.ReplaceAll(base, "{model}", providerModel(model))
+}
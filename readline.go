@@ -0,0 +1,588 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Máximo de entradas que se conservan en el historial del REPL
+const maxREPLHistory = 1000
+
+var (
+	errInterrupted = errors.New("interrumpido")
+
+	lineCursorStyle = lipgloss.NewStyle().Reverse(true)
+	lineSearchStyle = lipgloss.NewStyle().Faint(true)
+)
+
+func replHistoryFile() string {
+	return filepath.Join(dataDir(), "repl_history")
+}
+
+// LineReader lee líneas con edición estilo readline: historial persistente,
+// atajos de Emacs o Vi, búsqueda inversa (Ctrl+R) y pegado multilínea. Si
+// stdin no es un terminal lee líneas simples.
+type LineReader struct {
+	history []string
+	vi      bool
+	plain   *bufio.Reader
+}
+
+func newLineReader(vi bool) *LineReader {
+	r := &LineReader{vi: vi || inputrcViMode()}
+	if !isTerminal(os.Stdin) {
+		r.plain = bufio.NewReader(os.Stdin)
+		return r
+	}
+	r.history = loadREPLHistory()
+	return r
+}
+
+// inputrcViMode indica si ~/.inputrc (o $INPUTRC) activa el modo Vi, como
+// en bash y el resto de programas con readline
+func inputrcViMode() bool {
+	path := os.Getenv("INPUTRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return false
+		}
+		path = filepath.Join(home, ".inputrc")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if f := strings.Fields(line); len(f) == 3 && f[0] == "set" && f[1] == "editing-mode" {
+			return f[2] == "vi"
+		}
+	}
+	return false
+}
+
+// loadREPLHistory lee el historial; las entradas multilínea se guardan
+// entrecomilladas en una sola línea
+func loadREPLHistory() []string {
+	data, err := os.ReadFile(replHistoryFile())
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, `"`) {
+			if s, err := strconv.Unquote(line); err == nil {
+				line = s
+			}
+		}
+		history = append(history, line)
+	}
+	return history
+}
+
+// addHistory añade una entrada al historial y la guarda en disco
+func (r *LineReader) addHistory(line string) {
+	if r.plain != nil || strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(r.history); n > 0 && r.history[n-1] == line {
+		return
+	}
+	r.history = append(r.history, line)
+	if len(r.history) > maxREPLHistory {
+		r.history = r.history[len(r.history)-maxREPLHistory:]
+	}
+
+	var sb strings.Builder
+	for _, h := range r.history {
+		if strings.ContainsAny(h, "\n\r") || strings.HasPrefix(h, `"`) {
+			h = strconv.Quote(h)
+		}
+		sb.WriteString(h + "\n")
+	}
+	if err := os.MkdirAll(dataDir(), 0700); err != nil {
+		logger.Printf("No se pudo guardar el historial: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(replHistoryFile(), []byte(sb.String()), 0600); err != nil {
+		logger.Printf("No se pudo guardar el historial: %v\n", err)
+	}
+}
+
+// ReadLine muestra el prompt y devuelve la línea introducida; io.EOF con
+// Ctrl+D en una línea vacía y errInterrupted con Ctrl+C
+func (r *LineReader) ReadLine(prompt string) (string, error) {
+	if r.plain != nil {
+		line, err := r.plain.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	editor := &lineEditor{
+		prompt:  prompt,
+		history: r.history,
+		histIdx: len(r.history),
+		vi:      r.vi,
+	}
+	if _, err := tea.NewProgram(editor).Run(); err != nil {
+		return "", err
+	}
+	switch {
+	case editor.eof:
+		return "", io.EOF
+	case editor.interrupted:
+		return "", errInterrupted
+	}
+	line := string(editor.buf)
+	r.addHistory(line)
+	return line, nil
+}
+
+// lineEditor es el modelo de bubbletea que edita una línea
+type lineEditor struct {
+	prompt  string
+	buf     []rune
+	pos     int
+	width   int
+	history []string
+	histIdx int
+	saved   []rune // línea en edición al recorrer el historial
+
+	vi      bool
+	normal  bool // modo normal de Vi
+	pending rune // operador de Vi pendiente (d, c)
+
+	searching   bool
+	query       []rune
+	searchIdx   int
+	searchSaved []rune
+
+	done        bool
+	eof         bool
+	interrupted bool
+}
+
+func (e *lineEditor) Init() tea.Cmd { return nil }
+
+func (e *lineEditor) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		e.width = msg.Width
+	case tea.KeyMsg:
+		if msg.Paste {
+			e.insert(msg.Runes)
+			return e, nil
+		}
+		if e.searching {
+			return e, e.updateSearch(msg)
+		}
+		if e.vi && e.normal {
+			return e, e.updateNormal(msg)
+		}
+		return e, e.updateInsert(msg)
+	}
+	return e, nil
+}
+
+func (e *lineEditor) finish() tea.Cmd {
+	e.done = true
+	return tea.Quit
+}
+
+// updateInsert aplica los atajos de Emacs (y el modo inserción de Vi)
+func (e *lineEditor) updateInsert(msg tea.KeyMsg) tea.Cmd {
+	// Con Vi, Esc seguido rápidamente de una tecla llega como Alt+tecla
+	if e.vi && msg.Alt && msg.Type == tea.KeyRunes {
+		e.enterNormal()
+		return e.updateNormal(tea.KeyMsg{Type: tea.KeyRunes, Runes: msg.Runes})
+	}
+
+	switch msg.String() {
+	case "enter":
+		return e.finish()
+	case "alt+enter", "ctrl+j":
+		e.insert([]rune{'\n'})
+	case "ctrl+c":
+		e.interrupted = true
+		return e.finish()
+	case "ctrl+d":
+		if len(e.buf) == 0 {
+			e.eof = true
+			return e.finish()
+		}
+		e.deleteRange(e.pos, e.pos+1)
+	case "esc":
+		if e.vi {
+			e.enterNormal()
+		}
+	case "ctrl+a", "home":
+		e.pos = e.lineStart()
+	case "ctrl+e", "end":
+		e.pos = e.lineEnd()
+	case "ctrl+b", "left":
+		e.move(-1)
+	case "ctrl+f", "right":
+		e.move(1)
+	case "alt+b", "ctrl+left", "alt+left":
+		e.pos = e.wordLeft()
+	case "alt+f", "ctrl+right", "alt+right":
+		e.pos = e.wordRight()
+	case "backspace", "ctrl+h":
+		e.deleteRange(e.pos-1, e.pos)
+	case "delete":
+		e.deleteRange(e.pos, e.pos+1)
+	case "ctrl+k":
+		e.deleteRange(e.pos, e.lineEnd())
+	case "ctrl+u":
+		e.deleteRange(e.lineStart(), e.pos)
+	case "ctrl+w", "alt+backspace":
+		e.deleteRange(e.wordLeft(), e.pos)
+	case "alt+d":
+		e.deleteRange(e.pos, e.wordRight())
+	case "ctrl+t":
+		if e.pos > 0 && len(e.buf) > 1 {
+			if e.pos == len(e.buf) {
+				e.pos--
+			}
+			e.buf[e.pos-1], e.buf[e.pos] = e.buf[e.pos], e.buf[e.pos-1]
+			e.pos++
+		}
+	case "ctrl+p", "up":
+		e.historyMove(-1)
+	case "ctrl+n", "down":
+		e.historyMove(1)
+	case "ctrl+r":
+		e.startSearch()
+	case "ctrl+l":
+		return tea.ClearScreen
+	case "tab":
+		e.insert([]rune{'\t'})
+	case " ":
+		e.insert([]rune{' '})
+	default:
+		if msg.Type == tea.KeyRunes && !msg.Alt {
+			e.insert(msg.Runes)
+		}
+	}
+	return nil
+}
+
+func (e *lineEditor) enterNormal() {
+	e.normal = true
+	e.pending = 0
+	if e.pos > 0 && e.pos > e.lineStart() {
+		e.pos--
+	}
+}
+
+// updateNormal aplica las órdenes del modo normal de Vi
+func (e *lineEditor) updateNormal(msg tea.KeyMsg) tea.Cmd {
+	key := msg.String()
+	if op := e.pending; op != 0 {
+		e.pending = 0
+		var start, end int
+		switch key {
+		case string(op):
+			start, end = 0, len(e.buf)
+		case "w", "e":
+			start, end = e.pos, e.wordRight()
+		case "b":
+			start, end = e.wordLeft(), e.pos
+		case "$":
+			start, end = e.pos, e.lineEnd()
+		case "0", "^":
+			start, end = e.lineStart(), e.pos
+		default:
+			return nil
+		}
+		e.deleteRange(start, end)
+		if op == 'c' {
+			e.normal = false
+		} else {
+			e.clampNormal()
+		}
+		return nil
+	}
+
+	switch key {
+	case "enter":
+		return e.finish()
+	case "ctrl+c":
+		e.interrupted = true
+		return e.finish()
+	case "ctrl+d":
+		if len(e.buf) == 0 {
+			e.eof = true
+			return e.finish()
+		}
+	case "ctrl+r", "/", "?":
+		e.startSearch()
+	case "h", "left", "backspace":
+		e.move(-1)
+	case "l", "right", " ":
+		e.move(1)
+		e.clampNormal()
+	case "0", "home":
+		e.pos = e.lineStart()
+	case "^":
+		e.pos = e.lineStart()
+		for e.pos < len(e.buf) && unicode.IsSpace(e.buf[e.pos]) {
+			e.pos++
+		}
+	case "$", "end":
+		e.pos = e.lineEnd()
+		e.clampNormal()
+	case "w", "e":
+		e.pos = e.wordRight()
+		e.clampNormal()
+	case "b":
+		e.pos = e.wordLeft()
+	case "k", "up", "ctrl+p":
+		e.historyMove(-1)
+	case "j", "down", "ctrl+n":
+		e.historyMove(1)
+	case "x", "delete":
+		e.deleteRange(e.pos, e.pos+1)
+		e.clampNormal()
+	case "X":
+		e.deleteRange(e.pos-1, e.pos)
+	case "D":
+		e.deleteRange(e.pos, e.lineEnd())
+		e.clampNormal()
+	case "C":
+		e.deleteRange(e.pos, e.lineEnd())
+		e.normal = false
+	case "S":
+		e.deleteRange(0, len(e.buf))
+		e.normal = false
+	case "d", "c":
+		e.pending = rune(key[0])
+	case "i":
+		e.normal = false
+	case "a":
+		e.move(1)
+		e.normal = false
+	case "I":
+		e.pos = e.lineStart()
+		e.normal = false
+	case "A":
+		e.pos = e.lineEnd()
+		e.normal = false
+	}
+	return nil
+}
+
+// clampNormal mantiene el cursor sobre un carácter en el modo normal
+func (e *lineEditor) clampNormal() {
+	if e.pos >= len(e.buf) && e.pos > 0 {
+		e.pos = len(e.buf) - 1
+	}
+}
+
+func (e *lineEditor) startSearch() {
+	e.searching = true
+	e.query = nil
+	e.searchIdx = len(e.history)
+	e.searchSaved = append([]rune(nil), e.buf...)
+}
+
+// updateSearch gestiona la búsqueda inversa en el historial: Ctrl+R busca
+// la coincidencia anterior, Enter ejecuta, Esc/Ctrl+G cancela y cualquier
+// otra tecla de edición acepta la coincidencia
+func (e *lineEditor) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+r":
+		e.search(e.searchIdx - 1)
+	case "backspace", "ctrl+h":
+		if len(e.query) > 0 {
+			e.query = e.query[:len(e.query)-1]
+			e.search(len(e.history) - 1)
+		}
+	case "esc", "ctrl+g":
+		e.searching = false
+		e.buf = e.searchSaved
+		e.pos = len(e.buf)
+	case "ctrl+c":
+		e.interrupted = true
+		return e.finish()
+	case "enter":
+		e.searching = false
+		return e.finish()
+	case " ":
+		e.query = append(e.query, ' ')
+		e.search(e.searchIdx)
+	default:
+		if msg.Type == tea.KeyRunes && !msg.Alt {
+			e.query = append(e.query, msg.Runes...)
+			e.search(e.searchIdx)
+			return nil
+		}
+		e.searching = false
+	}
+	return nil
+}
+
+// search busca hacia atrás desde from la entrada que contiene la consulta
+func (e *lineEditor) search(from int) {
+	if from >= len(e.history) {
+		from = len(e.history) - 1
+	}
+	query := string(e.query)
+	for i := from; i >= 0; i-- {
+		if idx := strings.Index(e.history[i], query); idx >= 0 {
+			e.searchIdx = i
+			e.buf = []rune(e.history[i])
+			e.pos = len([]rune(e.history[i][:idx]))
+			return
+		}
+	}
+}
+
+func (e *lineEditor) historyMove(delta int) {
+	idx := e.histIdx + delta
+	if idx < 0 || idx > len(e.history) {
+		return
+	}
+	if e.histIdx == len(e.history) {
+		e.saved = append([]rune(nil), e.buf...)
+	}
+	e.histIdx = idx
+	if idx == len(e.history) {
+		e.buf = e.saved
+	} else {
+		e.buf = []rune(e.history[idx])
+	}
+	e.pos = len(e.buf)
+	if e.normal {
+		e.clampNormal()
+	}
+}
+
+func (e *lineEditor) insert(runes []rune) {
+	runes = []rune(strings.ReplaceAll(string(runes), "\r\n", "\n"))
+	for i, r := range runes {
+		if r == '\r' {
+			runes[i] = '\n'
+		}
+	}
+	buf := make([]rune, 0, len(e.buf)+len(runes))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, runes...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(runes)
+}
+
+func (e *lineEditor) deleteRange(start, end int) {
+	start = max(start, 0)
+	end = min(end, len(e.buf))
+	if start >= end {
+		return
+	}
+	e.buf = append(e.buf[:start:start], e.buf[end:]...)
+	e.pos = start
+}
+
+func (e *lineEditor) move(delta int) {
+	e.pos = min(max(e.pos+delta, 0), len(e.buf))
+}
+
+// lineStart y lineEnd delimitan la línea del cursor en entradas multilínea
+func (e *lineEditor) lineStart() int {
+	i := e.pos
+	for i > 0 && e.buf[i-1] != '\n' {
+		i--
+	}
+	return i
+}
+
+func (e *lineEditor) lineEnd() int {
+	i := e.pos
+	for i < len(e.buf) && e.buf[i] != '\n' {
+		i++
+	}
+	return i
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func (e *lineEditor) wordLeft() int {
+	i := e.pos
+	for i > 0 && !isWordRune(e.buf[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(e.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+func (e *lineEditor) wordRight() int {
+	i := e.pos
+	for i < len(e.buf) && !isWordRune(e.buf[i]) {
+		i++
+	}
+	for i < len(e.buf) && isWordRune(e.buf[i]) {
+		i++
+	}
+	return i
+}
+
+func (e *lineEditor) View() string {
+	prompt := e.prompt
+	if e.searching {
+		prompt = lineSearchStyle.Render("(búsqueda inversa)'" + string(e.query) + "': ")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	for i, r := range e.buf {
+		if i == e.pos && !e.done {
+			switch r {
+			case '\n':
+				sb.WriteString(lineCursorStyle.Render(" "))
+			case '\t':
+				sb.WriteString(lineCursorStyle.Render(" ") + "   ")
+				continue
+			default:
+				sb.WriteString(lineCursorStyle.Render(string(r)))
+				continue
+			}
+		}
+		if r == '\t' {
+			sb.WriteString("    ")
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	if e.pos == len(e.buf) && !e.done {
+		sb.WriteString(lineCursorStyle.Render(" "))
+	}
+	if e.done {
+		if e.interrupted {
+			sb.WriteString("^C")
+		}
+		sb.WriteString("\n")
+	}
+	// El renderizador recorta las líneas más anchas que el terminal
+	if e.width <= 0 {
+		return sb.String()
+	}
+	return ansi.Hardwrap(sb.String(), e.width, true)
+}
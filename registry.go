@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PromptRegistry es un repositorio git de plantillas sincronizado en la
+// biblioteca local; Version fija una etiqueta, rama o commit
+type PromptRegistry struct {
+	Source  string `yaml:"source"`
+	Version string `yaml:"version,omitempty"`
+}
+
+func registriesFile() string {
+	return filepath.Join(promptsDir(), "registries.yaml")
+}
+
+func loadRegistries() (map[string]PromptRegistry, error) {
+	registries := map[string]PromptRegistry{}
+	data, err := os.ReadFile(registriesFile())
+	if os.IsNotExist(err) {
+		return registries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &registries); err != nil {
+		return nil, fmt.Errorf("%s inválido: %v", registriesFile(), err)
+	}
+	return registries, nil
+}
+
+func saveRegistries(registries map[string]PromptRegistry) error {
+	data, err := yaml.Marshal(registries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(registriesFile(), data, 0644)
+}
+
+// parseRegistrySource separa "github.com/org/prompts@v1.2" en origen y
+// versión; la @ de "git@host:org/repo" no se toma como versión
+func parseRegistrySource(s string) (source, version string) {
+	if i := strings.LastIndex(s, "@"); i > 0 && !strings.ContainsAny(s[i:], ":/") {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// registryCloneURL convierte "github.com/org/prompts" en una URL de git;
+// las URLs completas y las rutas locales se usan tal cual
+func registryCloneURL(source string) string {
+	if strings.Contains(source, "://") || strings.HasPrefix(source, "git@") {
+		return source
+	}
+	if _, err := os.Stat(source); err == nil {
+		return source
+	}
+	return "https://" + strings.TrimSuffix(source, ".git") + ".git"
+}
+
+func registryName(source string) string {
+	name := strings.TrimSuffix(strings.TrimRight(source, "/"), ".git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func git(dir string, args ...string) (string, error) {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[len(args)-1], err, strings.TrimSpace(stderr.String()))
+	}
+	// Solo se recortan los saltos de línea finales: el espacio inicial es
+	// significativo en salidas como git status --short
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// pullRegistry clona o actualiza el repositorio en la biblioteca y deja
+// activa la versión fijada (o la rama principal del origen)
+func pullRegistry(name string, reg PromptRegistry) (string, error) {
+	if err := checkOffline("actualización del registro " + name); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(promptsDir(), name)
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if _, err := os.Stat(dir); err == nil {
+			return "", fmt.Errorf("%s ya existe y no es un repositorio git", dir)
+		}
+		if err := os.MkdirAll(promptsDir(), 0755); err != nil {
+			return "", err
+		}
+		logger.Printf("Clonando %s en %s\n", registryCloneURL(reg.Source), dir)
+		if _, err := git("", "clone", "--quiet", registryCloneURL(reg.Source), dir); err != nil {
+			return "", err
+		}
+	} else {
+		logger.Printf("Actualizando %s\n", dir)
+		if _, err := git(dir, "fetch", "--quiet", "--tags", "--force", "origin"); err != nil {
+			return "", err
+		}
+		// origin/HEAD puede no existir en clones antiguos
+		git(dir, "remote", "set-head", "origin", "--auto")
+	}
+
+	target := "origin/HEAD"
+	if reg.Version != "" {
+		target = reg.Version
+		if _, err := git(dir, "rev-parse", "--verify", "--quiet", "origin/"+reg.Version+"^{commit}"); err == nil {
+			target = "origin/" + reg.Version
+		} else if _, err := git(dir, "rev-parse", "--verify", "--quiet", reg.Version+"^{commit}"); err != nil {
+			return "", fmt.Errorf("la versión %q no existe en %s", reg.Version, reg.Source)
+		}
+	}
+	if _, err := git(dir, "checkout", "--quiet", "--detach", target); err != nil {
+		return "", err
+	}
+	return git(dir, "rev-parse", "--short", "HEAD")
+}
+
+// listPromptTemplates devuelve las plantillas de la biblioteca por nombre
+func listPromptTemplates() (map[string]*PromptTemplate, error) {
+	templates := map[string]*PromptTemplate{}
+	root := promptsDir()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".md", ".txt", ".tmpl":
+		default:
+			return nil
+		}
+		// La documentación de los repositorios no son plantillas
+		switch strings.ToUpper(strings.TrimSuffix(d.Name(), filepath.Ext(path))) {
+		case "README", "CHANGELOG", "LICENSE", "CONTRIBUTING":
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		name := strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+		t, err := loadPromptTemplate(path)
+		if err != nil {
+			logger.Printf("Ignorando %s: %v\n", path, err)
+			return nil
+		}
+		templates[name] = t
+		return nil
+	})
+	return templates, err
+}
+
+func runPrompt(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s prompt <acción> [opciones]
+
+Acciones:
+  pull <repo>[@versión] [--as <nombre>]
+                        Sincronizar un repositorio git de plantillas en la
+                        biblioteca (~/.config/deepcli/prompts/<nombre>); la
+                        versión fija una etiqueta, rama o commit.
+                        Ejemplo: pull github.com/org/prompts@v1.2
+  pull                  Actualizar todos los repositorios sincronizados
+  list                  Listar las plantillas de la biblioteca
+
+Las plantillas de un repositorio se usan con -p <nombre>/<plantilla>.
+`, os.Args[0])
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "pull":
+		fs := flag.NewFlagSet("prompt pull", flag.ExitOnError)
+		as := fs.String("as", "", "Nombre local del repositorio en la biblioteca")
+		fs.BoolVar(&verbose, "v", false, "Mostrar mensajes detallados de ejecución")
+		fs.BoolVar(&verbose, "verbose", false, "Mostrar mensajes detallados de ejecución")
+		fs.Usage = usage
+
+		var spec string
+		rest := args[1:]
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+			spec, rest = rest[0], rest[1:]
+		}
+		fs.Parse(rest)
+		if spec == "" && fs.NArg() > 0 {
+			spec = fs.Arg(0)
+		}
+		if !verbose {
+			logger.SetOutput(io.Discard)
+		}
+
+		registries, err := loadRegistries()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var names []string
+		if spec != "" {
+			source, version := parseRegistrySource(spec)
+			name := *as
+			if name == "" {
+				name = registryName(source)
+			}
+			registries[name] = PromptRegistry{Source: source, Version: version}
+			names = []string{name}
+		} else {
+			if len(registries) == 0 {
+				fmt.Fprintf(os.Stderr, "No hay repositorios de plantillas; usa: %s prompt pull <repo>\n", os.Args[0])
+				os.Exit(1)
+			}
+			for name := range registries {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+
+		failed := false
+		for _, name := range names {
+			reg := registries[name]
+			commit, err := pullRegistry(name, reg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error en %s: %v\n", name, err)
+				failed = true
+				continue
+			}
+			version := reg.Version
+			if version == "" {
+				version = "última"
+			}
+			fmt.Printf("%s: %s@%s (%s)\n", name, reg.Source, version, commit)
+		}
+		if spec != "" && !failed {
+			if err := saveRegistries(registries); err != nil {
+				fmt.Fprintf(os.Stderr, "Error al guardar %s: %v\n", registriesFile(), err)
+				os.Exit(1)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+
+	case "list":
+		if !verbose {
+			logger.SetOutput(io.Discard)
+		}
+		templates, err := listPromptTemplates()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		names := make([]string, 0, len(templates))
+		for name := range templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NOMBRE\tMODELO\tDESCRIPCIÓN")
+		for _, name := range names {
+			t := templates[name]
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, t.Model, t.Description)
+		}
+		w.Flush()
+
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
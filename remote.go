@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sshMaxSize (--ssh-max-size) limita lo que se descarga de un archivo
+// remoto de -f usuario@host:/ruta
+var sshMaxSize = "10MB"
+
+// sshPathRe reconoce [usuario@]host:/ruta o [usuario@]host:~/ruta, como scp;
+// el host de una letra sería una unidad de Windows. Usuario y host empiezan
+// por una letra o dígito para que ssh no los tome por opciones (-F...).
+var sshPathRe = regexp.MustCompile(`^(?:(\w[\w.-]*)@)?(\w[\w.-]+|\[[0-9A-Fa-f:]+\]):((?:/|~).*)$`)
+
+// isObjectStorageURL indica si el destino es un almacenamiento de objetos
+// (s3://, gs:// o az://)
+func isObjectStorageURL(target string) bool {
+	for _, scheme := range []string{"s3://", "gs://", "az://"} {
+		if strings.HasPrefix(target, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadObject sube los datos al almacenamiento de objetos usando la CLI
+// oficial de cada proveedor, que toma las credenciales de las variables de
+// entorno y la configuración estándar de su SDK
+func uploadObject(target string, data []byte) error {
+	if err := checkOffline("subida a " + target); err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	switch {
+	case strings.HasPrefix(target, "s3://"):
+		cmd = exec.Command("aws", "s3", "cp", "-", target)
+		cmd.Stdin = bytes.NewReader(data)
+
+	case strings.HasPrefix(target, "gs://"):
+		if _, err := exec.LookPath("gcloud"); err == nil {
+			cmd = exec.Command("gcloud", "storage", "cp", "-", target)
+		} else {
+			cmd = exec.Command("gsutil", "cp", "-", target)
+		}
+		cmd.Stdin = bytes.NewReader(data)
+
+	case strings.HasPrefix(target, "az://"):
+		// az://<cuenta>/<contenedor>/<blob>
+		parts := strings.SplitN(strings.TrimPrefix(target, "az://"), "/", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return fmt.Errorf("formato esperado: az://<cuenta>/<contenedor>/<blob>")
+		}
+		tmp, err := os.CreateTemp("", "deepcli-upload-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := tmp.Write(data); err != nil {
+			tmp.Close()
+			return err
+		}
+		tmp.Close()
+		cmd = exec.Command("az", "storage", "blob", "upload", "--only-show-errors", "--overwrite",
+			"--account-name", parts[0], "--container-name", parts[1], "--name", parts[2], "--file", tmp.Name())
+
+	default:
+		return fmt.Errorf("destino no soportado: %s", target)
+	}
+
+	if _, err := exec.LookPath(cmd.Args[0]); err != nil {
+		return fmt.Errorf("se necesita la CLI %q para escribir en %s", cmd.Args[0], target)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	logger.Printf("Subiendo a %s con: %s\n", target, strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s falló: %v: %s", cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// parseSSHPath separa el destino ssh y la ruta remota de un argumento de -f;
+// un archivo local con ese nombre tiene prioridad
+func parseSSHPath(name string) (host, path string, ok bool) {
+	m := sshPathRe.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", false
+	}
+	if _, err := os.Stat(name); err == nil {
+		return "", "", false
+	}
+	host = strings.Trim(m[2], "[]")
+	if m[1] != "" {
+		host = m[1] + "@" + host
+	}
+	return host, m[3], true
+}
+
+// remotePathArg cita la ruta para el shell remoto; ~/ se deja fuera de las
+// comillas para que se expanda
+func remotePathArg(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return "~/" + shellQuote(rest)
+	}
+	return shellQuote(path)
+}
+
+// readSSHFile descarga un archivo remoto con el ssh local, que usa su
+// configuración (~/.ssh/config, saltos, agente). BatchMode evita que se
+// quede esperando una contraseña: la clave debe estar en el agente. Se
+// leen como mucho --ssh-max-size bytes. Las reglas de ruta de la política
+// se aplican a la ruta remota antes de conectar y max_file_size, al
+// contenido descargado.
+func readSSHFile(name, host, path string) ([]byte, error) {
+	if err := checkOffline("lectura por ssh de " + name); err != nil {
+		return nil, err
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		if err := enforcePolicy(name, policy.checkPaths(path, path)); err != nil {
+			return nil, err
+		}
+	}
+	limit, err := parseSize(sshMaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("--ssh-max-size: %v", err)
+	}
+	if _, err := exec.LookPath("ssh"); err != nil {
+		return nil, fmt.Errorf("se necesita el cliente ssh para leer %s", name)
+	}
+
+	cmd := exec.Command("ssh", "-o", "BatchMode=yes", "-o", "ConnectTimeout=15", "--", host, "cat -- "+remotePathArg(path))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// Al cortar por tamaño no se espera a los procesos que ssh haya dejado
+	// con la salida abierta (ProxyCommand, multiplexación)
+	cmd.WaitDelay = 2 * time.Second
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	logger.Printf("Leyendo %s con: %s\n", name, strings.Join(cmd.Args, " "))
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("no se pudo ejecutar ssh: %v", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(stdout, limit+1))
+	if int64(len(data)) > limit {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("%s supera el límite de --ssh-max-size (%s)", name, sshMaxSize)
+	}
+	if werr := cmd.Wait(); werr != nil {
+		return nil, fmt.Errorf("ssh %s falló: %v: %s", host, werr, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		if err := enforcePolicy(name, policy.checkSize(int64(len(data)))); err != nil {
+			return nil, err
+		}
+	}
+	logger.Printf("Leídos %d bytes de %s\n", len(data), name)
+	return data, nil
+}
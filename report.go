@@ -0,0 +1,390 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+const reportCheckPrompt = `Ayudas a redactar un documento a partir de las respuestas del usuario a ` +
+	`una serie de preguntas. Recibirás una pregunta, lo que se espera de ella y la respuesta. Si la ` +
+	`respuesta basta para redactar esa parte del documento, responde exactamente OK. Si le falta algo ` +
+	`esencial (un dato, un paso, una evidencia), responde solo con una pregunta breve y concreta para ` +
+	`completarla, sin saludos ni explicaciones.`
+
+const reportWriterPrompt = `Redactas documentos profesionales a partir de las respuestas del ` +
+	`usuario a un cuestionario. Usa solo la información de las respuestas: no inventes datos, y si ` +
+	`falta algo que la estructura pide, indícalo como pendiente. Responde solo con el documento en ` +
+	`Markdown, siguiendo la estructura indicada.`
+
+// ReportTemplate es un cuestionario guiado: las preguntas que se hacen al
+// usuario y la estructura del documento que se redacta con las respuestas
+type ReportTemplate struct {
+	Name        string           `yaml:"-"`
+	Title       string           `yaml:"title"`
+	Description string           `yaml:"description"`
+	System      string           `yaml:"system"`
+	Questions   []ReportQuestion `yaml:"questions"`
+	Document    string           `yaml:"document"`
+}
+
+// ReportQuestion es una pregunta del cuestionario; Hint describe qué se
+// espera de la respuesta y guía también al modelo al revisarla
+type ReportQuestion struct {
+	ID       string `yaml:"id"`
+	Ask      string `yaml:"ask"`
+	Hint     string `yaml:"hint"`
+	Optional bool   `yaml:"optional"`
+}
+
+// Plantillas de informe incluidas; las de reportsDir() con el mismo nombre
+// las sustituyen
+var reportTemplates = map[string]ReportTemplate{
+	"pentest-finding": {
+		Title:       "Hallazgo de pentest",
+		Description: "Vulnerabilidad encontrada en una prueba de intrusión",
+		System:      "Eres un consultor de seguridad ofensiva que redacta hallazgos para un informe de pentest dirigido a un equipo técnico y a su dirección.",
+		Questions: []ReportQuestion{
+			{ID: "title", Ask: "¿Qué vulnerabilidad has encontrado?", Hint: "Tipo de vulnerabilidad y componente afectado, en una frase"},
+			{ID: "asset", Ask: "¿Qué activos están afectados?", Hint: "URL, host, endpoint, parámetro o versión"},
+			{ID: "steps", Ask: "¿Cómo se reproduce?", Hint: "Pasos concretos, peticiones o comandos usados"},
+			{ID: "evidence", Ask: "¿Qué evidencia tienes?", Hint: "Respuestas, capturas o datos obtenidos", Optional: true},
+			{ID: "impact", Ask: "¿Qué impacto tiene?", Hint: "Qué podría hacer un atacante y con qué requisitos previos"},
+			{ID: "severity", Ask: "¿Qué gravedad le das?", Hint: "Crítica, alta, media, baja o informativa; vector CVSS si lo tienes", Optional: true},
+			{ID: "remediation", Ask: "¿Cómo se corrige?", Hint: "Corrección recomendada y mitigaciones temporales", Optional: true},
+		},
+		Document: "# <título del hallazgo>\n\n| Gravedad | CVSS | Activos |\n|---|---|---|\n\n## Descripción\n## Activos afectados\n## Pasos para reproducir\n## Evidencia\n## Impacto\n## Recomendación\n## Referencias (CWE, OWASP)",
+	},
+	"incident-postmortem": {
+		Title:       "Postmortem de incidente",
+		Description: "Análisis sin culpables de un incidente de producción",
+		System:      "Eres un SRE que redacta postmortems sin culpables, claros y accionables.",
+		Questions: []ReportQuestion{
+			{ID: "summary", Ask: "¿Qué pasó?", Hint: "Resumen del incidente en dos o tres frases"},
+			{ID: "impact", Ask: "¿A quién afectó y cuánto?", Hint: "Usuarios o servicios afectados, duración, datos perdidos"},
+			{ID: "timeline", Ask: "¿Cuál fue la cronología?", Hint: "Hora de inicio, detección, mitigación y resolución"},
+			{ID: "root_cause", Ask: "¿Cuál fue la causa raíz?", Hint: "Causa técnica y factores que contribuyeron"},
+			{ID: "response", Ask: "¿Cómo se detectó y se resolvió?", Hint: "Alertas, acciones tomadas y qué funcionó o no"},
+			{ID: "actions", Ask: "¿Qué acciones de seguimiento hay?", Hint: "Tareas para evitar que se repita, con responsable si lo hay", Optional: true},
+		},
+		Document: "# Postmortem: <título>\n\n## Resumen\n## Impacto\n## Cronología\n## Causa raíz\n## Detección y respuesta\n## Lecciones aprendidas\n## Acciones de seguimiento (tabla: acción, responsable, prioridad)",
+	},
+	"bug-report": {
+		Title:       "Informe de bug",
+		Description: "Bug reproducible para un issue tracker",
+		System:      "Eres un ingeniero de QA que redacta informes de bugs precisos y reproducibles.",
+		Questions: []ReportQuestion{
+			{ID: "summary", Ask: "¿Qué falla?", Hint: "Comportamiento incorrecto en una frase"},
+			{ID: "steps", Ask: "¿Cómo se reproduce?", Hint: "Pasos numerados desde un estado conocido"},
+			{ID: "expected", Ask: "¿Qué esperabas que pasara?", Hint: "Comportamiento correcto"},
+			{ID: "actual", Ask: "¿Qué pasa en cambio?", Hint: "Comportamiento observado, mensajes de error"},
+			{ID: "environment", Ask: "¿En qué entorno?", Hint: "Versión, sistema operativo, navegador, configuración", Optional: true},
+		},
+		Document: "# <título>\n\n## Pasos para reproducir\n## Resultado esperado\n## Resultado actual\n## Entorno\n## Notas",
+	},
+}
+
+func reportsDir() string {
+	return filepath.Join(configDir(), "reports")
+}
+
+// loadReportTemplate busca la plantilla como archivo YAML, en reportsDir()
+// y entre las incluidas
+func loadReportTemplate(name string) (*ReportTemplate, error) {
+	path := name
+	if _, err := os.Stat(path); err != nil {
+		path = ""
+		for _, ext := range []string{".yaml", ".yml"} {
+			if p := filepath.Join(reportsDir(), name+ext); fileExists(p) {
+				path = p
+				break
+			}
+		}
+	}
+	if path == "" {
+		t, ok := reportTemplates[name]
+		if !ok {
+			return nil, fmt.Errorf("no se encontró la plantilla de informe %q (ni como archivo ni en %s); usa --list para ver las disponibles", name, reportsDir())
+		}
+		t.Name = name
+		return &t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer la plantilla %s: %v", path, err)
+	}
+	t := &ReportTemplate{Name: strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))}
+	if err := yaml.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("plantilla %s: YAML inválido: %v", path, err)
+	}
+	if len(t.Questions) == 0 {
+		return nil, fmt.Errorf("plantilla %s: no tiene preguntas", path)
+	}
+	for i, q := range t.Questions {
+		if q.Ask == "" {
+			return nil, fmt.Errorf("plantilla %s: la pregunta %d no tiene texto (ask)", path, i+1)
+		}
+		if q.ID == "" {
+			t.Questions[i].ID = fmt.Sprintf("q%d", i+1)
+		}
+	}
+	return t, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// listReportTemplates devuelve los nombres de las plantillas disponibles
+func listReportTemplates() []string {
+	names := map[string]bool{}
+	for name := range reportTemplates {
+		names[name] = true
+	}
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, _ := filepath.Glob(filepath.Join(reportsDir(), pattern))
+		for _, m := range matches {
+			names[strings.TrimSuffix(filepath.Base(m), filepath.Ext(m))] = true
+		}
+	}
+	var out []string
+	for name := range names {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// reportFollowUp pide al modelo que revise una respuesta; devuelve la
+// pregunta para completarla o "" si basta
+func reportFollowUp(t *ReportTemplate, q ReportQuestion, answer string) (string, error) {
+	requestBody := newRequestBody([]Message{
+		{Role: "system", Content: reportCheckPrompt},
+		{Role: "user", Content: fmt.Sprintf("Documento: %s\nPregunta: %s\nSe espera: %s\nRespuesta:\n%s", t.Title, q.Ask, q.Hint, answer)},
+	})
+	requestBody.Temperature = 0
+	requestBody.MaxTokens = 200
+	reply, _, err := complete(requestBody)
+	if err != nil {
+		return "", err
+	}
+	reply = strings.TrimSpace(reply)
+	if strings.EqualFold(strings.TrimRight(reply, "."), "OK") {
+		return "", nil
+	}
+	return reply, nil
+}
+
+// askReport hace las preguntas de la plantilla; cada respuesta la revisa el
+// modelo, que puede pedir un dato más. Las respuestas de answers no se
+// preguntan.
+func askReport(t *ReportTemplate, answers map[string]string, noFollowUp bool) error {
+	reader := newLineReader(false)
+	read := func(prompt string) (string, error) {
+		for {
+			line, err := reader.ReadLine(prompt)
+			if errors.Is(err, errInterrupted) {
+				continue
+			}
+			if err == io.EOF {
+				return "", fmt.Errorf("la entrada terminó antes de completar el cuestionario")
+			}
+			return strings.TrimSpace(line), err
+		}
+	}
+
+	dim := func(s string) string { return s }
+	if isTerminal(os.Stderr) && os.Getenv("NO_COLOR") == "" {
+		dim = func(s string) string { return ansiDim + s + ansiReset }
+	}
+
+	for i, q := range t.Questions {
+		if _, ok := answers[q.ID]; ok {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "\n[%d/%d] %s\n", i+1, len(t.Questions), q.Ask)
+		if q.Hint != "" {
+			fmt.Fprintf(os.Stderr, "      %s\n", dim(q.Hint))
+		}
+		if q.Optional {
+			fmt.Fprintf(os.Stderr, "      %s\n", dim("(opcional: Enter para omitirla)"))
+		}
+		var answer string
+		for answer == "" {
+			var err error
+			if answer, err = read(chatPrompt); err != nil {
+				return err
+			}
+			if answer == "" && q.Optional {
+				break
+			}
+		}
+		if answer == "" || noFollowUp {
+			answers[q.ID] = answer
+			continue
+		}
+
+		followUp, err := reportFollowUp(t, q, answer)
+		if err != nil {
+			return err
+		}
+		if followUp != "" {
+			fmt.Fprintf(os.Stderr, "      ↳ %s\n", followUp)
+			more, err := read(chatPrompt)
+			if err != nil {
+				return err
+			}
+			if more != "" {
+				answer += "\n" + followUp + "\n" + more
+			}
+		}
+		answers[q.ID] = answer
+	}
+	return nil
+}
+
+// writeReport redacta el documento final con las respuestas
+func writeReport(t *ReportTemplate, answers map[string]string) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Redacta el documento \"%s\" con estas respuestas:\n", t.Title)
+	for _, q := range t.Questions {
+		answer := answers[q.ID]
+		if answer == "" {
+			answer = "(sin respuesta)"
+		}
+		fmt.Fprintf(&sb, "\n### %s\n%s\n", q.Ask, answer)
+	}
+	if t.Document != "" {
+		fmt.Fprintf(&sb, "\nEstructura del documento:\n%s\n", t.Document)
+	}
+
+	system := reportWriterPrompt
+	if t.System != "" {
+		system = t.System + " " + system
+	}
+	requestBody := newRequestBody([]Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: sb.String()},
+	})
+	content, _, err := complete(requestBody)
+	return content, err
+}
+
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	templateName := fs.String("template", "", "Plantilla del informe")
+	list := fs.Bool("list", false, "Listar las plantillas disponibles")
+	answersFile := fs.String("answers", "", "Archivo YAML con respuestas ya preparadas (id: respuesta)")
+	saveAnswers := fs.String("save-answers", "", "Guardar las respuestas en un archivo YAML")
+	noFollowUp := fs.Bool("no-follow-up", false, "No dejar que el modelo pida datos adicionales")
+	output := fs.String("o", "", "Archivo de salida (default: stdout)")
+	fs.StringVar(output, "output", "", "Archivo de salida (default: stdout)")
+	addModelFlags(fs, defaultTemperature)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s report --template <nombre> [opciones]
+
+Asistente de redacción guiado: hace una serie fija de preguntas, el modelo
+revisa cada respuesta y pide el dato que falte, y al final redacta el
+documento estructurado con todas las respuestas.
+
+Las plantillas son archivos YAML en %s (o una ruta) con
+title, description, system (el papel del redactor), questions (id, ask,
+hint, optional) y document (la estructura del documento). Las incluidas
+son pentest-finding, incident-postmortem y bug-report.
+
+Opciones:
+  --template <nombre>     Plantilla del informe
+  --list                  Listar las plantillas disponibles
+  --answers <archivo>     Respuestas ya preparadas (YAML id: respuesta); solo
+                          se pregunta lo que falte
+  --save-answers <arch.>  Guardar las respuestas para repetir el informe
+  --no-follow-up          No dejar que el modelo pida datos adicionales
+  -o, --output <archivo>  Guardar el documento en un archivo
+  -t, --temperature       Temperatura de la redacción (default: %.1f)
+  -m, --maxtokens         Máximo de tokens a generar
+  -v, --verbose           Mostrar logs detallados
+
+Ejemplo:
+  %s report --template pentest-finding -o hallazgo-01.md
+`, os.Args[0], reportsDir(), defaultTemperature, os.Args[0])
+	}
+	fs.Parse(args)
+
+	if *list {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, name := range listReportTemplates() {
+			t, err := loadReportTemplate(name)
+			if err != nil {
+				fmt.Fprintf(w, "%s\t(error: %v)\n", name, err)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d preguntas\n", name, t.Description, len(t.Questions))
+		}
+		w.Flush()
+		return
+	}
+	if *templateName == "" || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	t, err := loadReportTemplate(*templateName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	answers := map[string]string{}
+	if *answersFile != "" {
+		data, err := readContextFile(*answersFile)
+		if err == nil {
+			err = yaml.Unmarshal(data, &answers)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: no se pudieron leer las respuestas de %s: %v\n", *answersFile, err)
+			os.Exit(1)
+		}
+	}
+	setupSubcommand()
+
+	statusf("%s: %d preguntas\n", t.Title, len(t.Questions))
+	if err := askReport(t, answers, *noFollowUp); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *saveAnswers != "" {
+		data, _ := yaml.Marshal(answers)
+		if err := os.WriteFile(*saveAnswers, data, 0600); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		statusf("Respuestas guardadas en %s\n", *saveAnswers)
+	}
+
+	statusf("\nRedactando el documento...\n")
+	doc, err := writeReport(t, answers)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *output != "" {
+		if err := writeOutputFile(*output, []byte(doc+"\n"), false, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		statusf("Documento escrito en %s\n", *output)
+		return
+	}
+	if isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" {
+		doc = renderMarkdown(doc)
+	}
+	fmt.Println(doc)
+}
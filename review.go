@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Partes de la entrada que se piden en paralelo
+const maxParallelChunks = 4
+
+const mergeSystemPrompt = `Eres un revisor de código experto. Recibirás respuestas parciales a una misma ` +
+	`instrucción, cada una hecha sobre una parte de un diff o de un archivo. Combínalas en una única ` +
+	`respuesta coherente a la instrucción original: elimina duplicados, agrupa los hallazgos por ` +
+	`importancia y conserva las referencias a archivos y líneas.`
+
+// isUnifiedDiff indica si el texto parece un diff unificado (git diff, diff -u)
+func isUnifiedDiff(s string) bool {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "diff --git ") {
+		return true
+	}
+	return (strings.HasPrefix(s, "--- ") || strings.Contains(s, "\n--- ")) &&
+		strings.Contains(s, "\n+++ ") && strings.Contains(s, "\n@@ ")
+}
+
+// findDiffSource devuelve la mayor fuente de contexto que es un diff
+func findDiffSource(sources []*contextSource) *contextSource {
+	var found *contextSource
+	for _, s := range sources {
+		if s.Raw != "" && isUnifiedDiff(s.Raw) && (found == nil || s.Tokens > found.Tokens) {
+			found = s
+		}
+	}
+	return found
+}
+
+// findOversizedSource devuelve la mayor fuente de stdin o de archivo que no
+// cabe sola en el presupuesto
+func findOversizedSource(sources []*contextSource, budget int) *contextSource {
+	var found *contextSource
+	for _, s := range sources {
+		if s.Raw != "" && s.Tokens > budget && (found == nil || s.Tokens > found.Tokens) {
+			found = s
+		}
+	}
+	return found
+}
+
+// splitDiffFiles divide un diff en un fragmento por archivo
+func splitDiffFiles(diff string) []string {
+	lines := strings.SplitAfter(diff, "\n")
+	gitHeaders := strings.HasPrefix(strings.TrimSpace(diff), "diff --git ")
+	var files []string
+	var current strings.Builder
+	for i, line := range lines {
+		start := false
+		if gitHeaders {
+			start = strings.HasPrefix(line, "diff --git ")
+		} else {
+			start = strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+		}
+		if start && current.Len() > 0 {
+			files = append(files, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		files = append(files, current.String())
+	}
+	return files
+}
+
+// splitDiffHunks divide el diff de un archivo en fragmentos de como mucho
+// maxTokens, cortando entre hunks y repitiendo la cabecera del archivo
+func splitDiffHunks(file string, maxTokens int) []string {
+	i := strings.Index(file, "\n@@ ")
+	if i < 0 {
+		return []string{file}
+	}
+	header, body := file[:i+1], file[i+1:]
+
+	var hunks []string
+	for _, part := range strings.SplitAfter(body, "\n") {
+		if strings.HasPrefix(part, "@@ ") || len(hunks) == 0 {
+			hunks = append(hunks, part)
+		} else {
+			hunks[len(hunks)-1] += part
+		}
+	}
+
+	var pieces []string
+	current := header
+	for _, h := range hunks {
+		if current != header && estimateTokens(current+h) > maxTokens {
+			pieces = append(pieces, current)
+			current = header
+		}
+		current += h
+	}
+	return append(pieces, current)
+}
+
+// diffFileName obtiene la ruta del archivo de la cabecera de su diff (la
+// de origen si el archivo se eliminó)
+func diffFileName(file string) string {
+	var oldName string
+	for _, line := range strings.Split(file, "\n") {
+		if name, ok := strings.CutPrefix(line, "+++ "); ok && strings.TrimSpace(name) != "/dev/null" {
+			return strings.TrimPrefix(strings.TrimSpace(name), "b/")
+		}
+		if name, ok := strings.CutPrefix(line, "--- "); ok && oldName == "" {
+			oldName = strings.TrimPrefix(strings.TrimSpace(name), "a/")
+		}
+		if strings.HasPrefix(line, "@@ ") {
+			break
+		}
+	}
+	if oldName != "" && oldName != "/dev/null" {
+		return oldName
+	}
+	if f := strings.Fields(strings.SplitN(file, "\n", 2)[0]); len(f) >= 4 && f[0] == "diff" {
+		return strings.TrimPrefix(f[3], "b/")
+	}
+	return "?"
+}
+
+// inputChunk es una parte de la entrada (del diff o de un archivo) que se
+// envía por separado; Files describe lo que contiene
+type inputChunk struct {
+	Text  string
+	Files []string
+}
+
+// chunkDiff agrupa los archivos (o hunks, si un archivo no cabe solo) del
+// diff en partes de como mucho maxTokens
+func chunkDiff(diff string, maxTokens int) []inputChunk {
+	var pieces []string
+	for _, file := range splitDiffFiles(diff) {
+		if estimateTokens(file) > maxTokens {
+			pieces = append(pieces, splitDiffHunks(file, maxTokens)...)
+		} else {
+			pieces = append(pieces, file)
+		}
+	}
+
+	var chunks []inputChunk
+	var current inputChunk
+	for _, p := range pieces {
+		if current.Text != "" && estimateTokens(current.Text+p) > maxTokens {
+			chunks = append(chunks, current)
+			current = inputChunk{}
+		}
+		current.Text += p
+		name := diffFileName(p)
+		if n := len(current.Files); n == 0 || current.Files[n-1] != name {
+			current.Files = append(current.Files, name)
+		}
+	}
+	if current.Text != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// chunkedReview es una entrada demasiado grande para una sola petición,
+// ya dividida en partes; extra es el resto del contexto, que solo se envía
+// en la combinación
+type chunkedReview struct {
+	prompt string
+	noun   string // "diff" o "archivo"
+	chunks []inputChunk
+	extra  string
+	budget int
+}
+
+// reviewInChunks procesa una entrada que no cabe en la ventana de contexto:
+// envía cada parte en paralelo y combina las respuestas en una pasada final.
+// Devuelve una respuesta con el formato de la API.
+func reviewInChunks(template RequestBody, r chunkedReview) ([]byte, error) {
+	prompt, extra, budget, chunks := r.prompt, r.extra, r.budget, r.chunks
+	statusf("El %s no cabe en la ventana del modelo; procesándolo en %d partes...\n", r.noun, len(chunks))
+
+	reviews := make([]string, len(chunks))
+	usages := make([]Usage, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, maxParallelChunks)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk inputChunk) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			note := fmt.Sprintf("\n\n(Esta es la parte %d de %d del %s; contiene: %s.)", i+1, len(chunks), r.noun, strings.Join(chunk.Files, ", "))
+			body := template
+			body.N = 0
+			body.Messages = buildMessages(prompt+note, chunk.Text, "")
+			var response ResponseBody
+			reviews[i], response, errs[i] = complete(body)
+			usages[i] = response.Usage
+			logger.Printf("Parte %d/%d procesada\n", i+1, len(chunks))
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	var usage Usage
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("parte %d del %s: %v", i+1, r.noun, err)
+		}
+		usage.add(usages[i])
+	}
+
+	// Pasada final: combinar las respuestas parciales
+	statusf("Combinando las respuestas...\n")
+	var sources []*contextSource
+	for i, review := range reviews {
+		text := fmt.Sprintf("### Parte %d (%s)\n\n%s", i+1, strings.Join(chunks[i].Files, ", "), review)
+		sources = append(sources, newContextSource("review", fmt.Sprintf("parte %d", i+1), text, true))
+	}
+	if extra != "" {
+		sources = append(sources, newContextSource("context", "contexto", "Contexto adicional:\n"+extra, true))
+	}
+	fitBudget(sources, budget)
+
+	body := template
+	body.N = 0
+	body.Messages = []Message{
+		{Role: "system", Content: mergeSystemPrompt},
+		{Role: "user", Content: "Instrucción original: " + prompt + "\n\nRespuestas parciales:\n\n" + joinSources(sources)},
+	}
+	merged, response, err := complete(body)
+	if err != nil {
+		return nil, fmt.Errorf("combinación de las respuestas: %v", err)
+	}
+	usage.add(response.Usage)
+	response.Usage = usage
+	response.Choices[0].Message.Content = merged
+	return json.Marshal(response)
+}
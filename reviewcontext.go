@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+)
+
+// reviewContext (--review-context) muestra lo que se va a enviar y pide
+// confirmación: la contrapartida manual de la política de envío
+var reviewContext bool
+
+// Nombres de las fuentes de contexto en el resumen
+var sourceKinds = map[string]string{
+	"stdin": "stdin", "file": "archivo", "exec": "comando", "git": "git", "about": "símbolos",
+}
+
+// contextPayload es el texto exacto que se enviará: los mensajes o, si la
+// entrada se procesa por partes, cada parte con su instrucción. Con
+// --anonymize se muestra ya anonimizado, como lo recibe la API.
+func contextPayload(messages []Message, chunked *chunkedReview) string {
+	sent := func(s string) string {
+		if anon != nil {
+			return anon.Anonymize(s)
+		}
+		return s
+	}
+	var sb strings.Builder
+	if chunked != nil {
+		for i, c := range chunked.chunks {
+			fmt.Fprintf(&sb, "===== parte %d de %d =====\n%s\n\n", i+1, len(chunked.chunks), sent(c.Text))
+		}
+		if chunked.extra != "" {
+			fmt.Fprintf(&sb, "===== contexto de todas las partes =====\n%s\n\n", sent(chunked.extra))
+		}
+		fmt.Fprintf(&sb, "===== instrucción =====\n%s\n", sent(chunked.prompt))
+		return sb.String()
+	}
+	if anon != nil {
+		messages = anonymizeMessages(messages)
+	}
+	for _, m := range messages {
+		fmt.Fprintf(&sb, "===== %s =====\n%s\n\n", m.Role, m.Content)
+		for _, img := range m.Images {
+			fmt.Fprintf(&sb, "[imagen %s, %s en base64]\n\n", img.Name, formatSize(len(img.URL)))
+		}
+	}
+	return sb.String()
+}
+
+// printContextSummary enumera las fuentes con los bytes y tokens que se
+// envían de cada una, el destino y el total
+func printContextSummary(w io.Writer, sources []*contextSource, payload string) {
+	fmt.Fprintf(w, "Se va a enviar a %s (%s):\n", redactURL(apiBaseURL), providerModel(model))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, s := range sources {
+		state := ""
+		switch {
+		case s.Content == "":
+			state = "(omitido)"
+		case s.Kept < s.Tokens:
+			state = "(truncado)"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t~%d tokens\t%s\n", orDefault(sourceKinds[s.Kind], s.Kind), s.Name, formatSize(len(s.Content)), estimateTokens(s.Content), state)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "  Total: %s (~%d tokens)\n", formatSize(len(payload)), estimateTokens(payload))
+}
+
+// showInPager muestra el texto con $PAGER (o less); sin paginador se
+// escribe directamente
+func showInPager(text string) {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		if _, err := exec.LookPath("less"); err == nil {
+			pager = "less"
+		}
+	}
+	if pager == "" {
+		fmt.Fprint(os.Stderr, text)
+		return
+	}
+	cmd := shellCommand(pager)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		defer tty.Close()
+		cmd.Stdout = tty
+	}
+	if err := cmd.Run(); err != nil {
+		statusf("Advertencia: no se pudo ejecutar el paginador %q: %v\n", pager, err)
+		fmt.Fprint(os.Stderr, text)
+	}
+}
+
+// confirmContext muestra qué fuentes y cuántos bytes se van a enviar y
+// pide confirmación; con "v" se revisa el contenido exacto en el paginador
+func confirmContext(sources []*contextSource, messages []Message, chunked *chunkedReview) error {
+	payload := contextPayload(messages, chunked)
+	printContextSummary(os.Stderr, sources, payload)
+
+	in := os.Stdin
+	if !isTerminal(os.Stdin) {
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			return fmt.Errorf("--review-context necesita un terminal para confirmar")
+		}
+		defer tty.Close()
+		in = tty
+	}
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(os.Stderr, "¿Enviar? [s/N/v=ver el contenido]: ")
+		answer, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "s", "si", "sí", "y", "yes":
+			return nil
+		case "v", "ver":
+			showInPager(payload)
+			if err == io.EOF {
+				return fmt.Errorf("envío cancelado (--review-context)")
+			}
+			continue
+		}
+		return fmt.Errorf("envío cancelado (--review-context)")
+	}
+}
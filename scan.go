@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const scanSystemPrompt = `Eres un experto en pruebas de intrusión que ayuda en auditorías autorizadas. ` +
+	`Recibirás el resumen de un escaneo de puertos. Para cada servicio relevante propón los siguientes pasos ` +
+	`de enumeración y verificación, ordenados por prioridad (exposición, versiones con vulnerabilidades ` +
+	`conocidas, servicios de administración, credenciales por defecto), con los comandos concretos cuando ` +
+	`proceda. Termina con una lista priorizada de los hallazgos que merecen atención inmediata.`
+
+// Máximo de salida de cada script de Nmap que se incluye en el resumen
+const maxScriptOutput = 300
+
+// scanHost es un host del escaneo con sus puertos abiertos
+type scanHost struct {
+	Address   string
+	Hostnames []string
+	OS        string
+	Ports     []scanPort
+}
+
+// scanPort es un puerto abierto con el servicio detectado
+type scanPort struct {
+	Port     int
+	Protocol string
+	Service  string // nombre, producto y versión
+	Scripts  []string
+}
+
+// Estructura del XML de Nmap (-oX); solo los campos que se resumen
+type nmapRun struct {
+	Hosts []struct {
+		Status struct {
+			State string `xml:"state,attr"`
+		} `xml:"status"`
+		Addresses []struct {
+			Addr string `xml:"addr,attr"`
+			Type string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"hostnames>hostname"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   int    `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+			Service struct {
+				Name      string `xml:"name,attr"`
+				Product   string `xml:"product,attr"`
+				Version   string `xml:"version,attr"`
+				ExtraInfo string `xml:"extrainfo,attr"`
+				Tunnel    string `xml:"tunnel,attr"`
+			} `xml:"service"`
+			Scripts []struct {
+				ID     string `xml:"id,attr"`
+				Output string `xml:"output,attr"`
+			} `xml:"script"`
+		} `xml:"ports>port"`
+		OSMatches []struct {
+			Name     string `xml:"name,attr"`
+			Accuracy string `xml:"accuracy,attr"`
+		} `xml:"os>osmatch"`
+	} `xml:"host"`
+}
+
+// parseNmapXML lee los hosts activos y sus puertos abiertos
+func parseNmapXML(data []byte) ([]*scanHost, error) {
+	var run nmapRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("XML de Nmap no válido: %v", err)
+	}
+	var hosts []*scanHost
+	for _, h := range run.Hosts {
+		if h.Status.State != "" && h.Status.State != "up" {
+			continue
+		}
+		host := &scanHost{}
+		for _, a := range h.Addresses {
+			if a.Type != "mac" && host.Address == "" {
+				host.Address = a.Addr
+			}
+		}
+		for _, n := range h.Hostnames {
+			host.Hostnames = append(host.Hostnames, n.Name)
+		}
+		if len(h.OSMatches) > 0 {
+			host.OS = fmt.Sprintf("%s (%s%%)", h.OSMatches[0].Name, h.OSMatches[0].Accuracy)
+		}
+		for _, p := range h.Ports {
+			if p.State.State != "open" {
+				continue
+			}
+			s := p.Service
+			name := s.Name
+			if s.Tunnel != "" {
+				name = s.Tunnel + "/" + name
+			}
+			service := strings.Join(strings.Fields(strings.Join([]string{name, s.Product, s.Version, parenthesize(s.ExtraInfo)}, " ")), " ")
+			port := scanPort{Port: p.PortID, Protocol: p.Protocol, Service: service}
+			for _, sc := range p.Scripts {
+				out := strings.Join(strings.Fields(sc.Output), " ")
+				if len(out) > maxScriptOutput {
+					out = out[:maxScriptOutput] + "..."
+				}
+				port.Scripts = append(port.Scripts, sc.ID+": "+out)
+			}
+			host.Ports = append(host.Ports, port)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+func parenthesize(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "(" + s + ")"
+}
+
+// trailingCommaRe elimina la coma final antes de ] que dejan algunas
+// versiones de masscan
+var trailingCommaRe = regexp.MustCompile(`,\s*\]\s*$`)
+
+// parseMasscanJSON lee la salida de masscan -oJ
+func parseMasscanJSON(data []byte) ([]*scanHost, error) {
+	data = trailingCommaRe.ReplaceAll(bytes.TrimSpace(data), []byte("]"))
+	var records []struct {
+		IP    string `json:"ip"`
+		Ports []struct {
+			Port    int    `json:"port"`
+			Proto   string `json:"proto"`
+			Status  string `json:"status"`
+			Service struct {
+				Name   string `json:"name"`
+				Banner string `json:"banner"`
+			} `json:"service"`
+		} `json:"ports"`
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("JSON de masscan no válido: %v", err)
+	}
+	byIP := map[string]*scanHost{}
+	var hosts []*scanHost
+	for _, r := range records {
+		host := byIP[r.IP]
+		if host == nil {
+			host = &scanHost{Address: r.IP}
+			byIP[r.IP] = host
+			hosts = append(hosts, host)
+		}
+		for _, p := range r.Ports {
+			if p.Status != "" && p.Status != "open" {
+				continue
+			}
+			service := strings.TrimSpace(p.Service.Name + " " + strings.Join(strings.Fields(p.Service.Banner), " "))
+			host.Ports = append(host.Ports, scanPort{Port: p.Port, Protocol: p.Proto, Service: service})
+		}
+	}
+	return hosts, nil
+}
+
+// parseNaabuJSON lee la salida de naabu -json (un objeto por línea); el
+// puerto es un número o, en versiones recientes, un objeto
+func parseNaabuJSON(data []byte) ([]*scanHost, error) {
+	byHost := map[string]*scanHost{}
+	var hosts []*scanHost
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var r struct {
+			Host     string          `json:"host"`
+			IP       string          `json:"ip"`
+			Port     json.RawMessage `json:"port"`
+			Protocol string          `json:"protocol"`
+		}
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil, fmt.Errorf("JSON de naabu no válido en la línea %d: %v", n, err)
+		}
+		port := scanPort{Protocol: r.Protocol}
+		if err := json.Unmarshal(r.Port, &port.Port); err != nil {
+			var obj struct {
+				Port     int    `json:"Port"`
+				Protocol string `json:"Protocol"`
+			}
+			json.Unmarshal(r.Port, &obj)
+			port.Port = obj.Port
+			if port.Protocol == "" {
+				port.Protocol = strings.ToLower(obj.Protocol)
+			}
+		}
+		if port.Protocol == "" {
+			port.Protocol = "tcp"
+		}
+		address := r.IP
+		if address == "" {
+			address = r.Host
+		}
+		host := byHost[address]
+		if host == nil {
+			host = &scanHost{Address: address}
+			if r.Host != "" && r.Host != address {
+				host.Hostnames = []string{r.Host}
+			}
+			byHost[address] = host
+			hosts = append(hosts, host)
+		}
+		host.Ports = append(host.Ports, port)
+	}
+	return hosts, scanner.Err()
+}
+
+// parseScan detecta el formato (XML de Nmap, JSON de masscan o JSON lines
+// de naabu) y lee el escaneo
+func parseScan(data []byte) ([]*scanHost, string, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		hosts, err := parseNmapXML(trimmed)
+		return hosts, "Nmap", err
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		hosts, err := parseMasscanJSON(trimmed)
+		return hosts, "masscan", err
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		hosts, err := parseNaabuJSON(trimmed)
+		return hosts, "naabu", err
+	}
+	return nil, "", fmt.Errorf("formato de escaneo no reconocido (se admite XML de Nmap, JSON de masscan y JSON de naabu)")
+}
+
+// scanSummary resume los hosts y servicios: una sección por host y un
+// recuento de servicios
+func scanSummary(hosts []*scanHost) string {
+	sort.SliceStable(hosts, func(i, j int) bool { return hosts[i].Address < hosts[j].Address })
+	var sb strings.Builder
+	services := map[string]int{}
+	openPorts := 0
+	for _, h := range hosts {
+		sort.Slice(h.Ports, func(i, j int) bool { return h.Ports[i].Port < h.Ports[j].Port })
+		fmt.Fprintf(&sb, "\n%s", h.Address)
+		if len(h.Hostnames) > 0 {
+			fmt.Fprintf(&sb, " (%s)", strings.Join(h.Hostnames, ", "))
+		}
+		if h.OS != "" {
+			fmt.Fprintf(&sb, " — SO: %s", h.OS)
+		}
+		sb.WriteString("\n")
+		if len(h.Ports) == 0 {
+			sb.WriteString("  sin puertos abiertos\n")
+		}
+		for _, p := range h.Ports {
+			service := p.Service
+			if service == "" {
+				service = "?"
+			}
+			fmt.Fprintf(&sb, "  %d/%s  %s\n", p.Port, p.Protocol, service)
+			for _, s := range p.Scripts {
+				fmt.Fprintf(&sb, "    %s\n", s)
+			}
+			if p.Service != "" {
+				services[strings.Fields(p.Service)[0]]++
+			}
+			openPorts++
+		}
+	}
+
+	var names []string
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if services[names[i]] != services[names[j]] {
+			return services[names[i]] > services[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	var counts []string
+	for _, name := range names {
+		counts = append(counts, name+" ×"+strconv.Itoa(services[name]))
+	}
+	header := fmt.Sprintf("%d hosts, %d puertos abiertos", len(hosts), openPorts)
+	if len(counts) > 0 {
+		header += "\nServicios: " + strings.Join(counts, ", ")
+	}
+	return header + "\n" + sb.String()
+}
+
+func runScanReport(args []string) {
+	fs := flag.NewFlagSet("scan-report", flag.ExitOnError)
+	var files stringList
+	fs.Var(&files, "f", "Archivo del escaneo (repetible)")
+	fs.Var(&files, "file", "Archivo del escaneo (repetible)")
+	instruction := fs.String("i", "", "Instrucciones adicionales para el análisis")
+	fs.StringVar(instruction, "instruction", "", "Instrucciones adicionales para el análisis")
+	summaryOnly := fs.Bool("summary-only", false, "Mostrar solo el resumen, sin consultar al modelo")
+	addModelFlags(fs, 0.3)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s scan-report -f <escaneo> [opciones]
+
+Resume los hosts y servicios de un escaneo de puertos y pide al modelo los
+siguientes pasos priorizados para cada servicio; el informe incluye el
+resumen y el análisis. Admite el XML de Nmap
+(-oX), el JSON de masscan (-oJ) y el JSON de naabu (-json); el formato se
+detecta automáticamente. Sin -f el escaneo se lee de stdin.
+
+Opciones:
+  -f, --file <archivo>   Archivo del escaneo (repetible)
+  -i, --instruction      Instrucciones adicionales (p. ej. "solo servicios web")
+  --summary-only         Mostrar solo el resumen, sin consultar al modelo
+  -t, --temperature      Temperatura (default: 0.3)
+  -m, --maxtokens        Máximo de tokens a generar
+  -v, --verbose          Mostrar logs detallados
+
+Ejemplos:
+  %s scan-report -f scan.xml
+  nmap -sV -oX - 10.0.0.0/24 | %s scan-report -i "prioriza servicios expuestos a Internet"
+`, os.Args[0], os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var inputs [][]byte
+	if len(files) == 0 {
+		if isTerminal(os.Stdin) {
+			fs.Usage()
+			os.Exit(1)
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al leer de stdin: %v\n", err)
+			os.Exit(1)
+		}
+		inputs = append(inputs, data)
+	}
+	for _, f := range files {
+		data, err := readContextFile(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		inputs = append(inputs, data)
+	}
+
+	var hosts []*scanHost
+	var formats []string
+	for i, data := range inputs {
+		h, format, err := parseScan(data)
+		if err != nil {
+			name := "stdin"
+			if len(files) > 0 {
+				name = files[i]
+			}
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		hosts = append(hosts, h...)
+		formats = append(formats, format)
+	}
+	summary := scanSummary(hosts)
+	if *summaryOnly {
+		fmt.Print(summary)
+		return
+	}
+	setupSubcommand()
+
+	tokens := estimateTokens(summary)
+	if budget := contextWindow(model) - maxTokens - contextMargin; tokens > budget {
+		statusf("Advertencia: el resumen del escaneo no cabe en la ventana del modelo; se ha recortado\n")
+		summary = truncateContext(summary, budget*4, tokens-budget)
+	}
+	prompt := fmt.Sprintf("Escaneo (%s):\n%s", strings.Join(formats, ", "), summary)
+	if *instruction != "" {
+		prompt += "\nInstrucciones adicionales: " + *instruction
+	}
+	statusf("Analizando %d hosts...\n", len(hosts))
+	response, _, err := complete(newRequestBody([]Message{
+		{Role: "system", Content: scanSystemPrompt},
+		{Role: "user", Content: prompt},
+	}))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	report := "# Escaneo\n\n```\n" + strings.TrimRight(summary, "\n") + "\n```\n\n" + response
+	if isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" {
+		report = renderMarkdown(report)
+	}
+	fmt.Println(report)
+}
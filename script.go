@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scriptFile (--script) es un guion de conversación: los turnos se envían
+// en orden como una conversación real de varios turnos
+var scriptFile string
+
+// Script es un guion de conversación
+type Script struct {
+	Name      string       `yaml:"name"`
+	System    string       `yaml:"system"`
+	Input     string       `yaml:"input"`
+	InputFile string       `yaml:"input_file"`
+	Turns     []ScriptTurn `yaml:"turns"`
+}
+
+// ScriptTurn es un turno del guion: una instrucción del usuario, con las
+// aserciones opcionales sobre la respuesta, o una respuesta fija del
+// asistente que se inserta en la conversación sin pedirla al modelo
+type ScriptTurn struct {
+	User       string      `yaml:"user"`
+	Assistant  string      `yaml:"assistant"`
+	Assertions []Assertion `yaml:"assert"`
+}
+
+func loadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer el guion: %v", err)
+	}
+	var script Script
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("guion inválido: %v", err)
+	}
+	if len(script.Turns) == 0 {
+		return nil, fmt.Errorf("el guion no define turnos")
+	}
+	if script.Name == "" {
+		script.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for i, t := range script.Turns {
+		switch {
+		case (t.User == "") == (t.Assistant == ""):
+			return nil, fmt.Errorf("turno %d: indica user o assistant, no ambos", i+1)
+		case t.Assistant != "" && len(t.Assertions) > 0:
+			return nil, fmt.Errorf("turno %d: las aserciones solo se aplican a los turnos user", i+1)
+		case t.Assistant != "" && (i == 0 || script.Turns[i-1].User == ""):
+			return nil, fmt.Errorf("turno %d: un turno assistant debe seguir a uno user", i+1)
+		}
+	}
+	return &script, nil
+}
+
+// scriptTranscript es la transcripción en Markdown de la conversación, con
+// el resultado de las aserciones de cada turno, para -o. El contexto de
+// input/input_file no se repite, solo se menciona.
+func scriptTranscript(script *Script, modelName string, turns []Message, failures map[int][]string, fixed map[int]bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n_Guion %s · %s_\n\n", script.Name, scriptFile, providerModel(modelName))
+	if script.System != "" {
+		fmt.Fprintf(&sb, "## system\n\n%s\n\n", strings.TrimSpace(script.System))
+	}
+	if script.InputFile != "" {
+		fmt.Fprintf(&sb, "_Contexto: %s_\n\n", script.InputFile)
+	} else if script.Input != "" {
+		sb.WriteString("_Contexto: input del guion_\n\n")
+	}
+	turn := 0
+	for _, m := range turns {
+		role := m.Role
+		if role == "assistant" {
+			turn++
+			if fixed[turn] {
+				role += " (respuesta fija del guion)"
+			}
+		}
+		fmt.Fprintf(&sb, "## %s\n\n%s\n\n", role, strings.TrimSpace(m.Content))
+		if m.Role != "assistant" {
+			continue
+		}
+		for _, f := range failures[turn] {
+			fmt.Fprintf(&sb, "> FAIL: %s\n", f)
+		}
+		if len(failures[turn]) > 0 {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// runScript ejecuta el guion como una conversación: cada turno user se
+// envía con las respuestas anteriores, se muestra y se comprueba con sus
+// aserciones. Termina con error si alguna falla.
+func runScript(path, outputFile string) {
+	script, err := loadScript(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	baseDir := filepath.Dir(path)
+
+	input := script.Input
+	if script.InputFile != "" {
+		data, err := readContextFile(filepath.Join(baseDir, script.InputFile))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: no se pudo leer input_file: %v\n", err)
+			os.Exit(1)
+		}
+		input += labelFile(script.InputFile, string(data))
+	}
+
+	// El primer turno user lleva el contexto, como una consulta normal; el
+	// sistema del guion sustituye al predeterminado
+	var messages []Message
+	if script.System != "" {
+		messages = append(messages, Message{Role: "system", Content: script.System})
+	}
+	if input != "" {
+		first := buildMessages(script.Turns[0].User, input, "")
+		first = first[:len(first)-1]
+		if script.System != "" && len(first) > 0 && first[0].Role == "system" {
+			first = first[1:]
+		}
+		messages = append(messages, first...)
+	}
+
+	prefix := len(messages)
+	requestBody := newRequestBody(nil)
+	failures := map[int][]string{}
+	fixed := map[int]bool{}
+	asserted, failed, turn := 0, 0, 0
+	for i, t := range script.Turns {
+		if t.Assistant != "" {
+			// Respuesta fija: sustituye a la que acaba de dar el modelo
+			messages[len(messages)-1].Content = t.Assistant
+			fixed[turn] = true
+			fmt.Printf("(respuesta fija del guion)\n%s\n\n", strings.TrimSpace(t.Assistant))
+			continue
+		}
+		turn++
+		messages = append(messages, Message{Role: "user", Content: t.User})
+		fmt.Printf("> %s\n\n", strings.ReplaceAll(strings.TrimSpace(t.User), "\n", "\n> "))
+		logger.Printf("Turno %d del guion (%d mensajes)\n", i+1, len(messages))
+
+		requestBody.Messages = messages
+		var output string
+		if streamOutput {
+			var result StreamResult
+			result, err = streamWithResume(requestBody, func(delta string) { fmt.Print(delta) }, nil)
+			output = result.Content
+			fmt.Println()
+		} else {
+			output, _, err = complete(requestBody)
+			fmt.Println(output)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error en el turno %d: %v\n", i+1, err)
+			os.Exit(1)
+		}
+		fmt.Println()
+		messages = append(messages, Message{Role: "assistant", Content: output})
+
+		for _, a := range t.Assertions {
+			asserted++
+			if msg := a.check(output, baseDir); msg != "" {
+				failed++
+				failures[turn] = append(failures[turn], msg)
+				fmt.Fprintf(os.Stderr, "FAIL  turno %d: %s\n", i+1, msg)
+			}
+		}
+	}
+
+	if asserted > 0 {
+		statusf("%d/%d aserciones correctas\n", asserted-failed, asserted)
+	}
+	if outputFile != "" {
+		saveOutput(outputFile, scriptTranscript(script, requestBody.Model, messages[prefix:], failures, fixed), requestBody, false)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed web/index.html
+var webIndex []byte
+
+// chatServer expone las sesiones del historial por HTTP y, con --web, una
+// interfaz de chat en el navegador
+type chatServer struct {
+	mu sync.Mutex
+	// Los mensajes a una misma sesión se procesan en orden y los de sesiones
+	// distintas en paralelo
+	sessionLocks map[string]*sync.Mutex
+}
+
+func (s *chatServer) sessionLock(id string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessionLocks == nil {
+		s.sessionLocks = map[string]*sync.Mutex{}
+	}
+	l, ok := s.sessionLocks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		s.sessionLocks[id] = l
+	}
+	return l
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// Cabecera con el token de la ejecución que deben enviar las llamadas a /api/
+const serveTokenHeader = "X-Deepcli-Token"
+
+// allowedHost indica si el Host de la solicitud es el servidor local: un
+// nombre de loopback en el puerto en el que escucha, o la dirección exacta
+// de --addr. Así una web cuyo dominio apunta a 127.0.0.1 (DNS rebinding)
+// no puede usar la API.
+func allowedHost(host, addr string) bool {
+	if strings.EqualFold(host, addr) {
+		return true
+	}
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return false
+	}
+	_, boundPort, _ := net.SplitHostPort(addr)
+	if port != boundPort {
+		return false
+	}
+	switch strings.ToLower(name) {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// sameOrigin rechaza solicitudes hechas desde otras webs abiertas en el
+// navegador (el servidor solo escucha en localhost, pero el navegador sí
+// puede alcanzarlo). Las solicitudes POST deben indicar su origen.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return r.Method == http.MethodGet || r.Method == http.MethodHead
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Scheme == "http" && u.Host == r.Host
+}
+
+// newServeToken genera el token de esta ejecución de serve
+func newServeToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *chatServer) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := listSessions()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	type summary struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+		Model string `json:"model"`
+	}
+	list := []summary{}
+	for _, session := range sessions {
+		list = append(list, summary{session.ID, session.Title, session.Model})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *chatServer) handleNewSession(w http.ResponseWriter, r *http.Request) {
+	session := newSession()
+	if err := session.Save(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, session)
+}
+
+func (s *chatServer) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	session, err := loadSession(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// handleSendMessage añade el mensaje del usuario, obtiene la respuesta del
+// modelo y devuelve la sesión actualizada
+func (s *chatServer) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Content) == "" {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("se requiere el campo content"))
+		return
+	}
+
+	session, err := loadSession(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	lock := s.sessionLock(session.ID)
+	lock.Lock()
+	defer lock.Unlock()
+	// Se vuelve a leer por si otro mensaje la modificó mientras se esperaba
+	if session, err = loadSession(session.ID); err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	session.Messages = append(session.Messages, Message{Role: "user", Content: body.Content})
+
+	requestBody := newRequestBody(session.Messages)
+	requestBody.Model = session.Model
+	requestBody.ctx = r.Context()
+	content, _, err := complete(requestBody)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, err)
+		return
+	}
+	session.Messages = append(session.Messages, Message{Role: "assistant", Content: content})
+	if err := session.Save(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// isLoopback indica si la dirección host:puerto es local
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8787", "Dirección local en la que escuchar")
+	web := fs.Bool("web", false, "Servir la interfaz web de chat")
+	addModelFlags(fs, defaultTemperature)
+	addNetworkFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s serve [--web] [--addr 127.0.0.1:8787]
+
+Servidor local que comparte las sesiones del historial con la CLI y la TUI.
+Con --web sirve además una interfaz de chat para el navegador.
+
+API:
+  GET  /api/sessions                 Listar sesiones
+  POST /api/sessions                 Crear una sesión
+  GET  /api/sessions/{id}            Ver una sesión
+  POST /api/sessions/{id}/messages   Enviar {"content": "..."} y responder
+
+Cada llamada a /api/ debe enviar en la cabecera X-Deepcli-Token el token
+que se muestra al arrancar (la interfaz web ya lo incluye). Solo se
+atienden solicitudes dirigidas a localhost, 127.0.0.1 o [::1] en el puerto
+de --addr, y las POST deben venir del mismo origen.
+
+Los mensajes a sesiones distintas se atienden en paralelo; si llegan a la
+vez solicitudes idénticas (misma conversación y opciones), se hace una sola
+llamada a la API y todas reciben su respuesta. Con --confirm-cost,
+presupuestos o registro de auditoría cada solicitud se envía por separado.
+
+Opciones:
+  --web                   Servir la interfaz web de chat
+  --addr <host:puerto>    Dirección local (default: 127.0.0.1:8787); solo se
+                          admiten direcciones de loopback
+  -t, --temperature       Temperatura (default: 0.7)
+  -m, --maxtokens         Máximo de tokens por respuesta (default: 2048)
+  --resolve <host:ip>     Fijar la IP de un host de la API (repetible)
+  --dns <ip[:puerto]>     Servidor DNS para resolver la API
+  --happy-eyeballs-delay <duración>
+                          Espera antes de probar la otra familia IP
+                          (default: 300ms; negativo: desactivar)
+  --tor [--tor-proxy <host:puerto>]
+                          Enviar el tráfico por Tor (SOCKS5)
+  -v, --verbose           Mostrar logs detallados
+
+La conexión con la API se abre al arrancar y se mantiene caliente.
+`, os.Args[0])
+	}
+	fs.Parse(args)
+
+	if !isLoopback(*addr) {
+		fmt.Fprintf(os.Stderr, "Error: la dirección %s no es local; el servidor solo escucha en localhost\n", *addr)
+		os.Exit(1)
+	}
+	setupSubcommand()
+	go keepConnectionWarm(time.Minute)
+
+	s := &chatServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/sessions", s.handleListSessions)
+	mux.HandleFunc("POST /api/sessions", s.handleNewSession)
+	mux.HandleFunc("GET /api/sessions/{id}", s.handleGetSession)
+	mux.HandleFunc("POST /api/sessions/{id}/messages", s.handleSendMessage)
+	token := newServeToken()
+	if *web {
+		page := bytes.ReplaceAll(webIndex, []byte("{{DEEPCLI_TOKEN}}"), []byte(token))
+		mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write(page)
+		})
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedHost(r.Host, *addr) {
+			writeJSONError(w, http.StatusForbidden, fmt.Errorf("host no permitido"))
+			return
+		}
+		if !sameOrigin(r) {
+			writeJSONError(w, http.StatusForbidden, fmt.Errorf("origen no permitido"))
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/") && subtle.ConstantTimeCompare([]byte(r.Header.Get(serveTokenHeader)), []byte(token)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, fmt.Errorf("falta el token de la API (cabecera %s) o no es válido", serveTokenHeader))
+			return
+		}
+		logger.Printf("%s %s\n", r.Method, r.URL.Path)
+		mux.ServeHTTP(w, r)
+	})
+
+	if *web {
+		statusf("Interfaz web disponible en http://%s/\n", *addr)
+	} else {
+		statusf("API de sesiones escuchando en http://%s/api/\n", *addr)
+	}
+	// El token se escribe siempre, aunque se use -q: sin él no hay API
+	fmt.Fprintf(os.Stderr, "Token de la API (cabecera %s): %s\n", serveTokenHeader, token)
+	if err := http.ListenAndServe(*addr, handler); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
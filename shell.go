@@ -0,0 +1,268 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Máximo de stderr capturado que se envía al modelo (se conserva el final)
+const maxCapturedStderr = 16 * 1024
+
+// stateDir es el directorio de estado ($XDG_STATE_HOME/deepcli o
+// ~/.local/state/deepcli) donde los hooks del shell guardan el último comando
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "deepcli")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "deepcli")
+	}
+	return filepath.Join(home, ".local", "state", "deepcli")
+}
+
+// shellQuote entrecomilla un valor para sh, bash, zsh y fish
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Los hooks escriben en last_command el código de salida, el directorio y
+// el comando (una línea cada uno, el comando puede ocupar varias). Con
+// captura de stderr, la salida de error del shell se duplica con tee en
+// stderr.live, que se vacía antes de cada comando y se copia a last_stderr
+// al terminar. Las llamadas a "<bin> wtf" no se registran.
+
+const bashHook = `# Integración de deepcli con bash: eval "$(%[1]s shell-init bash)"
+__deepcli_dir=%[2]s
+mkdir -p "$__deepcli_dir"
+__deepcli_precmd() {
+    local st=$? cmd
+    cmd=$(HISTTIMEFORMAT= history 1 | sed 's/^ *[0-9]*[* ] *//')
+    case "$cmd" in ""|%[3]s\ wtf*) return $st ;; esac
+    { printf '%%s\n%%s\n' "$st" "$PWD"; printf '%%s' "$cmd"; } > "$__deepcli_dir/last_command"
+    [ -n "$__deepcli_capture" ] && cp "$__deepcli_dir/stderr.live" "$__deepcli_dir/last_stderr" 2>/dev/null
+    return $st
+}
+PROMPT_COMMAND="__deepcli_precmd${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+`
+
+const bashCapture = `__deepcli_capture=1
+rm -f "$__deepcli_dir/last_stderr"
+exec 2> >(tee -a "$__deepcli_dir/stderr.live" >&2)
+PS0="${PS0}"'$(: > "$__deepcli_dir/stderr.live")'
+`
+
+const zshHook = `# Integración de deepcli con zsh: eval "$(%[1]s shell-init zsh)"
+__deepcli_dir=%[2]s
+mkdir -p "$__deepcli_dir"
+__deepcli_preexec() {
+    __deepcli_cmd=$1
+    [[ -n $__deepcli_capture ]] && : > "$__deepcli_dir/stderr.live"
+}
+__deepcli_precmd() {
+    local st=$?
+    [[ -z $__deepcli_cmd ]] && return
+    case $__deepcli_cmd in %[3]s\ wtf*) __deepcli_cmd=; return ;; esac
+    { printf '%%s\n%%s\n' "$st" "$PWD"; printf '%%s' "$__deepcli_cmd"; } > "$__deepcli_dir/last_command"
+    [[ -n $__deepcli_capture ]] && cp "$__deepcli_dir/stderr.live" "$__deepcli_dir/last_stderr" 2>/dev/null
+    __deepcli_cmd=
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook preexec __deepcli_preexec
+add-zsh-hook precmd __deepcli_precmd
+`
+
+const zshCapture = `__deepcli_capture=1
+rm -f "$__deepcli_dir/last_stderr"
+exec 2> >(tee -a "$__deepcli_dir/stderr.live" >&2)
+`
+
+const fishHook = `# Integración de deepcli con fish: %[1]s shell-init fish | source
+set -g __deepcli_dir %[2]s
+mkdir -p $__deepcli_dir
+function __deepcli_postexec --on-event fish_postexec
+    set -l st $status
+    string match -q -- '' $argv[1]; and return
+    string match -q -- '%[3]s wtf*' $argv[1]; and return
+    printf '%%s\n%%s\n%%s' $st $PWD $argv[1] > $__deepcli_dir/last_command
+end
+`
+
+// shellInitScript genera el hook para el shell indicado
+func shellInitScript(shell string, capture bool) (string, error) {
+	bin := filepath.Base(os.Args[0])
+	dir := shellQuote(stateDir())
+	switch shell {
+	case "bash":
+		script := fmt.Sprintf(bashHook, bin, dir, bin)
+		if capture {
+			script += bashCapture
+		}
+		return script, nil
+	case "zsh":
+		script := fmt.Sprintf(zshHook, bin, dir, bin)
+		if capture {
+			script += zshCapture
+		}
+		return script, nil
+	case "fish":
+		if capture {
+			return "", fmt.Errorf("fish no admite --capture-stderr")
+		}
+		return fmt.Sprintf(fishHook, bin, dir, bin), nil
+	}
+	return "", fmt.Errorf("shell no soportado %q (bash, zsh o fish)", shell)
+}
+
+func runShellInit(args []string) {
+	fs := flag.NewFlagSet("shell-init", flag.ExitOnError)
+	capture := fs.Bool("capture-stderr", false, "Capturar también la salida de error de los comandos")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %[1]s shell-init <bash|zsh|fish> [--capture-stderr]
+
+Imprime un hook para el shell que registra el último comando ejecutado y su
+código de salida, para que "%[1]s wtf" pueda explicarlo. Instalación:
+
+  bash (~/.bashrc):   eval "$(%[1]s shell-init bash)"
+  zsh (~/.zshrc):     eval "$(%[1]s shell-init zsh)"
+  fish (config.fish): %[1]s shell-init fish | source
+
+Opciones:
+  --capture-stderr   Duplicar la salida de error del shell en un archivo para
+                     enviarla también al modelo (bash y zsh). Los programas
+                     dejan de ver stderr como terminal, por lo que algunos
+                     desactivan colores o barras de progreso.
+`, os.Args[0])
+	}
+	// El shell puede ir antes o después de las opciones
+	var shell string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		shell, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if shell == "" && fs.NArg() == 1 {
+		shell = fs.Arg(0)
+	} else if shell == "" || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	script, err := shellInitScript(shell, *capture)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(script)
+}
+
+// LastCommand es el último comando registrado por el hook del shell
+type LastCommand struct {
+	Command  string
+	ExitCode int
+	Dir      string
+	Stderr   string
+}
+
+// loadLastCommand lee el último comando registrado por el hook
+func loadLastCommand() (*LastCommand, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir(), "last_command"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no hay ningún comando registrado; instala el hook con \"%s shell-init\"", os.Args[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(string(data), "\n", 3)
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("registro del último comando inválido")
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("registro del último comando inválido: %v", err)
+	}
+	last := &LastCommand{
+		Command:  strings.TrimSpace(parts[2]),
+		ExitCode: code,
+		Dir:      parts[1],
+	}
+
+	if data, err := os.ReadFile(filepath.Join(stateDir(), "last_stderr")); err == nil {
+		if len(data) > maxCapturedStderr {
+			data = data[len(data)-maxCapturedStderr:]
+		}
+		last.Stderr = strings.TrimSpace(string(data))
+	}
+	return last, nil
+}
+
+const wtfSystemPrompt = `Eres un experto en la línea de comandos. El usuario ejecutó un comando que falló. ` +
+	`Explica brevemente la causa más probable del error y propón la solución, ` +
+	`incluyendo el comando corregido en un bloque de código si procede.`
+
+// wtfPrompt describe el último comando para el modelo
+func wtfPrompt(last *LastCommand, question string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Comando:\n```\n%s\n```\n", last.Command)
+	fmt.Fprintf(&sb, "Código de salida: %d\n", last.ExitCode)
+	fmt.Fprintf(&sb, "Directorio: %s\n", last.Dir)
+	fmt.Fprintf(&sb, "Sistema: %s/%s, shell: %s\n", runtime.GOOS, runtime.GOARCH, filepath.Base(os.Getenv("SHELL")))
+	if last.Stderr != "" {
+		fmt.Fprintf(&sb, "Salida de error:\n```\n%s\n```\n", last.Stderr)
+	} else {
+		sb.WriteString("(No se capturó la salida de error)\n")
+	}
+	if question != "" {
+		fmt.Fprintf(&sb, "\n%s\n", question)
+	}
+	return sb.String()
+}
+
+func runWtf(args []string) {
+	fs := flag.NewFlagSet("wtf", flag.ExitOnError)
+	addModelFlags(fs, 0.3)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %[1]s wtf [pregunta adicional] [opciones]
+
+Explica por qué falló el último comando del shell y cómo arreglarlo. Requiere
+el hook de "%[1]s shell-init" (el comando, su código de salida y, con
+--capture-stderr, su salida de error).
+
+Opciones:
+  -t, --temperature   Temperatura (default: 0.3)
+  -m, --maxtokens     Máximo de tokens a generar
+  -v, --verbose       Mostrar logs detallados
+`, os.Args[0])
+	}
+	fs.Parse(args)
+
+	last, err := loadLastCommand()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	setupSubcommand()
+
+	statusf("Último comando: %s (código de salida %d)\n", last.Command, last.ExitCode)
+	if last.ExitCode == 0 {
+		statusf("Advertencia: el último comando terminó sin errores\n")
+	}
+
+	requestBody := newRequestBody([]Message{
+		{Role: "system", Content: wtfSystemPrompt},
+		{Role: "user", Content: wtfPrompt(last, strings.Join(fs.Args(), " "))},
+	})
+	output, _, err := complete(requestBody)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" {
+		output = renderMarkdown(output)
+	}
+	fmt.Println(output)
+}
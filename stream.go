@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxStreamResumes limita las reconexiones tras cortes del stream
+const maxStreamResumes = 3
+
+// errStreamInterrupted indica que el stream terminó sin el evento [DONE]
+var errStreamInterrupted = errors.New("el stream se interrumpió antes de terminar")
+
+// partialMarker marca en el archivo de -o una respuesta interrumpida
+const partialMarker = "[respuesta incompleta: interrumpida con Ctrl+C]"
+
+// errStreamCanceled indica que el usuario detuvo el stream (Ctrl+C); a
+// diferencia de un corte no se reanuda
+var errStreamCanceled = errors.New("interrumpido por el usuario")
+
+// untilPattern (--until) detiene el stream en cuanto una línea de la
+// respuesta coincide
+var untilPattern *regexp.Regexp
+
+// StreamResult es el resultado acumulado de una respuesta en streaming
+type StreamResult struct {
+	Content      string
+	FinishReason string
+	Usage        Usage
+}
+
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// streamCompletion envía la solicitud con stream=true y llama a onDelta con
+// cada fragmento de texto recibido. Si la conexión se corta, devuelve lo
+// recibido hasta entonces junto con errStreamInterrupted.
+func streamCompletion(endpoint string, requestBody RequestBody, onDelta func(string)) (result StreamResult, err error) {
+	requestBody.Stream = true
+
+	logger.Println("Enviando solicitud en streaming a la API...")
+	resp, err := doWithRetry(func() (*http.Request, error) {
+		req, err := newHTTPRequest(endpoint, requestBody)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		return req, nil
+	})
+	if err != nil {
+		if requestBody.ctx != nil && requestBody.ctx.Err() != nil {
+			return result, errStreamCanceled
+		}
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		finishTimings(resp)
+		auditResponse(resp, body, false)
+		return result, newAPIError(resp.StatusCode, resp.Header, body, requestBody)
+	}
+
+	// En la auditoría, la respuesta de un stream es el texto recibido; un
+	// stream cortado o cancelado se registra como error
+	var content strings.Builder
+	defer func() {
+		finishTimings(resp)
+		if err != nil {
+			auditFailure(resp.Request, resp.StatusCode, err)
+		} else {
+			auditResponse(resp, []byte(content.String()), true)
+		}
+		recordUsage(requestBody, result.Usage, content.String())
+	}()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	done := false
+	checked := 0 // inicio de la primera línea aún no comprobada con --until
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logger.Printf("Fragmento inválido ignorado: %s\n", data)
+			continue
+		}
+		if chunk.Error.Message != "" {
+			result.Content = content.String()
+			return result, fmt.Errorf("error de la API: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if delta := choice.Delta.Content; delta != "" {
+				prev := content.Len()
+				content.WriteString(delta)
+				if untilPattern != nil {
+					var cut int
+					if cut, checked = matchUntil(content.String(), checked); cut >= 0 {
+						// Se cierra la conexión sin esperar al resto de la respuesta
+						logger.Printf("--until coincide; se detiene el stream\n")
+						if onDelta != nil && cut > prev {
+							onDelta(delta[:cut-prev])
+						}
+						result.Content = content.String()[:cut]
+						result.FinishReason = "until"
+						return result, nil
+					}
+				}
+				if onDelta != nil {
+					onDelta(delta)
+				}
+			}
+			if choice.FinishReason != nil {
+				result.FinishReason = *choice.FinishReason
+			}
+		}
+	}
+
+	result.Content = content.String()
+	if !done {
+		if requestBody.ctx != nil && requestBody.ctx.Err() != nil {
+			return result, errStreamCanceled
+		}
+		if err := scanner.Err(); err != nil {
+			logger.Printf("Error leyendo el stream: %v\n", err)
+		}
+		return result, errStreamInterrupted
+	}
+	return result, nil
+}
+
+// matchUntil busca --until en las líneas completas a partir de from (inicio
+// de línea) y devuelve el final de la coincidencia, o -1, junto con el inicio
+// de la primera línea aún incompleta
+func matchUntil(content string, from int) (int, int) {
+	end := strings.LastIndex(content, "\n") + 1
+	if end <= from {
+		return -1, from
+	}
+	if loc := untilPattern.FindStringIndex(content[from:end]); loc != nil {
+		return from + loc[1], end
+	}
+	return -1, end
+}
+
+// streamWithResume transmite la respuesta y, si el stream se corta, vuelve a
+// conectar y continúa la generación enviando lo ya recibido como prefijo del
+// asistente (endpoint beta), de modo que no se pierde lo generado. onResume,
+// si no es nil, se llama antes de cada reconexión.
+func streamWithResume(requestBody RequestBody, onDelta func(string), onResume func(attempt int)) (StreamResult, error) {
+	endpoint := chatEndpoint(false)
+	messages := requestBody.Messages
+	initialPrefix := ""
+	if n := len(messages); n > 0 && messages[n-1].Prefix {
+		initialPrefix = messages[n-1].Content
+		messages = messages[:n-1]
+		endpoint = chatEndpoint(true)
+	}
+
+	var total StreamResult
+	var content strings.Builder
+	for attempt := 0; ; attempt++ {
+		body := requestBody
+		body.Messages = messages
+		if prefix := initialPrefix + content.String(); prefix != "" {
+			body.Messages = append(append([]Message{}, messages...), Message{
+				Role:    "assistant",
+				Content: prefix,
+				Prefix:  true,
+			})
+			endpoint = chatEndpoint(true)
+		}
+
+		result, err := streamCompletion(endpoint, body, func(delta string) {
+			content.WriteString(delta)
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		})
+		total.Usage.add(result.Usage)
+		total.FinishReason = result.FinishReason
+		total.Content = content.String()
+
+		if !errors.Is(err, errStreamInterrupted) || attempt >= maxStreamResumes {
+			return total, err
+		}
+		logger.Printf("Stream interrumpido tras %d caracteres; reanudando (%d/%d)\n", content.Len(), attempt+1, maxStreamResumes)
+		if onResume != nil {
+			onResume(attempt + 1)
+		}
+		time.Sleep(time.Duration(attempt+1) * 500 * time.Millisecond)
+	}
+}
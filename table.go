@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Valores distintos que se cuentan por columna y ejemplos de las de texto
+const (
+	maxDistinctValues = 10000
+	tableExamples     = 3
+	// Filas de la muestra si no se eligen con --rows
+	maxTableSample = 200
+)
+
+// Selectores de --rows y --columns para las tablas CSV/TSV
+var (
+	tableRows    string
+	tableColumns string
+)
+
+// Formatos de fecha que se reconocen al inferir los tipos
+var dateLayouts = []string{"2006-01-02", "02/01/2006", "01/02/2006", "2006/01/02"}
+var dateTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+// columnStats describe una columna de la tabla
+type columnStats struct {
+	Name     string
+	Type     string
+	Empty    int
+	distinct map[string]int
+	min, max string
+	minNum   float64
+	maxNum   float64
+	hasNum   bool
+}
+
+// inferType devuelve el tipo más específico que admite el valor
+func inferType(v string) string {
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return "entero"
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return "decimal"
+	}
+	switch strings.ToLower(v) {
+	case "true", "false", "yes", "no", "sí", "si":
+		return "booleano"
+	}
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, v); err == nil {
+			return "fecha"
+		}
+	}
+	for _, layout := range dateTimeLayouts {
+		if _, err := time.Parse(layout, v); err == nil {
+			return "fecha y hora"
+		}
+	}
+	return "texto"
+}
+
+// mergeTypes combina el tipo de la columna con el de un nuevo valor
+func mergeTypes(current, next string) string {
+	switch {
+	case current == "" || current == next:
+		return next
+	case (current == "entero" && next == "decimal") || (current == "decimal" && next == "entero"):
+		return "decimal"
+	case (current == "fecha" && next == "fecha y hora") || (current == "fecha y hora" && next == "fecha"):
+		return "fecha y hora"
+	}
+	return "texto"
+}
+
+func (c *columnStats) add(v string) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		c.Empty++
+		return
+	}
+	c.Type = mergeTypes(c.Type, inferType(v))
+	if len(c.distinct) < maxDistinctValues || c.distinct[v] > 0 {
+		c.distinct[v]++
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		if !c.hasNum || f < c.minNum {
+			c.minNum = f
+		}
+		if !c.hasNum || f > c.maxNum {
+			c.maxNum = f
+		}
+		c.hasNum = true
+	}
+	// Las fechas en formato ISO se ordenan como texto
+	if c.min == "" || v < c.min {
+		c.min = v
+	}
+	if v > c.max {
+		c.max = v
+	}
+}
+
+func (c *columnStats) String() string {
+	typ := c.Type
+	if typ == "" {
+		typ = "vacía"
+	}
+	s := fmt.Sprintf("- %s: %s, %d vacíos, ", c.Name, typ, c.Empty)
+	if len(c.distinct) >= maxDistinctValues {
+		s += fmt.Sprintf("más de %d valores distintos", maxDistinctValues)
+	} else {
+		s += fmt.Sprintf("%d valores distintos", len(c.distinct))
+	}
+	switch c.Type {
+	case "entero", "decimal":
+		s += fmt.Sprintf(", de %s a %s", strconv.FormatFloat(c.minNum, 'f', -1, 64), strconv.FormatFloat(c.maxNum, 'f', -1, 64))
+	case "fecha", "fecha y hora":
+		s += fmt.Sprintf(", de %s a %s", c.min, c.max)
+	case "texto", "booleano":
+		type count struct {
+			value string
+			n     int
+		}
+		var counts []count
+		for v, n := range c.distinct {
+			counts = append(counts, count{v, n})
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			if counts[i].n != counts[j].n {
+				return counts[i].n > counts[j].n
+			}
+			return counts[i].value < counts[j].value
+		})
+		var examples []string
+		for _, ct := range counts[:min(tableExamples, len(counts))] {
+			examples = append(examples, fmt.Sprintf("%q (%d)", clip(ct.value, 60), ct.n))
+		}
+		if len(examples) > 0 {
+			s += ", más frecuentes: " + strings.Join(examples, ", ")
+		}
+	}
+	return s
+}
+
+// parseRanges interpreta los selectores de --rows y --pages: números y
+// rangos (desde 1) separados por comas (1-100,250,900-)
+func parseRanges(flagName, spec string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(from)
+		if err != nil || a < 1 {
+			return nil, fmt.Errorf("%s: rango no válido %q", flagName, part)
+		}
+		b := a
+		if isRange {
+			if to == "" {
+				b = int(^uint(0) >> 1)
+			} else if b, err = strconv.Atoi(to); err != nil || b < a {
+				return nil, fmt.Errorf("%s: rango no válido %q", flagName, part)
+			}
+		}
+		ranges = append(ranges, [2]int{a, b})
+	}
+	return ranges, nil
+}
+
+func inRanges(n int, ranges [][2]int) bool {
+	for _, r := range ranges {
+		if n >= r[0] && n <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// selectColumns interpreta --columns: nombres o posiciones (desde 1)
+// separados por comas
+func selectColumns(spec string, header []string) ([]int, error) {
+	if spec == "" {
+		indexes := make([]int, len(header))
+		for i := range header {
+			indexes[i] = i
+		}
+		return indexes, nil
+	}
+	var indexes []int
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		found := -1
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				found = i
+				break
+			}
+		}
+		if n, err := strconv.Atoi(name); found < 0 && err == nil && n >= 1 && n <= len(header) {
+			found = n - 1
+		}
+		if found < 0 {
+			return nil, fmt.Errorf("--columns: la tabla no tiene la columna %q (columnas: %s)", name, strings.Join(header, ", "))
+		}
+		indexes = append(indexes, found)
+	}
+	return indexes, nil
+}
+
+// isTableFile indica si el archivo es una tabla CSV o TSV por su extensión
+func isTableFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".csv" || ext == ".tsv"
+}
+
+// summarizeTable convierte una tabla CSV/TSV en su esquema (tipos inferidos
+// y estadísticas por columna) y las filas seleccionadas con --rows o una
+// muestra espaciada uniformemente
+func summarizeTable(name, text string) (string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	format := "CSV"
+	if strings.EqualFold(filepath.Ext(name), ".tsv") {
+		reader.Comma = '\t'
+		format = "TSV"
+	} else if first, _, _ := strings.Cut(text, "\n"); strings.Count(first, ";") > strings.Count(first, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return "", fmt.Errorf("%s: la tabla está vacía", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	columns, err := selectColumns(tableColumns, header)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	var rowRanges [][2]int
+	if tableRows != "" {
+		if rowRanges, err = parseRanges("--rows", tableRows); err != nil {
+			return "", err
+		}
+	}
+
+	stats := make([]*columnStats, len(columns))
+	for i, c := range columns {
+		stats[i] = &columnStats{Name: header[c], distinct: map[string]int{}}
+	}
+	type row struct {
+		n      int
+		values []string
+	}
+	var rows []row
+	total := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", name, err)
+		}
+		total++
+		values := make([]string, len(columns))
+		for i, c := range columns {
+			if c < len(record) {
+				values[i] = record[c]
+			}
+			stats[i].add(values[i])
+		}
+		if rowRanges == nil || inRanges(total, rowRanges) {
+			rows = append(rows, row{total, values})
+		}
+	}
+
+	var schema strings.Builder
+	for _, s := range stats {
+		schema.WriteString(s.String() + "\n")
+	}
+	formatRows := func(selected []row) string {
+		var sb strings.Builder
+		w := csv.NewWriter(&sb)
+		w.Write(append([]string{"fila"}, func() []string {
+			names := make([]string, len(columns))
+			for i, c := range columns {
+				names[i] = header[c]
+			}
+			return names
+		}()...))
+		for _, r := range selected {
+			w.Write(append([]string{strconv.Itoa(r.n)}, r.values...))
+		}
+		w.Flush()
+		return sb.String()
+	}
+
+	// Sin --rows se envía una muestra espaciada uniformemente, que se reduce
+	// a la mitad hasta que quepa
+	sample := func(size int) []row {
+		picked := make([]row, size)
+		for i := range picked {
+			picked[i] = rows[i*len(rows)/size]
+		}
+		return picked
+	}
+	selected := rows
+	if rowRanges == nil && len(rows) > maxTableSample {
+		selected = sample(maxTableSample)
+	}
+	budget := inputTokenBudget() - estimateTokens(schema.String()) - 100
+	for len(selected) > 1 && estimateTokens(formatRows(selected)) > budget {
+		selected = sample(len(selected) / 2)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Tabla %s (%s, %d filas de datos, %d columnas", name, format, total, len(header))
+	if len(columns) < len(header) {
+		fmt.Fprintf(&sb, ", se muestran %d", len(columns))
+	}
+	sb.WriteString(")\n\nEsquema (tipos inferidos):\n" + schema.String())
+	switch {
+	case len(selected) < len(rows):
+		fmt.Fprintf(&sb, "\nMuestra de %d de %d filas, espaciadas uniformemente:\n", len(selected), len(rows))
+	case rowRanges != nil:
+		fmt.Fprintf(&sb, "\nFilas %s:\n", tableRows)
+	default:
+		sb.WriteString("\nFilas:\n")
+	}
+	sb.WriteString("```csv\n" + formatRows(selected) + "```")
+	logger.Printf("%s: tabla de %d filas, se envían %d\n", name, total, len(selected))
+	return sb.String(), nil
+}
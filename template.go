@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PromptTemplate es un prompt reutilizable; la cabecera YAML opcional fija
+// los parámetros de la llamada
+type PromptTemplate struct {
+	Name string `yaml:"-"`
+	Body string `yaml:"-"`
+
+	Description string                 `yaml:"description"`
+	Model       string                 `yaml:"model"`
+	Temperature *float64               `yaml:"temperature"`
+	MaxTokens   int                    `yaml:"max_tokens"`
+	Stop        []string               `yaml:"stop"`
+	Schema      map[string]interface{} `yaml:"schema"`
+}
+
+// configDir devuelve el directorio de configuración de deepcli
+// ($XDG_CONFIG_HOME/deepcli o ~/.config/deepcli)
+func configDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "deepcli")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "deepcli")
+	}
+	return filepath.Join(home, ".config", "deepcli")
+}
+
+func promptsDir() string {
+	return filepath.Join(configDir(), "prompts")
+}
+
+// findPromptTemplate busca la plantilla como ruta y, si no existe, por
+// nombre en la biblioteca de prompts
+func findPromptTemplate(name string) (string, error) {
+	if _, err := os.Stat(name); err == nil {
+		return name, nil
+	}
+	for _, ext := range []string{"", ".md", ".txt", ".tmpl"} {
+		path := filepath.Join(promptsDir(), name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no se encontró la plantilla %q (ni como archivo ni en %s)", name, promptsDir())
+}
+
+// loadPromptTemplate lee una plantilla y separa la cabecera YAML (entre
+// líneas "---") del texto del prompt
+func loadPromptTemplate(name string) (*PromptTemplate, error) {
+	path, err := findPromptTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer la plantilla %s: %v", path, err)
+	}
+
+	t := &PromptTemplate{Name: name}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if strings.HasPrefix(text, "---\n") {
+		rest := text[len("---\n"):]
+		end := strings.Index(rest, "\n---")
+		if end < 0 {
+			return nil, fmt.Errorf("plantilla %s: la cabecera YAML no está cerrada con ---", path)
+		}
+		if err := yaml.Unmarshal([]byte(rest[:end]), t); err != nil {
+			return nil, fmt.Errorf("plantilla %s: cabecera YAML inválida: %v", path, err)
+		}
+		text = rest[end+len("\n---"):]
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		} else {
+			text = ""
+		}
+	}
+	t.Body = strings.TrimSpace(text)
+
+	if t.Temperature != nil && (*t.Temperature < 0 || *t.Temperature > 2) {
+		return nil, fmt.Errorf("plantilla %s: temperature debe estar entre 0.0 y 2.0", path)
+	}
+	if t.MaxTokens < 0 {
+		return nil, fmt.Errorf("plantilla %s: max_tokens debe ser mayor que 0", path)
+	}
+	if t.Body == "" {
+		return nil, fmt.Errorf("plantilla %s: el prompt está vacío", path)
+	}
+	return t, nil
+}
+
+// apply construye el prompt final: el texto de la plantilla, la instrucción
+// adicional del usuario y, si hay esquema, la petición de JSON que lo cumpla
+func (t *PromptTemplate) apply(extra string) string {
+	prompt := t.Body
+	if extra != "" {
+		prompt += "\n\n" + extra
+	}
+	if t.Schema != nil {
+		schema, _ := json.MarshalIndent(t.Schema, "", "  ")
+		prompt += "\n\nResponde únicamente con un objeto JSON que cumpla este esquema:\n" + string(schema)
+	}
+	return prompt
+}
+
+// checkSchema valida una respuesta contra el esquema de la plantilla
+func (t *PromptTemplate) checkSchema(content string) error {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(extractJSON(content)), &doc); err != nil {
+		return fmt.Errorf("la respuesta no es JSON válido: %v", err)
+	}
+	return validateSchema(t.Schema, doc, "$")
+}
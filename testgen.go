@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Frameworks de test que admite testgen y cómo se describen al modelo
+var testFrameworks = map[string]string{
+	"std":     "solo el paquete testing de la biblioteca estándar (t.Errorf, t.Fatalf)",
+	"testify": "github.com/stretchr/testify (assert para las comprobaciones y require para los errores que impiden continuar)",
+}
+
+const testgenSystemPrompt = `Eres un experto escribiendo tests en Go. Recibirás un archivo de código y ` +
+	`el resumen de su paquete. Escribe un archivo _test.go completo que compile: del mismo paquete, con ` +
+	`todos los imports necesarios, un test por cada función o método relevante y tests de tabla ` +
+	`(casos con nombre ejecutados con t.Run). Cubre los casos normales, los límites y los errores. ` +
+	`Si un caso necesita datos que no puedes deducir, deja el caso con un comentario TODO en lugar de inventarlo. ` +
+	"Responde solo con el archivo dentro de un único bloque ```go."
+
+// testFilePath devuelve la ruta del archivo de tests de un archivo Go
+func testFilePath(source string) string {
+	return strings.TrimSuffix(source, ".go") + "_test.go"
+}
+
+// testgenPrompt adjunta el archivo y el resumen de su paquete
+func testgenPrompt(source, content, framework string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Framework: %s\n\n", testFrameworks[framework])
+	if sources, err := goPackageSources(filepath.Dir(source)); err == nil {
+		sb.WriteString(sources[0].Content + "\n\n")
+	} else {
+		logger.Printf("No se pudo resumir el paquete: %v\n", err)
+	}
+	sb.WriteString(labelFile(source, content))
+	return sb.String()
+}
+
+// extractTestFile obtiene el archivo de tests de la respuesta y le da formato;
+// el error de gofmt indica que el código no es Go válido
+func extractTestFile(response string) (string, error) {
+	code := response
+	if blocks := extractCodeBlocks(response); len(blocks) > 0 {
+		code = blocks[0]
+		for _, b := range blocks {
+			if strings.Contains(b, "package ") {
+				code = b
+				break
+			}
+		}
+	}
+	formatted, err := format.Source([]byte(code))
+	if err != nil {
+		return code, fmt.Errorf("el código generado no es Go válido: %v", err)
+	}
+	return string(formatted), nil
+}
+
+// vetPackage ejecuta go vet en el directorio del paquete, que también compila
+// los tests, y devuelve su salida si falla
+func vetPackage(dir string) (string, bool) {
+	cmd := exec.Command("go", "vet", ".")
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.String(), err == nil
+}
+
+func runTestgen(args []string) {
+	fs := flag.NewFlagSet("testgen", flag.ExitOnError)
+	var source string
+	fs.StringVar(&source, "f", "", "Archivo Go para el que generar tests")
+	fs.StringVar(&source, "file", "", "Archivo Go para el que generar tests")
+	framework := fs.String("framework", "std", "Framework de test: std o testify")
+	write := fs.Bool("write", false, "Escribir el archivo _test.go junto al código")
+	force := fs.Bool("force", false, "Sobrescribir el archivo _test.go si ya existe")
+	check := fs.Bool("check", false, "Comprobar con go vet y pedir correcciones hasta que compile")
+	maxIterations := fs.Int("n", 3, "Máximo de correcciones con --check")
+	fs.IntVar(maxIterations, "max-iterations", 3, "Máximo de correcciones con --check")
+	addModelFlags(fs, 0.2)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s testgen -f <archivo.go> [opciones]
+
+Genera un archivo de tests para el archivo Go: tests de tabla que compilan,
+del mismo paquete, con casos marcados como TODO donde faltan datos. Sin
+--write el archivo se muestra por la salida estándar.
+
+Opciones:
+  -f, --file <archivo>       Archivo Go para el que generar los tests
+  --framework <nombre>       std (paquete testing) o testify (default: std)
+  --write                    Escribir <archivo>_test.go junto al código
+  --force                    Sobrescribir el archivo de tests si ya existe
+  --check                    Con --write, ejecutar go vet en el paquete y
+                             pedir correcciones hasta que compile
+  -n, --max-iterations <n>   Máximo de correcciones con --check (default: 3)
+  -t, --temperature          Temperatura (default: 0.2)
+  -m, --maxtokens            Máximo de tokens a generar
+  -v, --verbose              Mostrar logs detallados
+
+Ejemplo:
+  %s testgen -f handler.go --framework testify --write --check
+`, os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+	if source == "" || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if !strings.HasSuffix(source, ".go") || strings.HasSuffix(source, "_test.go") {
+		fmt.Fprintf(os.Stderr, "Error: testgen necesita un archivo .go que no sea de tests\n")
+		os.Exit(1)
+	}
+	if _, ok := testFrameworks[*framework]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: framework desconocido %q (std o testify)\n", *framework)
+		os.Exit(1)
+	}
+	if *check && !*write {
+		fmt.Fprintf(os.Stderr, "Error: --check necesita --write\n")
+		os.Exit(1)
+	}
+	target := testFilePath(source)
+	if _, err := os.Stat(target); err == nil && *write && !*force {
+		fmt.Fprintf(os.Stderr, "Error: %s ya existe; usa --force para sobrescribirlo\n", target)
+		os.Exit(1)
+	}
+	setupSubcommand()
+
+	data, err := readContextFile(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	messages := []Message{
+		{Role: "system", Content: testgenSystemPrompt},
+		{Role: "user", Content: testgenPrompt(source, string(data), *framework)},
+	}
+
+	for i := 0; ; i++ {
+		statusf("Generando tests para %s...\n", source)
+		response, _, err := complete(newRequestBody(messages))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		messages = append(messages, Message{Role: "assistant", Content: response})
+
+		code, formatErr := extractTestFile(response)
+		if !*write {
+			if formatErr != nil {
+				statusf("Advertencia: %v\n", formatErr)
+			}
+			fmt.Print(code)
+			return
+		}
+		if err := os.WriteFile(target, []byte(code), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		statusf("Escrito %s\n", target)
+		if !*check {
+			if formatErr != nil {
+				statusf("Advertencia: %v\n", formatErr)
+			}
+			return
+		}
+
+		problem := ""
+		if formatErr != nil {
+			problem = formatErr.Error()
+		} else if out, ok := vetPackage(filepath.Dir(source)); !ok {
+			problem = "go vet falló:\n" + strings.TrimSpace(out)
+		}
+		if problem == "" {
+			statusf("go vet terminó correctamente\n")
+			return
+		}
+		if i >= *maxIterations {
+			fmt.Fprintf(os.Stderr, "Error: los tests siguen sin compilar tras %d correcciones:\n%s\n", *maxIterations, problem)
+			os.Exit(1)
+		}
+		statusf("Los tests no compilan; pidiendo una corrección (%d/%d)...\n", i+1, *maxIterations)
+		messages = append(messages, Message{
+			Role:    "user",
+			Content: fmt.Sprintf("El archivo no compila:\n```\n%s\n```\nDevuelve el archivo completo corregido.", problem),
+		})
+	}
+}
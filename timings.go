@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timings son los tiempos de una solicitud a la API, en milisegundos. DNS,
+// conexión y TLS son cero si se reutilizó una conexión abierta; el primer
+// byte y el total se miden desde el envío, así que la diferencia entre el
+// primer byte y la suma de los tres primeros es la espera del servidor (el
+// modelo).
+type Timings struct {
+	DNSMs      float64 `json:"dns_ms"`
+	ConnectMs  float64 `json:"connect_ms"`
+	TLSMs      float64 `json:"tls_ms"`
+	TTFBMs     float64 `json:"ttfb_ms"`
+	TotalMs    float64 `json:"total_ms"`
+	ReusedConn bool    `json:"reused_conn,omitempty"`
+}
+
+// requestTrace acumula los eventos de httptrace de una solicitud; los
+// callbacks pueden llegar desde otras goroutines del transporte
+type requestTrace struct {
+	mu                                    sync.Mutex
+	start, dnsStart, connStart, tlsStart  time.Time
+	dns, connect, tlsHandshake, firstByte time.Duration
+	reused                                bool
+}
+
+type traceKey struct{}
+
+// lastTimings son los tiempos de la última solicitud completada, para el
+// JSON de --json
+var lastTimings struct {
+	sync.Mutex
+	t *Timings
+}
+
+// traceRequest añade a la solicitud el seguimiento de tiempos
+func traceRequest(req *http.Request) *http.Request {
+	rt := &requestTrace{start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { rt.mark(&rt.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { rt.since(&rt.dns, rt.dnsStart) },
+		ConnectStart:      func(string, string) { rt.mark(&rt.connStart) },
+		ConnectDone:       func(string, string, error) { rt.since(&rt.connect, rt.connStart) },
+		TLSHandshakeStart: func() { rt.mark(&rt.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { rt.since(&rt.tlsHandshake, rt.tlsStart) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			rt.mu.Lock()
+			rt.reused = info.Reused
+			rt.mu.Unlock()
+		},
+		GotFirstResponseByte: func() { rt.since(&rt.firstByte, rt.start) },
+	}
+	ctx := context.WithValue(req.Context(), traceKey{}, rt)
+	return req.WithContext(httptrace.WithClientTrace(ctx, trace))
+}
+
+func (rt *requestTrace) mark(t *time.Time) {
+	rt.mu.Lock()
+	*t = time.Now()
+	rt.mu.Unlock()
+}
+
+func (rt *requestTrace) since(d *time.Duration, from time.Time) {
+	rt.mu.Lock()
+	if !from.IsZero() {
+		*d = time.Since(from)
+	}
+	rt.mu.Unlock()
+}
+
+// finishTimings cierra la medición de la solicitud de la respuesta, una vez
+// leído el cuerpo: la muestra con --verbose y la guarda para --json. Devuelve
+// nil si la solicitud no tenía seguimiento.
+func finishTimings(resp *http.Response) *Timings {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	rt, ok := resp.Request.Context().Value(traceKey{}).(*requestTrace)
+	if !ok {
+		return nil
+	}
+	ms := func(d time.Duration) float64 {
+		return math.Round(float64(d)/float64(time.Millisecond)*10) / 10
+	}
+	rt.mu.Lock()
+	t := &Timings{
+		DNSMs:      ms(rt.dns),
+		ConnectMs:  ms(rt.connect),
+		TLSMs:      ms(rt.tlsHandshake),
+		TTFBMs:     ms(rt.firstByte),
+		TotalMs:    ms(time.Since(rt.start)),
+		ReusedConn: rt.reused,
+	}
+	rt.mu.Unlock()
+
+	lastTimings.Lock()
+	lastTimings.t = t
+	lastTimings.Unlock()
+	if verbose {
+		logger.Printf("Tiempos: %s\n", t)
+	}
+	return t
+}
+
+// String resume los tiempos separando la red de la espera del servidor
+func (t *Timings) String() string {
+	var parts []string
+	if t.ReusedConn {
+		parts = append(parts, "conexión reutilizada")
+	} else {
+		parts = append(parts, fmt.Sprintf("DNS %.1fms", t.DNSMs), fmt.Sprintf("conexión %.1fms", t.ConnectMs))
+		if t.TLSMs > 0 {
+			parts = append(parts, fmt.Sprintf("TLS %.1fms", t.TLSMs))
+		}
+	}
+	network := t.DNSMs + t.ConnectMs + t.TLSMs
+	parts = append(parts,
+		fmt.Sprintf("primer byte %.1fms (espera del servidor %.1fms)", t.TTFBMs, math.Max(t.TTFBMs-network, 0)),
+		fmt.Sprintf("total %.1fms", t.TotalMs))
+	return strings.Join(parts, ", ")
+}
+
+// latestTimings devuelve los tiempos de la última solicitud, o nil
+func latestTimings() *Timings {
+	lastTimings.Lock()
+	defer lastTimings.Unlock()
+	return lastTimings.t
+}
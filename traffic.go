@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Tamaños máximos de los cuerpos que se prueban, de mayor a menor, hasta
+// que el resumen de la captura cabe en el presupuesto
+var trafficBodyLimits = []int{4096, 2048, 1024, 512, 256, 0}
+
+// Máximo de cada valor de cabecera
+const maxHeaderValue = 300
+
+// httpExchange es una solicitud con su respuesta, normalizada desde HAR o
+// desde el XML de Burp
+type httpExchange struct {
+	Method          string
+	URL             string
+	Status          int
+	RequestHeaders  [][2]string
+	RequestBody     string
+	ResponseHeaders [][2]string
+	ResponseBody    string
+	MimeType        string
+}
+
+// Cabeceras que se conservan: las relacionadas con la autenticación, las
+// sesiones y la seguridad; el resto se omite para ahorrar tokens
+var keptRequestHeaders = []string{"authorization", "cookie", "content-type", "origin", "x-api-key", "x-csrf-token", "x-xsrf-token"}
+var keptResponseHeaders = []string{"set-cookie", "content-type", "location", "www-authenticate", "access-control-allow-origin",
+	"access-control-allow-credentials", "content-security-policy", "strict-transport-security", "x-frame-options",
+	"x-content-type-options", "cache-control", "server", "x-powered-by"}
+
+func keepHeader(name string, kept []string) bool {
+	name = strings.ToLower(name)
+	for _, k := range kept {
+		if name == k {
+			return true
+		}
+	}
+	for _, s := range []string{"auth", "token", "session", "csrf", "api-key"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Estructura de un HAR; solo los campos que se resumen
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method   string `json:"method"`
+				URL      string `json:"url"`
+				Headers  []harHeader
+				PostData *struct {
+					Text string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Headers []harHeader
+				Content struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func parseHAR(text string) ([]httpExchange, error) {
+	var har harFile
+	if err := json.Unmarshal([]byte(text), &har); err != nil {
+		return nil, fmt.Errorf("HAR no válido: %v", err)
+	}
+	var exchanges []httpExchange
+	for _, e := range har.Log.Entries {
+		x := httpExchange{
+			Method:   e.Request.Method,
+			URL:      e.Request.URL,
+			Status:   e.Response.Status,
+			MimeType: e.Response.Content.MimeType,
+		}
+		for _, h := range e.Request.Headers {
+			x.RequestHeaders = append(x.RequestHeaders, [2]string{h.Name, h.Value})
+		}
+		for _, h := range e.Response.Headers {
+			x.ResponseHeaders = append(x.ResponseHeaders, [2]string{h.Name, h.Value})
+		}
+		if e.Request.PostData != nil {
+			x.RequestBody = e.Request.PostData.Text
+		}
+		x.ResponseBody = e.Response.Content.Text
+		if e.Response.Content.Encoding == "base64" {
+			if data, err := base64.StdEncoding.DecodeString(x.ResponseBody); err == nil {
+				x.ResponseBody = string(data)
+			}
+		}
+		exchanges = append(exchanges, x)
+	}
+	return exchanges, nil
+}
+
+// Estructura del XML que exporta Burp (Save items)
+type burpItems struct {
+	Items []struct {
+		URL      string      `xml:"url"`
+		Method   string      `xml:"method"`
+		Status   int         `xml:"status"`
+		MimeType string      `xml:"mimetype"`
+		Request  burpMessage `xml:"request"`
+		Response burpMessage `xml:"response"`
+	} `xml:"item"`
+}
+
+type burpMessage struct {
+	Base64 bool   `xml:"base64,attr"`
+	Data   string `xml:",chardata"`
+}
+
+// decode devuelve el mensaje HTTP en crudo
+func (m burpMessage) decode() string {
+	if m.Base64 {
+		if data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(m.Data)); err == nil {
+			return string(data)
+		}
+	}
+	return m.Data
+}
+
+// splitHTTPMessage separa las cabeceras y el cuerpo de un mensaje HTTP en
+// crudo; la primera línea (solicitud o estado) se descarta
+func splitHTTPMessage(raw string) ([][2]string, string) {
+	head, body, _ := strings.Cut(strings.ReplaceAll(raw, "\r\n", "\n"), "\n\n")
+	var headers [][2]string
+	scanner := bufio.NewScanner(strings.NewReader(head))
+	scanner.Scan()
+	for scanner.Scan() {
+		if name, value, ok := strings.Cut(scanner.Text(), ":"); ok {
+			headers = append(headers, [2]string{strings.TrimSpace(name), strings.TrimSpace(value)})
+		}
+	}
+	return headers, body
+}
+
+func parseBurpXML(text string) ([]httpExchange, error) {
+	var items burpItems
+	if err := xml.Unmarshal([]byte(text), &items); err != nil {
+		return nil, fmt.Errorf("XML de Burp no válido: %v", err)
+	}
+	var exchanges []httpExchange
+	for _, item := range items.Items {
+		x := httpExchange{Method: item.Method, URL: item.URL, Status: item.Status}
+		x.RequestHeaders, x.RequestBody = splitHTTPMessage(item.Request.decode())
+		x.ResponseHeaders, x.ResponseBody = splitHTTPMessage(item.Response.decode())
+		for _, h := range x.ResponseHeaders {
+			if strings.EqualFold(h[0], "Content-Type") {
+				x.MimeType = h[1]
+			}
+		}
+		exchanges = append(exchanges, x)
+	}
+	return exchanges, nil
+}
+
+// isStaticAsset indica si la respuesta es un recurso estático (imágenes,
+// hojas de estilo, scripts, fuentes), que se resume en una línea
+func isStaticAsset(x httpExchange) bool {
+	mime := strings.ToLower(x.MimeType)
+	for _, s := range []string{"image/", "font/", "text/css", "javascript", "video/", "audio/"} {
+		if strings.Contains(mime, s) {
+			return true
+		}
+	}
+	if u, err := url.Parse(x.URL); err == nil {
+		switch strings.ToLower(path.Ext(u.Path)) {
+		case ".png", ".jpg", ".jpeg", ".gif", ".svg", ".ico", ".webp", ".css", ".js", ".woff", ".woff2", ".ttf", ".map":
+			return true
+		}
+	}
+	return false
+}
+
+// clip acorta el texto a max bytes sin cortar un carácter
+func clip(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max] + fmt.Sprintf("... [%d bytes más]", len(s)-max)
+}
+
+// summarizeBody devuelve el cuerpo recortado, o una descripción si es binario
+func summarizeBody(body string, limit int) string {
+	body = strings.TrimSpace(body)
+	if body == "" || limit == 0 {
+		return ""
+	}
+	if !utf8.ValidString(body) || isBinary([]byte(body)) {
+		return fmt.Sprintf("[binario, %d bytes]", len(body))
+	}
+	return clip(body, limit)
+}
+
+// formatExchanges resume la captura con los cuerpos recortados a bodyLimit
+func formatExchanges(format string, exchanges []httpExchange, bodyLimit int) string {
+	hosts := map[string]bool{}
+	for _, x := range exchanges {
+		if u, err := url.Parse(x.URL); err == nil && u.Host != "" {
+			hosts[u.Host] = true
+		}
+	}
+	var hostList []string
+	for h := range hosts {
+		hostList = append(hostList, h)
+	}
+	sort.Strings(hostList)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Captura HTTP (%s, %d solicitudes; hosts: %s)", format, len(exchanges), strings.Join(hostList, ", "))
+	if bodyLimit < trafficBodyLimits[0] {
+		if bodyLimit == 0 {
+			sb.WriteString("; sin cuerpos para ajustarse al presupuesto")
+		} else {
+			fmt.Fprintf(&sb, "; cuerpos recortados a %d bytes", bodyLimit)
+		}
+	}
+	sb.WriteString(":\n")
+	writeHeaders := func(prefix string, headers [][2]string, kept []string) {
+		for _, h := range headers {
+			if keepHeader(h[0], kept) {
+				fmt.Fprintf(&sb, "  %s %s: %s\n", prefix, h[0], clip(h[1], maxHeaderValue))
+			}
+		}
+	}
+	writeBody := func(prefix, body string) {
+		if b := summarizeBody(body, bodyLimit); b != "" {
+			for _, line := range strings.Split(b, "\n") {
+				fmt.Fprintf(&sb, "  %s %s\n", prefix, line)
+			}
+		}
+	}
+	for i, x := range exchanges {
+		fmt.Fprintf(&sb, "\n%d. %s %s → %d", i+1, x.Method, x.URL, x.Status)
+		if x.MimeType != "" {
+			fmt.Fprintf(&sb, " (%s)", x.MimeType)
+		}
+		sb.WriteString("\n")
+		if isStaticAsset(x) {
+			continue
+		}
+		writeHeaders(">", x.RequestHeaders, keptRequestHeaders)
+		writeBody(">", x.RequestBody)
+		writeHeaders("<", x.ResponseHeaders, keptResponseHeaders)
+		writeBody("<", x.ResponseBody)
+	}
+	return sb.String()
+}
+
+// summarizeTraffic convierte un HAR o una exportación XML de Burp en un
+// resumen compacto de las solicitudes y respuestas que cabe en la ventana
+// del modelo; ok es false si el texto no es una captura HTTP
+func summarizeTraffic(name, text string) (string, bool, error) {
+	trimmed := strings.TrimSpace(text)
+	var exchanges []httpExchange
+	var format string
+	var err error
+	switch {
+	case strings.HasPrefix(trimmed, "{") && strings.Contains(trimmed[:min(len(trimmed), 200)], `"log"`):
+		format = "HAR"
+		exchanges, err = parseHAR(trimmed)
+	case strings.HasPrefix(trimmed, "<?xml") && strings.Contains(trimmed[:min(len(trimmed), 500)], "<items burpVersion"):
+		format = "Burp"
+		exchanges, err = parseBurpXML(trimmed)
+	default:
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %v", name, err)
+	}
+
+	budget := inputTokenBudget()
+	var summary string
+	for _, limit := range trafficBodyLimits {
+		summary = formatExchanges(format, exchanges, limit)
+		if estimateTokens(summary) <= budget {
+			break
+		}
+	}
+	logger.Printf("%s: captura %s con %d solicitudes resumida en ~%d tokens\n", name, format, len(exchanges), estimateTokens(summary))
+	return summary, true, nil
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const triageSystemPrompt = `Eres un experto clasificando issues de proyectos de software. Para cada ` +
+	`issue indica su tipo (bug, feature, question u other), su gravedad (critical, high, medium o low; ` +
+	`para lo que no es un bug, su importancia), si es un duplicado de otro issue de la lista de issues ` +
+	`recientes (su número, o 0 si no lo es) y un resumen de una frase. Responde solo con JSON: ` +
+	`{"issues": [{"number": 1, "type": "bug", "severity": "high", "duplicate_of": 0, "summary": "..."}]}.`
+
+// Máximo del cuerpo de cada issue que se envía al modelo
+const maxTriageBody = 2000
+
+// githubIssue es un issue de la API de GitHub; las pull requests también
+// aparecen en /issues y se distinguen por PullRequest
+type githubIssue struct {
+	Number      int       `json:"number"`
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	HTMLURL     string    `json:"html_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	PullRequest *struct{} `json:"pull_request"`
+	Labels      []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+// triagedIssue es la clasificación de un issue
+type triagedIssue struct {
+	Number      int    `json:"number"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Type        string `json:"type"`
+	Severity    string `json:"severity"`
+	DuplicateOf int    `json:"duplicate_of,omitempty"`
+	Summary     string `json:"summary"`
+}
+
+var severityOrder = map[string]int{"critical": 0, "high": 1, "medium": 2, "low": 3}
+
+// parseSince acepta una duración (7d, 2w, 12h) o una fecha (2024-05-01)
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	units := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if len(s) > 1 {
+		if unit, ok := units[s[len(s)-1]]; ok {
+			if n, err := strconv.Atoi(s[:len(s)-1]); err == nil && n > 0 {
+				return time.Now().Add(-time.Duration(n) * unit), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("--since espera una duración (7d, 2w, 12h) o una fecha (AAAA-MM-DD), no %q", s)
+}
+
+// fetchIssues descarga los issues del repositorio actualizados desde since,
+// sin las pull requests
+func fetchIssues(repo, state string, since time.Time, limit int) ([]githubIssue, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	var issues []githubIssue
+	for page := 1; len(issues) < limit; page++ {
+		endpoint := fmt.Sprintf("%s/repos/%s/issues?state=%s&since=%s&per_page=100&page=%d",
+			githubAPIURL(), repo, state, since.UTC().Format(time.RFC3339), page)
+		req, err := http.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo contactar con GitHub: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			var result struct {
+				Message string `json:"message"`
+			}
+			json.Unmarshal(body, &result)
+			return nil, fmt.Errorf("GitHub respondió %d: %s", resp.StatusCode, result.Message)
+		}
+		var batch []githubIssue
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, fmt.Errorf("respuesta inesperada de GitHub: %v", err)
+		}
+		for _, issue := range batch {
+			if issue.PullRequest == nil && len(issues) < limit {
+				issues = append(issues, issue)
+			}
+		}
+		if len(batch) < 100 {
+			break
+		}
+	}
+	return issues, nil
+}
+
+// triageBatch clasifica un lote de issues; index es la lista de todos los
+// issues recientes para detectar duplicados
+func triageBatch(batch []githubIssue, index string) ([]triagedIssue, error) {
+	var sb strings.Builder
+	sb.WriteString("Issues recientes (para detectar duplicados):\n" + index + "\nIssues a clasificar:\n")
+	for _, issue := range batch {
+		body := strings.TrimSpace(issue.Body)
+		if len(body) > maxTriageBody {
+			body = body[:maxTriageBody] + "\n[...]"
+		}
+		var labels []string
+		for _, l := range issue.Labels {
+			labels = append(labels, l.Name)
+		}
+		fmt.Fprintf(&sb, "\n### #%d %s\n", issue.Number, issue.Title)
+		if len(labels) > 0 {
+			fmt.Fprintf(&sb, "Etiquetas: %s\n", strings.Join(labels, ", "))
+		}
+		sb.WriteString(body + "\n")
+	}
+
+	requestBody := newRequestBody([]Message{
+		{Role: "system", Content: triageSystemPrompt},
+		{Role: "user", Content: sb.String()},
+	})
+	requestBody.ResponseFormat = &ResponseFormat{Type: "json_object"}
+	content, _, err := complete(requestBody)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Issues []triagedIssue `json:"issues"`
+	}
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return nil, fmt.Errorf("el modelo no devolvió JSON válido: %v", err)
+	}
+	return result.Issues, nil
+}
+
+// triageIssues clasifica los issues en lotes, en paralelo
+func triageIssues(issues []githubIssue, batchSize int) ([]triagedIssue, error) {
+	var index strings.Builder
+	byNumber := map[int]githubIssue{}
+	for _, issue := range issues {
+		fmt.Fprintf(&index, "#%d %s\n", issue.Number, issue.Title)
+		byNumber[issue.Number] = issue
+	}
+
+	var batches [][]githubIssue
+	for i := 0; i < len(issues); i += batchSize {
+		batches = append(batches, issues[i:min(i+batchSize, len(issues))])
+	}
+	results := make([][]triagedIssue, len(batches))
+	errs := make([]error, len(batches))
+	sem := make(chan struct{}, maxParallelChunks)
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch []githubIssue) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = triageBatch(batch, index.String())
+			logger.Printf("Lote %d/%d clasificado\n", i+1, len(batches))
+		}(i, batch)
+	}
+	wg.Wait()
+
+	var triaged []triagedIssue
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("lote %d: %v", i+1, err)
+		}
+		for _, t := range results[i] {
+			issue, ok := byNumber[t.Number]
+			if !ok {
+				continue
+			}
+			t.Title, t.URL = issue.Title, issue.HTMLURL
+			if _, ok := byNumber[t.DuplicateOf]; !ok || t.DuplicateOf == t.Number {
+				t.DuplicateOf = 0
+			}
+			triaged = append(triaged, t)
+		}
+	}
+	sort.SliceStable(triaged, func(i, j int) bool {
+		a, b := triaged[i], triaged[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return severityRank(a.Severity) < severityRank(b.Severity)
+	})
+	return triaged, nil
+}
+
+func severityRank(s string) int {
+	if r, ok := severityOrder[s]; ok {
+		return r
+	}
+	return len(severityOrder)
+}
+
+// triageMarkdown agrupa los issues por tipo, de mayor a menor gravedad
+func triageMarkdown(repo string, since time.Time, triaged []triagedIssue) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Triaje de %s desde el %s\n", repo, since.Format("2006-01-02"))
+	counts := map[string]int{}
+	for _, t := range triaged {
+		counts[t.Type]++
+	}
+	var types []string
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		fmt.Fprintf(&sb, "\n## %s (%d)\n\n| Issue | Gravedad | Resumen | Duplicado de |\n|---|---|---|---|\n", typ, counts[typ])
+		for _, t := range triaged {
+			if t.Type != typ {
+				continue
+			}
+			dup := ""
+			if t.DuplicateOf != 0 {
+				dup = fmt.Sprintf("#%d", t.DuplicateOf)
+			}
+			summary := strings.ReplaceAll(t.Summary, "|", `\|`)
+			fmt.Fprintf(&sb, "| [#%d](%s) | %s | %s | %s |\n", t.Number, t.URL, t.Severity, summary, dup)
+		}
+	}
+	return sb.String()
+}
+
+func runTriage(args []string) {
+	fs := flag.NewFlagSet("triage", flag.ExitOnError)
+	repo := fs.String("repo", "", "Repositorio de GitHub (org/nombre)")
+	sinceFlag := fs.String("since", "7d", "Issues actualizados desde (7d, 2w, 12h o AAAA-MM-DD)")
+	state := fs.String("state", "open", "Estado de los issues: open, closed o all")
+	limit := fs.Int("limit", 200, "Máximo de issues a clasificar")
+	batchSize := fs.Int("batch-size", 10, "Issues por solicitud al modelo")
+	format := fs.String("format", "markdown", "Formato del informe: markdown o json")
+	addModelFlags(fs, 0.0)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s triage --repo <org/nombre> [opciones]
+
+Descarga los issues recientes de un repositorio de GitHub y los clasifica
+por tipo (bug, feature, question), gravedad y duplicados. Los issues se
+envían al modelo en lotes, en paralelo; cada lote incluye la lista de
+títulos para detectar duplicados entre lotes.
+
+Usa GITHUB_TOKEN si está definida (necesaria para repositorios privados) y
+GITHUB_API_URL para GitHub Enterprise.
+
+Opciones:
+  --repo <org/nombre>    Repositorio de GitHub
+  --since <periodo>      Issues actualizados desde (7d, 2w, 12h o
+                         AAAA-MM-DD; default: 7d)
+  --state <estado>       open, closed o all (default: open)
+  --limit <n>            Máximo de issues (default: 200)
+  --batch-size <n>       Issues por solicitud (default: 10)
+  --format <formato>     markdown o json (default: markdown)
+  -t, --temperature      Temperatura (default: 0.0)
+  -m, --maxtokens        Máximo de tokens a generar
+  -v, --verbose          Mostrar logs detallados
+
+Ejemplo:
+  %s triage --repo org/nombre --since 7d > triaje.md
+`, os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+	if *repo == "" || strings.Count(*repo, "/") != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	since, err := parseSince(*sinceFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *format != "markdown" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: --format debe ser markdown o json\n")
+		os.Exit(1)
+	}
+	if *batchSize <= 0 || *limit <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --batch-size y --limit deben ser mayores que 0\n")
+		os.Exit(1)
+	}
+	setupSubcommand()
+
+	issues, err := fetchIssues(*repo, *state, since, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(issues) == 0 {
+		statusf("No hay issues actualizados desde el %s\n", since.Format("2006-01-02"))
+		return
+	}
+	statusf("Clasificando %d issues...\n", len(issues))
+	triaged, err := triageIssues(issues, *batchSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(triaged)
+		return
+	}
+	fmt.Print(triageMarkdown(*repo, since, triaged))
+}
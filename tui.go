@@ -0,0 +1,327 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	tuiUserStyle      = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	tuiAssistantStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("10"))
+	tuiSystemStyle    = lipgloss.NewStyle().Italic(true).Foreground(lipgloss.Color("8"))
+	tuiErrorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	tuiStatusStyle    = lipgloss.NewStyle().Reverse(true).Padding(0, 1)
+	tuiSelectedStyle  = lipgloss.NewStyle().Reverse(true)
+)
+
+// Mensajes internos de la TUI
+type (
+	tuiDeltaMsg string
+	tuiDoneMsg  struct {
+		result StreamResult
+		err    error
+	}
+)
+
+type tuiModel struct {
+	session  *Session
+	viewport viewport.Model
+	input    textarea.Model
+	width    int
+	height   int
+
+	// Respuesta en curso
+	streaming bool
+	pending   strings.Builder
+	events    chan tea.Msg
+	lastErr   string
+	tokens    int
+
+	// Selector de sesiones
+	switching bool
+	sessions  []*Session
+	cursor    int
+}
+
+func newTUIModel(session *Session) *tuiModel {
+	input := textarea.New()
+	input.Placeholder = "Escribe tu mensaje (Enter envía, Alt+Enter nueva línea)"
+	input.ShowLineNumbers = false
+	input.SetHeight(3)
+	input.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
+	input.Focus()
+
+	return &tuiModel{
+		session:  session,
+		input:    input,
+		viewport: viewport.New(80, 20),
+	}
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func waitTUIEvent(ch <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg { return <-ch }
+}
+
+// send inicia la respuesta en streaming al último mensaje del usuario
+func (m *tuiModel) send() tea.Cmd {
+	m.streaming = true
+	m.pending.Reset()
+	m.lastErr = ""
+	m.events = make(chan tea.Msg, 64)
+
+	requestBody := newRequestBody(m.session.Messages)
+	requestBody.Model = m.session.Model
+	ch := m.events
+	go func() {
+		result, err := streamWithResume(requestBody, func(delta string) {
+			ch <- tuiDeltaMsg(delta)
+		}, nil)
+		ch <- tuiDoneMsg{result: result, err: err}
+	}()
+	return waitTUIEvent(ch)
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.input.SetWidth(msg.Width)
+		m.viewport.Width = msg.Width
+		m.viewport.Height = msg.Height - m.input.Height() - 2
+		m.refresh()
+
+	case tea.KeyMsg:
+		if m.switching {
+			return m, m.updateSwitcher(msg)
+		}
+		switch msg.String() {
+		case "ctrl+c", "ctrl+d":
+			// Una respuesta a medias se guarda marcada como incompleta
+			if m.streaming && m.pending.Len() > 0 {
+				m.session.addPartial(m.pending.String())
+				m.session.Save()
+			}
+			return m, tea.Quit
+		case "ctrl+o":
+			if !m.streaming {
+				m.openSwitcher()
+			}
+			return m, nil
+		case "ctrl+n":
+			if !m.streaming {
+				m.session = newSession()
+				m.tokens = 0
+				m.refresh()
+			}
+			return m, nil
+		case "pgup", "pgdown":
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		case "enter":
+			text := strings.TrimSpace(m.input.Value())
+			if m.streaming || text == "" {
+				return m, nil
+			}
+			m.input.Reset()
+			m.session.Messages = append(m.session.Messages, Message{Role: "user", Content: text})
+			m.refresh()
+			return m, m.send()
+		}
+
+	case tea.MouseMsg:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd
+
+	case tuiDeltaMsg:
+		m.pending.WriteString(string(msg))
+		m.refresh()
+		return m, waitTUIEvent(m.events)
+
+	case tuiDoneMsg:
+		m.streaming = false
+		content := msg.result.Content
+		if content != "" {
+			m.session.Messages = append(m.session.Messages, Message{Role: "assistant", Content: content})
+		}
+		m.tokens += msg.result.Usage.TotalTokens
+		if msg.err != nil {
+			m.lastErr = msg.err.Error()
+		}
+		if err := m.session.Save(); err != nil {
+			m.lastErr = err.Error()
+		}
+		m.pending.Reset()
+		m.refresh()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	cmds = append(cmds, cmd)
+	return m, tea.Batch(cmds...)
+}
+
+// refresh vuelve a dibujar la conversación en el panel
+func (m *tuiModel) refresh() {
+	width := m.viewport.Width
+	if width <= 0 {
+		width = 80
+	}
+	wrap := lipgloss.NewStyle().Width(width)
+
+	var sb strings.Builder
+	for _, msg := range m.session.Messages {
+		sb.WriteString(m.renderMessage(msg.Role, msg.Content, wrap))
+	}
+	if m.streaming {
+		sb.WriteString(m.renderMessage("assistant", m.pending.String()+"▍", wrap))
+	}
+	if m.lastErr != "" {
+		sb.WriteString(tuiErrorStyle.Render("Error: "+m.lastErr) + "\n")
+	}
+	m.viewport.SetContent(sb.String())
+	m.viewport.GotoBottom()
+}
+
+func (m *tuiModel) renderMessage(role, content string, wrap lipgloss.Style) string {
+	var label string
+	switch role {
+	case "user":
+		label = tuiUserStyle.Render("Tú")
+	case "assistant":
+		label = tuiAssistantStyle.Render("DeepSeek")
+		content = renderMarkdownWidth(content, wrap.GetWidth())
+	default:
+		label = tuiSystemStyle.Render(role)
+	}
+	return label + "\n" + wrap.Render(content) + "\n\n"
+}
+
+func (m *tuiModel) openSwitcher() {
+	sessions, err := listSessions()
+	if err != nil {
+		m.lastErr = err.Error()
+		return
+	}
+	m.sessions = sessions
+	m.cursor = 0
+	m.switching = true
+}
+
+func (m *tuiModel) updateSwitcher(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit
+	case "esc", "ctrl+o":
+		m.switching = false
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.sessions)-1 {
+			m.cursor++
+		}
+	case "enter":
+		if len(m.sessions) > 0 {
+			m.session = m.sessions[m.cursor]
+			m.tokens = 0
+		}
+		m.switching = false
+		m.refresh()
+	}
+	return nil
+}
+
+func (m *tuiModel) statusBar() string {
+	state := "listo"
+	if m.streaming {
+		state = "generando…"
+	}
+	status := fmt.Sprintf("%s │ t=%.1f │ max %d │ %d tokens │ sesión %s │ %s │ ctrl+o sesiones · ctrl+n nueva · ctrl+c salir",
+		m.session.Model, temperature, maxTokens, m.tokens, m.session.ID, state)
+	return tuiStatusStyle.Width(m.width).MaxWidth(m.width).Render(status)
+}
+
+func (m *tuiModel) View() string {
+	if m.switching {
+		var sb strings.Builder
+		sb.WriteString(tuiAssistantStyle.Render("Sesiones (Enter abre, Esc cancela)") + "\n\n")
+		if len(m.sessions) == 0 {
+			sb.WriteString("No hay sesiones guardadas.\n")
+		}
+		for i, s := range m.sessions {
+			line := fmt.Sprintf("%s  %s  %s", s.ID, s.UpdatedAt.Format("2006-01-02 15:04"), s.Title)
+			if i == m.cursor {
+				line = tuiSelectedStyle.Render(line)
+			}
+			sb.WriteString(line + "\n")
+		}
+		return sb.String()
+	}
+	return m.viewport.View() + "\n" + m.statusBar() + "\n" + m.input.View()
+}
+
+func runTUI(args []string) {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	sessionID := fs.String("session", "", "Continuar una sesión guardada")
+	system := fs.String("system", "", "Mensaje de sistema para una sesión nueva")
+	addModelFlags(fs, defaultTemperature)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s tui [opciones]
+
+Interfaz de chat a pantalla completa: conversación con scroll, respuestas en
+streaming, barra de estado y selector de sesiones.
+
+Teclas:
+  Enter             Enviar el mensaje
+  Alt+Enter/Ctrl+J  Nueva línea
+  RePág/AvPág       Desplazar la conversación (también con la rueda)
+  Ctrl+O            Selector de sesiones
+  Ctrl+N            Nueva sesión
+  Ctrl+C            Salir
+
+Opciones:
+  --session <id>          Continuar una sesión guardada
+  --system <texto>        Mensaje de sistema para una sesión nueva
+  -t, --temperature       Temperatura (default: 0.7)
+  -m, --maxtokens         Máximo de tokens por respuesta (default: 2048)
+`, os.Args[0])
+	}
+	fs.Parse(args)
+	setupSubcommand()
+
+	session := newSession()
+	if *sessionID != "" {
+		var err error
+		session, err = loadSession(*sessionID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *system != "" {
+		session.Messages = append(session.Messages, Message{Role: "system", Content: *system})
+	}
+
+	program := tea.NewProgram(newTUIModel(session), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	if _, err := program.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
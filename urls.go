@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const urlsSystemPrompt = `Eres un analista que resume documentos web con precisión. Recibirás el ` +
+	`texto extraído de una página y una instrucción; respóndela usando solo la información de la página, ` +
+	`en Markdown, sin encabezado de título y sin inventar datos que no aparezcan en ella.`
+
+const urlsDigestPrompt = `Eres un analista que combina resúmenes de varias fuentes. Recibirás los ` +
+	`resúmenes numerados de cada URL y la instrucción original. Escribe un resumen conjunto en Markdown ` +
+	`que destaque los puntos comunes, las diferencias y las contradicciones entre fuentes, citando cada ` +
+	`afirmación con el número de su fuente entre corchetes ([1], [2]). No añadas una lista de fuentes.`
+
+const (
+	// Descargas simultáneas y tamaño máximo de cada página
+	maxParallelFetches = 8
+	maxPageBytes       = 10 << 20
+)
+
+// webPage es una URL descargada y resumida
+type webPage struct {
+	URL     string
+	Title   string
+	Text    string
+	Summary string
+	Err     error
+}
+
+// readURLList lee las URL del archivo ("-" para stdin), una por línea; se
+// ignoran las líneas vacías y los comentarios (#)
+func readURLList(file string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// fetchPage descarga la URL y la convierte a texto: el HTML se convierte a
+// Markdown y el resto (texto, PDF, imágenes) pasa por la misma preparación que -f
+func fetchPage(client *http.Client, rawURL string) (title, text string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", fmt.Errorf("URL no válida")
+	}
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("User-Agent", "deepcli")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("el servidor respondió %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return "", "", err
+	}
+	if strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "html") {
+		page, _ := decodeText(data)
+		return htmlTitle(page), htmlToMarkdown(page, rawURL), nil
+	}
+	text, err = prepareInput(rawURL, data)
+	return "", text, err
+}
+
+// summarizePage resume el texto de la página según la instrucción,
+// recortándolo si no cabe en la ventana del modelo
+func summarizePage(page *webPage, instruction string) error {
+	text := page.Text
+	budget := inputTokenBudget() - estimateTokens(urlsSystemPrompt+instruction) - 100
+	if tokens := estimateTokens(text); tokens > budget {
+		text = truncateContext(text, budget*4, tokens-budget)
+	}
+	title := page.URL
+	if page.Title != "" {
+		title = page.Title + " (" + page.URL + ")"
+	}
+	content, _, err := complete(newRequestBody([]Message{
+		{Role: "system", Content: urlsSystemPrompt},
+		{Role: "user", Content: "Página " + title + ":\n\n" + text},
+		{Role: "user", Content: instruction},
+	}))
+	page.Summary = strings.TrimSpace(content)
+	return err
+}
+
+// processURLs descarga y resume las URL en paralelo
+func processURLs(urls []string, instruction string) []*webPage {
+	client := &http.Client{Timeout: 30 * time.Second}
+	pages := make([]*webPage, len(urls))
+	fetchSem := make(chan struct{}, maxParallelFetches)
+	modelSem := make(chan struct{}, maxParallelChunks)
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		pages[i] = &webPage{URL: u}
+		wg.Add(1)
+		go func(page *webPage) {
+			defer wg.Done()
+			fetchSem <- struct{}{}
+			page.Title, page.Text, page.Err = fetchPage(client, page.URL)
+			<-fetchSem
+			if page.Err == nil && strings.TrimSpace(page.Text) == "" {
+				page.Err = fmt.Errorf("la página no contiene texto")
+			}
+			if page.Err != nil {
+				statusf("Advertencia: %s: %v\n", page.URL, page.Err)
+				return
+			}
+			logger.Printf("%s: %d bytes de texto (~%d tokens)\n", page.URL, len(page.Text), estimateTokens(page.Text))
+			modelSem <- struct{}{}
+			defer func() { <-modelSem }()
+			if page.Err = summarizePage(page, instruction); page.Err != nil {
+				statusf("Advertencia: %s: %v\n", page.URL, page.Err)
+			}
+		}(pages[i])
+	}
+	wg.Wait()
+	return pages
+}
+
+// digestPages combina los resúmenes en uno solo con citas a cada fuente
+func digestPages(pages []*webPage, instruction string) (string, error) {
+	var sb strings.Builder
+	for i, page := range pages {
+		if page.Err == nil {
+			fmt.Fprintf(&sb, "[%d] %s\n%s\n\n", i+1, page.URL, page.Summary)
+		}
+	}
+	content, _, err := complete(newRequestBody([]Message{
+		{Role: "system", Content: urlsDigestPrompt},
+		{Role: "user", Content: sb.String()},
+		{Role: "user", Content: "Instrucción original: " + instruction},
+	}))
+	return strings.TrimSpace(content), err
+}
+
+// urlsReport une el resumen conjunto y una sección por URL; el número de
+// cada sección es el de las citas
+func urlsReport(pages []*webPage, digest string) string {
+	var sb strings.Builder
+	if digest != "" {
+		sb.WriteString("# Resumen conjunto\n\n" + digest + "\n\n")
+	}
+	sb.WriteString("# Fuentes\n")
+	for i, page := range pages {
+		title := page.Title
+		if title == "" {
+			title = page.URL
+		}
+		fmt.Fprintf(&sb, "\n## [%d] %s\n\n<%s>\n\n", i+1, title, page.URL)
+		if page.Err != nil {
+			fmt.Fprintf(&sb, "_No se pudo resumir: %v_\n", page.Err)
+		} else {
+			sb.WriteString(page.Summary + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func runURLs(args []string) {
+	fs := flag.NewFlagSet("urls", flag.ExitOnError)
+	file := fs.String("f", "", "Archivo con las URL, una por línea (- para stdin)")
+	fs.StringVar(file, "file", "", "Archivo con las URL, una por línea (- para stdin)")
+	instruction := fs.String("i", "Resume el contenido de la página", "Instrucción para cada URL")
+	fs.StringVar(instruction, "instruction", "Resume el contenido de la página", "Instrucción para cada URL")
+	noDigest := fs.Bool("no-digest", false, "No generar el resumen conjunto")
+	addModelFlags(fs, 0.3)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s urls [-f <archivo>] [-i <instrucción>] [opciones] [url...]
+
+Descarga en paralelo las URL (del archivo, de stdin o de los argumentos),
+limpia el HTML, aplica la instrucción a cada una y genera un resumen
+conjunto con una sección por URL; las citas [n] remiten a esas secciones.
+Las URL que fallan se señalan en el informe sin detener el resto.
+
+Opciones:
+  -f, --file <archivo>          Archivo con las URL, una por línea; las
+                                líneas vacías y las que empiezan por # se
+                                ignoran (- para stdin)
+  -i, --instruction <texto>     Instrucción para cada URL (default:
+                                resumir el contenido)
+  --no-digest                   Solo las secciones por URL, sin resumen
+                                conjunto
+  -t, --temperature             Temperatura (default: 0.3)
+  -m, --maxtokens               Máximo de tokens a generar
+  -v, --verbose                 Mostrar logs detallados
+
+Ejemplo:
+  %s urls -f enlaces.txt -i "resume cada artículo en 5 puntos" > resumen.md
+`, os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+
+	urls := fs.Args()
+	if *file != "" {
+		list, err := readURLList(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al leer las URL: %v\n", err)
+			os.Exit(1)
+		}
+		urls = append(urls, list...)
+	} else if stat, _ := os.Stdin.Stat(); len(urls) == 0 && stat.Mode()&os.ModeCharDevice == 0 {
+		list, err := readURLList("-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al leer las URL: %v\n", err)
+			os.Exit(1)
+		}
+		urls = list
+	}
+	if len(urls) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	setupSubcommand()
+
+	statusf("Procesando %d URL...\n", len(urls))
+	pages := processURLs(urls, *instruction)
+	ok := 0
+	for _, page := range pages {
+		if page.Err == nil {
+			ok++
+		}
+	}
+	if ok == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no se pudo resumir ninguna URL\n")
+		os.Exit(1)
+	}
+
+	var digest string
+	if !*noDigest && ok > 1 {
+		var err error
+		if digest, err = digestPages(pages, *instruction); err != nil {
+			statusf("Advertencia: no se pudo generar el resumen conjunto: %v\n", err)
+		}
+	}
+	fmt.Print(urlsReport(pages, digest))
+}
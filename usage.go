@@ -0,0 +1,557 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	subcommands["usage"] = runUsage
+}
+
+// Porcentajes del presupuesto a partir de los que se avisa y se bloquea
+const (
+	budgetWarnRatio = 0.8
+	budgetStopRatio = 1.0
+)
+
+// projectFileName es la configuración del proyecto, que se busca desde el
+// directorio actual hacia arriba
+const projectFileName = ".deepcli.yaml"
+
+// BudgetsConfig son los presupuestos de gasto de config.yaml, en USD por
+// periodo (daily, weekly o monthly)
+type BudgetsConfig struct {
+	Period string `yaml:"period"`
+	// Por API key, identificada como la muestra "deepcli auth test"
+	// (sk-…1234)
+	Keys map[string]float64 `yaml:"keys"`
+	// Por proyecto, con el nombre de su .deepcli.yaml
+	Projects map[string]float64 `yaml:"projects"`
+}
+
+// ProjectConfig es el .deepcli.yaml de un proyecto
+type ProjectConfig struct {
+	// Nombre del proyecto en los informes (default: el del directorio)
+	Name string `yaml:"name"`
+	// Presupuesto del proyecto en USD por periodo
+	Budget       float64 `yaml:"budget"`
+	BudgetPeriod string  `yaml:"budget_period"`
+	// Conjuntos de contexto del proyecto (--context), con las rutas
+	// relativas a su directorio
+	Contexts map[string][]string `yaml:"contexts"`
+
+	dir string
+}
+
+// usageRecord es una línea del registro de uso: una respuesta de la API
+type usageRecord struct {
+	Time             time.Time `json:"time"`
+	User             string    `json:"user"`
+	Project          string    `json:"project,omitempty"`
+	Key              string    `json:"key,omitempty"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	CachedTokens     int       `json:"cached_tokens,omitempty"`
+	CostUSD          float64   `json:"cost_usd"`
+	// Estimated indica que el proveedor no informó del uso (algunos
+	// streams) y los tokens se han estimado
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// budget es un límite aplicable a esta ejecución, con lo ya gastado
+type budget struct {
+	kind   string // "clave" o "proyecto"
+	name   string
+	limit  float64
+	period string
+	source string
+	spent  float64
+	warned bool
+}
+
+var (
+	usageMu     sync.Mutex
+	usageLoaded bool
+	budgets     []*budget
+
+	projectOnce sync.Once
+	project     *ProjectConfig
+)
+
+func usageFile() string {
+	return filepath.Join(dataDir(), "usage.jsonl")
+}
+
+// currentProject busca el .deepcli.yaml más cercano; nil si no hay
+func currentProject() *ProjectConfig {
+	projectOnce.Do(func() {
+		dir, err := os.Getwd()
+		if err != nil {
+			return
+		}
+		for {
+			data, err := os.ReadFile(filepath.Join(dir, projectFileName))
+			if err == nil {
+				var p ProjectConfig
+				if err := yaml.Unmarshal(data, &p); err != nil {
+					statusf("Advertencia: %s inválido: %v\n", filepath.Join(dir, projectFileName), err)
+					return
+				}
+				p.dir = dir
+				if p.Name == "" {
+					p.Name = filepath.Base(dir)
+				}
+				logger.Printf("Proyecto %s (%s)\n", p.Name, filepath.Join(dir, projectFileName))
+				project = &p
+				return
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return
+			}
+			dir = parent
+		}
+	})
+	return project
+}
+
+func projectName() string {
+	if p := currentProject(); p != nil {
+		return p.Name
+	}
+	return ""
+}
+
+// usageKey identifica la API key en el registro sin guardarla
+func usageKey() string {
+	if apiKey == "" {
+		return ""
+	}
+	return maskKey(apiKey)
+}
+
+// periodStart devuelve el inicio del periodo (daily, weekly o monthly) que
+// contiene t, en hora local
+func periodStart(period string, t time.Time) time.Time {
+	t = t.Local()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	switch period {
+	case "daily":
+		return day
+	case "weekly":
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+}
+
+func periodName(period string) string {
+	switch period {
+	case "daily":
+		return "hoy"
+	case "weekly":
+		return "esta semana"
+	}
+	return "este mes"
+}
+
+func validPeriod(period string) bool {
+	switch period {
+	case "", "daily", "weekly", "monthly":
+		return true
+	}
+	return false
+}
+
+// readUsage lee el registro de uso completo
+func readUsage() ([]usageRecord, error) {
+	f, err := os.Open(usageFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []usageRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r usageRecord
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// configuredBudgets reúne los presupuestos que afectan a la clave y al
+// proyecto actuales
+func configuredBudgets() ([]*budget, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	period := cfg.Budgets.Period
+	if !validPeriod(period) {
+		return nil, fmt.Errorf("budgets.period debe ser daily, weekly o monthly, no %q", period)
+	}
+	var list []*budget
+	if key := usageKey(); key != "" {
+		if limit := cfg.Budgets.Keys[key]; limit > 0 {
+			list = append(list, &budget{kind: "clave", name: key, limit: limit, period: period, source: "budgets.keys en " + configFile()})
+		}
+	}
+	if p := currentProject(); p != nil {
+		switch {
+		case p.Budget > 0:
+			pp := p.BudgetPeriod
+			if pp == "" {
+				pp = period
+			}
+			if !validPeriod(pp) {
+				return nil, fmt.Errorf("%s: budget_period debe ser daily, weekly o monthly, no %q", filepath.Join(p.dir, projectFileName), pp)
+			}
+			list = append(list, &budget{kind: "proyecto", name: p.Name, limit: p.Budget, period: pp, source: filepath.Join(p.dir, projectFileName)})
+		case cfg.Budgets.Projects[p.Name] > 0:
+			list = append(list, &budget{kind: "proyecto", name: p.Name, limit: cfg.Budgets.Projects[p.Name], period: period, source: "budgets.projects en " + configFile()})
+		}
+	}
+	return list, nil
+}
+
+// matches indica si un registro cuenta para el presupuesto en su periodo
+func (b *budget) matches(r usageRecord, now time.Time) bool {
+	if r.Time.Before(periodStart(b.period, now)) {
+		return false
+	}
+	if b.kind == "clave" {
+		return r.Key == b.name
+	}
+	return r.Project == b.name
+}
+
+func (b *budget) describe() string {
+	return fmt.Sprintf("%s %s: $%.2f de $%.2f %s (%.0f%%)", b.kind, b.name, b.spent, b.limit, periodName(b.period), 100*b.spent/b.limit)
+}
+
+// loadBudgets calcula una sola vez lo gastado en cada presupuesto; después
+// recordUsage lo mantiene al día. Se llama con usageMu tomado.
+func loadBudgets() error {
+	if usageLoaded {
+		return nil
+	}
+	list, err := configuredBudgets()
+	if err != nil {
+		return err
+	}
+	var records []usageRecord
+	if len(list) > 0 {
+		if records, err = readUsage(); err != nil {
+			return fmt.Errorf("no se pudo leer el registro de uso: %v", err)
+		}
+	}
+	usageLoaded = true
+	now := time.Now()
+	for _, r := range records {
+		for _, b := range list {
+			if b.matches(r, now) {
+				b.spent += r.CostUSD
+			}
+		}
+	}
+	budgets = list
+	return nil
+}
+
+// checkBudgets bloquea la solicitud si algún presupuesto está agotado y
+// avisa (una vez) al pasar del 80%
+func checkBudgets() error {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	if err := loadBudgets(); err != nil {
+		return err
+	}
+	for _, b := range budgets {
+		if b.spent >= b.limit*budgetStopRatio {
+			return fmt.Errorf("presupuesto agotado (%s); se puede ampliar en %s", b.describe(), b.source)
+		}
+		if b.spent >= b.limit*budgetWarnRatio && !b.warned {
+			b.warned = true
+			statusf("Advertencia: se ha superado el %.0f%% del presupuesto (%s)\n", 100*budgetWarnRatio, b.describe())
+		}
+	}
+	return nil
+}
+
+// budgetsActive indica si hay presupuestos configurados
+func budgetsActive() bool {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	return loadBudgets() == nil && len(budgets) > 0
+}
+
+// recordUsage añade una respuesta al registro de uso y la suma a los
+// presupuestos. Sin uso informado se estima a partir del texto.
+func recordUsage(requestBody RequestBody, usage Usage, content string) {
+	estimated := false
+	if usage.TotalTokens == 0 && usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		for _, m := range requestBody.Messages {
+			usage.PromptTokens += estimateTokens(m.Content)
+		}
+		usage.CompletionTokens = estimateTokens(content)
+		estimated = true
+	}
+	providerName := activeProvider
+	if providerName == "" {
+		providerName = "deepseek"
+	}
+	r := usageRecord{
+		Time:             time.Now().UTC(),
+		User:             currentUser(),
+		Project:          projectName(),
+		Key:              usageKey(),
+		Provider:         providerName,
+		Model:            providerModel(requestBody.Model),
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		CachedTokens:     usage.PromptCacheHitTokens,
+		Estimated:        estimated,
+	}
+	r.CostUSD, _ = estimateCost(activeProvider, r.Model, usage)
+
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	data, _ := json.Marshal(r)
+	if err := os.MkdirAll(dataDir(), 0700); err == nil {
+		if f, err := os.OpenFile(usageFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+			f.Write(append(data, '\n'))
+			f.Close()
+		} else {
+			logger.Printf("No se pudo escribir el registro de uso: %v\n", err)
+		}
+	}
+	if !usageLoaded {
+		return
+	}
+	now := time.Now()
+	for _, b := range budgets {
+		if !b.matches(r, now) {
+			continue
+		}
+		before := b.spent
+		b.spent += r.CostUSD
+		switch {
+		case before < b.limit*budgetStopRatio && b.spent >= b.limit*budgetStopRatio:
+			statusf("Advertencia: se ha agotado el presupuesto (%s); las siguientes solicitudes se bloquearán\n", b.describe())
+			b.warned = true
+		case !b.warned && b.spent >= b.limit*budgetWarnRatio:
+			statusf("Advertencia: se ha superado el %.0f%% del presupuesto (%s)\n", 100*budgetWarnRatio, b.describe())
+			b.warned = true
+		}
+	}
+}
+
+// usageRow es una fila agregada del informe de uso
+type usageRow struct {
+	Period, Project, Key, Provider, Model string
+	Requests                              int
+	PromptTokens, CompletionTokens        int
+	CostUSD                               float64
+	Estimated                             bool
+}
+
+func runUsage(args []string) {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	csvOut := fs.Bool("csv", false, "Exportar en CSV")
+	by := fs.String("by", "month", "Agrupar por day o month")
+	since := fs.String("since", "", "Solo desde esta fecha (AAAA-MM-DD)")
+	until := fs.String("until", "", "Solo hasta esta fecha incluida (AAAA-MM-DD)")
+	projectFilter := fs.String("project", "", "Solo este proyecto")
+	fs.BoolVar(&verbose, "v", false, "Mostrar mensajes detallados de ejecución")
+	fs.BoolVar(&verbose, "verbose", false, "Mostrar mensajes detallados de ejecución")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Uso: %s usage report [opciones]
+
+Resume el gasto registrado de cada respuesta de la API por periodo,
+proyecto, API key y modelo, y el estado de los presupuestos que afectan
+al directorio actual. El registro está en %s.
+
+Los presupuestos (USD por periodo) se definen por API key y por proyecto
+en config.yaml, o en el .deepcli.yaml del proyecto; se avisa al pasar del
+80%% y se bloquea al llegar al 100%%:
+  budgets:
+    period: monthly          # daily, weekly o monthly
+    keys: {"sk-…1234": 50}   # la clave como la muestra "deepcli auth test"
+    projects: {acme: 20}
+
+Opciones:
+  --csv                 Exportar en CSV (para contabilidad)
+  --by day|month        Agrupar por día o por mes (default: month)
+  --since <fecha>       Solo desde esta fecha (AAAA-MM-DD)
+  --until <fecha>       Solo hasta esta fecha incluida (AAAA-MM-DD)
+  --project <nombre>    Solo este proyecto
+  -v, --verbose         Mostrar logs detallados
+
+Ejemplos:
+  %s usage report
+  %s usage report --csv --since 2026-01-01 > gasto-2026.csv
+`, os.Args[0], usageFile(), os.Args[0], os.Args[0])
+	}
+	if len(args) == 0 || args[0] != "report" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	fs.Parse(args[1:])
+	if !verbose {
+		logger.SetOutput(io.Discard)
+	}
+
+	layout := map[string]string{"day": "2006-01-02", "month": "2006-01"}[*by]
+	if layout == "" {
+		fmt.Fprintf(os.Stderr, "Error: --by debe ser day o month\n")
+		os.Exit(1)
+	}
+	var from, to time.Time
+	for _, d := range []struct {
+		value string
+		dst   *time.Time
+		name  string
+	}{{*since, &from, "--since"}, {*until, &to, "--until"}} {
+		if d.value == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", d.value, time.Local)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s espera una fecha AAAA-MM-DD, no %q\n", d.name, d.value)
+			os.Exit(1)
+		}
+		*d.dst = t
+	}
+	if !to.IsZero() {
+		to = to.AddDate(0, 0, 1)
+	}
+
+	records, err := readUsage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	groups := map[string]*usageRow{}
+	for _, r := range records {
+		if (!from.IsZero() && r.Time.Before(from)) || (!to.IsZero() && !r.Time.Before(to)) {
+			continue
+		}
+		if *projectFilter != "" && r.Project != *projectFilter {
+			continue
+		}
+		row := usageRow{Period: r.Time.Local().Format(layout), Project: r.Project, Key: r.Key, Provider: r.Provider, Model: r.Model}
+		id := strings.Join([]string{row.Period, row.Project, row.Key, row.Provider, row.Model}, "\x00")
+		g, ok := groups[id]
+		if !ok {
+			g = &row
+			groups[id] = g
+		}
+		g.Requests++
+		g.PromptTokens += r.PromptTokens
+		g.CompletionTokens += r.CompletionTokens
+		g.CostUSD += r.CostUSD
+		g.Estimated = g.Estimated || r.Estimated
+	}
+	rows := make([]*usageRow, 0, len(groups))
+	for _, g := range groups {
+		rows = append(rows, g)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Provider+a.Model < b.Provider+b.Model
+	})
+
+	if *csvOut {
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"period", "project", "key", "provider", "model", "requests", "prompt_tokens", "completion_tokens", "cost_usd", "estimated"})
+		for _, r := range rows {
+			w.Write([]string{r.Period, r.Project, r.Key, r.Provider, r.Model, strconv.Itoa(r.Requests),
+				strconv.Itoa(r.PromptTokens), strconv.Itoa(r.CompletionTokens),
+				strconv.FormatFloat(r.CostUSD, 'f', 6, 64), strconv.FormatBool(r.Estimated)})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(rows) == 0 {
+		statusf("No hay uso registrado\n")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PERIODO\tPROYECTO\tCLAVE\tMODELO\tSOLICITUDES\tENTRADA\tSALIDA\tCOSTE")
+		total := 0.0
+		for _, r := range rows {
+			cost := fmt.Sprintf("$%.4f", r.CostUSD)
+			if r.Estimated {
+				cost += " (estimado)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s/%s\t%d\t%d\t%d\t%s\n", r.Period, orDefault(r.Project, "-"), orDefault(r.Key, "-"),
+				r.Provider, r.Model, r.Requests, r.PromptTokens, r.CompletionTokens, cost)
+			total += r.CostUSD
+		}
+		w.Flush()
+		fmt.Printf("\nTotal: $%.4f\n", total)
+	}
+
+	// Los presupuestos se calculan con la API key configurada, sin validarla
+	if err := loadEnv(); err != nil {
+		logger.Printf("Advertencia: %v\n", err)
+	}
+	if v := os.Getenv(apiKeyEnvName()); v != "" {
+		apiKey = v
+	}
+	usageMu.Lock()
+	err = loadBudgets()
+	usageMu.Unlock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(budgets) > 0 {
+		fmt.Println("\nPresupuestos:")
+		for _, b := range budgets {
+			state := ""
+			switch {
+			case b.spent >= b.limit*budgetStopRatio:
+				state = "  AGOTADO"
+			case b.spent >= b.limit*budgetWarnRatio:
+				state = "  aviso"
+			}
+			fmt.Printf("  %s%s\n", b.describe(), state)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Máximo de salida del comando de validación que se devuelve al modelo (se
+// conserva el final, donde suelen estar los errores)
+const maxValidateOutput = 16 * 1024
+
+var (
+	// validateCmd (--validate-cmd) comprueba la respuesta antes de darla por
+	// buena; si falla, su salida se envía al modelo para que la corrija
+	validateCmd     string
+	validateRetries int
+)
+
+// runValidation ejecuta el comando de validación con la salida en stdin y
+// en el archivo DEEPCLI_OUTPUT; devuelve la salida combinada y el código de
+// salida
+func runValidation(output, file string) (string, int, error) {
+	cmd := shellCommand(validateCmd)
+	cmd.Stdin = strings.NewReader(output)
+	cmd.Env = append(os.Environ(), "DEEPCLI_OUTPUT="+file)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("no se pudo ejecutar --validate-cmd: %v", err)
+	}
+	return out.String(), 0, nil
+}
+
+// validationFeedback es el mensaje que describe al modelo el fallo de la
+// validación
+func validationFeedback(exitCode int, output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxValidateOutput {
+		output = "...\n" + output[len(output)-maxValidateOutput:]
+	}
+	if output == "" {
+		output = "(sin salida)"
+	}
+	return fmt.Sprintf("La respuesta no pasa la validación: el comando `%s` terminó con código %d y esta salida:\n```\n%s\n```\n"+
+		"Corrige el problema y devuelve la respuesta completa corregida, en el mismo formato y sin explicaciones.", validateCmd, exitCode, output)
+}
+
+// validateResponse comprueba la respuesta con --validate-cmd y, mientras
+// falle, pide al modelo una corrección hasta validateRetries veces. El
+// comando recibe la salida final (la que se escribiría con -o) en stdin y en
+// la ruta de DEEPCLI_OUTPUT: el propio archivo de -o, para que comandos como
+// "go build ./..." la encuentren en su sitio, o uno temporal sin -o.
+func validateResponse(requestBody RequestBody, response *ResponseBody, path string) error {
+	file := path
+	if file == "" || isObjectStorageURL(file) {
+		tmp, err := os.CreateTemp("", "deepcli-validar-*"+filepath.Ext(path))
+		if err != nil {
+			return err
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		file = tmp.Name()
+	}
+
+	messages := append([]Message(nil), requestBody.Messages...)
+	for attempt := 0; ; attempt++ {
+		output, err := formatOutput(*response)
+		if err != nil {
+			return err
+		}
+		if file == path {
+			// La copia de --backup se hace con la primera versión; las
+			// siguientes y la final sustituyen a la respuesta rechazada
+			err = writeOutputFile(file, []byte(output), false, backupOutput)
+			backupOutput = false
+		} else {
+			err = os.WriteFile(file, []byte(output), 0600)
+		}
+		if err != nil {
+			return fmt.Errorf("no se pudo escribir la respuesta para validarla: %v", err)
+		}
+
+		logger.Printf("Validando la respuesta con: %s\n", validateCmd)
+		out, code, err := runValidation(output, file)
+		if err != nil {
+			return err
+		}
+		corrections := fmt.Sprintf("%d correcciones", attempt)
+		if attempt == 1 {
+			corrections = "1 corrección"
+		}
+		if code == 0 {
+			if attempt > 0 {
+				statusf("La respuesta pasa la validación tras %s\n", corrections)
+			}
+			return nil
+		}
+		if verbose {
+			logger.Printf("Salida de la validación (código %d):\n%s\n", code, out)
+		}
+		if attempt >= validateRetries {
+			return fmt.Errorf("la respuesta no pasa la validación tras %s (código %d):\n%s", corrections, code, strings.TrimSpace(out))
+		}
+		statusf("La respuesta no pasa la validación (código %d); pidiendo una corrección (%d/%d)...\n", code, attempt+1, validateRetries)
+
+		messages = append(messages,
+			Message{Role: "assistant", Content: response.Choices[0].Message.Content},
+			Message{Role: "user", Content: validationFeedback(code, out)})
+		body := requestBody
+		body.Messages = messages
+		data, _, err := sendWithFailover(false, body)
+		if err != nil {
+			return err
+		}
+		var next ResponseBody
+		if err := json.Unmarshal(data, &next); err != nil {
+			return fmt.Errorf("no se pudo parsear la respuesta JSON: %v", err)
+		}
+		if next.Error.Message != "" {
+			return fmt.Errorf("error de la API: %s", next.Error.Message)
+		}
+		if len(next.Choices) == 0 {
+			return fmt.Errorf("no se recibió ninguna respuesta válida de la API")
+		}
+		if anon != nil {
+			for i := range next.Choices {
+				next.Choices[i].Message.Content = anon.Restore(next.Choices[i].Message.Content)
+			}
+		}
+		next.Usage.add(response.Usage)
+		*response = next
+	}
+}